@@ -6,6 +6,7 @@ package config
 import (
 	"fmt"
 	"log"
+	"net/url"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -21,6 +22,10 @@ type Config struct {
 	Casbin      CasbinConfig      // Casbin权限控制配置
 	Log         LogConfig         // 日志记录配置
 	RateLimiter RateLimiterConfig // 速率限制配置
+	Auth        AuthConfig        // 账户安全相关配置
+	Mail        MailConfig        // 邮件发送配置
+	Password    PasswordConfig    // 密码哈希和密码策略配置
+	OIDC        OIDCConfig        // 第三方登录（OpenID Connect）配置
 }
 
 // RateLimiterConfig 存储速率限制相关的配置。
@@ -32,6 +37,99 @@ type RateLimiterConfig struct {
 // AppConfig 存储应用级别的配置。
 type AppConfig struct {
 	DefaultRole string // 新用户注册时的默认角色
+	FrontendURL string // 前端地址，用于生成邮件中的链接
+
+	DeletedUserRetention     int // 已删除的用户在被彻底清除之前保留的天数，0表示一直保留
+	DeletedUserPurgeInterval int // 检查需要清除的已删除用户的间隔（以秒为单位）
+}
+
+// AuthConfig 存储账户安全相关的配置。
+type AuthConfig struct {
+	PasswordResetExpiration int // 密码重置令牌的有效期（以秒为单位）
+
+	// EmailVerification 决定未验证邮箱的账户受到的限制：
+	//   - "off"：注册时发送验证邮件，但不做任何限制
+	//   - "block"：邮箱验证之前禁止登录
+	//   - "restrict"：邮箱验证之前签发的令牌只带有 UnverifiedRole 角色
+	EmailVerification           string
+	EmailVerificationExpiration int    // 邮箱验证链接的有效期（以秒为单位）
+	UnverifiedRole              string // restrict 模式下未验证账户使用的Casbin角色
+
+	MFAIssuer              string // 显示在身份验证器应用中的发行方名称
+	MFAChallengeExpiration int    // 两步验证挑战令牌的有效期（以秒为单位）
+	MFAMaxAttempts         int    // 每个挑战令牌最多可以尝试的验证码次数，用完之后需要重新输入密码
+
+	// 按用户名统计连续登录失败。失败后下一次尝试需要等待 LockoutBackoffBase 秒，并随失败次数指数增长；
+	// 连续失败 LockoutThreshold 次后账户被锁定 LockoutDuration 秒。LockoutThreshold 为0时关闭该功能。
+	LockoutThreshold   int
+	LockoutDuration    int
+	LockoutBackoffBase int
+
+	APIKeyMaxPerUser  int // 每个用户最多可以创建的API密钥数量，0表示不限制
+	APIKeyMaxLifetime int // API密钥的最长有效期（以天为单位），0表示允许永不过期的密钥
+}
+
+// 数据库驱动，对应 DatabaseConfig.Driver 的取值。
+const (
+	DatabaseDriverPostgres = "postgres"
+	DatabaseDriverMySQL    = "mysql"
+	DatabaseDriverSQLite   = "sqlite"
+)
+
+// 邮箱验证模式，对应 AuthConfig.EmailVerification 的取值。
+const (
+	EmailVerificationOff      = "off"
+	EmailVerificationBlock    = "block"
+	EmailVerificationRestrict = "restrict"
+)
+
+// PasswordConfig 存储密码哈希和密码策略相关的配置。
+// 修改算法或参数后，已有用户的哈希会在下次成功登录时自动升级。
+type PasswordConfig struct {
+	Algorithm         string // 新密码使用的哈希算法："argon2id" 或 "bcrypt"
+	BcryptCost        int    // bcrypt 的计算成本
+	Argon2Memory      uint32 // Argon2id 使用的内存（以KiB为单位）
+	Argon2Iterations  uint32 // Argon2id 的迭代次数
+	Argon2Parallelism uint8  // Argon2id 的并行度
+
+	// 密码策略，在注册、修改密码和重置密码时检查。数值为0或开关为false时不检查对应的规则。
+	MinLength        int    // 最小长度（按字符计算）
+	MaxLength        int    // 最大长度（按字符计算）
+	RequireUppercase bool   // 必须包含大写字母
+	RequireLowercase bool   // 必须包含小写字母
+	RequireDigit     bool   // 必须包含数字
+	RequireSymbol    bool   // 必须包含字母和数字以外的字符
+	DisallowUserInfo bool   // 禁止包含用户名或邮箱
+	History          int    // 禁止重复使用最近的 History 个密码（包括当前密码）
+	BreachedListFile string // 泄露密码的SHA-1哈希列表文件，为空时不检查
+}
+
+// OIDCConfig 存储第三方登录（OpenID Connect）相关的配置。
+type OIDCConfig struct {
+	Providers       []OIDCProviderConfig // 可用的身份提供方，为空时关闭第三方登录
+	StateExpiration int                  // 从发起登录到身份提供方回调的最长时间（以秒为单位）
+}
+
+// OIDCProviderConfig 描述一个OpenID Connect身份提供方。
+// 端点和签名公钥通过 {Issuer}/.well-known/openid-configuration 自动发现。
+type OIDCProviderConfig struct {
+	Name         string   `mapstructure:"name"`          // 提供方名称，出现在URL中，例如 /auth/oidc/{name}/login
+	Issuer       string   `mapstructure:"issuer"`        // 发行方URL，必须与ID令牌的iss声明完全一致
+	ClientID     string   `mapstructure:"client_id"`     // 在身份提供方注册的客户端ID
+	ClientSecret string   `mapstructure:"client_secret"` // 客户端密钥，公共客户端可以为空（仅依赖PKCE）
+	RedirectURL  string   `mapstructure:"redirect_url"`  // 回调地址，需要与在身份提供方注册的一致
+	Scopes       []string `mapstructure:"scopes"`        // 申请的scope，为空时使用 openid email profile
+}
+
+// MailConfig 存储邮件发送相关的配置。
+type MailConfig struct {
+	Driver       string // 发送方式："log"（写入日志，可选同时写入文件）或 "smtp"
+	From         string // 发件人地址
+	File         string // log 驱动下额外写入邮件内容的文件路径，为空时只写日志
+	SMTPHost     string // SMTP服务器地址
+	SMTPPort     int    // SMTP服务器端口
+	SMTPUsername string // SMTP认证用户名
+	SMTPPassword string // SMTP认证密码
 }
 
 // ServerConfig 存储服务器相关的配置。
@@ -42,28 +140,81 @@ type ServerConfig struct {
 
 // DatabaseConfig 存储数据库连接信息。
 type DatabaseConfig struct {
+	Driver          string // 数据库驱动："postgres"、"mysql" 或 "sqlite"
 	Host            string // 数据库主机地址
 	Port            int    // 数据库端口
 	User            string // 数据库用户名
 	Password        string // 数据库密码
 	DBName          string // 数据库名称
-	SSLMode         string // SSL模式（例如 "disable", "require"）
+	SSLMode         string // PostgreSQL 的SSL模式（例如 "disable", "require"）
+	Path            string // SQLite 数据库文件的路径
+	TimeZone        string // 连接使用的时区（IANA名称，例如 "Asia/Shanghai", "UTC"），为空时使用数据库的默认值
 	MaxIdleConns    int    // 连接池中的最大空闲连接数
 	MaxOpenConns    int    // 数据库的最大打开连接数
 	ConnMaxLifetime int    // 连接可被重用的最大时间（以分钟为单位）
+	MigrateOnStart  bool   // 启动时是否自动执行尚未执行的数据库迁移
+
+	// Replicas 是只读副本的DSN，格式与 Driver 对应。配置后用户和角色的查询会随机发往其中一个副本，
+	// 写入和事务仍在主库上执行。
+	Replicas []string
 }
 
 // JWTConfig 存储JWT（JSON Web Token）相关的配置。
 type JWTConfig struct {
-	Secret     string // 用于签名JWT的密钥
-	Expiration int    // JWT的过期时间（以秒为单位）
+	Algorithm         string // 签名算法："HS256"（默认）、"RS256" 或 "EdDSA"
+	Secret            string // HS256 使用的对称密钥
+	PrivateKeyFile    string // RS256/EdDSA 使用的签名私钥（PEM格式）文件路径
+	KeyID             string // 当前签名密钥的kid，为空时根据公钥自动计算
+	Expiration        int    // 访问令牌（JWT）的过期时间（以秒为单位）
+	RefreshExpiration int    // 刷新令牌的过期时间（以秒为单位）
+
+	// VerificationKeys 是密钥轮换期间仍然接受的其他公钥。
+	// 当前签名密钥的公钥总是会被接受，无需在此重复配置。
+	VerificationKeys []JWTKeyConfig
+
+	RevocationStore         string // 访问令牌吊销列表的存储方式："database" 或 "memory"
+	RevocationPurgeInterval int    // 清理过期吊销记录和失效刷新令牌的间隔（以秒为单位）
+}
+
+// JWTKeyConfig 描述一个用于验证JWT签名的公钥。
+type JWTKeyConfig struct {
+	KeyID         string `mapstructure:"key_id"`          // 公钥对应的kid，为空时根据公钥自动计算
+	PublicKeyFile string `mapstructure:"public_key_file"` // 公钥（PEM格式）文件路径
 }
 
 // CasbinConfig 存储Casbin相关的配置。
 type CasbinConfig struct {
-	Model string // Casbin模型的路径
+	Model          string // Casbin模型定义，未配置时使用 DefaultCasbinModel
+	Watcher        string // 多实例之间同步策略修改的方式："none"、"postgres"（LISTEN/NOTIFY）或 "polling"
+	WatcherChannel string // postgres 方式使用的通知频道
+	PollInterval   int    // polling 方式检查策略修订号的间隔（以秒为单位）
 }
 
+// DefaultCasbinModel 是默认的Casbin模型：支持按域（"global" 或组织对应的 "org:<ID>"）的角色继承（g），
+// 策略中的域可以使用 "*"、"org:*" 这样的模式，路径可以使用路由模板（如 /users/:id，按 keyMatch2 匹配），
+// "*" 匹配任意路径或方法。
+// r2/p2/e2/m2 用于服务中基于属性的授权：请求者和资源是结构体，p2 与 p 一样按域生效（请求者的 Domain 字段），
+// 最后一个字段是规则表达式。
+const DefaultCasbinModel = `[request_definition]
+r = sub, dom, obj, act
+r2 = sub, obj, act
+
+[policy_definition]
+p = sub, dom, obj, act
+p2 = sub, dom, obj, act, rule
+
+[role_definition]
+g = _, _, _
+
+[policy_effect]
+e = some(where (p.eft == allow))
+e2 = some(where (p.eft == allow))
+
+[matchers]
+m = g(r.sub, p.sub, r.dom) && keyMatch(r.dom, p.dom) && (p.obj == "*" || keyMatch2(r.obj, p.obj)) && (p.act == "*" || r.act == p.act)
+m2 = g(r2.sub.Role, p2.sub, r2.sub.Domain) && keyMatch(r2.sub.Domain, p2.dom) && r2.obj.Type == p2.obj && r2.act == p2.act && eval(p2.rule)
+`
+
 // LogConfig 存储日志记录相关的配置。
 type LogConfig struct {
 	Level      string // 日志级别 (e.g., "debug", "info", "warn", "error")
@@ -92,24 +243,35 @@ func LoadConfig() *Config {
 
 	// 应用配置
 	viper.SetDefault("app.default_role", "user")
+	viper.SetDefault("app.frontend_url", "http://localhost:3000")
+	viper.SetDefault("app.deleted_user_retention", 0)         // 默认不自动清除已删除的用户
+	viper.SetDefault("app.deleted_user_purge_interval", 3600) // 默认每小时检查一次
 
 	// 服务器配置
 	viper.SetDefault("server.port", 8080)
 	viper.SetDefault("server.allowed_origins", []string{"http://localhost:3000"})
 
 	// 数据库配置
+	viper.SetDefault("database.driver", DatabaseDriverPostgres)
 	viper.SetDefault("database.host", "localhost")
 	viper.SetDefault("database.port", 5432)
 	viper.SetDefault("database.user", "postgres")
 	viper.SetDefault("database.password", "postgres")
 	viper.SetDefault("database.dbname", "goweb")
 	viper.SetDefault("database.sslmode", "disable")
+	viper.SetDefault("database.path", "goweb.db")
+	viper.SetDefault("database.timezone", "Asia/Shanghai")
 	viper.SetDefault("database.max_idle_conns", 10)
 	viper.SetDefault("database.max_open_conns", 100)
 	viper.SetDefault("database.conn_max_lifetime", 60)
+	viper.SetDefault("database.migrate_on_start", true)
 
 	// JWT配置
-	viper.SetDefault("jwt.expiration", 86400) // 默认24小时
+	viper.SetDefault("jwt.algorithm", "HS256")
+	viper.SetDefault("jwt.expiration", 900)            // 访问令牌默认15分钟
+	viper.SetDefault("jwt.refresh_expiration", 604800) // 刷新令牌默认7天
+	viper.SetDefault("jwt.revocation_store", "database")
+	viper.SetDefault("jwt.revocation_purge_interval", 600) // 默认每10分钟清理一次
 
 	// 日志配置
 	viper.SetDefault("log.level", "debug")
@@ -123,38 +285,109 @@ func LoadConfig() *Config {
 	viper.SetDefault("ratelimiter.period", "1m")
 	viper.SetDefault("ratelimiter.limit", 10)
 
+	// 账户安全配置
+	viper.SetDefault("auth.password_reset_expiration", 3600) // 密码重置链接默认1小时内有效
+	viper.SetDefault("auth.email_verification", "off")
+	viper.SetDefault("auth.email_verification_expiration", 86400) // 邮箱验证链接默认24小时内有效
+	viper.SetDefault("auth.unverified_role", "unverified")
+	viper.SetDefault("auth.mfa_issuer", "go-web")
+	viper.SetDefault("auth.mfa_challenge_expiration", 300) // 输入两步验证码的时间默认为5分钟
+	viper.SetDefault("auth.mfa_max_attempts", 5)
+	viper.SetDefault("auth.lockout_threshold", 5)
+	viper.SetDefault("auth.lockout_duration", 900) // 默认锁定15分钟
+	viper.SetDefault("auth.lockout_backoff_base", 1)
+	viper.SetDefault("auth.api_key_max_per_user", 20)
+	viper.SetDefault("auth.api_key_max_lifetime", 365) // API密钥默认最长有效1年
+
+	// 邮件配置
+	viper.SetDefault("mail.driver", "log")
+	viper.SetDefault("mail.from", "no-reply@localhost")
+	viper.SetDefault("mail.smtp_port", 587)
+
+	// 密码哈希配置
+	viper.SetDefault("password.algorithm", "argon2id")
+	viper.SetDefault("password.bcrypt_cost", 12)
+	viper.SetDefault("password.argon2_memory", 19456) // 19 MiB
+	viper.SetDefault("password.argon2_iterations", 2)
+	viper.SetDefault("password.argon2_parallelism", 1)
+	viper.SetDefault("password.min_length", 8)
+	viper.SetDefault("password.max_length", 128)
+	viper.SetDefault("password.disallow_user_info", true)
+	viper.SetDefault("password.history", 5)
+
+	// 第三方登录配置
+	viper.SetDefault("oidc.state_expiration", 600) // 默认10分钟内完成第三方登录
+
+	// Casbin配置
+	viper.SetDefault("casbin.model", DefaultCasbinModel)
+	viper.SetDefault("casbin.watcher", "none")
+	viper.SetDefault("casbin.watcher_channel", "casbin_policy")
+	viper.SetDefault("casbin.poll_interval", 10)
+
 	// 尝试读取配置文件
 	if err := viper.ReadInConfig(); err != nil {
 		// 如果读取失败，记录一条警告信息，程序将使用默认配置继续运行
 		log.Printf("Warning: unable to read config file: %v, using defaults", err)
 	}
 
+	// 轮换期间的验证公钥是一个对象列表，需要单独反序列化
+	var verificationKeys []JWTKeyConfig
+	if err := viper.UnmarshalKey("jwt.verification_keys", &verificationKeys); err != nil {
+		log.Printf("Warning: unable to parse jwt.verification_keys: %v", err)
+	}
+
+	// 身份提供方同样是一个对象列表
+	var oidcProviders []OIDCProviderConfig
+	if err := viper.UnmarshalKey("oidc.providers", &oidcProviders); err != nil {
+		log.Printf("Warning: unable to parse oidc.providers: %v", err)
+	}
+
 	// 将读取到的配置信息反序列化到Config结构体中
 	config := &Config{
 		App: AppConfig{
 			DefaultRole: viper.GetString("app.default_role"),
+			FrontendURL: viper.GetString("app.frontend_url"),
+
+			DeletedUserRetention:     viper.GetInt("app.deleted_user_retention"),
+			DeletedUserPurgeInterval: viper.GetInt("app.deleted_user_purge_interval"),
 		},
 		Server: ServerConfig{
 			Port:           viper.GetInt("server.port"),
 			AllowedOrigins: viper.GetStringSlice("server.allowed_origins"),
 		},
 		Database: DatabaseConfig{
+			Driver:          viper.GetString("database.driver"),
 			Host:            viper.GetString("database.host"),
 			Port:            viper.GetInt("database.port"),
 			User:            viper.GetString("database.user"),
 			Password:        viper.GetString("database.password"),
 			DBName:          viper.GetString("database.dbname"),
 			SSLMode:         viper.GetString("database.sslmode"),
+			Path:            viper.GetString("database.path"),
+			TimeZone:        viper.GetString("database.timezone"),
 			MaxIdleConns:    viper.GetInt("database.max_idle_conns"),
 			MaxOpenConns:    viper.GetInt("database.max_open_conns"),
 			ConnMaxLifetime: viper.GetInt("database.conn_max_lifetime"),
+			MigrateOnStart:  viper.GetBool("database.migrate_on_start"),
+			Replicas:        viper.GetStringSlice("database.replicas"),
 		},
 		JWT: JWTConfig{
-			Secret:     viper.GetString("jwt.secret"),
-			Expiration: viper.GetInt("jwt.expiration"),
+			Algorithm:         viper.GetString("jwt.algorithm"),
+			Secret:            viper.GetString("jwt.secret"),
+			PrivateKeyFile:    viper.GetString("jwt.private_key_file"),
+			KeyID:             viper.GetString("jwt.key_id"),
+			Expiration:        viper.GetInt("jwt.expiration"),
+			RefreshExpiration: viper.GetInt("jwt.refresh_expiration"),
+			VerificationKeys:  verificationKeys,
+
+			RevocationStore:         viper.GetString("jwt.revocation_store"),
+			RevocationPurgeInterval: viper.GetInt("jwt.revocation_purge_interval"),
 		},
 		Casbin: CasbinConfig{
-			Model: viper.GetString("casbin.model"),
+			Model:          viper.GetString("casbin.model"),
+			Watcher:        viper.GetString("casbin.watcher"),
+			WatcherChannel: viper.GetString("casbin.watcher_channel"),
+			PollInterval:   viper.GetInt("casbin.poll_interval"),
 		},
 		Log: LogConfig{
 			Level:      viper.GetString("log.level"),
@@ -168,14 +401,102 @@ func LoadConfig() *Config {
 			Period: viper.GetString("ratelimiter.period"),
 			Limit:  viper.GetInt64("ratelimiter.limit"),
 		},
+		Auth: AuthConfig{
+			PasswordResetExpiration: viper.GetInt("auth.password_reset_expiration"),
+
+			EmailVerification:           viper.GetString("auth.email_verification"),
+			EmailVerificationExpiration: viper.GetInt("auth.email_verification_expiration"),
+			UnverifiedRole:              viper.GetString("auth.unverified_role"),
+
+			MFAIssuer:              viper.GetString("auth.mfa_issuer"),
+			MFAChallengeExpiration: viper.GetInt("auth.mfa_challenge_expiration"),
+			MFAMaxAttempts:         viper.GetInt("auth.mfa_max_attempts"),
+
+			LockoutThreshold:   viper.GetInt("auth.lockout_threshold"),
+			LockoutDuration:    viper.GetInt("auth.lockout_duration"),
+			LockoutBackoffBase: viper.GetInt("auth.lockout_backoff_base"),
+
+			APIKeyMaxPerUser:  viper.GetInt("auth.api_key_max_per_user"),
+			APIKeyMaxLifetime: viper.GetInt("auth.api_key_max_lifetime"),
+		},
+		Mail: MailConfig{
+			Driver:       viper.GetString("mail.driver"),
+			From:         viper.GetString("mail.from"),
+			File:         viper.GetString("mail.file"),
+			SMTPHost:     viper.GetString("mail.smtp_host"),
+			SMTPPort:     viper.GetInt("mail.smtp_port"),
+			SMTPUsername: viper.GetString("mail.smtp_username"),
+			SMTPPassword: viper.GetString("mail.smtp_password"),
+		},
+		Password: PasswordConfig{
+			Algorithm:         viper.GetString("password.algorithm"),
+			BcryptCost:        viper.GetInt("password.bcrypt_cost"),
+			Argon2Memory:      viper.GetUint32("password.argon2_memory"),
+			Argon2Iterations:  viper.GetUint32("password.argon2_iterations"),
+			Argon2Parallelism: uint8(viper.GetUint("password.argon2_parallelism")),
+
+			MinLength:        viper.GetInt("password.min_length"),
+			MaxLength:        viper.GetInt("password.max_length"),
+			RequireUppercase: viper.GetBool("password.require_uppercase"),
+			RequireLowercase: viper.GetBool("password.require_lowercase"),
+			RequireDigit:     viper.GetBool("password.require_digit"),
+			RequireSymbol:    viper.GetBool("password.require_symbol"),
+			DisallowUserInfo: viper.GetBool("password.disallow_user_info"),
+			History:          viper.GetInt("password.history"),
+			BreachedListFile: viper.GetString("password.breached_list_file"),
+		},
+		OIDC: OIDCConfig{
+			Providers:       oidcProviders,
+			StateExpiration: viper.GetInt("oidc.state_expiration"),
+		},
 	}
 
 	return config
 }
 
 // GetDSN 根据数据库配置生成数据源名称（Data Source Name）。
-// DSN是用于连接数据库的字符串。
+// DSN是用于连接数据库的字符串，格式取决于 database.driver，未配置驱动时使用PostgreSQL。
 func (c *Config) GetDSN() string {
-	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=Asia/Shanghai",
-		c.Database.Host, c.Database.User, c.Database.Password, c.Database.DBName, c.Database.Port, c.Database.SSLMode)
+	switch c.Database.Driver {
+	case DatabaseDriverMySQL:
+		return c.Database.MySQLDSN()
+	case DatabaseDriverSQLite:
+		return c.Database.SQLiteDSN()
+	default:
+		return c.Database.PostgresDSN()
+	}
+}
+
+// PostgresDSN 生成 PostgreSQL（pgx）使用的 key=value 格式的DSN。
+func (d DatabaseConfig) PostgresDSN() string {
+	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s",
+		d.Host, d.User, d.Password, d.DBName, d.Port, d.SSLMode)
+	if d.TimeZone != "" {
+		dsn += " TimeZone=" + d.TimeZone
+	}
+	return dsn
+}
+
+// MySQLDSN 生成 go-sql-driver/mysql 使用的DSN。
+// 迁移文件中一个文件包含多条语句，因此需要开启 multiStatements；时间列按 TimeZone 解析为 time.Time。
+func (d DatabaseConfig) MySQLDSN() string {
+	params := url.Values{}
+	params.Set("charset", "utf8mb4")
+	params.Set("parseTime", "true")
+	params.Set("multiStatements", "true")
+	if d.TimeZone != "" {
+		params.Set("loc", d.TimeZone)
+	}
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?%s", d.User, d.Password, d.Host, d.Port, d.DBName, params.Encode())
+}
+
+// SQLiteDSN 生成 SQLite 使用的DSN：开启外键约束（与其他数据库的行为一致），并在数据库被锁定时等待而不是立即失败。
+func (d DatabaseConfig) SQLiteDSN() string {
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_busy_timeout", "5000")
+	if d.TimeZone != "" {
+		params.Set("_loc", d.TimeZone)
+	}
+	return "file:" + d.Path + "?" + params.Encode()
 }
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDSN_PerDriver(t *testing.T) {
+	cfg := &Config{Database: DatabaseConfig{
+		Host: "db.internal", Port: 5432, User: "app", Password: "s3cret", DBName: "goweb", SSLMode: "require",
+		Path: "/var/lib/goweb/goweb.db", TimeZone: "Europe/Berlin",
+	}}
+
+	// 未配置驱动时使用PostgreSQL
+	assert.Equal(t, "host=db.internal user=app password=s3cret dbname=goweb port=5432 sslmode=require TimeZone=Europe/Berlin", cfg.GetDSN())
+
+	cfg.Database.Driver = DatabaseDriverMySQL
+	cfg.Database.Port = 3306
+	assert.Equal(t, "app:s3cret@tcp(db.internal:3306)/goweb?charset=utf8mb4&loc=Europe%2FBerlin&multiStatements=true&parseTime=true", cfg.GetDSN())
+
+	cfg.Database.Driver = DatabaseDriverSQLite
+	assert.Equal(t, "file:/var/lib/goweb/goweb.db?_busy_timeout=5000&_foreign_keys=on&_loc=Europe%2FBerlin", cfg.GetDSN())
+}
+
+func TestGetDSN_WithoutTimeZone(t *testing.T) {
+	cfg := &Config{Database: DatabaseConfig{Driver: DatabaseDriverPostgres, Host: "localhost", Port: 5432, User: "postgres", Password: "postgres", DBName: "goweb", SSLMode: "disable"}}
+	assert.Equal(t, "host=localhost user=postgres password=postgres dbname=goweb port=5432 sslmode=disable", cfg.GetDSN())
+
+	cfg.Database.Driver = DatabaseDriverMySQL
+	assert.NotContains(t, cfg.GetDSN(), "loc=")
+}
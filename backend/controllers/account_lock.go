@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"go-web/dtos"
+	"go-web/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AccountLockController struct {
+	LockoutService services.LockoutServiceInterface
+}
+
+func NewAccountLockController(lockoutService services.LockoutServiceInterface) *AccountLockController {
+	return &AccountLockController{LockoutService: lockoutService}
+}
+
+// GetLocks 获取当前所有因登录失败过多而被锁定的用户名
+func (lc *AccountLockController) GetLocks(c *gin.Context) {
+	failures, err := lc.LockoutService.ListLocked()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	locks := make([]dtos.AccountLockResponse, 0, len(failures))
+	for _, failure := range failures {
+		locks = append(locks, dtos.AccountLockResponse{
+			Username:     failure.Username,
+			FailedCount:  failure.FailedCount,
+			LastFailedAt: failure.LastFailedAt,
+			LockedUntil:  *failure.LockedUntil,
+		})
+	}
+
+	c.JSON(http.StatusOK, locks)
+}
+
+// DeleteLock 解除用户名的锁定并清除失败计数
+func (lc *AccountLockController) DeleteLock(c *gin.Context) {
+	if err := lc.LockoutService.Unlock(c.Param("username"), c.GetUint("user_id")); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account unlocked successfully"})
+}
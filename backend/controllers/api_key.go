@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"go-web/dtos"
+	"go-web/models"
+	"go-web/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyController struct {
+	APIKeyService services.APIKeyServiceInterface
+}
+
+func NewAPIKeyController(apiKeyService services.APIKeyServiceInterface) *APIKeyController {
+	return &APIKeyController{APIKeyService: apiKeyService}
+}
+
+// CreateAPIKey 为当前用户创建API密钥，明文只在响应中返回这一次
+func (kc *APIKeyController) CreateAPIKey(c *gin.Context) {
+	var req dtos.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	key, token, err := kc.APIKeyService.Create(c.GetUint("user_id"), req.Name, req.Scopes, req.ExpiresInDays)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dtos.CreatedAPIKeyResponse{APIKeyResponse: newAPIKeyResponse(key), Token: token})
+}
+
+// GetAPIKeys 返回当前用户的所有API密钥
+func (kc *APIKeyController) GetAPIKeys(c *gin.Context) {
+	keys, err := kc.APIKeyService.List(c.GetUint("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp := make([]dtos.APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		resp = append(resp, newAPIKeyResponse(&keys[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetAPIKey 返回当前用户的指定API密钥
+func (kc *APIKeyController) GetAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	key, err := kc.APIKeyService.Get(c.GetUint("user_id"), uint(id))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAPIKeyResponse(key))
+}
+
+// UpdateAPIKey 修改API密钥的名称和权限范围
+func (kc *APIKeyController) UpdateAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var req dtos.UpdateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	key, err := kc.APIKeyService.Update(c.GetUint("user_id"), uint(id), req.Name, req.Scopes)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAPIKeyResponse(key))
+}
+
+// DeleteAPIKey 删除API密钥，之后使用该密钥的请求会被拒绝
+func (kc *APIKeyController) DeleteAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := kc.APIKeyService.Delete(c.GetUint("user_id"), uint(id)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key deleted successfully"})
+}
+
+func newAPIKeyResponse(key *models.APIKey) dtos.APIKeyResponse {
+	return dtos.APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
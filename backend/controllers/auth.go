@@ -1,8 +1,12 @@
 package controllers
 
 import (
+	"errors"
 	"go-web/dtos"
+	"go-web/models"
 	"go-web/services"
+	"go-web/utils"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
@@ -24,56 +28,140 @@ func (ac *AuthController) Register(c *gin.Context) {
 		return
 	}
 
-	user, token, err := ac.AuthService.Register(req.Username, req.Email, req.Password)
+	user, tokens, err := ac.AuthService.Register(req.Username, req.Email, req.Password, clientInfo(c))
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
 
-	// 构造响应
-	userResponse := dtos.UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		RoleID:   user.RoleID,
-		Role:     user.Role.Name,
+	c.JSON(http.StatusCreated, newAuthResponse(user, tokens))
+}
+
+// Login 用户登录
+func (ac *AuthController) Login(c *gin.Context) {
+	var req dtos.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
 	}
 
-	response := dtos.AuthResponse{
-		Token: token,
-		User:  userResponse,
+	user, tokens, err := ac.AuthService.Login(req.Username, req.Password, clientInfo(c))
+	if err != nil {
+		// 启用了两步验证的用户需要继续提交验证码
+		var mfaRequired *services.MFARequiredError
+		if errors.As(err, &mfaRequired) {
+			c.JSON(http.StatusOK, dtos.MFAChallengeResponse{
+				MFARequired: true,
+				MFAToken:    mfaRequired.MFAToken,
+				ExpiresIn:   mfaRequired.ExpiresIn,
+			})
+			return
+		}
+		_ = c.Error(err)
+		return
 	}
 
-	c.JSON(http.StatusCreated, response)
+	c.JSON(http.StatusOK, newAuthResponse(user, tokens))
 }
 
-// Login 用户登录
-func (ac *AuthController) Login(c *gin.Context) {
-	var req dtos.LoginRequest
+// VerifyMFA 两步登录的第二步，使用挑战令牌和验证码（或恢复码）换取令牌
+func (ac *AuthController) VerifyMFA(c *gin.Context) {
+	var req dtos.MFAVerifyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		_ = c.Error(err)
 		return
 	}
 
-	user, token, err := ac.AuthService.Login(req.Username, req.Password)
+	user, tokens, err := ac.AuthService.VerifyMFA(req.MFAToken, req.Code, clientInfo(c))
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
 
-	// 构造响应
-	userResponse := dtos.UserResponse{
-		ID:       user.ID,
-		Username: user.Username,
-		Email:    user.Email,
-		RoleID:   user.RoleID,
-		Role:     user.Role.Name,
+	c.JSON(http.StatusOK, newAuthResponse(user, tokens))
+}
+
+// Refresh 使用刷新令牌换取新的访问令牌，旧的刷新令牌随之失效
+func (ac *AuthController) Refresh(c *gin.Context) {
+	var req dtos.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
 	}
 
-	response := dtos.AuthResponse{
-		Token: token,
-		User:  userResponse,
+	user, tokens, err := ac.AuthService.Refresh(req.RefreshToken)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAuthResponse(user, tokens))
+}
+
+// ChangePassword 当前用户修改自己的密码，其他设备上的会话全部失效，当前设备使用返回的新令牌
+func (ac *AuthController) ChangePassword(c *gin.Context) {
+	var req dtos.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, tokens, err := ac.AuthService.ChangePassword(c.GetUint("user_id"), req.CurrentPassword, req.NewPassword, clientInfo(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAuthResponse(user, tokens))
+}
+
+// Logout 用户登出，吊销当前访问令牌以及（可选的）刷新令牌
+func (ac *AuthController) Logout(c *gin.Context) {
+	// 请求体是可选的，只有需要同时吊销刷新令牌时才提供
+	var req dtos.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		_ = c.Error(err)
+		return
 	}
 
-	c.JSON(http.StatusOK, response)
+	// 使用API密钥认证的请求没有可吊销的访问令牌，API密钥需要通过删除来失效
+	claimsValue, ok := c.Get("claims")
+	if !ok {
+		_ = c.Error(services.ErrAPIKeyNotAllowed)
+		return
+	}
+	claims := claimsValue.(*utils.Claims)
+	if err := ac.AuthService.Logout(claims, req.RefreshToken); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// clientInfo 返回发起请求的客户端信息，记录在登录会话中
+func clientInfo(c *gin.Context) services.ClientInfo {
+	return services.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
+
+// newAuthResponse 根据用户和令牌对构造认证响应，tokens 为 nil 时只返回用户信息
+func newAuthResponse(user *models.User, tokens *services.TokenPair) dtos.AuthResponse {
+	response := dtos.AuthResponse{
+		User: dtos.UserResponse{
+			ID:       user.ID,
+			Username: user.Username,
+			Email:    user.Email,
+			RoleID:   user.RoleID,
+			Role:     user.Role.Name,
+		},
+	}
+	if tokens != nil {
+		response.Token = tokens.AccessToken
+		response.RefreshToken = tokens.RefreshToken
+		response.ExpiresIn = tokens.ExpiresIn
+	}
+	return response
 }
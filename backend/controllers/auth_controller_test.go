@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -21,24 +22,54 @@ import (
 var _ services.AuthServiceInterface = (*MockAuthService)(nil)
 
 // MockAuthService is a mock implementation of AuthService for testing controllers.
+// MockAuthService ignores the client info, which is not part of the expectations.
 type MockAuthService struct {
 	mock.Mock
 }
 
-func (m *MockAuthService) Register(username, email, password string) (*models.User, string, error) {
+func (m *MockAuthService) Register(username, email, password string, client services.ClientInfo) (*models.User, *services.TokenPair, error) {
 	args := m.Called(username, email, password)
 	if args.Get(0) == nil {
-		return nil, "", args.Error(2)
+		return nil, nil, args.Error(2)
 	}
-	return args.Get(0).(*models.User), args.String(1), args.Error(2)
+	return args.Get(0).(*models.User), args.Get(1).(*services.TokenPair), args.Error(2)
 }
 
-func (m *MockAuthService) Login(username, password string) (*models.User, string, error) {
+func (m *MockAuthService) Login(username, password string, client services.ClientInfo) (*models.User, *services.TokenPair, error) {
 	args := m.Called(username, password)
 	if args.Get(0) == nil {
-		return nil, "", args.Error(2)
+		return nil, nil, args.Error(2)
 	}
-	return args.Get(0).(*models.User), args.String(1), args.Error(2)
+	return args.Get(0).(*models.User), args.Get(1).(*services.TokenPair), args.Error(2)
+}
+
+func (m *MockAuthService) Refresh(refreshToken string) (*models.User, *services.TokenPair, error) {
+	args := m.Called(refreshToken)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*models.User), args.Get(1).(*services.TokenPair), args.Error(2)
+}
+
+func (m *MockAuthService) VerifyMFA(mfaToken, code string, client services.ClientInfo) (*models.User, *services.TokenPair, error) {
+	args := m.Called(mfaToken, code)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*models.User), args.Get(1).(*services.TokenPair), args.Error(2)
+}
+
+func (m *MockAuthService) ChangePassword(userID uint, currentPassword, newPassword string, client services.ClientInfo) (*models.User, *services.TokenPair, error) {
+	args := m.Called(userID, currentPassword, newPassword)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*models.User), args.Get(1).(*services.TokenPair), args.Error(2)
+}
+
+func (m *MockAuthService) Logout(claims *utils.Claims, refreshToken string) error {
+	args := m.Called(claims, refreshToken)
+	return args.Error(0)
 }
 
 func setupAuthTestRouter() (*gin.Engine, *MockAuthService) {
@@ -51,6 +82,17 @@ func setupAuthTestRouter() (*gin.Engine, *MockAuthService) {
 	router.Use(middleware.ErrorHandler())
 	router.POST("/auth/register", authController.Register)
 	router.POST("/auth/login", authController.Login)
+	router.POST("/auth/refresh", authController.Refresh)
+	router.POST("/auth/mfa/verify", authController.VerifyMFA)
+	router.POST("/auth/logout", func(c *gin.Context) {
+		// Stand in for AuthMiddleware so the controller can be tested in isolation
+		c.Set("claims", &utils.Claims{UserID: 1, Role: "user"})
+		c.Next()
+	}, authController.Logout)
+	router.PUT("/users/me/password", func(c *gin.Context) {
+		c.Set("user_id", uint(1))
+		c.Next()
+	}, authController.ChangePassword)
 
 	return router, mockAuthService
 }
@@ -72,8 +114,9 @@ func TestRegister_Endpoint_Success(t *testing.T) {
 		Role:     models.Role{Name: "user"},
 	}
 	mockedToken := "mocked-jwt-token"
+	mockedTokens := &services.TokenPair{AccessToken: mockedToken, RefreshToken: "mocked-refresh-token", ExpiresIn: 900}
 
-	mockAuthService.On("Register", registerReq.Username, registerReq.Email, registerReq.Password).Return(mockedUser, mockedToken, nil)
+	mockAuthService.On("Register", registerReq.Username, registerReq.Email, registerReq.Password).Return(mockedUser, mockedTokens, nil)
 
 	// 3. Execution
 	jsonValue, _ := json.Marshal(registerReq)
@@ -89,6 +132,7 @@ func TestRegister_Endpoint_Success(t *testing.T) {
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err, "Should be able to unmarshal the response body")
 	assert.Equal(t, mockedToken, response.Token, "Response token should match the mocked token")
+	assert.Equal(t, "mocked-refresh-token", response.RefreshToken, "Response refresh token should match the mocked token")
 	assert.Equal(t, registerReq.Username, response.User.Username, "Response username should match the request username")
 
 	mockAuthService.AssertExpectations(t)
@@ -106,7 +150,7 @@ func TestRegister_Endpoint_UserExists(t *testing.T) {
 	}
 
 	// Simulate the service returning a UserExistsError
-	mockAuthService.On("Register", registerReq.Username, registerReq.Email, registerReq.Password).Return(nil, "", &services.UserExistsError{})
+	mockAuthService.On("Register", registerReq.Username, registerReq.Email, registerReq.Password).Return(nil, nil, &services.UserExistsError{})
 
 	// 3. Execution
 	jsonValue, _ := json.Marshal(registerReq)
@@ -127,6 +171,41 @@ func TestRegister_Endpoint_UserExists(t *testing.T) {
 	mockAuthService.AssertExpectations(t)
 }
 
+func TestRegister_Endpoint_PasswordPolicy(t *testing.T) {
+	// 1. Setup
+	router, mockAuthService := setupAuthTestRouter()
+
+	// 2. Define Mock Expectations
+	registerReq := dtos.RegisterRequest{
+		Username: "newuser",
+		Email:    "new@example.com",
+		Password: "newuser",
+	}
+	violations := []services.PasswordViolation{
+		{Code: services.PasswordTooShort, Message: "密码长度不能少于 8 个字符"},
+		{Code: services.PasswordContainsUserInfo, Message: "密码不能包含用户名或邮箱"},
+	}
+	mockAuthService.On("Register", registerReq.Username, registerReq.Email, registerReq.Password).Return(nil, nil, &services.PasswordPolicyError{Violations: violations})
+
+	// 3. Execution
+	jsonValue, _ := json.Marshal(registerReq)
+	req, _ := http.NewRequest(http.MethodPost, "/auth/register", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 4. Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var errorResponse middleware.ErrorResponse
+	err := json.Unmarshal(w.Body.Bytes(), &errorResponse)
+	assert.NoError(t, err)
+	assert.Equal(t, services.ErrPasswordPolicy.Error(), errorResponse.Message)
+	assert.Equal(t, violations, errorResponse.Violations)
+
+	mockAuthService.AssertExpectations(t)
+}
+
 func TestLogin_Endpoint_Success(t *testing.T) {
 	// 1. Setup
 	router, mockAuthService := setupAuthTestRouter()
@@ -142,8 +221,9 @@ func TestLogin_Endpoint_Success(t *testing.T) {
 		Role:     models.Role{Name: "user"},
 	}
 	mockedToken := "mocked-jwt-token-for-login"
+	mockedTokens := &services.TokenPair{AccessToken: mockedToken, RefreshToken: "mocked-refresh-token", ExpiresIn: 900}
 
-	mockAuthService.On("Login", loginReq.Username, loginReq.Password).Return(mockedUser, mockedToken, nil)
+	mockAuthService.On("Login", loginReq.Username, loginReq.Password).Return(mockedUser, mockedTokens, nil)
 
 	// 3. Execution
 	jsonValue, _ := json.Marshal(loginReq)
@@ -174,7 +254,7 @@ func TestLogin_Endpoint_InvalidCredentials(t *testing.T) {
 		Password: "wrongpassword",
 	}
 
-	mockAuthService.On("Login", loginReq.Username, loginReq.Password).Return(nil, "", &services.InvalidCredentialsError{})
+	mockAuthService.On("Login", loginReq.Username, loginReq.Password).Return(nil, nil, &services.InvalidCredentialsError{})
 
 	// 3. Execution
 	jsonValue, _ := json.Marshal(loginReq)
@@ -194,3 +274,228 @@ func TestLogin_Endpoint_InvalidCredentials(t *testing.T) {
 
 	mockAuthService.AssertExpectations(t)
 }
+
+func TestLogin_Endpoint_AccountLocked(t *testing.T) {
+	// 1. Setup
+	router, mockAuthService := setupAuthTestRouter()
+
+	// 2. Define Mock Expectations
+	loginReq := dtos.LoginRequest{
+		Username: "testuser",
+		Password: "password123",
+	}
+	mockAuthService.On("Login", loginReq.Username, loginReq.Password).Return(nil, nil, &services.AccountLockedError{Locked: true, RetryAfter: 90 * time.Second})
+
+	// 3. Execution
+	jsonValue, _ := json.Marshal(loginReq)
+	req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 4. Assertions
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "90", w.Header().Get("Retry-After"))
+
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestLogin_Endpoint_MFARequired(t *testing.T) {
+	// 1. Setup
+	router, mockAuthService := setupAuthTestRouter()
+
+	// 2. Define Mock Expectations
+	loginReq := dtos.LoginRequest{
+		Username: "mfauser",
+		Password: "password123",
+	}
+	mockAuthService.On("Login", loginReq.Username, loginReq.Password).Return(nil, nil, &services.MFARequiredError{MFAToken: "challenge-token", ExpiresIn: 300})
+
+	// 3. Execution
+	jsonValue, _ := json.Marshal(loginReq)
+	req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 4. Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response dtos.MFAChallengeResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.MFARequired)
+	assert.Equal(t, "challenge-token", response.MFAToken)
+	assert.Equal(t, 300, response.ExpiresIn)
+
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestVerifyMFA_Endpoint_InvalidCode(t *testing.T) {
+	// 1. Setup
+	router, mockAuthService := setupAuthTestRouter()
+
+	// 2. Define Mock Expectations
+	verifyReq := dtos.MFAVerifyRequest{MFAToken: "challenge-token", Code: "000000"}
+	mockAuthService.On("VerifyMFA", verifyReq.MFAToken, verifyReq.Code).Return(nil, nil, services.ErrInvalidMFACode)
+
+	// 3. Execution
+	jsonValue, _ := json.Marshal(verifyReq)
+	req, _ := http.NewRequest(http.MethodPost, "/auth/mfa/verify", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 4. Assertions
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestRefresh_Endpoint_Success(t *testing.T) {
+	// 1. Setup
+	router, mockAuthService := setupAuthTestRouter()
+
+	// 2. Define Mock Expectations
+	refreshReq := dtos.RefreshRequest{RefreshToken: "old-refresh-token"}
+
+	mockedUser := &models.User{Username: "testuser", Role: models.Role{Name: "user"}}
+	mockedTokens := &services.TokenPair{AccessToken: "new-access-token", RefreshToken: "new-refresh-token", ExpiresIn: 900}
+
+	mockAuthService.On("Refresh", refreshReq.RefreshToken).Return(mockedUser, mockedTokens, nil)
+
+	// 3. Execution
+	jsonValue, _ := json.Marshal(refreshReq)
+	req, _ := http.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 4. Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response dtos.AuthResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "new-access-token", response.Token)
+	assert.Equal(t, "new-refresh-token", response.RefreshToken)
+
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestRefresh_Endpoint_Reused(t *testing.T) {
+	// 1. Setup
+	router, mockAuthService := setupAuthTestRouter()
+
+	// 2. Define Mock Expectations
+	refreshReq := dtos.RefreshRequest{RefreshToken: "rotated-refresh-token"}
+	mockAuthService.On("Refresh", refreshReq.RefreshToken).Return(nil, nil, services.ErrRefreshTokenReused)
+
+	// 3. Execution
+	jsonValue, _ := json.Marshal(refreshReq)
+	req, _ := http.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 4. Assertions
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestLogout_Endpoint_Success(t *testing.T) {
+	// 1. Setup
+	router, mockAuthService := setupAuthTestRouter()
+
+	// 2. Define Mock Expectations
+	logoutReq := dtos.LogoutRequest{RefreshToken: "refresh-token"}
+	mockAuthService.On("Logout", mock.AnythingOfType("*utils.Claims"), logoutReq.RefreshToken).Return(nil)
+
+	// 3. Execution
+	jsonValue, _ := json.Marshal(logoutReq)
+	req, _ := http.NewRequest(http.MethodPost, "/auth/logout", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 4. Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestLogout_Endpoint_WithoutBody(t *testing.T) {
+	// 1. Setup
+	router, mockAuthService := setupAuthTestRouter()
+
+	// 2. Define Mock Expectations
+	mockAuthService.On("Logout", mock.AnythingOfType("*utils.Claims"), "").Return(nil)
+
+	// 3. Execution
+	req, _ := http.NewRequest(http.MethodPost, "/auth/logout", http.NoBody)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 4. Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestChangePassword_Endpoint_Success(t *testing.T) {
+	// 1. Setup
+	router, mockAuthService := setupAuthTestRouter()
+
+	// 2. Define Mock Expectations
+	changeReq := dtos.ChangePasswordRequest{
+		CurrentPassword: "old-password",
+		NewPassword:     "new-password",
+	}
+	user := &models.User{Username: "testuser", Email: "test@example.com", Role: models.Role{Name: "user"}}
+	user.ID = 1
+	tokens := &services.TokenPair{AccessToken: "new-access-token", RefreshToken: "new-refresh-token", ExpiresIn: 900}
+	mockAuthService.On("ChangePassword", uint(1), changeReq.CurrentPassword, changeReq.NewPassword).Return(user, tokens, nil)
+
+	// 3. Execution
+	jsonValue, _ := json.Marshal(changeReq)
+	req, _ := http.NewRequest(http.MethodPut, "/users/me/password", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 4. Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response dtos.AuthResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "new-access-token", response.Token)
+	assert.Equal(t, "new-refresh-token", response.RefreshToken)
+
+	mockAuthService.AssertExpectations(t)
+}
+
+func TestChangePassword_Endpoint_IncorrectPassword(t *testing.T) {
+	// 1. Setup
+	router, mockAuthService := setupAuthTestRouter()
+
+	// 2. Define Mock Expectations
+	changeReq := dtos.ChangePasswordRequest{
+		CurrentPassword: "wrong-password",
+		NewPassword:     "new-password",
+	}
+	mockAuthService.On("ChangePassword", uint(1), changeReq.CurrentPassword, changeReq.NewPassword).Return(nil, nil, services.ErrIncorrectPassword)
+
+	// 3. Execution
+	jsonValue, _ := json.Marshal(changeReq)
+	req, _ := http.NewRequest(http.MethodPut, "/users/me/password", bytes.NewBuffer(jsonValue))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// 4. Assertions
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	mockAuthService.AssertExpectations(t)
+}
@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"go-web/dtos"
+	"go-web/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type EmailVerificationController struct {
+	EmailVerificationService services.EmailVerificationServiceInterface
+}
+
+func NewEmailVerificationController(emailVerificationService services.EmailVerificationServiceInterface) *EmailVerificationController {
+	return &EmailVerificationController{EmailVerificationService: emailVerificationService}
+}
+
+// VerifyEmail 使用邮件中的令牌验证邮箱
+func (ec *EmailVerificationController) VerifyEmail(c *gin.Context) {
+	var req dtos.VerifyEmailRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if _, err := ec.EmailVerificationService.VerifyEmail(req.Token); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email has been verified successfully"})
+}
+
+// ResendVerification 重新发送邮箱验证邮件
+func (ec *EmailVerificationController) ResendVerification(c *gin.Context) {
+	var req dtos.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := ec.EmailVerificationService.ResendVerification(req.Email); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// 无论邮箱是否注册都返回相同的响应，避免泄露账户信息
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered and not yet verified, a verification link has been sent"})
+}
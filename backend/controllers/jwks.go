@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"go-web/config"
+	"go-web/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type JWKSController struct {
+	Config *config.Config
+}
+
+func NewJWKSController(cfg *config.Config) *JWKSController {
+	return &JWKSController{Config: cfg}
+}
+
+// GetJWKS 返回用于验证访问令牌签名的公钥集合，供下游服务在不持有密钥的情况下校验令牌
+func (jc *JWKSController) GetJWKS(c *gin.Context) {
+	jwks, err := utils.GetJWKS(jc.Config)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// 允许下游服务短暂缓存，密钥轮换时应让新旧公钥同时发布一段时间
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, jwks)
+}
@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"go-web/dtos"
+	"go-web/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MFAController struct {
+	MFAService services.MFAServiceInterface
+}
+
+func NewMFAController(mfaService services.MFAServiceInterface) *MFAController {
+	return &MFAController{MFAService: mfaService}
+}
+
+// EnrollTOTP 为当前用户生成TOTP密钥，返回可生成二维码的 otpauth URI
+func (mc *MFAController) EnrollTOTP(c *gin.Context) {
+	enrollment, err := mc.MFAService.EnrollTOTP(c.GetUint("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dtos.TOTPEnrollmentResponse{
+		Secret:     enrollment.Secret,
+		OTPAuthURI: enrollment.OTPAuthURI,
+	})
+}
+
+// ConfirmTOTP 使用验证码确认绑定并启用两步验证，返回一次性恢复码
+func (mc *MFAController) ConfirmTOTP(c *gin.Context) {
+	var req dtos.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	codes, err := mc.MFAService.ConfirmTOTP(c.GetUint("user_id"), req.Code)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dtos.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP 校验验证码后关闭两步验证
+func (mc *MFAController) DisableTOTP(c *gin.Context) {
+	var req dtos.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := mc.MFAService.DisableTOTP(c.GetUint("user_id"), req.Code); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication has been disabled"})
+}
+
+// RegenerateRecoveryCodes 校验验证码后生成一组新的恢复码，旧的恢复码全部作废
+func (mc *MFAController) RegenerateRecoveryCodes(c *gin.Context) {
+	var req dtos.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	codes, err := mc.MFAService.RegenerateRecoveryCodes(c.GetUint("user_id"), req.Code)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dtos.RecoveryCodesResponse{RecoveryCodes: codes})
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"go-web/dtos"
+	"go-web/services"
+	"go-web/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type OIDCController struct {
+	OIDCService services.OIDCServiceInterface
+}
+
+func NewOIDCController(oidcService services.OIDCServiceInterface) *OIDCController {
+	return &OIDCController{OIDCService: oidcService}
+}
+
+// Login 发起第三方登录，返回身份提供方的授权URL
+func (oc *OIDCController) Login(c *gin.Context) {
+	authURL, err := oc.OIDCService.BeginLogin(c.Request.Context(), c.Param("provider"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dtos.OIDCAuthorizationResponse{AuthorizationURL: authURL})
+}
+
+// Callback 处理身份提供方的回调，完成登录或关联外部身份
+func (oc *OIDCController) Callback(c *gin.Context) {
+	var req dtos.OIDCCallbackRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	// 用户拒绝授权或身份提供方出错时不会返回授权码
+	if req.Error != "" || req.Code == "" {
+		utils.Logger.Warn("oidc authorization failed", zap.String("provider", c.Param("provider")), zap.String("error", req.Error), zap.String("error_description", req.ErrorDescription))
+		_ = c.Error(services.ErrOIDCAuthenticationFailed)
+		return
+	}
+
+	user, tokens, err := oc.OIDCService.Callback(c.Request.Context(), c.Param("provider"), req.Code, req.State, clientInfo(c))
+	if err != nil {
+		// 启用了两步验证的用户需要继续提交验证码
+		var mfaRequired *services.MFARequiredError
+		if errors.As(err, &mfaRequired) {
+			c.JSON(http.StatusOK, dtos.MFAChallengeResponse{
+				MFARequired: true,
+				MFAToken:    mfaRequired.MFAToken,
+				ExpiresIn:   mfaRequired.ExpiresIn,
+			})
+			return
+		}
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAuthResponse(user, tokens))
+}
+
+// Link 为当前用户发起关联外部身份的流程，返回身份提供方的授权URL
+func (oc *OIDCController) Link(c *gin.Context) {
+	authURL, err := oc.OIDCService.BeginLink(c.Request.Context(), c.Param("provider"), c.GetUint("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dtos.OIDCAuthorizationResponse{AuthorizationURL: authURL})
+}
+
+// GetIdentities 返回当前用户关联的外部身份
+func (oc *OIDCController) GetIdentities(c *gin.Context) {
+	identities, err := oc.OIDCService.ListIdentities(c.GetUint("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp := make([]dtos.UserIdentityResponse, 0, len(identities))
+	for _, identity := range identities {
+		resp = append(resp, dtos.UserIdentityResponse{
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,176 @@
+package controllers
+
+import (
+	"go-web/dtos"
+	"go-web/models"
+	"go-web/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OrganizationController struct {
+	OrganizationService services.OrganizationServiceInterface
+}
+
+func NewOrganizationController(organizationService services.OrganizationServiceInterface) *OrganizationController {
+	return &OrganizationController{OrganizationService: organizationService}
+}
+
+// GetOrganizations 获取所有组织
+func (oc *OrganizationController) GetOrganizations(c *gin.Context) {
+	organizations, err := oc.OrganizationService.List()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp := make([]dtos.OrganizationResponse, 0, len(organizations))
+	for i := range organizations {
+		resp = append(resp, toOrganizationResponse(&organizations[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateOrganization 创建组织，创建者以管理员角色加入
+func (oc *OrganizationController) CreateOrganization(c *gin.Context) {
+	var req dtos.OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	organization, err := oc.OrganizationService.Create(req.Name, req.Slug, c.GetUint("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, toOrganizationResponse(organization))
+}
+
+// GetMyOrganizations 获取当前用户加入的组织及其在组织中的角色
+func (oc *OrganizationController) GetMyOrganizations(c *gin.Context) {
+	memberships, err := oc.OrganizationService.ListForUser(c.GetUint("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp := make([]dtos.UserOrganizationResponse, 0, len(memberships))
+	for _, membership := range memberships {
+		resp = append(resp, dtos.UserOrganizationResponse{
+			OrganizationResponse: toOrganizationResponse(&membership.Organization),
+			Role:                 membership.Role.Name,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SwitchTenant 切换当前组织，返回以该组织为当前组织的新令牌
+func (oc *OrganizationController) SwitchTenant(c *gin.Context) {
+	var req dtos.SwitchTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, tokens, err := oc.OrganizationService.SwitchTenant(c.GetUint("user_id"), req.OrganizationID, clientInfo(c))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newAuthResponse(user, tokens))
+}
+
+// GetMembers 获取当前组织的成员
+func (oc *OrganizationController) GetMembers(c *gin.Context) {
+	memberships, err := oc.OrganizationService.ListMembers(c.Request.Context())
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp := make([]dtos.MemberResponse, 0, len(memberships))
+	for i := range memberships {
+		resp = append(resp, toMemberResponse(&memberships[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// AddMember 将用户加入当前组织
+func (oc *OrganizationController) AddMember(c *gin.Context) {
+	var req dtos.AddMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	membership, err := oc.OrganizationService.AddMember(c.Request.Context(), req.UserID, req.Role)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, toMemberResponse(membership))
+}
+
+// UpdateMember 修改成员在当前组织中的角色
+func (oc *OrganizationController) UpdateMember(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var req dtos.UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	membership, err := oc.OrganizationService.UpdateMember(c.Request.Context(), uint(userID), req.Role)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toMemberResponse(membership))
+}
+
+// RemoveMember 将成员移出当前组织
+func (oc *OrganizationController) RemoveMember(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := oc.OrganizationService.RemoveMember(c.Request.Context(), uint(userID)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
+}
+
+func toOrganizationResponse(organization *models.Organization) dtos.OrganizationResponse {
+	return dtos.OrganizationResponse{
+		ID:        organization.ID,
+		Name:      organization.Name,
+		Slug:      organization.Slug,
+		CreatedAt: organization.CreatedAt,
+	}
+}
+
+func toMemberResponse(membership *models.Membership) dtos.MemberResponse {
+	return dtos.MemberResponse{
+		UserID:   membership.UserID,
+		Username: membership.User.Username,
+		Email:    membership.User.Email,
+		Role:     membership.Role.Name,
+		JoinedAt: membership.CreatedAt,
+	}
+}
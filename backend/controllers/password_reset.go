@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"go-web/dtos"
+	"go-web/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PasswordResetController struct {
+	PasswordResetService services.PasswordResetServiceInterface
+}
+
+func NewPasswordResetController(passwordResetService services.PasswordResetServiceInterface) *PasswordResetController {
+	return &PasswordResetController{PasswordResetService: passwordResetService}
+}
+
+// ForgotPassword 申请重置密码，向注册邮箱发送重置链接
+func (pc *PasswordResetController) ForgotPassword(c *gin.Context) {
+	var req dtos.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := pc.PasswordResetService.RequestReset(req.Email); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	// 无论邮箱是否注册都返回相同的响应，避免泄露账户信息
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the email is registered, a password reset link has been sent"})
+}
+
+// ResetPassword 使用邮件中的令牌设置新密码
+func (pc *PasswordResetController) ResetPassword(c *gin.Context) {
+	var req dtos.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := pc.PasswordResetService.ResetPassword(req.Token, req.Password); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset successfully"})
+}
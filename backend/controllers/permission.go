@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"go-web/dtos"
+	"go-web/services"
+	"go-web/tenant"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PermissionController struct {
+	PermissionService services.PermissionServiceInterface
+}
+
+func NewPermissionController(permissionService services.PermissionServiceInterface) *PermissionController {
+	return &PermissionController{PermissionService: permissionService}
+}
+
+// GetMyPermissions 获取当前用户的角色在当前组织中的有效权限，前端据此决定显示哪些功能
+func (pc *PermissionController) GetMyPermissions(c *gin.Context) {
+	role, tenantID := c.GetString("role"), c.GetUint("tenant_id")
+	permissions, err := pc.PermissionService.GetPermissions(role, tenantID)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp := dtos.PermissionsResponse{
+		Role:        role,
+		Domain:      tenant.Domain(tenantID),
+		Permissions: make([]dtos.PermissionResponse, 0, len(permissions)),
+	}
+	for _, permission := range permissions {
+		resp.Permissions = append(resp.Permissions, dtos.PermissionResponse{Object: permission.Object, Action: permission.Action})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CheckPermissions 一次检查当前用户能否进行多项访问
+func (pc *PermissionController) CheckPermissions(c *gin.Context) {
+	var req dtos.CheckPermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	checks := make([]services.PermissionCheck, 0, len(req.Checks))
+	for _, check := range req.Checks {
+		checks = append(checks, services.PermissionCheck{Object: check.Object, Action: check.Action})
+	}
+	allowed, err := pc.PermissionService.Check(c.GetString("role"), c.GetUint("tenant_id"), checks)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp := dtos.CheckPermissionsResponse{Results: make([]dtos.PermissionCheckResult, 0, len(checks))}
+	for i, check := range req.Checks {
+		resp.Results = append(resp.Results, dtos.PermissionCheckResult{Object: check.Object, Action: check.Action, Allowed: allowed[i]})
+	}
+	c.JSON(http.StatusOK, resp)
+}
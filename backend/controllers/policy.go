@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"go-web/dtos"
+	"go-web/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PolicyController struct {
+	PolicyService services.PolicyServiceInterface
+}
+
+func NewPolicyController(policyService services.PolicyServiceInterface) *PolicyController {
+	return &PolicyController{PolicyService: policyService}
+}
+
+// GetPolicies 返回所有访问策略
+func (pc *PolicyController) GetPolicies(c *gin.Context) {
+	pc.list(c, pc.PolicyService.GetPolicies)
+}
+
+// AddPolicy 添加一条访问策略，立即生效
+func (pc *PolicyController) AddPolicy(c *gin.Context) {
+	pc.modify(c, pc.PolicyService.AddPolicy, "p", http.StatusCreated)
+}
+
+// DeletePolicy 删除一条访问策略，立即生效
+func (pc *PolicyController) DeletePolicy(c *gin.Context) {
+	pc.modify(c, pc.PolicyService.RemovePolicy, "p", http.StatusOK)
+}
+
+// GetGroupings 返回所有角色继承关系
+func (pc *PolicyController) GetGroupings(c *gin.Context) {
+	pc.list(c, pc.PolicyService.GetGroupings)
+}
+
+// AddGrouping 添加一条角色继承关系，立即生效
+func (pc *PolicyController) AddGrouping(c *gin.Context) {
+	pc.modify(c, pc.PolicyService.AddGrouping, "g", http.StatusCreated)
+}
+
+// DeleteGrouping 删除一条角色继承关系，立即生效
+func (pc *PolicyController) DeleteGrouping(c *gin.Context) {
+	pc.modify(c, pc.PolicyService.RemoveGrouping, "g", http.StatusOK)
+}
+
+func (pc *PolicyController) list(c *gin.Context, get func() ([]services.PolicyRule, error)) {
+	rules, err := get()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp := make([]dtos.PolicyResponse, 0, len(rules))
+	for _, rule := range rules {
+		resp = append(resp, dtos.PolicyResponse{PType: rule.PType, Rule: rule.Rule})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (pc *PolicyController) modify(c *gin.Context, apply func(services.PolicyRule) error, defaultPType string, status int) {
+	var req dtos.PolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	rule := services.PolicyRule{PType: req.PType, Rule: req.Rule}
+	if rule.PType == "" {
+		rule.PType = defaultPType
+	}
+	if err := apply(rule); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(status, dtos.PolicyResponse{PType: rule.PType, Rule: rule.Rule})
+}
@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	"go-web/dtos"
+	"go-web/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleController struct {
+	RoleService services.RoleServiceInterface
+}
+
+func NewRoleController(roleService services.RoleServiceInterface) *RoleController {
+	return &RoleController{RoleService: roleService}
+}
+
+// GetRoles 获取角色列表
+func (rc *RoleController) GetRoles(c *gin.Context) {
+	roles, err := rc.RoleService.List()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp := make([]dtos.RoleResponse, 0, len(roles))
+	for i := range roles {
+		resp = append(resp, toRoleResponse(&roles[i]))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetRole 获取单个角色
+func (rc *RoleController) GetRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	role, err := rc.RoleService.Get(uint(id))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toRoleResponse(role))
+}
+
+// CreateRole 创建角色
+func (rc *RoleController) CreateRole(c *gin.Context) {
+	var req dtos.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	role, err := rc.RoleService.Create(req.Name, req.Description, req.Parents)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, toRoleResponse(role))
+}
+
+// UpdateRole 修改角色的名称、描述和继承的角色
+func (rc *RoleController) UpdateRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	var req dtos.RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	role, err := rc.RoleService.Update(uint(id), req.Name, req.Description, req.Parents)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toRoleResponse(role))
+}
+
+// DeleteRole 删除角色，仍有用户持有或被其他角色继承的角色不能删除
+func (rc *RoleController) DeleteRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := rc.RoleService.Delete(uint(id)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+}
+
+func toRoleResponse(role *services.RoleDetails) dtos.RoleResponse {
+	return dtos.RoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Parents:     role.Parents,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"go-web/dtos"
+	"go-web/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionController struct {
+	SessionService services.SessionServiceInterface
+}
+
+func NewSessionController(sessionService services.SessionServiceInterface) *SessionController {
+	return &SessionController{SessionService: sessionService}
+}
+
+// GetSessions 返回当前用户所有有效的登录会话，并标记发起本次请求的会话
+func (sc *SessionController) GetSessions(c *gin.Context) {
+	sessions, err := sc.SessionService.List(c.GetUint("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	currentID := c.GetUint("session_id")
+	resp := make([]dtos.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		resp = append(resp, dtos.SessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IPAddress:  session.IPAddress,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == currentID,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteSession 吊销当前用户的指定会话，该设备需要重新登录
+func (sc *SessionController) DeleteSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := sc.SessionService.Revoke(c.GetUint("user_id"), uint(id)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
+
+// DeleteUserSessions 管理员吊销指定用户的所有会话，强制其在所有设备上重新登录
+func (sc *SessionController) DeleteUserSessions(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := sc.SessionService.RevokeAllForUser(uint(id)); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions of the user have been revoked"})
+}
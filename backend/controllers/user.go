@@ -1,9 +1,11 @@
 package controllers
 
 import (
+	"fmt"
 	"go-web/dtos"
 	"go-web/models"
 	"go-web/services"
+	"go-web/tenant"
 	"net/http"
 	"strconv"
 
@@ -18,18 +20,42 @@ func NewUserController(userService services.UserServiceInterface) *UserControlle
 	return &UserController{UserService: userService}
 }
 
-// GetUsers 获取用户列表
+// GetUsers 分页获取用户列表，支持按角色、用户名或邮箱、创建时间筛选和排序
 func (uc *UserController) GetUsers(c *gin.Context) {
-	users, err := uc.UserService.GetUsers()
+	var req dtos.UserListQuery
+	if err := c.ShouldBindQuery(&req); err != nil {
+		_ = c.Error(fmt.Errorf("%w: %v", services.ErrInvalidUserQuery, err))
+		return
+	}
+
+	opts := services.UserListOptions{
+		Page:   req.Page,
+		Size:   req.Size,
+		Cursor: req.Cursor,
+		Sort:   req.Sort,
+		Role:   req.Role,
+		Search: req.Search,
+	}
+	if !req.CreatedAfter.IsZero() {
+		opts.CreatedAfter = &req.CreatedAfter
+	}
+	if !req.CreatedBefore.IsZero() {
+		opts.CreatedBefore = &req.CreatedBefore
+	}
+	list, err := uc.UserService.GetUsers(opts)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
 
-	var userResponses []dtos.UserResponse
-	for i := range users {
-		user := &users[i]
-		userResponses = append(userResponses, dtos.UserResponse{
+	resp := dtos.UserListResponse{
+		Users:      make([]dtos.UserResponse, 0, len(list.Users)),
+		Total:      list.Total,
+		NextCursor: list.NextCursor,
+	}
+	for i := range list.Users {
+		user := &list.Users[i]
+		resp.Users = append(resp.Users, dtos.UserResponse{
 			ID:       user.ID,
 			Username: user.Username,
 			Email:    user.Email,
@@ -38,7 +64,7 @@ func (uc *UserController) GetUsers(c *gin.Context) {
 		})
 	}
 
-	c.JSON(http.StatusOK, userResponses)
+	c.JSON(http.StatusOK, resp)
 }
 
 // GetUser 获取单个用户信息
@@ -87,10 +113,7 @@ func (uc *UserController) UpdateUser(c *gin.Context) {
 		RoleID:   req.RoleID,
 	}
 
-	currentUserID := c.GetUint("user_id")
-	currentUserRole, _ := c.Get("role")
-
-	user, err := uc.UserService.UpdateUser(uint(targetUserID), currentUserID, currentUserRole.(string), updateData)
+	user, err := uc.UserService.UpdateUser(uint(targetUserID), currentSubject(c), updateData)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -115,7 +138,7 @@ func (uc *UserController) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	err = uc.UserService.DeleteUser(uint(id))
+	err = uc.UserService.DeleteUser(uint(id), currentSubject(c))
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -123,3 +146,76 @@ func (uc *UserController) DeleteUser(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
+
+// GetDeletedUsers 获取已删除但尚未清除的用户
+func (uc *UserController) GetDeletedUsers(c *gin.Context) {
+	users, err := uc.UserService.GetDeletedUsers()
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	resp := make([]dtos.DeletedUserResponse, 0, len(users))
+	for i := range users {
+		user := &users[i]
+		resp = append(resp, dtos.DeletedUserResponse{
+			UserResponse: dtos.UserResponse{
+				ID:       user.ID,
+				Username: user.Username,
+				Email:    user.Email,
+				RoleID:   user.RoleID,
+				Role:     user.Role.Name,
+			},
+			DeletedAt: user.DeletedAt.Time,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RestoreUser 恢复已删除的用户
+func (uc *UserController) RestoreUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	user, err := uc.UserService.RestoreUser(uint(id), c.GetUint("user_id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dtos.UserResponse{
+		ID:       user.ID,
+		Username: user.Username,
+		Email:    user.Email,
+		RoleID:   user.RoleID,
+		Role:     user.Role.Name,
+	})
+}
+
+// PurgeUser 彻底清除已删除的用户及其数据，无法恢复
+func (uc *UserController) PurgeUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	if err := uc.UserService.PurgeUser(uint(id), c.GetUint("user_id")); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User purged successfully"})
+}
+
+// currentSubject 返回发起请求的用户及其在当前域中的角色，用于属性授权。
+func currentSubject(c *gin.Context) services.Subject {
+	return services.Subject{
+		ID:     c.GetUint("user_id"),
+		Role:   c.GetString("role"),
+		Domain: tenant.Domain(c.GetUint("tenant_id")),
+	}
+}
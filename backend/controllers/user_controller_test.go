@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/casbin/casbin/v2/model"
@@ -29,9 +30,12 @@ type MockUserService struct {
 	mock.Mock
 }
 
-func (m *MockUserService) GetUsers() ([]models.User, error) {
-	args := m.Called()
-	return args.Get(0).([]models.User), args.Error(1)
+func (m *MockUserService) GetUsers(opts services.UserListOptions) (*services.UserList, error) {
+	args := m.Called(opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*services.UserList), args.Error(1)
 }
 
 func (m *MockUserService) GetUser(id uint) (*models.User, error) {
@@ -39,13 +43,28 @@ func (m *MockUserService) GetUser(id uint) (*models.User, error) {
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserService) UpdateUser(targetUserID, currentUserID uint, currentUserRole string, updateUser *models.User) (*models.User, error) {
-	args := m.Called(targetUserID, currentUserID, currentUserRole, updateUser)
+func (m *MockUserService) UpdateUser(targetUserID uint, subject services.Subject, updateUser *models.User) (*models.User, error) {
+	args := m.Called(targetUserID, subject, updateUser)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserService) DeleteUser(id uint) error {
-	args := m.Called(id)
+func (m *MockUserService) DeleteUser(targetUserID uint, subject services.Subject) error {
+	args := m.Called(targetUserID, subject)
+	return args.Error(0)
+}
+
+func (m *MockUserService) GetDeletedUsers() ([]models.User, error) {
+	args := m.Called()
+	return args.Get(0).([]models.User), args.Error(1)
+}
+
+func (m *MockUserService) RestoreUser(id, adminID uint) (*models.User, error) {
+	args := m.Called(id, adminID)
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserService) PurgeUser(id, adminID uint) error {
+	args := m.Called(id, adminID)
 	return args.Error(0)
 }
 
@@ -65,6 +84,7 @@ func setupCorrectIsolatedUserRouter(e *casbin.Enforcer) (*gin.Engine, *MockUserS
 	}
 
 	router := gin.New()
+	router.Use(middleware.ErrorHandler())
 	router.Use(middleware.AuthMiddleware(cfg))
 	router.Use(middleware.CasbinMiddlewareWithEnforcer(e))
 
@@ -75,29 +95,37 @@ func setupCorrectIsolatedUserRouter(e *casbin.Enforcer) (*gin.Engine, *MockUserS
 
 func TestGetUsers_Endpoint_Success_Correct(t *testing.T) {
 	casbinModel, _ := model.NewModelFromString(`[request_definition]
-r = sub, obj, act
+r = sub, dom, obj, act
 [policy_definition]
-p = sub, obj, act
+p = sub, dom, obj, act
 [role_definition]
-g = _, _
+g = _, _, _
 [policy_effect]
 e = some(where (p.eft == allow))
 [matchers]
-m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act`)
+m = g(r.sub, p.sub, r.dom) && r.dom == p.dom && r.obj == p.obj && r.act == p.act`)
 	casbinEnforcer, _ := casbin.NewEnforcer(casbinModel)
 
-	_, err := casbinEnforcer.AddPolicy("admin", "/users", "GET")
+	_, err := casbinEnforcer.AddPolicy("admin", "global", "/users", "GET")
 	assert.NoError(t, err)
 
 	router, mockUserService, cfg := setupCorrectIsolatedUserRouter(casbinEnforcer)
 
 	mockedUsers := []models.User{{Model: gorm.Model{ID: 1}, Username: "user1"}}
-	mockUserService.On("GetUsers").Return(mockedUsers, nil)
+	createdAfter := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockUserService.On("GetUsers", services.UserListOptions{
+		Page:         2,
+		Size:         10,
+		Sort:         "-created_at",
+		Role:         "user",
+		Search:       "user",
+		CreatedAfter: &createdAfter,
+	}).Return(&services.UserList{Users: mockedUsers, Total: 11, NextCursor: "next"}, nil)
 
 	token, err := utils.GenerateToken(1, "admin", cfg)
 	assert.NoError(t, err)
 
-	req, _ := http.NewRequest(http.MethodGet, "/users", http.NoBody)
+	req, _ := http.NewRequest(http.MethodGet, "/users?page=2&size=10&sort=-created_at&role=user&q=user&created_after=2024-01-01T00:00:00Z", http.NoBody)
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
 
 	w := httptest.NewRecorder()
@@ -105,28 +133,30 @@ m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act`)
 
 	assert.Equal(t, http.StatusOK, w.Code)
 
-	var responseUsers []dtos.UserResponse
-	err = json.Unmarshal(w.Body.Bytes(), &responseUsers)
+	var response dtos.UserListResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Len(t, responseUsers, 1)
+	assert.Len(t, response.Users, 1)
+	assert.Equal(t, int64(11), response.Total)
+	assert.Equal(t, "next", response.NextCursor)
 	mockUserService.AssertExpectations(t)
 }
 
 func TestGetUsers_Endpoint_Forbidden_Correct(t *testing.T) {
 	casbinModel, _ := model.NewModelFromString(`[request_definition]
-r = sub, obj, act
+r = sub, dom, obj, act
 [policy_definition]
-p = sub, obj, act
+p = sub, dom, obj, act
 [role_definition]
-g = _, _
+g = _, _, _
 [policy_effect]
 e = some(where (p.eft == allow))
 [matchers]
-m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act`)
+m = g(r.sub, p.sub, r.dom) && r.dom == p.dom && r.obj == p.obj && r.act == p.act`)
 	casbinEnforcer, _ := casbin.NewEnforcer(casbinModel)
 
 	// Add policy for admin, but not for user
-	_, err := casbinEnforcer.AddPolicy("admin", "/users", "GET")
+	_, err := casbinEnforcer.AddPolicy("admin", "global", "/users", "GET")
 	assert.NoError(t, err)
 
 	router, _, cfg := setupCorrectIsolatedUserRouter(casbinEnforcer)
@@ -143,3 +173,31 @@ m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act`)
 
 	assert.Equal(t, http.StatusForbidden, w.Code)
 }
+
+func TestGetUsers_Endpoint_InvalidQuery(t *testing.T) {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // errors are logged by the error handler
+	casbinModel, _ := model.NewModelFromString(`[request_definition]
+r = sub, dom, obj, act
+[policy_definition]
+p = sub, dom, obj, act
+[policy_effect]
+e = some(where (p.eft == allow))
+[matchers]
+m = r.sub == p.sub && r.dom == p.dom && r.obj == p.obj && r.act == p.act`)
+	casbinEnforcer, _ := casbin.NewEnforcer(casbinModel)
+	_, err := casbinEnforcer.AddPolicy("admin", "global", "/users", "GET")
+	assert.NoError(t, err)
+
+	router, mockUserService, cfg := setupCorrectIsolatedUserRouter(casbinEnforcer)
+	token, err := utils.GenerateToken(1, "admin", cfg)
+	assert.NoError(t, err)
+
+	for _, query := range []string{"size=1000", "page=-1", "page=abc", "created_before=yesterday"} {
+		req, _ := http.NewRequest(http.MethodGet, "/users?"+query, http.NoBody)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	mockUserService.AssertNotCalled(t, "GetUsers", mock.Anything)
+}
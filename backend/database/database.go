@@ -3,14 +3,19 @@ package database
 // 负责处理数据库连接和初始化。
 
 import (
+	"fmt"
 	"go-web/config"
+	"go-web/migrations"
 	"go-web/models"
+	"go-web/tenant"
 	"log"
 	"time"
 
-	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
 // DB 是一个全局的*gorm.DB对象，用于在整个应用程序中访问数据库。
@@ -22,30 +27,72 @@ func NewPostgresDialector(dsn string) gorm.Dialector {
 	return postgres.Open(dsn)
 }
 
-// ConnectDB 使用提供的配置初始化数据库连接。
-// 它会执行以下操作：
-// 1. 使用配置中的DSN（数据源名称）打开一个到PostgreSQL数据库的连接。
-// 2. 如果连接失败，将记录致命错误并终止程序。
-// 3. 连接成功后，使用GORM的AutoMigrate功能自动迁移数据模型。
-//   - 这将确保数据库中的表结构与代码中定义的模型（User, Role, CasbinRule）保持一致。
-//
-// 4. 如果迁移失败，同样会记录致命错误并终止程序。
-func ConnectDB(cfg *config.Config) {
-	var err error
-
-	// 使用GORM和PostgreSQL驱动打开数据库连接
-	DB, err = gorm.Open(NewPostgresDialector(cfg.GetDSN()), &gorm.Config{})
+// NewDialector 根据 database.driver 创建对应数据库的 gorm.Dialector，未配置驱动时使用PostgreSQL。
+func NewDialector(cfg *config.Config) (gorm.Dialector, error) {
+	return newDialector(cfg.Database.Driver, cfg.GetDSN())
+}
+
+// newDialector 使用驱动 driver 和数据源名称 dsn 创建 gorm.Dialector，主库和只读副本共用。
+func newDialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case "", config.DatabaseDriverPostgres:
+		return NewPostgresDialector(dsn), nil
+	case config.DatabaseDriverMySQL:
+		return mysql.Open(dsn), nil
+	case config.DatabaseDriverSQLite:
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+}
+
+// replicaTables 是从只读副本读取的模型。其他表的数据通常在写入后马上被读取（如令牌和会话），仍然只使用主库。
+var replicaTables = []interface{}{&models.User{}, &models.Role{}}
+
+// useReplicas 注册 dbresolver 插件：replicaTables 的查询随机发往一个只读副本，写入、事务和其他表使用主库。
+// 需要读取刚刚写入的数据时，使用仓库的 Primary 方法（即 dbresolver.Write 子句）强制从主库读取。
+func useReplicas(db *gorm.DB, cfg *config.Config) error {
+	replicas := make([]gorm.Dialector, 0, len(cfg.Database.Replicas))
+	for _, dsn := range cfg.Database.Replicas {
+		dialector, err := newDialector(cfg.Database.Driver, dsn)
+		if err != nil {
+			return err
+		}
+		replicas = append(replicas, dialector)
+	}
+
+	resolver := dbresolver.Register(dbresolver.Config{Replicas: replicas, Policy: dbresolver.RandomPolicy{}}, replicaTables...).
+		SetMaxIdleConns(cfg.Database.MaxIdleConns).
+		SetMaxOpenConns(cfg.Database.MaxOpenConns).
+		SetConnMaxLifetime(time.Duration(cfg.Database.ConnMaxLifetime) * time.Minute)
+	if err := db.Use(resolver); err != nil {
+		return fmt.Errorf("无法连接到只读副本: %w", err)
+	}
+	return nil
+}
+
+// Open 使用提供的配置打开数据库连接，注册插件并配置连接池，但不执行迁移。
+func Open(cfg *config.Config) (*gorm.DB, error) {
+	dialector, err := NewDialector(cfg)
 	if err != nil {
-		// 如果连接失败，记录致命错误并退出程序
-		log.Fatal("无法连接到数据库: ", err)
+		return nil, err
 	}
 
-	log.Println("数据库连接成功")
+	// 使用GORM和配置的驱动打开数据库连接
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, fmt.Errorf("无法连接到数据库: %w", err)
+	}
+
+	// 注册租户插件，按上下文中的当前组织自动限定租户数据的查询和写入
+	if err := db.Use(tenant.Plugin{}); err != nil {
+		return nil, fmt.Errorf("无法注册租户插件: %w", err)
+	}
 
 	// 配置数据库连接池
-	sqlDB, err := DB.DB()
+	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatal("无法获取底层的sql.DB: ", err)
+		return nil, fmt.Errorf("无法获取底层的sql.DB: %w", err)
 	}
 	// 设置连接池中的最大空闲连接数
 	sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
@@ -54,13 +101,46 @@ func ConnectDB(cfg *config.Config) {
 	// 设置连接可被重用的最大时间
 	sqlDB.SetConnMaxLifetime(time.Duration(cfg.Database.ConnMaxLifetime) * time.Minute)
 
-	// 自动迁移数据模型，确保表结构与模型定义一致
-	// AutoMigrate 会创建或更新表以匹配 User, Role 和 CasbinRule 结构体
-	err = DB.AutoMigrate(&models.User{}, &models.Role{}, &gormadapter.CasbinRule{})
+	// 配置了只读副本时，把用户和角色的查询分流到副本
+	if len(cfg.Database.Replicas) > 0 {
+		if err := useReplicas(db, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// ConnectDB 使用提供的配置初始化全局的数据库连接 DB。
+// 如果配置了 database.migrate_on_start，连接成功后会执行所有尚未执行的迁移；
+// 多个实例同时启动时，迁移锁保证只有一个实例执行迁移，其他实例等待它完成。
+// 关闭该选项时需要在部署新版本之前运行 `go-web migrate up`。
+func ConnectDB(cfg *config.Config) error {
+	db, err := Open(cfg)
 	if err != nil {
-		// 如果迁移失败，记录致命错误并退出程序
-		log.Fatal("数据库迁移失败: ", err)
+		return err
+	}
+	DB = db
+	log.Println("数据库连接成功")
+
+	if !cfg.Database.MigrateOnStart {
+		return nil
 	}
+	return Migrate(DB)
+}
 
+// Migrate 执行所有尚未执行的迁移，并记录执行了哪些迁移。
+func Migrate(db *gorm.DB) error {
+	m, err := migrations.NewForDB(db)
+	if err != nil {
+		return err
+	}
+	applied, err := m.Up()
+	for _, migration := range applied {
+		log.Printf("已执行迁移 %04d_%s", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("数据库迁移失败: %w", err)
+	}
 	log.Println("数据库迁移完成")
+	return nil
 }
@@ -0,0 +1,137 @@
+package database
+
+import (
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestConnectDB_SQLite(t *testing.T) {
+	cfg := &config.Config{Database: config.DatabaseConfig{
+		Driver:         config.DatabaseDriverSQLite,
+		Path:           filepath.Join(t.TempDir(), "goweb.db"),
+		TimeZone:       "UTC",
+		MaxIdleConns:   1,
+		MaxOpenConns:   1,
+		MigrateOnStart: true,
+	}}
+	require.NoError(t, ConnectDB(cfg))
+	defer func() {
+		sqlDB, _ := DB.DB()
+		sqlDB.Close()
+	}()
+
+	assert.Equal(t, "sqlite", DB.Dialector.Name())
+	assert.True(t, DB.Migrator().HasTable("users"))
+	assert.True(t, DB.Migrator().HasTable("schema_migrations"))
+
+	// 外键约束已开启
+	assert.Error(t, DB.Exec("INSERT INTO users (username, email, password, role_id) VALUES ('alice', 'alice@example.com', 'x', 42)").Error)
+
+	// 再次启动时没有需要执行的迁移
+	require.NoError(t, Migrate(DB))
+}
+
+func TestNewDialector_UnsupportedDriver(t *testing.T) {
+	_, err := NewDialector(&config.Config{Database: config.DatabaseConfig{Driver: "oracle"}})
+	assert.ErrorContains(t, err, "oracle")
+
+	for _, driver := range []string{"", config.DatabaseDriverPostgres, config.DatabaseDriverMySQL, config.DatabaseDriverSQLite} {
+		dialector, err := NewDialector(&config.Config{Database: config.DatabaseConfig{Driver: driver}})
+		require.NoError(t, err)
+		assert.NotNil(t, dialector)
+	}
+}
+
+// TestOpen_RoutesReadsToReplicas 使用两个SQLite文件模拟主库和一个延迟的只读副本：
+// 只写入主库的角色在副本中不存在，由此可以看出每个查询发往了哪个数据库。
+func TestOpen_RoutesReadsToReplicas(t *testing.T) {
+	dir := t.TempDir()
+	replicaCfg := config.DatabaseConfig{Driver: config.DatabaseDriverSQLite, Path: filepath.Join(dir, "replica.db")}
+	replica, err := Open(&config.Config{Database: replicaCfg})
+	require.NoError(t, err)
+	require.NoError(t, Migrate(replica))
+	require.NoError(t, replica.Create(&models.Role{Name: "replicated"}).Error)
+
+	db, err := Open(&config.Config{Database: config.DatabaseConfig{
+		Driver:   config.DatabaseDriverSQLite,
+		Path:     filepath.Join(dir, "primary.db"),
+		Replicas: []string{replicaCfg.SQLiteDSN()},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, Migrate(db))
+
+	// 写入发往主库，随后的查询发往副本，看不到刚刚写入的角色
+	roles := repositories.NewGormRoleRepository(db)
+	require.NoError(t, roles.Create(&models.Role{Name: "editor"}))
+	_, err = roles.FindByName("editor")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = roles.FindByName("replicated")
+	assert.NoError(t, err)
+
+	// Primary 强制从主库读取，并且可以重复使用
+	primary := roles.Primary()
+	editor, err := primary.FindByName("editor")
+	require.NoError(t, err)
+	_, err = primary.FindByID(editor.ID)
+	assert.NoError(t, err)
+	_, err = primary.FindByName("replicated")
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// 事务中的查询总是在主库上执行
+	require.NoError(t, db.Transaction(func(tx *gorm.DB) error {
+		_, err := repositories.NewGormRoleRepository(tx).FindByName("editor")
+		return err
+	}))
+
+	// 只有用户和角色的查询使用副本
+	require.NoError(t, db.Create(&models.PolicyRevision{Revision: 7}).Error)
+	var revision models.PolicyRevision
+	assert.NoError(t, db.First(&revision).Error)
+}
+
+// TestOpen_PrimaryUserReadsForAuthentication 测试认证使用的 Primary 读取：预加载的角色同样来自主库，
+// 在主库中被删除的用户即使仍在副本中也查不到。
+func TestOpen_PrimaryUserReadsForAuthentication(t *testing.T) {
+	dir := t.TempDir()
+	replicaCfg := config.DatabaseConfig{Driver: config.DatabaseDriverSQLite, Path: filepath.Join(dir, "replica.db")}
+	replica, err := Open(&config.Config{Database: replicaCfg})
+	require.NoError(t, err)
+	require.NoError(t, Migrate(replica))
+
+	db, err := Open(&config.Config{Database: config.DatabaseConfig{
+		Driver:   config.DatabaseDriverSQLite,
+		Path:     filepath.Join(dir, "primary.db"),
+		Replicas: []string{replicaCfg.SQLiteDSN()},
+	}})
+	require.NoError(t, err)
+	require.NoError(t, Migrate(db))
+
+	// 副本还没有同步新角色和用户的降级
+	for _, target := range []*gorm.DB{db, replica} {
+		require.NoError(t, target.Create(&models.Role{Name: "user"}).Error)
+		require.NoError(t, target.Create(&models.User{Username: "alice", Email: "alice@example.com", Password: "x", RoleID: 1}).Error)
+	}
+	require.NoError(t, db.Create(&models.Role{Name: "suspended"}).Error)
+	require.NoError(t, db.Model(&models.User{}).Where("id = ?", 1).Update("role_id", 2).Error)
+
+	users := repositories.NewGormUserRepository(db)
+	stale, err := users.FindByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "user", stale.Role.Name)
+	current, err := users.Primary().FindByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "suspended", current.Role.Name)
+
+	require.NoError(t, db.Delete(&models.User{}, 1).Error)
+	_, err = users.FindByID(1)
+	assert.NoError(t, err)
+	_, err = users.Primary().FindByID(1)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
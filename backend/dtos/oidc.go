@@ -0,0 +1,23 @@
+package dtos
+
+import "time"
+
+// OIDCCallbackRequest 是身份提供方重定向回来时携带的查询参数
+type OIDCCallbackRequest struct {
+	Code             string `form:"code"`
+	State            string `form:"state" binding:"required"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
+
+// OIDCAuthorizationResponse 返回身份提供方的授权URL，前端应将浏览器重定向到该地址
+type OIDCAuthorizationResponse struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type UserIdentityResponse struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,43 @@
+package dtos
+
+import "time"
+
+type OrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+	Slug string `json:"slug" binding:"required,max=50"` // 小写字母、数字和连字符，如 acme-labs
+}
+
+type OrganizationResponse struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserOrganizationResponse 是当前用户加入的组织以及用户在其中的角色
+type UserOrganizationResponse struct {
+	OrganizationResponse
+	Role string `json:"role"`
+}
+
+type AddMemberRequest struct {
+	UserID uint   `json:"user_id" binding:"required"`
+	Role   string `json:"role" binding:"required"`
+}
+
+type UpdateMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type MemberResponse struct {
+	UserID   uint      `json:"user_id"`
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// SwitchTenantRequest 中 organization_id 为0或省略时切换回全局（不属于任何组织）
+type SwitchTenantRequest struct {
+	OrganizationID uint `json:"organization_id"`
+}
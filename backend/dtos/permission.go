@@ -0,0 +1,35 @@
+package dtos
+
+// PermissionsResponse 是当前用户的角色在当前组织（或全局）中的有效权限，包括继承得到的权限
+type PermissionsResponse struct {
+	Role        string               `json:"role"`
+	Domain      string               `json:"domain"`
+	Permissions []PermissionResponse `json:"permissions"`
+}
+
+// PermissionResponse 是一条可以访问的路由，Object 为路由模板或 "*"，Action 为HTTP方法或 "*"
+type PermissionResponse struct {
+	Object string `json:"object"`
+	Action string `json:"action"`
+}
+
+// CheckPermissionsRequest 是一组待检查的访问，Object 为实际的请求路径，如 /users/42
+type CheckPermissionsRequest struct {
+	Checks []PermissionCheck `json:"checks" binding:"required,min=1,max=100,dive"`
+}
+
+type PermissionCheck struct {
+	Object string `json:"object" binding:"required"`
+	Action string `json:"action" binding:"required"`
+}
+
+// CheckPermissionsResponse 按请求的顺序返回每一项访问是否允许
+type CheckPermissionsResponse struct {
+	Results []PermissionCheckResult `json:"results"`
+}
+
+type PermissionCheckResult struct {
+	Object  string `json:"object"`
+	Action  string `json:"action"`
+	Allowed bool   `json:"allowed"`
+}
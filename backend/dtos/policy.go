@@ -0,0 +1,12 @@
+package dtos
+
+// PolicyRequest 描述一条Casbin规则。PType 省略时访问策略默认为 "p"，角色继承关系默认为 "g"
+type PolicyRequest struct {
+	PType string   `json:"ptype"`
+	Rule  []string `json:"rule" binding:"required,min=1"`
+}
+
+type PolicyResponse struct {
+	PType string   `json:"ptype"`
+	Rule  []string `json:"rule"`
+}
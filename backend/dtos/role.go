@@ -0,0 +1,18 @@
+package dtos
+
+import "time"
+
+type RoleRequest struct {
+	Name        string   `json:"name" binding:"required,max=50"`
+	Description string   `json:"description" binding:"max=255"`
+	Parents     []string `json:"parents"` // 继承的角色名称，子角色拥有父角色的全部权限
+}
+
+type RoleResponse struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Parents     []string  `json:"parents"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
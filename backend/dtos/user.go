@@ -1,5 +1,7 @@
 package dtos
 
+import "time"
+
 type UserResponse struct {
 	ID       uint   `json:"id"`
 	Username string `json:"username"`
@@ -8,9 +10,29 @@ type UserResponse struct {
 	Role     string `json:"role"`
 }
 
+// UserListQuery 是用户列表的查询参数。cursor 为上一页返回的 next_cursor，设置时忽略 page；
+// sort 可以是 id、username、email 或 created_at，前缀 "-" 表示倒序；时间使用 RFC 3339 格式
+type UserListQuery struct {
+	Page          int       `form:"page" binding:"omitempty,min=1"`
+	Size          int       `form:"size" binding:"omitempty,min=1,max=100"`
+	Cursor        string    `form:"cursor"`
+	Sort          string    `form:"sort"`
+	Role          string    `form:"role"`
+	Search        string    `form:"q" binding:"max=100"`
+	CreatedAfter  time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
 type UserListResponse struct {
-	Users []UserResponse `json:"users"`
-	Total int64          `json:"total"`
+	Users      []UserResponse `json:"users"`
+	Total      int64          `json:"total"`
+	NextCursor string         `json:"next_cursor,omitempty"` // 为空表示没有更多用户
+}
+
+// DeletedUserResponse 是已删除但尚未清除的用户
+type DeletedUserResponse struct {
+	UserResponse
+	DeletedAt time.Time `json:"deleted_at"`
 }
 
 type UpdateUserRequest struct {
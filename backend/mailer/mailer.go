@@ -0,0 +1,119 @@
+package mailer
+
+// package mailer 提供了发送邮件的抽象。
+// 业务代码只依赖 Mailer 接口，具体使用日志/文件还是SMTP发送由配置决定。
+
+import (
+	"fmt"
+	"go-web/config"
+	"go-web/utils"
+	"mime"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Message 是一封待发送的纯文本邮件。
+type Message struct {
+	To      string // 收件人地址
+	Subject string // 邮件主题
+	Body    string // 纯文本正文
+}
+
+// Mailer 定义了发送邮件的接口。
+type Mailer interface {
+	// Send 发送一封邮件。
+	Send(msg Message) error
+}
+
+// NewMailer 根据配置创建对应的 Mailer 实现。
+func NewMailer(cfg config.MailConfig) (Mailer, error) {
+	switch cfg.Driver {
+	case "", "log":
+		return NewLogMailer(cfg.File), nil
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("mail.smtp_host is required for the smtp driver")
+		}
+		return NewSMTPMailer(cfg), nil
+	default:
+		return nil, fmt.Errorf("unsupported mail driver %q", cfg.Driver)
+	}
+}
+
+// LogMailer 不真正发送邮件，而是把邮件内容写入日志（以及可选的文件），用于开发和测试环境。
+type LogMailer struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewLogMailer 创建一个 LogMailer；path 为空时只写日志。
+func NewLogMailer(path string) *LogMailer {
+	return &LogMailer{path: path}
+}
+
+// Send 实现了 Mailer 接口。
+func (m *LogMailer) Send(msg Message) error {
+	if utils.Logger != nil {
+		utils.Logger.Info("mail sent",
+			zap.String("to", msg.To),
+			zap.String("subject", msg.Subject),
+			zap.String("body", msg.Body),
+		)
+	}
+
+	if m.path == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC1123Z), msg.To, msg.Subject, msg.Body)
+	return err
+}
+
+// SMTPMailer 通过SMTP服务器发送邮件。服务器支持时会自动使用STARTTLS。
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer 创建一个 SMTPMailer；未配置用户名时不进行认证。
+func NewSMTPMailer(cfg config.MailConfig) *SMTPMailer {
+	m := &SMTPMailer{
+		addr: fmt.Sprintf("%s:%d", cfg.SMTPHost, cfg.SMTPPort),
+		from: cfg.From,
+	}
+	if cfg.SMTPUsername != "" {
+		m.auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	return m
+}
+
+// Send 实现了 Mailer 接口。
+func (m *SMTPMailer) Send(msg Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(m.addr, m.auth, m.from, []string{msg.To}, []byte(b.String()))
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"go-web/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogMailer_WritesToFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	m := NewLogMailer(path)
+
+	err := m.Send(Message{To: "user@example.com", Subject: "Hello", Body: "first"})
+	require.NoError(t, err)
+	err = m.Send(Message{To: "user@example.com", Subject: "Hello again", Body: "second"})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "To: user@example.com")
+	assert.Contains(t, string(content), "first")
+	assert.Contains(t, string(content), "second")
+}
+
+func TestNewMailer(t *testing.T) {
+	m, err := NewMailer(config.MailConfig{Driver: "log"})
+	assert.NoError(t, err)
+	assert.IsType(t, &LogMailer{}, m)
+
+	m, err = NewMailer(config.MailConfig{Driver: "smtp", SMTPHost: "smtp.example.com", SMTPPort: 587})
+	assert.NoError(t, err)
+	assert.IsType(t, &SMTPMailer{}, m)
+
+	_, err = NewMailer(config.MailConfig{Driver: "smtp"})
+	assert.Error(t, err)
+
+	_, err = NewMailer(config.MailConfig{Driver: "carrier-pigeon"})
+	assert.Error(t, err)
+}
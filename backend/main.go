@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"go-web/config"
 	"go-web/database"
+	"go-web/middleware"
 	"go-web/routers"
 	"go-web/utils"
 	"log"
@@ -20,9 +21,24 @@ func main() {
 	// 加载配置
 	cfg := config.LoadConfig()
 
-	// 安全检查：确保JWT密钥已设置
-	if cfg.JWT.Secret == "" {
-		log.Fatal("FATAL: JWT secret is not configured. Please set 'jwt.secret' in config.yaml or environment variables.")
+	// 数据库迁移子命令：go-web migrate up|down|status
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(cfg, os.Args[2:]))
+	}
+
+	// 安全检查：确保JWT签名密钥已正确配置
+	if _, err := utils.LoadKeySet(cfg.JWT); err != nil {
+		log.Fatalf("FATAL: JWT signing keys are not configured correctly: %v. Please check the 'jwt' section in config.yaml or environment variables.", err)
+	}
+
+	// 安全检查：确保密码哈希算法和参数有效
+	if _, err := utils.NewPasswordHasher(cfg.Password); err != nil {
+		log.Fatalf("FATAL: password hashing is not configured correctly: %v. Please check the 'password' section in config.yaml.", err)
+	}
+
+	// 加载泄露密码列表，文件有误时尽早失败，而不是在用户注册时才报错
+	if _, err := utils.BreachedPasswordListFor(cfg); err != nil {
+		log.Fatalf("FATAL: unable to load the breached password list: %v. Please check 'password.breached_list_file' in config.yaml.", err)
 	}
 
 	// 初始化日志
@@ -37,7 +53,9 @@ func main() {
 	defer utils.SyncLogger()
 
 	// 连接数据库
-	database.ConnectDB(cfg)
+	if err := database.ConnectDB(cfg); err != nil {
+		log.Fatal(err)
+	}
 
 	// 设置路由
 	r := routers.SetupRouter(cfg)
@@ -72,5 +90,8 @@ func main() {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	// 停止监听其他实例的策略修改
+	middleware.CloseCasbin()
+
 	log.Println("Server exiting")
 }
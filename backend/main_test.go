@@ -9,6 +9,7 @@ import (
 	"go-web/dtos"
 	"go-web/models"
 	"go-web/routers"
+	"go-web/tenant"
 	"go-web/utils"
 	"net/http"
 	"net/http/httptest"
@@ -68,20 +69,7 @@ func setupTestConfig() *config.Config {
 			AllowedOrigins: []string{"*"},
 		},
 		Casbin: config.CasbinConfig{
-			Model: `[request_definition]
-    r = sub, obj, act
-    
-    [policy_definition]
-    p = sub, obj, act
-    
-    [role_definition]
-    g = _, _
-    
-    [policy_effect]
-    e = some(where (p.eft == allow))
-    
-    [matchers]
-    m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act`,
+			Model: config.DefaultCasbinModel,
 		},
 		Log: config.LogConfig{
 			Level: "debug",
@@ -114,9 +102,12 @@ func setupTestDatabase(cfg *config.Config) *gorm.DB {
 		panic(fmt.Sprintf("Failed to connect to test database: %v", err))
 	}
 
+	if err := testDB.Use(tenant.Plugin{}); err != nil {
+		panic(fmt.Sprintf("Failed to register tenant plugin: %v", err))
+	}
+
 	// Run migrations
-	err = testDB.AutoMigrate(&models.User{}, &models.Role{}, &gormadapter.CasbinRule{})
-	if err != nil {
+	if err := database.Migrate(testDB); err != nil {
 		panic(fmt.Sprintf("Failed to migrate database: %v", err))
 	}
 
@@ -3,16 +3,44 @@ package middleware
 import (
 	"go-web/config"
 	"go-web/database"
+	"go-web/services"
+	"go-web/tenant"
+	"go-web/utils"
 	"strings"
 
 	"github.com/casbin/casbin/v2"
 	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/persist"
 	gormadapter "github.com/casbin/gorm-adapter/v3"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"net/http"
 )
 
-var Enforcer *casbin.Enforcer
+// Enforcer is safe for concurrent use: checks hold a read lock while reloads triggered by the
+// policy watcher swap in the new policy under the write lock.
+var Enforcer *casbin.SyncedEnforcer
+
+// policyWatcher notifies other instances of policy changes, nil for single-instance deployments.
+var policyWatcher persist.Watcher
+
+// defaultPolicies 按策略类型列出默认策略，某一类型还没有任何策略时写入。
+// p 的字段为 sub, dom, obj, act：dom 是域（"global" 或 "org:<ID>"），可以使用 "*" 或 "org:*" 这样的模式；
+// 路径使用路由模板，由模型中的 keyMatch2 匹配实际请求路径。p2 是服务中使用的属性授权规则。
+// 这里新增默认规则时，已有策略的数据库不会写入，需要同时在迁移中插入（见 0004_casbin_domains 中的组织成员规则）
+var defaultPolicies = map[string][][]string{
+	"p": {
+		{"admin", tenant.GlobalDomain, "*", "*"},
+		{"user", tenant.AllDomains, "/users/:id", "GET"},
+		{"user", tenant.AllDomains, "/users/:id", "PUT"},
+		{"anonymous", tenant.AllDomains, "/auth/register", "POST"},
+		{"anonymous", tenant.AllDomains, "/auth/login", "POST"},
+		{"admin", "org:*", "/organizations/current/members", "*"},
+		{"admin", "org:*", "/organizations/current/members/:user_id", "*"},
+		{"user", "org:*", "/organizations/current/members", "GET"},
+	},
+	"p2": services.DefaultAuthorizationRules,
+}
 
 func InitCasbin(cfg *config.Config) error {
 	// 从配置中读取模型定义
@@ -34,36 +62,32 @@ func InitCasbin(cfg *config.Config) error {
 	}
 
 	// 创建Casbin执行器
-	e, err := casbin.NewEnforcer(m, a)
+	e, err := casbin.NewSyncedEnforcer(m, a)
 	if err != nil {
 		return err
 	}
 
+	// 角色继承关系中的域支持 "*" 这样的模式，必须在加载策略之前设置
+	tenant.ConfigureEnforcer(e.Enforcer)
+
 	// 加载策略
 	if err := e.LoadPolicy(); err != nil {
 		return err
 	}
 
-	// 添加默认策略（如果策略为空）
-	policies, err := e.GetPolicy()
-	if err != nil {
-		return err
-	}
-	if len(policies) == 0 {
-		if _, err := e.AddPolicy("admin", "*", "*"); err != nil {
-			return err
-		}
-		if _, err := e.AddPolicy("user", "/users/:id", "GET"); err != nil {
-			return err
+	// 添加默认策略（如果该类型的策略为空），模型中没有定义的类型跳过
+	for ptype, rules := range defaultPolicies {
+		if _, ok := e.GetModel()["p"][ptype]; !ok {
+			continue
 		}
-		if _, err := e.AddPolicy("user", "/users/:id", "PUT"); err != nil {
-			return err
-		}
-		if _, err := e.AddPolicy("anonymous", "/auth/register", "POST"); err != nil {
+		policies, err := e.GetNamedPolicy(ptype)
+		if err != nil {
 			return err
 		}
-		if _, err := e.AddPolicy("anonymous", "/auth/login", "POST"); err != nil {
-			return err
+		if len(policies) == 0 {
+			if _, err := e.AddNamedPolicies(ptype, rules); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -71,6 +95,44 @@ func InitCasbin(cfg *config.Config) error {
 	return nil
 }
 
+// WatchPolicy attaches a watcher to the enforcer: policy changes made through it notify the other
+// instances, and notifications from them reload the policy from the database.
+func WatchPolicy(w persist.Watcher) error {
+	if err := watchPolicy(Enforcer, w); err != nil {
+		return err
+	}
+	policyWatcher = w
+	return nil
+}
+
+// watchPolicy attaches w to e.
+func watchPolicy(e *casbin.SyncedEnforcer, w persist.Watcher) error {
+	if err := e.SetWatcher(w); err != nil {
+		return err
+	}
+	// SetWatcher installs a reload that bypasses the enforcer's lock, so replace it
+	return w.SetUpdateCallback(reloadPolicy(e))
+}
+
+// CloseCasbin stops the policy watcher, if any.
+func CloseCasbin() {
+	if policyWatcher != nil {
+		policyWatcher.Close()
+		policyWatcher = nil
+	}
+}
+
+// reloadPolicy returns a watcher callback that reloads the policy of e.
+func reloadPolicy(e *casbin.SyncedEnforcer) func(string) {
+	return func(source string) {
+		if err := e.LoadPolicy(); err != nil {
+			utils.Logger.Error("failed to reload casbin policy", zap.String("source", source), zap.Error(err))
+			return
+		}
+		utils.Logger.Info("casbin policy reloaded", zap.String("source", source))
+	}
+}
+
 func CasbinMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Use the global enforcer
@@ -80,7 +142,7 @@ func CasbinMiddleware() gin.HandlerFunc {
 }
 
 // CasbinMiddlewareWithEnforcer creates a middleware with a specific enforcer instance.
-func CasbinMiddlewareWithEnforcer(e *casbin.Enforcer) gin.HandlerFunc {
+func CasbinMiddlewareWithEnforcer(e casbin.IEnforcer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取用户角色，如果不存在则默认为anonymous
 		role, exists := c.Get("role")
@@ -88,8 +150,13 @@ func CasbinMiddlewareWithEnforcer(e *casbin.Enforcer) gin.HandlerFunc {
 			role = "anonymous"
 		}
 
-		// Check permission
-		ok, err := e.Enforce(role.(string), c.Request.URL.Path, c.Request.Method)
+		// Requests are checked in the domain of the active organisation, or the global domain
+		tenantID, _ := c.Get("tenant_id")
+		id, _ := tenantID.(uint)
+
+		// Check permission against the concrete path; policies use route templates
+		// such as /users/:id, which the model matches with keyMatch2
+		ok, err := e.Enforce(role.(string), tenant.Domain(id), c.Request.URL.Path, c.Request.Method)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurred when authorizing user"})
 			c.Abort()
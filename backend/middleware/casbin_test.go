@@ -0,0 +1,248 @@
+package middleware
+
+import (
+	"go-web/config"
+	"go-web/database"
+	"go-web/services"
+	"go-web/tenant"
+	"go-web/utils"
+	"go-web/watcher/watchertest"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// shippedCasbinModel reads the model from the config.yaml shipped with the application.
+func shippedCasbinModel(t *testing.T) string {
+	v := viper.New()
+	v.SetConfigFile("../config/config.yaml")
+	require.NoError(t, v.ReadInConfig())
+	model := v.GetString("casbin.model")
+	require.NotEmpty(t, model)
+	return model
+}
+
+// setupSeededCasbinRouter initialises Casbin with the seeded policies on an empty database and
+// returns a router that authorizes requests as the role given in the X-Role header, within the
+// organisation given in the X-Tenant header.
+func setupSeededCasbinRouter(t *testing.T, name, model string) *gin.Engine {
+	db, err := gorm.Open(sqlite.Open("file:casbin_"+name+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&gormadapter.CasbinRule{}))
+	db.Exec("DELETE FROM casbin_rule")
+
+	previousDB, previousEnforcer := database.DB, Enforcer
+	t.Cleanup(func() { database.DB, Enforcer = previousDB, previousEnforcer })
+	database.DB = db
+	require.NoError(t, InitCasbin(&config.Config{Casbin: config.CasbinConfig{Model: model}}))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if role := c.GetHeader("X-Role"); role != "" {
+			c.Set("role", role)
+		}
+		if id, err := strconv.Atoi(c.GetHeader("X-Tenant")); err == nil {
+			c.Set("tenant_id", uint(id))
+		}
+	})
+	router.Use(CasbinMiddleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/users/", ok)
+	router.GET("/users/:id", ok)
+	router.PUT("/users/:id", ok)
+	router.DELETE("/users/:id", ok)
+	router.POST("/auth/register", ok)
+	router.POST("/auth/login", ok)
+	router.GET("/admin/locks", ok)
+	router.DELETE("/roles/:id", ok)
+	router.GET("/organizations/current/members", ok)
+	router.POST("/organizations/current/members", ok)
+	router.DELETE("/organizations/current/members/:user_id", ok)
+	return router
+}
+
+func TestCasbinMiddleware_SeededPolicies(t *testing.T) {
+	models := map[string]string{
+		"default": config.DefaultCasbinModel,
+		"shipped": shippedCasbinModel(t),
+	}
+
+	cases := []struct {
+		role, method, path string
+		tenant             uint
+		want               int
+	}{
+		// admin, global, *, *
+		{"admin", http.MethodGet, "/admin/locks", 0, http.StatusOK},
+		{"admin", http.MethodDelete, "/roles/3", 0, http.StatusOK},
+		{"admin", http.MethodGet, "/users/", 0, http.StatusOK},
+		// user, *, /users/:id, GET and PUT
+		{"user", http.MethodGet, "/users/42", 0, http.StatusOK},
+		{"user", http.MethodPut, "/users/42", 0, http.StatusOK},
+		{"user", http.MethodDelete, "/users/42", 0, http.StatusForbidden},
+		{"user", http.MethodGet, "/users/", 0, http.StatusForbidden},
+		{"user", http.MethodGet, "/admin/locks", 0, http.StatusForbidden},
+		// anonymous, *, /auth/register and /auth/login, POST
+		{"", http.MethodPost, "/auth/register", 0, http.StatusOK},
+		{"", http.MethodPost, "/auth/login", 0, http.StatusOK},
+		{"", http.MethodGet, "/users/42", 0, http.StatusForbidden},
+		// Inside an organisation the admin role only manages members
+		{"admin", http.MethodPost, "/organizations/current/members", 3, http.StatusOK},
+		{"admin", http.MethodDelete, "/organizations/current/members/7", 3, http.StatusOK},
+		{"admin", http.MethodGet, "/admin/locks", 3, http.StatusForbidden},
+		{"admin", http.MethodDelete, "/roles/3", 3, http.StatusForbidden},
+		{"admin", http.MethodGet, "/users/", 3, http.StatusForbidden},
+		{"admin", http.MethodDelete, "/users/42", 3, http.StatusForbidden},
+		{"user", http.MethodGet, "/organizations/current/members", 3, http.StatusOK},
+		{"user", http.MethodPost, "/organizations/current/members", 3, http.StatusForbidden},
+		{"user", http.MethodGet, "/organizations/current/members", 0, http.StatusForbidden},
+		{"user", http.MethodGet, "/users/42", 3, http.StatusOK},
+	}
+
+	for name, model := range models {
+		t.Run(name, func(t *testing.T) {
+			router := setupSeededCasbinRouter(t, name, model)
+			for _, tc := range cases {
+				req, _ := http.NewRequest(tc.method, tc.path, http.NoBody)
+				if tc.role != "" {
+					req.Header.Set("X-Role", tc.role)
+				}
+				if tc.tenant != 0 {
+					req.Header.Set("X-Tenant", strconv.Itoa(int(tc.tenant)))
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, tc.want, w.Code, "%s %s %s in %d", tc.role, tc.method, tc.path, tc.tenant)
+			}
+		})
+	}
+}
+
+func TestInitCasbin_SeedsOnlyEmptyTable(t *testing.T) {
+	setupSeededCasbinRouter(t, "reseed", config.DefaultCasbinModel)
+	_, err := Enforcer.RemovePolicy("user", "*", "/users/:id", "PUT")
+	require.NoError(t, err)
+
+	// Restarting keeps the administrator's changes instead of seeding again
+	require.NoError(t, InitCasbin(&config.Config{Casbin: config.CasbinConfig{Model: config.DefaultCasbinModel}}))
+	policies, err := Enforcer.GetPolicy()
+	require.NoError(t, err)
+	assert.Len(t, policies, len(defaultPolicies["p"])-1)
+}
+
+func TestCasbinMiddleware_DomainGroupings(t *testing.T) {
+	router := setupSeededCasbinRouter(t, "domains", config.DefaultCasbinModel)
+	_, err := Enforcer.AddGroupingPolicy("moderator", "user", "*")
+	require.NoError(t, err)
+	_, err = Enforcer.AddGroupingPolicy("owner", "admin", "org:3")
+	require.NoError(t, err)
+
+	cases := []struct {
+		role, method, path string
+		tenant             uint
+		want               int
+	}{
+		// Groupings in the "*" domain apply everywhere
+		{"moderator", http.MethodGet, "/users/42", 0, http.StatusOK},
+		{"moderator", http.MethodGet, "/users/42", 5, http.StatusOK},
+		// Groupings in an organisation's domain apply only there
+		{"owner", http.MethodPost, "/organizations/current/members", 3, http.StatusOK},
+		{"owner", http.MethodPost, "/organizations/current/members", 5, http.StatusForbidden},
+		{"owner", http.MethodGet, "/admin/locks", 0, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req, _ := http.NewRequest(tc.method, tc.path, http.NoBody)
+		req.Header.Set("X-Role", tc.role)
+		if tc.tenant != 0 {
+			req.Header.Set("X-Tenant", strconv.Itoa(int(tc.tenant)))
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, tc.want, w.Code, "%s %s %s in %d", tc.role, tc.method, tc.path, tc.tenant)
+	}
+}
+
+func TestInitCasbin_SeedsAuthorizationRules(t *testing.T) {
+	setupSeededCasbinRouter(t, "abac", shippedCasbinModel(t))
+	authorizer := services.NewCasbinAuthorizer(Enforcer)
+	own := services.Resource{Type: services.ResourceUser, OwnerID: 1}
+	other := services.Resource{Type: services.ResourceUser, OwnerID: 2}
+
+	assert.NoError(t, authorizer.Authorize(services.Subject{ID: 1, Role: "user", Domain: tenant.GlobalDomain}, services.ActionUpdate, own))
+	assert.ErrorIs(t, authorizer.Authorize(services.Subject{ID: 1, Role: "user", Domain: tenant.GlobalDomain}, services.ActionUpdate, other), services.ErrPermissionDenied)
+	assert.ErrorIs(t, authorizer.Authorize(services.Subject{ID: 1, Role: "user", Domain: tenant.GlobalDomain}, services.ActionChangeRole, own), services.ErrPermissionDenied)
+	assert.NoError(t, authorizer.Authorize(services.Subject{ID: 1, Role: "admin", Domain: tenant.GlobalDomain}, services.ActionDelete, other))
+}
+
+func TestWatchPolicy_ReloadsOtherInstances(t *testing.T) {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // reloads are logged
+	cfg := &config.Config{Casbin: config.CasbinConfig{Model: config.DefaultCasbinModel}}
+
+	// Two instances sharing one database, connected by an in-process bus
+	router := setupSeededCasbinRouter(t, "watch", config.DefaultCasbinModel)
+	local := Enforcer
+	require.NoError(t, InitCasbin(cfg))
+	remote := Enforcer
+	Enforcer = local
+
+	bus := watchertest.NewBus()
+	localWatcher, remoteWatcher := bus.Watcher(), bus.Watcher()
+	require.NoError(t, watchPolicy(local, localWatcher))
+	require.NoError(t, watchPolicy(remote, remoteWatcher))
+
+	// A change made on the other instance reaches this one
+	_, err := remote.AddPolicy("auditor", tenant.GlobalDomain, "/admin/locks", "GET")
+	require.NoError(t, err)
+	assert.Equal(t, 1, remoteWatcher.Updates())
+	assert.Zero(t, localWatcher.Updates())
+
+	req, _ := http.NewRequest(http.MethodGet, "/admin/locks", http.NoBody)
+	req.Header.Set("X-Role", "auditor")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// And the other way round
+	_, err = local.RemovePolicy("user", tenant.AllDomains, "/users/:id", "PUT")
+	require.NoError(t, err)
+	allowed, err := remote.Enforce("user", tenant.GlobalDomain, "/users/42", "PUT")
+	require.NoError(t, err)
+	assert.False(t, allowed)
+}
+
+func TestWatchPolicy_ConcurrentReloads(t *testing.T) {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // reloads are logged
+	setupSeededCasbinRouter(t, "reload", config.DefaultCasbinModel)
+	reload := reloadPolicy(Enforcer)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				reload("test")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				allowed, err := Enforcer.Enforce("user", tenant.GlobalDomain, "/users/42", "GET")
+				assert.NoError(t, err)
+				assert.True(t, allowed)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -6,6 +6,7 @@ import (
 	"go-web/utils"
 	"gorm.io/gorm"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -14,9 +15,10 @@ import (
 
 // ErrorResponse represents a standardized error response format.
 type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Details string `json:"details,omitempty"`
+	Code       int                          `json:"code"`
+	Message    string                       `json:"message"`
+	Details    string                       `json:"details,omitempty"`
+	Violations []services.PasswordViolation `json:"violations,omitempty"`
 }
 
 // NewErrorResponse creates a new ErrorResponse instance.
@@ -37,10 +39,47 @@ func NewErrorResponseWithDetails(code int, message, details string) ErrorRespons
 }
 
 var errorMappings = map[error]int{
-	services.ErrPermissionDenied:        http.StatusForbidden,
-	&services.UserExistsError{}:         http.StatusConflict,
-	&services.InvalidCredentialsError{}: http.StatusUnauthorized,
-	gorm.ErrRecordNotFound:              http.StatusNotFound,
+	services.ErrPermissionDenied:         http.StatusForbidden,
+	&services.UserExistsError{}:          http.StatusConflict,
+	&services.InvalidCredentialsError{}:  http.StatusUnauthorized,
+	gorm.ErrRecordNotFound:               http.StatusNotFound,
+	services.ErrInvalidRefreshToken:      http.StatusUnauthorized,
+	services.ErrRefreshTokenReused:       http.StatusUnauthorized,
+	services.ErrInvalidResetToken:        http.StatusBadRequest,
+	services.ErrInvalidVerificationToken: http.StatusBadRequest,
+	services.ErrEmailNotVerified:         http.StatusForbidden,
+	services.ErrInvalidMFACode:           http.StatusUnauthorized,
+	services.ErrInvalidMFAToken:          http.StatusUnauthorized,
+	services.ErrMFAAlreadyEnabled:        http.StatusConflict,
+	services.ErrMFANotEnabled:            http.StatusBadRequest,
+	services.ErrAccountLocked:            http.StatusTooManyRequests,
+	services.ErrPasswordPolicy:           http.StatusBadRequest,
+	services.ErrIncorrectPassword:        http.StatusBadRequest,
+	services.ErrUnknownOIDCProvider:      http.StatusNotFound,
+	services.ErrInvalidOIDCState:         http.StatusBadRequest,
+	services.ErrOIDCAuthenticationFailed: http.StatusUnauthorized,
+	services.ErrOIDCEmailInUse:           http.StatusConflict,
+	services.ErrIdentityAlreadyLinked:    http.StatusConflict,
+	services.ErrInvalidAPIKey:            http.StatusUnauthorized,
+	services.ErrInvalidAPIKeyScope:       http.StatusBadRequest,
+	services.ErrInvalidAPIKeyExpiration:  http.StatusBadRequest,
+	services.ErrAPIKeyLimitReached:       http.StatusConflict,
+	services.ErrAPIKeyNotAllowed:         http.StatusForbidden,
+	services.ErrInvalidPolicy:            http.StatusBadRequest,
+	services.ErrPolicyExists:             http.StatusConflict,
+	services.ErrPolicyNotFound:           http.StatusNotFound,
+	services.ErrRoleExists:               http.StatusConflict,
+	services.ErrRoleInUse:                http.StatusConflict,
+	services.ErrRoleProtected:            http.StatusConflict,
+	services.ErrInvalidRoleName:          http.StatusBadRequest,
+	services.ErrInvalidRoleParent:        http.StatusBadRequest,
+	services.ErrNoActiveTenant:           http.StatusBadRequest,
+	services.ErrNotMember:                http.StatusForbidden,
+	services.ErrOrganizationExists:       http.StatusConflict,
+	services.ErrAlreadyMember:            http.StatusConflict,
+	services.ErrInvalidOrganizationSlug:  http.StatusBadRequest,
+	services.ErrInvalidUserQuery:         http.StatusBadRequest,
+	services.ErrInvalidCursor:            http.StatusBadRequest,
 }
 
 // ErrorHandler is a middleware to handle errors in a centralized way.
@@ -68,6 +107,12 @@ func ErrorHandler() gin.HandlerFunc {
 				}
 			}
 
+			// Tell clients when they may retry, e.g. after an account lockout
+			var retryable interface{ RetryAfterSeconds() int }
+			if errors.As(err, &retryable) {
+				c.Header("Retry-After", strconv.Itoa(retryable.RetryAfterSeconds()))
+			}
+
 			// Handle validation errors
 			if validationErrors, ok := err.(interface{ Errors() []error }); ok {
 				var errorMessages []string
@@ -84,7 +129,15 @@ func ErrorHandler() gin.HandlerFunc {
 				msg = "Invalid request format"
 			}
 
-			c.JSON(status, NewErrorResponse(status, msg))
+			resp := NewErrorResponse(status, msg)
+
+			// List every rule a rejected password violates
+			var policyErr *services.PasswordPolicyError
+			if errors.As(err, &policyErr) {
+				resp.Violations = policyErr.Violations
+			}
+
+			c.JSON(status, resp)
 		}
 	}
-}
\ No newline at end of file
+}
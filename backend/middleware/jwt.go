@@ -1,15 +1,73 @@
 package middleware
 
 import (
+	"errors"
 	"go-web/config"
+	"go-web/repositories"
+	"go-web/services"
+	"go-web/tenant"
 	"go-web/utils"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// sessionLastSeenResolution is how often the last-seen time of a session is written to the database.
+const sessionLastSeenResolution = time.Minute
+
+// authOptions holds the optional checks performed by AuthMiddleware.
+type authOptions struct {
+	revokedTokens repositories.RevokedTokenRepository
+	users         repositories.UserRepository
+	apiKeys       services.APIKeyServiceInterface
+	sessions      repositories.SessionRepository
+}
+
+// AuthOption configures optional behaviour of AuthMiddleware.
+type AuthOption func(*authOptions)
+
+// WithRevokedTokens makes AuthMiddleware reject tokens whose jti is on the revocation list.
+func WithRevokedTokens(repo repositories.RevokedTokenRepository) AuthOption {
+	return func(o *authOptions) {
+		o.revokedTokens = repo
+	}
+}
+
+// WithPasswordChanges makes AuthMiddleware reject tokens issued before the user last changed
+// or reset their password, as well as tokens of users that no longer exist.
+func WithPasswordChanges(repo repositories.UserRepository) AuthOption {
+	return func(o *authOptions) {
+		o.users = repo
+	}
+}
+
+// WithSessions makes AuthMiddleware reject tokens bound to a login session (the sid claim) that
+// has been revoked, and records when each session was last used.
+func WithSessions(repo repositories.SessionRepository) AuthOption {
+	return func(o *authOptions) {
+		o.sessions = repo
+	}
+}
+
+// WithAPIKeys makes AuthMiddleware accept personal API keys (bearer credentials starting with
+// services.APIKeyTokenPrefix) in addition to JWTs. Requests authenticated with an API key carry
+// the owner's current role, so the same Casbin checks apply, and are further limited by the key's scopes.
+func WithAPIKeys(service services.APIKeyServiceInterface) AuthOption {
+	return func(o *authOptions) {
+		o.apiKeys = service
+	}
+}
+
+func AuthMiddleware(cfg *config.Config, opts ...AuthOption) gin.HandlerFunc {
+	options := &authOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -25,15 +83,120 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := utils.ParseToken(tokenString, cfg.JWT.Secret)
+		if options.apiKeys != nil && services.IsAPIKey(tokenString) {
+			authenticateAPIKey(c, options.apiKeys, tokenString)
+			return
+		}
+
+		claims, err := utils.ValidateToken(tokenString, cfg)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 			c.Abort()
 			return
 		}
 
+		if options.revokedTokens != nil && claims.ID != "" {
+			revoked, err := options.revokedTokens.IsRevoked(claims.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurred when validating token"})
+				c.Abort()
+				return
+			}
+			if revoked {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+				c.Abort()
+				return
+			}
+		}
+
+		if options.users != nil {
+			changedAt, err := options.users.FindPasswordChangedAt(claims.UserID)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurred when validating token"})
+				}
+				c.Abort()
+				return
+			}
+			if changedAt != nil && (claims.IssuedAt == nil || claims.IssuedAt.Time.Before(*changedAt)) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token was issued before the password was changed"})
+				c.Abort()
+				return
+			}
+		}
+
+		if options.sessions != nil && claims.SessionID != 0 {
+			session, err := options.sessions.FindByID(claims.SessionID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurred when validating token"})
+				c.Abort()
+				return
+			}
+			if err != nil || session.RevokedAt != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+				c.Abort()
+				return
+			}
+			now := time.Now()
+			if now.Sub(session.LastSeenAt) > sessionLastSeenResolution {
+				// Failing to record activity must not fail the request
+				if err := options.sessions.Touch(session.ID, now, now.Add(-sessionLastSeenResolution)); err != nil {
+					utils.Logger.Warn("failed to record session activity", zap.Uint("session_id", session.ID), zap.Error(err))
+				}
+			}
+			c.Set("session_id", session.ID)
+		}
+
+		// Tokens issued for an organisation carry its ID; repositories scope tenant data by the request context
+		if claims.TenantID != 0 {
+			c.Set("tenant_id", claims.TenantID)
+			c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), claims.TenantID))
+		}
+
 		c.Set("user_id", claims.UserID)
 		c.Set("role", claims.Role)
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
+
+// authenticateAPIKey authenticates a request carrying a personal API key. Unlike access tokens,
+// API keys survive password changes and are only invalidated by deleting them or by expiry.
+func authenticateAPIKey(c *gin.Context, apiKeys services.APIKeyServiceInterface, token string) {
+	auth, err := apiKeys.Authenticate(token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidAPIKey) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error occurred when validating API key"})
+		}
+		c.Abort()
+		return
+	}
+
+	if !auth.Key.AllowsMethod(c.Request.Method) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key does not have the required scope"})
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", auth.User.ID)
+	c.Set("role", auth.Role)
+	c.Set("api_key_id", auth.Key.ID)
+	c.Next()
+}
+
+// RejectAPIKeys aborts requests authenticated with an API key. It guards endpoints that need an
+// interactive session, such as managing API keys, so that a leaked key cannot mint new keys.
+func RejectAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, viaAPIKey := c.Get("api_key_id"); viaAPIKey {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This operation cannot be performed with an API key"})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"fmt"
+	"go-web/config"
+	"go-web/mocks"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/services"
+	"go-web/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func setupAuthMiddlewareRouter(cfg *config.Config, opts ...AuthOption) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AuthMiddleware(cfg, opts...))
+	router.GET("/protected", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetUint("user_id")})
+	})
+	return router
+}
+
+func TestAuthMiddleware_RejectsRevokedToken(t *testing.T) {
+	cfg := &config.Config{
+		JWT: config.JWTConfig{
+			Secret:     "middleware-test-secret",
+			Expiration: 60,
+		},
+	}
+	revokedTokens := repositories.NewMemoryRevokedTokenRepository()
+	router := setupAuthMiddlewareRouter(cfg, WithRevokedTokens(revokedTokens))
+
+	token, err := utils.GenerateToken(1, "user", cfg)
+	assert.NoError(t, err)
+
+	request := func() int {
+		req, _ := http.NewRequest(http.MethodGet, "/protected", http.NoBody)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// The token is accepted until it is revoked
+	assert.Equal(t, http.StatusOK, request())
+
+	claims, err := utils.ValidateToken(token, cfg)
+	assert.NoError(t, err)
+	assert.NoError(t, revokedTokens.Revoke(claims.ID, claims.ExpiresAt.Time))
+
+	assert.Equal(t, http.StatusUnauthorized, request())
+}
+
+func TestAuthMiddleware_RejectsTokenIssuedBeforePasswordChange(t *testing.T) {
+	cfg := &config.Config{
+		JWT: config.JWTConfig{
+			Secret:     "middleware-test-secret",
+			Expiration: 60,
+		},
+	}
+	users := new(mocks.MockUserRepository)
+	router := setupAuthMiddlewareRouter(cfg, WithPasswordChanges(users))
+
+	request := func(userID uint) int {
+		token, err := utils.GenerateToken(userID, "user", cfg)
+		assert.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodGet, "/protected", http.NoBody)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	changedEarlier := time.Now().Add(-time.Hour)
+	changedLater := time.Now().Add(time.Hour)
+	users.On("FindPasswordChangedAt", uint(1)).Return(nil, nil)
+	users.On("FindPasswordChangedAt", uint(2)).Return(&changedEarlier, nil)
+	users.On("FindPasswordChangedAt", uint(3)).Return(&changedLater, nil)
+	users.On("FindPasswordChangedAt", uint(4)).Return(nil, gorm.ErrRecordNotFound)
+
+	assert.Equal(t, http.StatusOK, request(1), "password never changed")
+	assert.Equal(t, http.StatusOK, request(2), "token issued after the change")
+	assert.Equal(t, http.StatusUnauthorized, request(3), "token issued before the change")
+	assert.Equal(t, http.StatusUnauthorized, request(4), "user no longer exists")
+}
+
+func TestMemoryRevokedTokenRepository_PurgeExpired(t *testing.T) {
+	repo := repositories.NewMemoryRevokedTokenRepository()
+	assert.NoError(t, repo.Revoke("expired", time.Now().Add(-time.Minute)))
+	assert.NoError(t, repo.Revoke("active", time.Now().Add(time.Minute)))
+
+	purged, err := repo.PurgeExpired()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	revoked, _ := repo.IsRevoked("expired")
+	assert.False(t, revoked)
+	revoked, _ = repo.IsRevoked("active")
+	assert.True(t, revoked)
+}
+
+// stubAPIKeyService accepts a single API key with the given scopes.
+type stubAPIKeyService struct {
+	services.APIKeyServiceInterface
+	token  string
+	scopes string
+}
+
+func (s *stubAPIKeyService) Authenticate(token string) (*services.APIKeyAuthentication, error) {
+	if token != s.token {
+		return nil, services.ErrInvalidAPIKey
+	}
+	return &services.APIKeyAuthentication{
+		Key:  &models.APIKey{ID: 7, UserID: 3, Scopes: s.scopes},
+		User: &models.User{Model: gorm.Model{ID: 3}},
+		Role: "user",
+	}, nil
+}
+
+func TestAuthMiddleware_AcceptsAPIKey(t *testing.T) {
+	cfg := &config.Config{
+		JWT: config.JWTConfig{
+			Secret:     "middleware-test-secret",
+			Expiration: 60,
+		},
+	}
+	apiKey := services.APIKeyTokenPrefix + "0123456789ab_secret"
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(AuthMiddleware(cfg, WithAPIKeys(&stubAPIKeyService{token: apiKey, scopes: models.APIKeyScopeRead})))
+	handler := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"user_id": c.GetUint("user_id"), "role": c.GetString("role")})
+	}
+	router.GET("/protected", handler)
+	router.POST("/protected", handler)
+	router.GET("/tokens", RejectAPIKeys(), handler)
+
+	request := func(method, path, credential string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, http.NoBody)
+		req.Header.Set("Authorization", "Bearer "+credential)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request(http.MethodGet, "/protected", apiKey)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id": 3, "role": "user"}`, w.Body.String())
+
+	// A read-only key cannot be used for writes
+	assert.Equal(t, http.StatusForbidden, request(http.MethodPost, "/protected", apiKey).Code)
+	// Endpoints that need an interactive session reject API keys
+	assert.Equal(t, http.StatusForbidden, request(http.MethodGet, "/tokens", apiKey).Code)
+	assert.Equal(t, http.StatusUnauthorized, request(http.MethodGet, "/protected", services.APIKeyTokenPrefix+"0123456789ab_wrong").Code)
+
+	// JWTs keep working alongside API keys
+	token, err := utils.GenerateToken(1, "user", cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, request(http.MethodPost, "/protected", token).Code)
+	assert.Equal(t, http.StatusOK, request(http.MethodGet, "/tokens", token).Code)
+}
+
+// stubSessionRepository serves a fixed set of sessions.
+type stubSessionRepository struct {
+	repositories.SessionRepository
+	sessions map[uint]*models.Session
+}
+
+func (r *stubSessionRepository) FindByID(id uint) (*models.Session, error) {
+	session, ok := r.sessions[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return session, nil
+}
+
+func (r *stubSessionRepository) Touch(id uint, seenAt, staleBefore time.Time) error {
+	r.sessions[id].LastSeenAt = seenAt
+	return nil
+}
+
+func TestAuthMiddleware_RejectsRevokedSession(t *testing.T) {
+	cfg := &config.Config{
+		JWT: config.JWTConfig{
+			Secret:     "middleware-test-secret",
+			Expiration: 60,
+		},
+	}
+	revokedAt := time.Now()
+	sessions := &stubSessionRepository{sessions: map[uint]*models.Session{
+		1: {ID: 1, UserID: 1, LastSeenAt: time.Now().Add(-time.Hour)},
+		2: {ID: 2, UserID: 1, RevokedAt: &revokedAt},
+	}}
+	router := setupAuthMiddlewareRouter(cfg, WithSessions(sessions))
+
+	request := func(sessionID uint) int {
+		token, err := utils.GenerateSessionToken(1, "user", sessionID, cfg)
+		assert.NoError(t, err)
+		req, _ := http.NewRequest(http.MethodGet, "/protected", http.NoBody)
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request(1))
+	assert.WithinDuration(t, time.Now(), sessions.sessions[1].LastSeenAt, time.Minute)
+	assert.Equal(t, http.StatusUnauthorized, request(2))
+	assert.Equal(t, http.StatusUnauthorized, request(3))
+	// Tokens issued before sessions existed carry no sid and are still accepted
+	assert.Equal(t, http.StatusOK, request(0))
+}
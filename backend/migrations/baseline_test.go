@@ -0,0 +1,148 @@
+package migrations
+
+import (
+	"go-web/models"
+	"strings"
+	"testing"
+	"time"
+
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// baselineModels 是所有迁移执行之后需要存在的全部模型。
+var baselineModels = []interface{}{
+	&models.User{}, &models.Role{}, &models.RefreshToken{}, &models.RevokedToken{}, &models.PasswordResetToken{},
+	&models.TOTPCredential{}, &models.RecoveryCode{}, &models.MFAChallenge{}, &models.LoginFailure{}, &models.PasswordHistory{},
+	&models.UserIdentity{}, &models.OIDCLoginState{}, &models.APIKey{}, &models.Session{}, &models.Organization{},
+	&models.Membership{}, &models.PolicyRevision{}, &gormadapter.CasbinRule{},
+}
+
+// TestBaseline_SQLiteMatchesModels 测试SQLite迁移创建了模型需要的所有表、列和索引，并且可以完整回滚。
+func TestBaseline_SQLiteMatchesModels(t *testing.T) {
+	db := newTestDB(t, "migrations_baseline")
+	m, err := NewForDB(db)
+	require.NoError(t, err)
+	_, err = m.Up()
+	require.NoError(t, err)
+
+	for _, model := range baselineModels {
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(model))
+		table := stmt.Schema.Table
+		require.True(t, db.Migrator().HasTable(table), table)
+		for _, column := range stmt.Schema.DBNames {
+			assert.True(t, db.Migrator().HasColumn(model, column), "%s.%s", table, column)
+		}
+		for _, index := range stmt.Schema.ParseIndexes() {
+			assert.True(t, db.Migrator().HasIndex(model, index.Name), "%s: %s", table, index.Name)
+		}
+	}
+
+	// 已删除的用户不占用用户名
+	require.NoError(t, db.Exec("INSERT INTO roles (name) VALUES ('user')").Error)
+	require.NoError(t, db.Exec("INSERT INTO users (username, email, password, role_id, deleted_at) VALUES ('alice', 'alice@example.com', 'x', 1, CURRENT_TIMESTAMP)").Error)
+	require.NoError(t, db.Exec("INSERT INTO users (username, email, password, role_id) VALUES ('alice', 'alice@example.com', 'x', 1)").Error)
+	assert.Error(t, db.Exec("INSERT INTO users (username, email, password, role_id) VALUES ('alice', 'other@example.com', 'x', 1)").Error)
+
+	// 回滚所有迁移，最后回滚的是基线。基线的唯一索引包含已删除的用户，回滚之前需要先清除它们
+	require.NoError(t, db.Exec("DELETE FROM users WHERE deleted_at IS NOT NULL").Error)
+	for {
+		reverted, err := m.Down()
+		require.NoError(t, err)
+		if reverted.Version == 1 {
+			break
+		}
+	}
+	for _, model := range baselineModels {
+		assert.False(t, db.Migrator().HasTable(model))
+	}
+}
+
+// TestBaseline_AllDialectsCreateEveryTable 测试每种数据库的迁移都创建并删除了所有模型的表。
+// PostgreSQL 和 MySQL 的迁移无法在单元测试中执行，这里只检查SQL文本。
+func TestBaseline_AllDialectsCreateEveryTable(t *testing.T) {
+	db := newTestDB(t, "migrations_baseline_dialects")
+	for _, dialect := range []string{"postgres", "mysql", "sqlite"} {
+		source, err := Source(dialect)
+		require.NoError(t, err)
+		migrations, err := Load(source)
+		require.NoError(t, err)
+		var up, down strings.Builder
+		for _, migration := range migrations {
+			up.WriteString(migration.Up)
+			down.WriteString(migration.Down)
+		}
+
+		for _, model := range baselineModels {
+			stmt := &gorm.Statement{DB: db}
+			require.NoError(t, stmt.Parse(model))
+			table := stmt.Schema.Table
+			assert.Regexp(t, `CREATE TABLE (IF NOT EXISTS )?`+table+` \(`, up.String(), "%s: %s", dialect, table)
+			assert.Contains(t, down.String(), "DROP TABLE IF EXISTS "+table+";", "%s: %s", dialect, table)
+		}
+	}
+}
+
+// releasedRole 和 releasedUser 是引入版本化迁移之前发布的版本中的模型，这些版本使用 AutoMigrate 创建表。
+type releasedRole struct {
+	gorm.Model
+	Name        string `gorm:"uniqueIndex;not null"`
+	Description string
+}
+
+func (releasedRole) TableName() string { return "roles" }
+
+type releasedUser struct {
+	gorm.Model
+	Username string `gorm:"uniqueIndex;not null"`
+	Email    string `gorm:"uniqueIndex;not null"`
+	Password string `gorm:"not null"`
+	RoleID   uint   `gorm:"not null"`
+	Role     releasedRole
+}
+
+func (releasedUser) TableName() string { return "users" }
+
+// TestBaseline_UpgradesAutoMigrateSchema 测试由发布版本的 AutoMigrate 创建的数据库执行迁移之后与模型一致，
+// 已有的用户可以正常读取。
+func TestBaseline_UpgradesAutoMigrateSchema(t *testing.T) {
+	db := newTestDB(t, "migrations_baseline_upgrade")
+	require.NoError(t, db.AutoMigrate(&releasedRole{}, &releasedUser{}, &gormadapter.CasbinRule{}))
+	role := releasedRole{Name: "user"}
+	require.NoError(t, db.Create(&role).Error)
+	require.NoError(t, db.Create(&releasedUser{Username: "alice", Email: "alice@example.com", Password: "x", RoleID: role.ID}).Error)
+
+	m, err := NewForDB(db)
+	require.NoError(t, err)
+	_, err = m.Up()
+	require.NoError(t, err)
+
+	for _, model := range baselineModels {
+		stmt := &gorm.Statement{DB: db}
+		require.NoError(t, stmt.Parse(model))
+		table := stmt.Schema.Table
+		require.True(t, db.Migrator().HasTable(table), table)
+		for _, column := range stmt.Schema.DBNames {
+			assert.True(t, db.Migrator().HasColumn(model, column), "%s.%s", table, column)
+		}
+		for _, index := range stmt.Schema.ParseIndexes() {
+			assert.True(t, db.Migrator().HasIndex(model, index.Name), "%s: %s", table, index.Name)
+		}
+	}
+	assert.False(t, db.Migrator().HasIndex("users", "idx_users_username"))
+
+	// 已有的用户被视为已验证邮箱，登录和认证中间件读取的列都存在
+	var user models.User
+	require.NoError(t, db.Preload("Role").Where("username = ?", "alice").First(&user).Error)
+	assert.True(t, user.IsEmailVerified())
+	assert.Nil(t, user.PasswordChangedAt)
+	require.NoError(t, db.Select("id", "password_changed_at").First(&models.User{}, user.ID).Error)
+
+	// 删除之后用户名可以被新用户使用
+	require.NoError(t, db.Delete(&user).Error)
+	require.NoError(t, db.Create(&models.User{Username: "alice", Email: "alice@example.com", Password: "x", RoleID: role.ID}).Error)
+	require.NoError(t, db.Create(&models.RefreshToken{UserID: user.ID, TokenHash: "hash", FamilyID: "family", ExpiresAt: time.Now()}).Error)
+}
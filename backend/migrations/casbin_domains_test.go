@@ -0,0 +1,141 @@
+package migrations
+
+import (
+	"go-web/config"
+	"go-web/tenant"
+	"io/fs"
+	"testing"
+	"testing/fstest"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// migrationsBefore 返回版本小于 version 的SQLite迁移文件，用于构造升级之前的数据库。
+func migrationsBefore(t *testing.T, version int64) fstest.MapFS {
+	source, err := Source("sqlite")
+	require.NoError(t, err)
+	files := fstest.MapFS{}
+	entries, err := fs.ReadDir(source, ".")
+	require.NoError(t, err)
+	for _, entry := range entries {
+		if v, _, _, err := parseFilename(entry.Name()); err == nil && v < version {
+			data, err := fs.ReadFile(source, entry.Name())
+			require.NoError(t, err)
+			files[entry.Name()] = &fstest.MapFile{Data: data}
+		}
+	}
+	return files
+}
+
+// newDomainEnforcer 使用默认模型加载数据库中的策略。
+func newDomainEnforcer(t *testing.T, db *gorm.DB) *casbin.Enforcer {
+	m, err := model.NewModelFromString(config.DefaultCasbinModel)
+	require.NoError(t, err)
+	adapter, err := gormadapter.NewAdapterByDB(db)
+	require.NoError(t, err)
+	e, err := casbin.NewEnforcer(m, adapter)
+	require.NoError(t, err)
+	tenant.ConfigureEnforcer(e)
+	require.NoError(t, e.LoadPolicy())
+	return e
+}
+
+// TestCasbinDomains_MigratesLegacyPolicies 测试没有域的旧策略在迁移之后按原来的含义生效。
+func TestCasbinDomains_MigratesLegacyPolicies(t *testing.T) {
+	db := newTestDB(t, "migrations_casbin_domains")
+	previous, err := New(db, migrationsBefore(t, 4))
+	require.NoError(t, err)
+	_, err = previous.Up()
+	require.NoError(t, err)
+
+	// 引入组织之前的策略：p = sub, obj, act；p2 = sub, obj, act, rule；g = role, parent
+	legacy := []gormadapter.CasbinRule{
+		{Ptype: "p", V0: "admin", V1: "*", V2: "*"},
+		{Ptype: "p", V0: "user", V1: "/users/:id", V2: "GET"},
+		{Ptype: "p", V0: "anonymous", V1: "/auth/login", V2: "POST"},
+		{Ptype: "p", V0: "editor", V1: "/articles", V2: "POST"},
+		{Ptype: "p2", V0: "admin", V1: "user", V2: "update", V3: "true"},
+		{Ptype: "p2", V0: "user", V1: "user", V2: "update", V3: "r2.sub.ID == r2.obj.OwnerID"},
+		{Ptype: "g", V0: "senior", V1: "editor"},
+		// 升级之后已经按新格式添加的规则保持不变，与之重复的旧规则被删除
+		{Ptype: "p", V0: "user", V1: tenant.AllDomains, V2: "/users/:id", V3: "GET"},
+		{Ptype: "p", V0: "editor", V1: "org:*", V2: "/drafts", V3: "GET"},
+	}
+	require.NoError(t, db.Create(&legacy).Error)
+
+	m, err := NewForDB(db)
+	require.NoError(t, err)
+	applied, err := m.Up()
+	require.NoError(t, err)
+	require.NotEmpty(t, applied)
+	assert.Equal(t, "casbin_domains", applied[0].Name)
+
+	e := newDomainEnforcer(t, db)
+	policies, err := e.GetPolicy()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, [][]string{
+		{"admin", tenant.GlobalDomain, "*", "*"},
+		{"user", tenant.AllDomains, "/users/:id", "GET"},
+		{"anonymous", tenant.AllDomains, "/auth/login", "POST"},
+		{"editor", tenant.AllDomains, "/articles", "POST"},
+		{"editor", "org:*", "/drafts", "GET"},
+		// 引入组织时新增的默认规则
+		{"admin", "org:*", "/organizations/current/members", "*"},
+		{"admin", "org:*", "/organizations/current/members/:user_id", "*"},
+		{"user", "org:*", "/organizations/current/members", "GET"},
+	}, policies)
+	rules, err := e.GetNamedPolicy("p2")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, [][]string{
+		{"admin", tenant.GlobalDomain, "user", "update", "true"},
+		{"user", tenant.AllDomains, "user", "update", "r2.sub.ID == r2.obj.OwnerID"},
+	}, rules)
+
+	cases := []struct {
+		sub, dom, obj, act string
+		want               bool
+	}{
+		{"admin", tenant.GlobalDomain, "/roles/3", "DELETE", true},
+		{"admin", tenant.Domain(3), "/roles/3", "DELETE", false},
+		{"user", tenant.Domain(3), "/users/42", "GET", true},
+		{"admin", tenant.Domain(3), "/organizations/current/members/7", "DELETE", true},
+		{"user", tenant.Domain(3), "/organizations/current/members", "GET", true},
+		{"user", tenant.Domain(3), "/organizations/current/members", "POST", false},
+		{"anonymous", tenant.GlobalDomain, "/auth/login", "POST", true},
+		// 旧的继承关系在所有域中生效
+		{"senior", tenant.GlobalDomain, "/articles", "POST", true},
+		{"senior", tenant.Domain(3), "/drafts", "GET", true},
+		{"senior", tenant.GlobalDomain, "/drafts", "GET", false},
+	}
+	for _, tc := range cases {
+		ok, err := e.Enforce(tc.sub, tc.dom, tc.obj, tc.act)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, ok, "%s %s %s %s", tc.sub, tc.dom, tc.obj, tc.act)
+	}
+
+	// 再次执行时新格式的规则不受影响
+	require.NoError(t, db.Exec("DELETE FROM schema_migrations WHERE version = 4").Error)
+	_, err = m.Up()
+	require.NoError(t, err)
+	again, err := newDomainEnforcer(t, db).GetPolicy()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, policies, again)
+}
+
+// TestCasbinDomains_LeavesEmptyTable 测试新安装的数据库中迁移不写入任何规则，默认策略由程序启动时写入。
+func TestCasbinDomains_LeavesEmptyTable(t *testing.T) {
+	db := newTestDB(t, "migrations_casbin_domains_empty")
+	m, err := NewForDB(db)
+	require.NoError(t, err)
+	_, err = m.Up()
+	require.NoError(t, err)
+
+	var count int64
+	require.NoError(t, db.Model(&gormadapter.CasbinRule{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// advisoryLockKey 是PostgreSQL中迁移使用的 advisory lock 键，所有实例使用同一个值。
+const advisoryLockKey int64 = 0x676f776562 // "goweb"
+
+// lockName 是MySQL中迁移使用的命名锁。
+const lockName = "goweb.schema_migrations"
+
+// lock 在 conn 所在的数据库连接上获取迁移锁，其他实例会等待锁被释放。返回的函数释放锁。
+// 锁属于会话，因此 conn 必须固定在同一个连接上（见 gorm.DB.Connection）。
+// SQLite 不加锁：写事务本身是互斥的，并且通常只有一个进程访问数据库文件。
+func lock(conn *gorm.DB) (func(), error) {
+	switch conn.Dialector.Name() {
+	case "postgres":
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", advisoryLockKey).Error; err != nil {
+			return nil, err
+		}
+		return func() {
+			if err := conn.Exec("SELECT pg_advisory_unlock(?)", advisoryLockKey).Error; err != nil {
+				// 连接关闭时锁也会被释放
+				log.Println("无法释放迁移锁: ", err)
+			}
+		}, nil
+	case "mysql":
+		// 超时时间为负数表示一直等待；返回1表示获得了锁
+		var acquired int
+		if err := conn.Raw("SELECT GET_LOCK(?, -1)", lockName).Scan(&acquired).Error; err != nil {
+			return nil, err
+		}
+		if acquired != 1 {
+			return nil, fmt.Errorf("GET_LOCK(%q) returned %d", lockName, acquired)
+		}
+		return func() {
+			var released int
+			if err := conn.Raw("SELECT RELEASE_LOCK(?)", lockName).Scan(&released).Error; err != nil {
+				log.Println("无法释放迁移锁: ", err)
+			}
+		}, nil
+	default:
+		return func() {}, nil
+	}
+}
@@ -0,0 +1,314 @@
+package migrations
+
+// package migrations 管理数据库表结构的版本。
+// 迁移是嵌入到程序中的SQL文件，按数据库方言放在不同的目录下，文件名为 <版本>_<名称>.up.sql 和 <版本>_<名称>.down.sql。
+// 已执行的版本记录在 schema_migrations 表中；每个迁移和它的版本记录在同一个事务中提交。
+// 执行迁移之前先获取数据库锁（PostgreSQL 使用 advisory lock，MySQL 使用 GET_LOCK），多个实例同时启动时只有一个会执行迁移。
+// 注意MySQL中的DDL语句会隐式提交事务，迁移在中途失败时已经执行的语句不会回滚，编写MySQL迁移时应尽量让每个文件只做一件事。
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed postgres/*.sql mysql/*.sql sqlite/*.sql
+var embedded embed.FS
+
+// ErrNoMigrations 表示没有可以回滚的迁移。
+var ErrNoMigrations = errors.New("no migrations have been applied")
+
+// ErrIrreversible 表示迁移没有对应的 down 文件，不能回滚。
+var ErrIrreversible = errors.New("migration cannot be reverted")
+
+// tableName 是记录已执行版本的表。
+const tableName = "schema_migrations"
+
+// Migration 是一个版本的迁移。
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string // 升级的SQL
+	Down    string // 回滚的SQL，为空表示不能回滚
+}
+
+// Status 是一个迁移的执行状态。
+type Status struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time // 执行时间，为空表示尚未执行
+	Missing   bool       // 数据库中记录了该版本，但程序中没有对应的迁移文件（通常是由更新的版本执行的）
+}
+
+// schemaMigration 是 schema_migrations 表中的一行。
+type schemaMigration struct {
+	Version   int64
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return tableName
+}
+
+// Migrator 对一个数据库执行迁移。
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New 使用 source 中的迁移文件创建 Migrator。
+func New(db *gorm.DB, source fs.FS) (*Migrator, error) {
+	migrations, err := Load(source)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// NewForDB 使用程序中嵌入的、与数据库方言对应的迁移文件创建 Migrator。
+func NewForDB(db *gorm.DB) (*Migrator, error) {
+	source, err := Source(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	return New(db, source)
+}
+
+// Source 返回程序中嵌入的方言 dialect 的迁移文件。
+func Source(dialect string) (fs.FS, error) {
+	if _, err := fs.Stat(embedded, dialect); err != nil {
+		return nil, fmt.Errorf("no migrations for database %q", dialect)
+	}
+	return fs.Sub(embedded, dialect)
+}
+
+// Load 读取 source 根目录下的迁移文件，按版本排序。同一版本的 up 和 down 文件名称必须相同，并且必须有 up 文件。
+func Load(source fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(source, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		version, name, direction, err := parseFilename(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		content, err := fs.ReadFile(source, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %q and %q", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// parseFilename 解析 <版本>_<名称>.<up|down>.sql 格式的文件名。
+func parseFilename(filename string) (version int64, name, direction string, err error) {
+	base := strings.TrimSuffix(filename, ".sql")
+	dot := strings.LastIndex(base, ".")
+	if dot < 0 {
+		return 0, "", "", fmt.Errorf("invalid migration filename %q: missing .up or .down", filename)
+	}
+	base, direction = base[:dot], base[dot+1:]
+	if direction != "up" && direction != "down" {
+		return 0, "", "", fmt.Errorf("invalid migration filename %q: direction must be up or down", filename)
+	}
+
+	digits, name, _ := strings.Cut(base, "_")
+	version, err = strconv.ParseInt(digits, 10, 64)
+	if err != nil || version <= 0 || name == "" {
+		return 0, "", "", fmt.Errorf("invalid migration filename %q: expected <version>_<name>", filename)
+	}
+	return version, name, direction, nil
+}
+
+// Up 按版本顺序执行所有尚未执行的迁移，返回本次执行的迁移。
+// 某个迁移失败时，之前的迁移已经提交，失败的迁移会回滚（在支持事务性DDL的数据库中）。
+func (m *Migrator) Up() ([]Migration, error) {
+	var applied []Migration
+	err := m.withLock(func(db *gorm.DB) error {
+		versions, err := appliedVersions(db)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err := apply(db, migration); err != nil {
+				return err
+			}
+			applied = append(applied, migration)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down 回滚最近执行的一个迁移，返回被回滚的迁移。
+func (m *Migrator) Down() (*Migration, error) {
+	var reverted *Migration
+	err := m.withLock(func(db *gorm.DB) error {
+		var last schemaMigration
+		err := db.Order("version DESC").Limit(1).Find(&last).Error
+		if err != nil {
+			return err
+		}
+		if last.Version == 0 {
+			return ErrNoMigrations
+		}
+
+		migration := m.find(last.Version)
+		if migration == nil {
+			return fmt.Errorf("migration %04d_%s is not known to this build", last.Version, last.Name)
+		}
+		if migration.Down == "" {
+			return fmt.Errorf("%w: %04d_%s has no down file", ErrIrreversible, migration.Version, migration.Name)
+		}
+		if err := revert(db, *migration); err != nil {
+			return err
+		}
+		reverted = migration
+		return nil
+	})
+	return reverted, err
+}
+
+// Status 返回每个迁移的执行状态，按版本排序。
+func (m *Migrator) Status() ([]Status, error) {
+	if err := ensureTable(m.db); err != nil {
+		return nil, err
+	}
+	var rows []schemaMigration
+	if err := m.db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, Status{Version: row.Version, Name: row.Name, AppliedAt: &appliedAt, Missing: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// find 返回版本 version 的迁移，不存在时返回 nil。
+func (m *Migrator) find(version int64) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// withLock 在一个固定的数据库连接上获取迁移锁，创建版本表后执行 fn，最后释放锁。
+func (m *Migrator) withLock(fn func(db *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		unlock, err := lock(conn)
+		if err != nil {
+			return fmt.Errorf("acquire migration lock: %w", err)
+		}
+		defer unlock()
+
+		if err := ensureTable(conn); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
+
+// ensureTable 创建 schema_migrations 表。
+func ensureTable(db *gorm.DB) error {
+	return db.Exec("CREATE TABLE IF NOT EXISTS " + tableName + " (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)").Error
+}
+
+// appliedVersions 返回已执行的版本。
+func appliedVersions(db *gorm.DB) (map[int64]struct{}, error) {
+	var versions []int64
+	if err := db.Model(&schemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return nil, err
+	}
+	set := make(map[int64]struct{}, len(versions))
+	for _, version := range versions {
+		set[version] = struct{}{}
+	}
+	return set, nil
+}
+
+// apply 在一个事务中执行迁移并记录版本。
+func apply(db *gorm.DB, migration Migration) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(migration.Up).Error; err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now().UTC()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("apply migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
+
+// revert 在一个事务中回滚迁移并删除版本记录。
+func revert(db *gorm.DB, migration Migration) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(migration.Down).Error; err != nil {
+			return err
+		}
+		return tx.Where("version = ?", migration.Version).Delete(&schemaMigration{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("revert migration %04d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
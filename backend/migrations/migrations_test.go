@@ -0,0 +1,156 @@
+package migrations
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// testSource 包含两个可以回滚的迁移，以及一个会被忽略的非SQL文件。
+func testSource() fstest.MapFS {
+	return fstest.MapFS{
+		"0001_create_notes.up.sql":     {Data: []byte("CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT NOT NULL);")},
+		"0001_create_notes.down.sql":   {Data: []byte("DROP TABLE notes;")},
+		"0002_add_note_title.up.sql":   {Data: []byte("ALTER TABLE notes ADD COLUMN title TEXT;\nCREATE INDEX idx_notes_title ON notes (title);")},
+		"0002_add_note_title.down.sql": {Data: []byte("DROP INDEX idx_notes_title;\nALTER TABLE notes DROP COLUMN title;")},
+		"README.md":                    {Data: []byte("not a migration")},
+	}
+}
+
+func newTestDB(t *testing.T, name string) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file:"+name+"?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	return db
+}
+
+func TestMigrator_UpAndDown(t *testing.T) {
+	db := newTestDB(t, "migrations_up_down")
+	m, err := New(db, testSource())
+	require.NoError(t, err)
+
+	applied, err := m.Up()
+	require.NoError(t, err)
+	require.Len(t, applied, 2)
+	assert.Equal(t, int64(1), applied[0].Version)
+	assert.Equal(t, "add_note_title", applied[1].Name)
+	require.NoError(t, db.Exec("INSERT INTO notes (body, title) VALUES ('hello', 'greeting')").Error)
+
+	// 再次执行时没有需要执行的迁移
+	applied, err = m.Up()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	statuses, err := m.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.NotNil(t, statuses[1].AppliedAt)
+
+	// 每次回滚一个版本
+	reverted, err := m.Down()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), reverted.Version)
+	assert.False(t, db.Migrator().HasColumn("notes", "title"))
+	statuses, err = m.Status()
+	require.NoError(t, err)
+	assert.NotNil(t, statuses[0].AppliedAt)
+	assert.Nil(t, statuses[1].AppliedAt)
+
+	_, err = m.Down()
+	require.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable("notes"))
+	_, err = m.Down()
+	assert.ErrorIs(t, err, ErrNoMigrations)
+
+	// 回滚之后可以重新执行
+	applied, err = m.Up()
+	require.NoError(t, err)
+	assert.Len(t, applied, 2)
+}
+
+func TestMigrator_FailedMigrationIsNotRecorded(t *testing.T) {
+	db := newTestDB(t, "migrations_failed")
+	source := testSource()
+	source["0003_broken.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE broken (id INTEGER PRIMARY KEY);\nINSERT INTO missing_table VALUES (1);")}
+	m, err := New(db, source)
+	require.NoError(t, err)
+
+	applied, err := m.Up()
+	assert.ErrorContains(t, err, "0003_broken")
+	assert.Len(t, applied, 2)
+
+	// 失败的迁移整体回滚，之前的迁移保持执行
+	assert.False(t, db.Migrator().HasTable("broken"))
+	statuses, err := m.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.NotNil(t, statuses[1].AppliedAt)
+	assert.Nil(t, statuses[2].AppliedAt)
+}
+
+func TestMigrator_Irreversible(t *testing.T) {
+	db := newTestDB(t, "migrations_irreversible")
+	m, err := New(db, fstest.MapFS{
+		"0001_create_notes.up.sql": {Data: []byte("CREATE TABLE notes (id INTEGER PRIMARY KEY);")},
+	})
+	require.NoError(t, err)
+	_, err = m.Up()
+	require.NoError(t, err)
+
+	_, err = m.Down()
+	assert.ErrorIs(t, err, ErrIrreversible)
+	assert.True(t, db.Migrator().HasTable("notes"))
+}
+
+func TestMigrator_StatusReportsUnknownVersions(t *testing.T) {
+	db := newTestDB(t, "migrations_unknown")
+	newer, err := New(db, testSource())
+	require.NoError(t, err)
+	_, err = newer.Up()
+	require.NoError(t, err)
+
+	// 旧版本的程序只知道第一个迁移
+	older, err := New(db, fstest.MapFS{"0001_create_notes.up.sql": testSource()["0001_create_notes.up.sql"]})
+	require.NoError(t, err)
+	statuses, err := older.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.False(t, statuses[0].Missing)
+	assert.True(t, statuses[1].Missing)
+	assert.Equal(t, "add_note_title", statuses[1].Name)
+
+	_, err = older.Down()
+	assert.ErrorContains(t, err, "not known")
+}
+
+func TestLoad_InvalidFiles(t *testing.T) {
+	for name, source := range map[string]fstest.MapFS{
+		"missing direction": {"0001_notes.sql": {}},
+		"bad direction":     {"0001_notes.sideways.sql": {}},
+		"missing version":   {"notes.up.sql": {}},
+		"missing name":      {"0001.up.sql": {}},
+		"down only":         {"0001_notes.down.sql": {Data: []byte("DROP TABLE notes;")}},
+		"conflicting names": {"0001_notes.up.sql": {Data: []byte("SELECT 1;")}, "0001_other.down.sql": {Data: []byte("SELECT 1;")}},
+	} {
+		_, err := Load(source)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestSource_Embedded(t *testing.T) {
+	source, err := Source("postgres")
+	require.NoError(t, err)
+	migrations, err := Load(source)
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "baseline", migrations[0].Name)
+	assert.NotEmpty(t, migrations[0].Down)
+
+	_, err = Source("oracle")
+	assert.Error(t, err)
+}
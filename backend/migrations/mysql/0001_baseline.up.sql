@@ -0,0 +1,42 @@
+-- Baseline: the users, roles and casbin_rule tables as AutoMigrate created
+-- them before versioned migrations. Databases created that way already contain
+-- these tables, so the statements only create what is missing. Everything added
+-- since then is created or altered by the later migrations.
+
+CREATE TABLE IF NOT EXISTS roles (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at datetime(3),
+    updated_at datetime(3),
+    deleted_at datetime(3),
+    name varchar(255) NOT NULL,
+    description longtext,
+    UNIQUE KEY idx_roles_name (name),
+    KEY idx_roles_deleted_at (deleted_at)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS users (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at datetime(3),
+    updated_at datetime(3),
+    deleted_at datetime(3),
+    username varchar(255) NOT NULL,
+    email varchar(255) NOT NULL,
+    password longtext NOT NULL,
+    role_id bigint unsigned NOT NULL,
+    UNIQUE KEY idx_users_username (username),
+    UNIQUE KEY idx_users_email (email),
+    KEY idx_users_deleted_at (deleted_at),
+    CONSTRAINT fk_users_role FOREIGN KEY (role_id) REFERENCES roles (id)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS casbin_rule (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    ptype varchar(100),
+    v0 varchar(100),
+    v1 varchar(100),
+    v2 varchar(100),
+    v3 varchar(100),
+    v4 varchar(100),
+    v5 varchar(100),
+    UNIQUE KEY idx_casbin_rule (ptype, v0, v1, v2, v3, v4, v5)
+) DEFAULT CHARSET=utf8mb4;
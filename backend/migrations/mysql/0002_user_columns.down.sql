@@ -0,0 +1,15 @@
+-- Restores the users table of the baseline. Fails if a deleted user shares its
+-- username or email with another user; purge such users first.
+
+ALTER TABLE users
+    DROP INDEX idx_users_created_at_id,
+    DROP INDEX idx_users_username,
+    DROP INDEX idx_users_email,
+    DROP INDEX idx_users_live_username,
+    DROP INDEX idx_users_live_email,
+    DROP COLUMN live_email,
+    DROP COLUMN live_username,
+    DROP COLUMN password_changed_at,
+    DROP COLUMN email_verified_at,
+    ADD UNIQUE KEY idx_users_username (username),
+    ADD UNIQUE KEY idx_users_email (email);
@@ -0,0 +1,21 @@
+-- Columns and indexes added to users for email verification, password changes,
+-- soft-deleted users and the user listing. MySQL has no partial indexes, so
+-- usernames and emails of live users are kept unique through generated columns
+-- that are NULL for deleted users.
+
+ALTER TABLE users
+    ADD COLUMN email_verified_at datetime(3),
+    ADD COLUMN password_changed_at datetime(3),
+    ADD COLUMN live_username varchar(255) GENERATED ALWAYS AS (IF(deleted_at IS NULL, username, NULL)) VIRTUAL,
+    ADD COLUMN live_email varchar(255) GENERATED ALWAYS AS (IF(deleted_at IS NULL, email, NULL)) VIRTUAL,
+    DROP INDEX idx_users_username,
+    DROP INDEX idx_users_email,
+    ADD UNIQUE KEY idx_users_live_username (live_username),
+    ADD UNIQUE KEY idx_users_live_email (live_email),
+    ADD KEY idx_users_username (username),
+    ADD KEY idx_users_email (email),
+    ADD KEY idx_users_created_at_id (created_at, id);
+
+-- Accounts created before email verification existed count as verified, so
+-- auth.email_verification: block does not lock them out.
+UPDATE users SET email_verified_at = COALESCE(created_at, CURRENT_TIMESTAMP(3));
@@ -0,0 +1,171 @@
+-- Tables added for refresh tokens, logout, password reset, two-factor
+-- authentication, lockout, password history, single sign-on, API keys,
+-- sessions, organizations and policy sync.
+
+CREATE TABLE refresh_tokens (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at datetime(3),
+    updated_at datetime(3),
+    deleted_at datetime(3),
+    user_id bigint unsigned NOT NULL,
+    token_hash varchar(255) NOT NULL,
+    family_id varchar(255) NOT NULL,
+    expires_at datetime(3) NOT NULL,
+    revoked_at datetime(3),
+    replaced_by_id bigint unsigned,
+    tenant_id bigint unsigned,
+    KEY idx_refresh_tokens_family_id (family_id),
+    UNIQUE KEY idx_refresh_tokens_token_hash (token_hash),
+    KEY idx_refresh_tokens_user_id (user_id),
+    KEY idx_refresh_tokens_deleted_at (deleted_at)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE revoked_tokens (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    jti varchar(255) NOT NULL,
+    expires_at datetime(3) NOT NULL,
+    created_at datetime(3),
+    KEY idx_revoked_tokens_expires_at (expires_at),
+    UNIQUE KEY idx_revoked_tokens_jti (jti)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE password_reset_tokens (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at datetime(3),
+    updated_at datetime(3),
+    deleted_at datetime(3),
+    user_id bigint unsigned NOT NULL,
+    token_hash varchar(255) NOT NULL,
+    expires_at datetime(3) NOT NULL,
+    used_at datetime(3),
+    UNIQUE KEY idx_password_reset_tokens_token_hash (token_hash),
+    KEY idx_password_reset_tokens_user_id (user_id),
+    KEY idx_password_reset_tokens_deleted_at (deleted_at)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE totp_credentials (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id bigint unsigned NOT NULL,
+    secret longtext NOT NULL,
+    confirmed_at datetime(3),
+    last_used_step bigint NOT NULL DEFAULT 0,
+    created_at datetime(3),
+    updated_at datetime(3),
+    UNIQUE KEY idx_totp_credentials_user_id (user_id)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE recovery_codes (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id bigint unsigned NOT NULL,
+    code_hash varchar(255) NOT NULL,
+    used_at datetime(3),
+    created_at datetime(3),
+    KEY idx_recovery_codes_code_hash (code_hash),
+    KEY idx_recovery_codes_user_id (user_id)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE login_failures (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    username varchar(255) NOT NULL,
+    failed_count bigint NOT NULL DEFAULT 0,
+    last_failed_at datetime(3) NOT NULL,
+    locked_until datetime(3),
+    created_at datetime(3),
+    updated_at datetime(3),
+    KEY idx_login_failures_locked_until (locked_until),
+    UNIQUE KEY idx_login_failures_username (username)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE password_histories (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id bigint unsigned NOT NULL,
+    password_hash longtext NOT NULL,
+    created_at datetime(3),
+    KEY idx_password_histories_user_id (user_id)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE user_identities (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id bigint unsigned NOT NULL,
+    provider varchar(64) NOT NULL,
+    subject varchar(255) NOT NULL,
+    email longtext,
+    created_at datetime(3),
+    updated_at datetime(3),
+    UNIQUE KEY idx_user_identities_subject (provider, subject),
+    KEY idx_user_identities_user_id (user_id)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE o_id_c_login_states (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    state_hash varchar(255) NOT NULL,
+    provider longtext NOT NULL,
+    nonce longtext NOT NULL,
+    code_verifier longtext NOT NULL,
+    link_user_id bigint unsigned,
+    expires_at datetime(3) NOT NULL,
+    created_at datetime(3),
+    KEY idx_o_id_c_login_states_expires_at (expires_at),
+    UNIQUE KEY idx_o_id_c_login_states_state_hash (state_hash)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE api_keys (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id bigint unsigned NOT NULL,
+    name varchar(100) NOT NULL,
+    prefix varchar(32) NOT NULL,
+    secret_hash longtext NOT NULL,
+    scopes longtext NOT NULL,
+    expires_at datetime(3),
+    last_used_at datetime(3),
+    created_at datetime(3),
+    updated_at datetime(3),
+    UNIQUE KEY idx_api_keys_prefix (prefix),
+    KEY idx_api_keys_user_id (user_id)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE sessions (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id bigint unsigned NOT NULL,
+    family_id varchar(255) NOT NULL,
+    user_agent varchar(512),
+    ip_address varchar(64),
+    created_at datetime(3),
+    last_seen_at datetime(3),
+    expires_at datetime(3) NOT NULL,
+    revoked_at datetime(3),
+    KEY idx_sessions_expires_at (expires_at),
+    UNIQUE KEY idx_sessions_family_id (family_id),
+    KEY idx_sessions_user_id (user_id)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE organizations (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    created_at datetime(3),
+    updated_at datetime(3),
+    deleted_at datetime(3),
+    name longtext NOT NULL,
+    slug varchar(255) NOT NULL,
+    UNIQUE KEY idx_organizations_slug (slug),
+    KEY idx_organizations_deleted_at (deleted_at)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE memberships (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    organization_id bigint unsigned NOT NULL,
+    user_id bigint unsigned NOT NULL,
+    role_id bigint unsigned NOT NULL,
+    created_at datetime(3),
+    updated_at datetime(3),
+    KEY idx_memberships_user_id (user_id),
+    UNIQUE KEY idx_memberships_organization_user (organization_id, user_id),
+    CONSTRAINT fk_memberships_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_memberships_role FOREIGN KEY (role_id) REFERENCES roles (id),
+    CONSTRAINT fk_memberships_organization FOREIGN KEY (organization_id) REFERENCES organizations (id)
+) DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE policy_revisions (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    revision bigint NOT NULL DEFAULT 0,
+    updated_at datetime(3)
+) DEFAULT CHARSET=utf8mb4;
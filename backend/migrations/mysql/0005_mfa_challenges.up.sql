@@ -0,0 +1,15 @@
+-- Two-factor challenges issued after a correct password. Each challenge may be
+-- used successfully once and allows a limited number of code attempts.
+
+CREATE TABLE mfa_challenges (
+    id bigint unsigned NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    jti varchar(255) NOT NULL,
+    user_id bigint unsigned NOT NULL,
+    attempts bigint NOT NULL DEFAULT 0,
+    expires_at datetime(3) NOT NULL,
+    used_at datetime(3),
+    created_at datetime(3),
+    UNIQUE KEY idx_mfa_challenges_jti (jti),
+    KEY idx_mfa_challenges_user_id (user_id),
+    KEY idx_mfa_challenges_expires_at (expires_at)
+) DEFAULT CHARSET=utf8mb4;
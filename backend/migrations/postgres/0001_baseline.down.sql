@@ -0,0 +1,5 @@
+-- Drops every table of the baseline. All data is lost.
+
+DROP TABLE IF EXISTS casbin_rule;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS roles;
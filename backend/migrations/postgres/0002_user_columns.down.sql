@@ -0,0 +1,13 @@
+-- Restores the released users table. Fails if a deleted user shares its
+-- username or email with another user; purge such users first.
+
+DROP INDEX IF EXISTS idx_users_username_trgm;
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_created_at_id;
+DROP INDEX IF EXISTS idx_users_live_username;
+DROP INDEX IF EXISTS idx_users_live_email;
+CREATE UNIQUE INDEX idx_users_username ON users (username);
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+
+ALTER TABLE users DROP COLUMN password_changed_at;
+ALTER TABLE users DROP COLUMN email_verified_at;
@@ -0,0 +1,29 @@
+-- Columns and indexes added to users for email verification, password changes,
+-- soft-deleted users and the user listing.
+
+ALTER TABLE users ADD COLUMN email_verified_at timestamptz;
+ALTER TABLE users ADD COLUMN password_changed_at timestamptz;
+
+-- Accounts created before email verification existed count as verified, so
+-- auth.email_verification: block does not lock them out.
+UPDATE users SET email_verified_at = COALESCE(created_at, now());
+
+-- Usernames and emails are only unique among users that are not deleted.
+DROP INDEX IF EXISTS idx_users_username;
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX idx_users_live_username ON users (username) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_users_live_email ON users (email) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_created_at_id ON users (created_at, id);
+
+-- Trigram indexes let substring searches on usernames and emails use an index.
+-- Creating the extension needs privileges the application user may not have;
+-- without it searches still work but scan the table.
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS pg_trgm;
+    CREATE INDEX IF NOT EXISTS idx_users_username_trgm ON users USING gin (username gin_trgm_ops);
+    CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING gin (email gin_trgm_ops);
+EXCEPTION WHEN insufficient_privilege OR feature_not_supported OR undefined_file THEN
+    RAISE NOTICE 'pg_trgm is unavailable, user search will scan the table: %', SQLERRM;
+END
+$$;
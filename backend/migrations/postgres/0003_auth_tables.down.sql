@@ -0,0 +1,16 @@
+-- Drops the tables of 0003_auth_tables. Their data is lost.
+
+DROP TABLE IF EXISTS policy_revisions;
+DROP TABLE IF EXISTS memberships;
+DROP TABLE IF EXISTS organizations;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS o_id_c_login_states;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS password_histories;
+DROP TABLE IF EXISTS login_failures;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp_credentials;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
@@ -0,0 +1,171 @@
+-- Tables added for refresh tokens, logout, password reset, two-factor
+-- authentication, lockout, password history, single sign-on, API keys,
+-- sessions, organizations and policy sync.
+
+CREATE TABLE refresh_tokens (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    token_hash text NOT NULL,
+    family_id text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz,
+    replaced_by_id bigint,
+    tenant_id bigint
+);
+CREATE INDEX idx_refresh_tokens_family_id ON refresh_tokens (family_id);
+CREATE UNIQUE INDEX idx_refresh_tokens_token_hash ON refresh_tokens (token_hash);
+CREATE INDEX idx_refresh_tokens_user_id ON refresh_tokens (user_id);
+CREATE INDEX idx_refresh_tokens_deleted_at ON refresh_tokens (deleted_at);
+
+CREATE TABLE revoked_tokens (
+    id bigserial PRIMARY KEY,
+    jti text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX idx_revoked_tokens_expires_at ON revoked_tokens (expires_at);
+CREATE UNIQUE INDEX idx_revoked_tokens_jti ON revoked_tokens (jti);
+
+CREATE TABLE password_reset_tokens (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    user_id bigint NOT NULL,
+    token_hash text NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz
+);
+CREATE UNIQUE INDEX idx_password_reset_tokens_token_hash ON password_reset_tokens (token_hash);
+CREATE INDEX idx_password_reset_tokens_user_id ON password_reset_tokens (user_id);
+CREATE INDEX idx_password_reset_tokens_deleted_at ON password_reset_tokens (deleted_at);
+
+CREATE TABLE totp_credentials (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    secret text NOT NULL,
+    confirmed_at timestamptz,
+    last_used_step bigint NOT NULL DEFAULT 0,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX idx_totp_credentials_user_id ON totp_credentials (user_id);
+
+CREATE TABLE recovery_codes (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    code_hash text NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz
+);
+CREATE INDEX idx_recovery_codes_code_hash ON recovery_codes (code_hash);
+CREATE INDEX idx_recovery_codes_user_id ON recovery_codes (user_id);
+
+CREATE TABLE login_failures (
+    id bigserial PRIMARY KEY,
+    username text NOT NULL,
+    failed_count bigint NOT NULL DEFAULT 0,
+    last_failed_at timestamptz NOT NULL,
+    locked_until timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE INDEX idx_login_failures_locked_until ON login_failures (locked_until);
+CREATE UNIQUE INDEX idx_login_failures_username ON login_failures (username);
+
+CREATE TABLE password_histories (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    password_hash text NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX idx_password_histories_user_id ON password_histories (user_id);
+
+CREATE TABLE user_identities (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    provider varchar(64) NOT NULL,
+    subject varchar(255) NOT NULL,
+    email text,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX idx_user_identities_subject ON user_identities (provider, subject);
+CREATE INDEX idx_user_identities_user_id ON user_identities (user_id);
+
+CREATE TABLE o_id_c_login_states (
+    id bigserial PRIMARY KEY,
+    state_hash text NOT NULL,
+    provider text NOT NULL,
+    nonce text NOT NULL,
+    code_verifier text NOT NULL,
+    link_user_id bigint,
+    expires_at timestamptz NOT NULL,
+    created_at timestamptz
+);
+CREATE INDEX idx_o_id_c_login_states_expires_at ON o_id_c_login_states (expires_at);
+CREATE UNIQUE INDEX idx_o_id_c_login_states_state_hash ON o_id_c_login_states (state_hash);
+
+CREATE TABLE api_keys (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    name varchar(100) NOT NULL,
+    prefix varchar(32) NOT NULL,
+    secret_hash text NOT NULL,
+    scopes text NOT NULL,
+    expires_at timestamptz,
+    last_used_at timestamptz,
+    created_at timestamptz,
+    updated_at timestamptz
+);
+CREATE UNIQUE INDEX idx_api_keys_prefix ON api_keys (prefix);
+CREATE INDEX idx_api_keys_user_id ON api_keys (user_id);
+
+CREATE TABLE sessions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL,
+    family_id text NOT NULL,
+    user_agent varchar(512),
+    ip_address varchar(64),
+    created_at timestamptz,
+    last_seen_at timestamptz,
+    expires_at timestamptz NOT NULL,
+    revoked_at timestamptz
+);
+CREATE INDEX idx_sessions_expires_at ON sessions (expires_at);
+CREATE UNIQUE INDEX idx_sessions_family_id ON sessions (family_id);
+CREATE INDEX idx_sessions_user_id ON sessions (user_id);
+
+CREATE TABLE organizations (
+    id bigserial PRIMARY KEY,
+    created_at timestamptz,
+    updated_at timestamptz,
+    deleted_at timestamptz,
+    name text NOT NULL,
+    slug text NOT NULL
+);
+CREATE UNIQUE INDEX idx_organizations_slug ON organizations (slug);
+CREATE INDEX idx_organizations_deleted_at ON organizations (deleted_at);
+
+CREATE TABLE memberships (
+    id bigserial PRIMARY KEY,
+    organization_id bigint NOT NULL,
+    user_id bigint NOT NULL,
+    role_id bigint NOT NULL,
+    created_at timestamptz,
+    updated_at timestamptz,
+    CONSTRAINT fk_memberships_user FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT fk_memberships_role FOREIGN KEY (role_id) REFERENCES roles (id),
+    CONSTRAINT fk_memberships_organization FOREIGN KEY (organization_id) REFERENCES organizations (id)
+);
+CREATE INDEX idx_memberships_user_id ON memberships (user_id);
+CREATE UNIQUE INDEX idx_memberships_organization_user ON memberships (organization_id, user_id);
+
+CREATE TABLE policy_revisions (
+    id bigserial PRIMARY KEY,
+    revision bigint NOT NULL DEFAULT 0,
+    updated_at timestamptz
+);
@@ -0,0 +1,4 @@
+-- Drops the two-factor challenges. Users in the middle of a login must enter
+-- their password again.
+
+DROP TABLE IF EXISTS mfa_challenges;
@@ -0,0 +1,15 @@
+-- Two-factor challenges issued after a correct password. Each challenge may be
+-- used successfully once and allows a limited number of code attempts.
+
+CREATE TABLE mfa_challenges (
+    id bigserial PRIMARY KEY,
+    jti text NOT NULL,
+    user_id bigint NOT NULL,
+    attempts bigint NOT NULL DEFAULT 0,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    created_at timestamptz
+);
+CREATE UNIQUE INDEX idx_mfa_challenges_jti ON mfa_challenges (jti);
+CREATE INDEX idx_mfa_challenges_user_id ON mfa_challenges (user_id);
+CREATE INDEX idx_mfa_challenges_expires_at ON mfa_challenges (expires_at);
@@ -0,0 +1,16 @@
+-- Columns and indexes added to users for email verification, password changes,
+-- soft-deleted users and the user listing.
+
+ALTER TABLE users ADD COLUMN email_verified_at datetime;
+ALTER TABLE users ADD COLUMN password_changed_at datetime;
+
+-- Accounts created before email verification existed count as verified, so
+-- auth.email_verification: block does not lock them out.
+UPDATE users SET email_verified_at = COALESCE(created_at, CURRENT_TIMESTAMP);
+
+-- Usernames and emails are only unique among users that are not deleted.
+DROP INDEX IF EXISTS idx_users_username;
+DROP INDEX IF EXISTS idx_users_email;
+CREATE UNIQUE INDEX idx_users_live_username ON users (username) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX idx_users_live_email ON users (email) WHERE deleted_at IS NULL;
+CREATE INDEX idx_users_created_at_id ON users (created_at, id);
@@ -0,0 +1,12 @@
+-- Moves Casbin rules back to the layout without domains. The old model cannot
+-- express rules limited to one organisation, so those are deleted; rules in
+-- the "global" and "*" domains lose their domain.
+
+DELETE FROM casbin_rule WHERE ptype IN ('p', 'p2') AND v1 NOT IN ('global', '*');
+DELETE FROM casbin_rule WHERE ptype = 'g' AND v2 <> '*';
+UPDATE casbin_rule SET v1 = v2, v2 = v3, v3 = ''
+    WHERE ptype = 'p';
+UPDATE casbin_rule SET v1 = v2, v2 = v3, v3 = v4, v4 = ''
+    WHERE ptype = 'p2';
+UPDATE casbin_rule SET v2 = ''
+    WHERE ptype = 'g';
@@ -0,0 +1,41 @@
+-- Moves Casbin rules stored before domain-based RBAC into the current layout:
+--   p  (sub, obj, act)       -> (sub, dom, obj, act)
+--   p2 (sub, obj, act, rule) -> (sub, dom, obj, act, rule)
+--   g  (role, parent)        -> (role, parent, '*')
+-- Rules of the admin role get the "global" domain, because "admin" is also a
+-- membership role inside organisations and must not inherit global
+-- permissions there. Every other rule applies in all domains ("*").
+-- Rows already in the current layout are left alone. A legacy row whose
+-- converted form already exists is dropped instead of violating the unique
+-- index.
+
+DELETE FROM casbin_rule
+    WHERE ptype = 'p' AND COALESCE(v3, '') = ''
+    AND EXISTS (SELECT 1 FROM casbin_rule c WHERE c.ptype = casbin_rule.ptype AND c.v0 = casbin_rule.v0 AND c.v1 = CASE WHEN casbin_rule.v0 = 'admin' THEN 'global' ELSE '*' END AND c.v2 = casbin_rule.v1 AND c.v3 = casbin_rule.v2);
+UPDATE casbin_rule SET v3 = v2, v2 = v1, v1 = CASE WHEN v0 = 'admin' THEN 'global' ELSE '*' END
+    WHERE ptype = 'p' AND COALESCE(v3, '') = '';
+
+DELETE FROM casbin_rule
+    WHERE ptype = 'p2' AND COALESCE(v4, '') = ''
+    AND EXISTS (SELECT 1 FROM casbin_rule c WHERE c.ptype = casbin_rule.ptype AND c.v0 = casbin_rule.v0 AND c.v1 = CASE WHEN casbin_rule.v0 = 'admin' THEN 'global' ELSE '*' END AND c.v2 = casbin_rule.v1 AND c.v3 = casbin_rule.v2 AND c.v4 = casbin_rule.v3);
+UPDATE casbin_rule SET v4 = v3, v3 = v2, v2 = v1, v1 = CASE WHEN v0 = 'admin' THEN 'global' ELSE '*' END
+    WHERE ptype = 'p2' AND COALESCE(v4, '') = '';
+
+DELETE FROM casbin_rule
+    WHERE ptype = 'g' AND COALESCE(v2, '') = ''
+    AND EXISTS (SELECT 1 FROM casbin_rule c WHERE c.ptype = casbin_rule.ptype AND c.v0 = casbin_rule.v0 AND c.v1 = casbin_rule.v1 AND c.v2 = '*');
+UPDATE casbin_rule SET v2 = '*'
+    WHERE ptype = 'g' AND COALESCE(v2, '') = '';
+
+-- Default rules introduced together with organisations. The application only
+-- seeds default rules into an empty table, so installs that already have
+-- rules get them here; fresh installs are left empty for the seeding.
+INSERT INTO casbin_rule (ptype, v0, v1, v2, v3, v4, v5)
+    SELECT 'p', d.sub, 'org:*', d.obj, d.act, '', ''
+    FROM (
+        SELECT 'admin' AS sub, '/organizations/current/members' AS obj, '*' AS act
+        UNION ALL SELECT 'admin', '/organizations/current/members/:user_id', '*'
+        UNION ALL SELECT 'user', '/organizations/current/members', 'GET'
+    ) d
+    WHERE EXISTS (SELECT 1 FROM casbin_rule WHERE ptype = 'p')
+    AND NOT EXISTS (SELECT 1 FROM casbin_rule c WHERE c.ptype = 'p' AND c.v0 = d.sub AND c.v1 = 'org:*' AND c.v2 = d.obj AND c.v3 = d.act);
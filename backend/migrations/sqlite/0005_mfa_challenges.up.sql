@@ -0,0 +1,15 @@
+-- Two-factor challenges issued after a correct password. Each challenge may be
+-- used successfully once and allows a limited number of code attempts.
+
+CREATE TABLE mfa_challenges (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    jti text NOT NULL,
+    user_id integer NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    expires_at datetime NOT NULL,
+    used_at datetime,
+    created_at datetime
+);
+CREATE UNIQUE INDEX idx_mfa_challenges_jti ON mfa_challenges (jti);
+CREATE INDEX idx_mfa_challenges_user_id ON mfa_challenges (user_id);
+CREATE INDEX idx_mfa_challenges_expires_at ON mfa_challenges (expires_at);
@@ -3,6 +3,8 @@ package mocks
 
 import (
 	"go-web/models"
+	"go-web/repositories"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 )
@@ -20,6 +22,14 @@ func (m *MockUserRepository) FindByUsername(username string) (*models.User, erro
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
+func (m *MockUserRepository) FindByEmail(email string) (*models.User, error) {
+	args := m.Called(email)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
 func (m *MockUserRepository) FindByUsernameOrEmail(username, email string) (*models.User, error) {
 	args := m.Called(username, email)
 	if args.Get(0) == nil {
@@ -33,7 +43,15 @@ func (m *MockUserRepository) Create(user *models.User) error {
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) FindAll() ([]models.User, error) {
+func (m *MockUserRepository) List(query repositories.UserQuery) ([]models.User, int64, error) {
+	args := m.Called(query)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]models.User), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockUserRepository) FindDeleted() ([]models.User, error) {
 	args := m.Called()
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -41,6 +59,32 @@ func (m *MockUserRepository) FindAll() ([]models.User, error) {
 	return args.Get(0).([]models.User), args.Error(1)
 }
 
+func (m *MockUserRepository) FindDeletedByID(id uint) (*models.User, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Restore(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Purge(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) FindDeletedBefore(cutoff time.Time) ([]uint, error) {
+	args := m.Called(cutoff)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uint), args.Error(1)
+}
+
 func (m *MockUserRepository) FindByID(id uint) (*models.User, error) {
 	args := m.Called(id)
 	if args.Get(0) == nil {
@@ -54,6 +98,29 @@ func (m *MockUserRepository) Update(user *models.User) error {
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) UpdatePassword(userID uint, hashedPassword string) error {
+	args := m.Called(userID, hashedPassword)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) ChangePassword(userID uint, hashedPassword string, changedAt time.Time) error {
+	args := m.Called(userID, hashedPassword, changedAt)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) FindPasswordChangedAt(userID uint) (*time.Time, error) {
+	args := m.Called(userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*time.Time), args.Error(1)
+}
+
+func (m *MockUserRepository) MarkEmailVerified(userID uint) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
 func (m *MockUserRepository) Delete(user *models.User) error {
 	args := m.Called(user)
 	return args.Error(0)
@@ -67,6 +134,11 @@ func (m *MockUserRepository) LoadRole(user *models.User) error {
 	return args.Error(0)
 }
 
+// Primary returns the mock itself, so the same expectations apply to primary reads.
+func (m *MockUserRepository) Primary() repositories.UserRepository {
+	return m
+}
+
 
 // MockRoleRepository is a mock implementation of RoleRepository for testing.
 type MockRoleRepository struct {
@@ -85,3 +157,44 @@ func (m *MockRoleRepository) Create(role *models.Role) error {
 	args := m.Called(role)
 	return args.Error(0)
 }
+
+func (m *MockRoleRepository) FindAll() ([]models.Role, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) FindByID(id uint) (*models.Role, error) {
+	args := m.Called(id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) Update(role *models.Role) error {
+	args := m.Called(role)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) Delete(role *models.Role) error {
+	args := m.Called(role)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) CountUsers(roleID uint) (int64, error) {
+	args := m.Called(roleID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRoleRepository) CountMemberships(roleID uint) (int64, error) {
+	args := m.Called(roleID)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+// Primary returns the mock itself, so the same expectations apply to primary reads.
+func (m *MockRoleRepository) Primary() repositories.RoleRepository {
+	return m
+}
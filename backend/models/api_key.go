@@ -0,0 +1,61 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// API密钥的权限范围。密钥在用户角色的基础上进一步限制可以执行的操作。
+const (
+	APIKeyScopeRead  = "read"  // 只读请求：GET、HEAD、OPTIONS
+	APIKeyScopeWrite = "write" // 其他所有请求
+)
+
+// APIKey 是用户为脚本和CI等机器客户端创建的个人访问令牌。
+// 令牌明文只在创建时返回一次，数据库中保存用于查找的前缀和完整令牌的SHA-256哈希。
+// 使用API密钥认证的请求拥有所属用户当前的角色，与JWT经过相同的Casbin授权。
+type APIKey struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`              // 所属用户ID
+	Name       string     `gorm:"size:100;not null" json:"name"`              // 用户填写的名称，便于区分不同的密钥
+	Prefix     string     `gorm:"size:32;uniqueIndex;not null" json:"prefix"` // 令牌的公开前缀，用于查找和展示
+	SecretHash string     `gorm:"not null" json:"-"`                          // 完整令牌的SHA-256哈希
+	Scopes     string     `gorm:"not null" json:"-"`                          // 以空格分隔的权限范围
+	ExpiresAt  *time.Time `json:"expires_at"`                                 // 过期时间，为空表示永不过期
+	LastUsedAt *time.Time `json:"last_used_at"`                               // 最近一次使用的时间，按分钟精度更新
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// ScopeList 返回密钥的权限范围列表。
+func (k *APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
+
+// HasScope 返回密钥是否拥有指定的权限范围。
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.ScopeList() {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsMethod 返回密钥的权限范围是否允许使用该HTTP方法。
+func (k *APIKey) AllowsMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return k.HasScope(APIKeyScopeRead) || k.HasScope(APIKeyScopeWrite)
+	default:
+		return k.HasScope(APIKeyScopeWrite)
+	}
+}
+
+// IsExpired 返回密钥在 now 时是否已经过期。
+func (k *APIKey) IsExpired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
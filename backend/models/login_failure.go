@@ -0,0 +1,25 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+)
+
+// LoginFailure 记录某个用户名的连续登录失败情况，用于退避和临时锁定。
+// 记录以用户名而不是用户ID为键，不存在的用户名同样会被统计，避免通过锁定行为判断账户是否存在。
+// 登录成功或管理员解锁时直接删除记录，因此这里不使用软删除。
+type LoginFailure struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	Username     string     `gorm:"uniqueIndex;not null" json:"username"`   // 尝试登录的用户名（统一为小写）
+	FailedCount  int        `gorm:"not null;default:0" json:"failed_count"` // 连续失败次数
+	LastFailedAt time.Time  `gorm:"not null" json:"last_failed_at"`         // 最近一次失败的时间
+	LockedUntil  *time.Time `gorm:"index" json:"locked_until,omitempty"`    // 锁定的截止时间，为空表示未被锁定
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// IsLocked 返回在时间 now 时账户是否处于锁定状态。
+func (f *LoginFailure) IsLocked(now time.Time) bool {
+	return f.LockedUntil != nil && now.Before(*f.LockedUntil)
+}
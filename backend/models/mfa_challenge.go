@@ -0,0 +1,19 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+)
+
+// MFAChallenge 记录一个已签发的两步验证挑战令牌。
+// 挑战令牌只能成功使用一次，尝试次数达到上限后同样作废，过期的记录在签发新的挑战时被清理。
+type MFAChallenge struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	JTI       string     `gorm:"uniqueIndex;not null" json:"-"`    // 挑战令牌的唯一标识（jti声明）
+	UserID    uint       `gorm:"index;not null" json:"user_id"`    // 通过了密码校验的用户ID
+	Attempts  int        `gorm:"not null;default:0" json:"-"`      // 已经尝试验证码的次数
+	ExpiresAt time.Time  `gorm:"index;not null" json:"expires_at"` // 挑战令牌的过期时间
+	UsedAt    *time.Time `json:"used_at,omitempty"`                // 验证成功的时间，为空表示尚未使用
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+)
+
+// OIDCLoginState 保存一次尚未完成的第三方登录。
+// 发起登录时创建，身份提供方回调时凭 state 取出并删除，因此每个 state 只能使用一次。
+type OIDCLoginState struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	StateHash    string    `gorm:"uniqueIndex;not null" json:"-"` // state 参数的SHA-256哈希
+	Provider     string    `gorm:"not null" json:"provider"`      // 发起登录的身份提供方名称
+	Nonce        string    `gorm:"not null" json:"-"`             // 写入授权请求的nonce，必须与ID令牌中的一致
+	CodeVerifier string    `gorm:"not null" json:"-"`             // PKCE code_verifier，换取令牌时提交
+	LinkUserID   *uint     `json:"link_user_id,omitempty"`        // 不为空时表示把外部身份关联到该用户，而不是登录
+	ExpiresAt    time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
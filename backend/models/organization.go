@@ -0,0 +1,44 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TenantOwned 由属于某个组织的模型实现。
+// 这些模型必须有 OrganizationID 字段，通过带有当前组织的上下文查询时会自动限定到该组织，
+// 上下文中没有组织时查询失败，跨组织查询必须使用 tenant.Unscoped 的上下文。
+// 目前只有 Membership 属于组织。用户、角色、会话、刷新令牌和API密钥是账号级别的数据：
+// 同一个用户可以加入多个组织，角色在所有组织中共用，会话和API密钥属于用户本人。
+// 组织令牌对这些数据的访问由Casbin在组织的域中授权（默认策略在组织中不授予管理接口），
+// /users/me 下的接口只作用于当前用户自己的数据。
+type TenantOwned interface {
+	TenantOwned()
+}
+
+// Organization 代表部署中的一个客户组织（租户）。
+type Organization struct {
+	gorm.Model
+	Name string `gorm:"not null" json:"name"`             // 组织的显示名称
+	Slug string `gorm:"uniqueIndex;not null" json:"slug"` // 组织的唯一标识，只包含小写字母、数字和连字符
+}
+
+// Membership 表示用户加入了一个组织，并在该组织中拥有一个角色。
+// 同一个用户在不同组织中可以有不同的角色，与用户的全局角色相互独立。
+type Membership struct {
+	ID             uint         `gorm:"primarykey" json:"id"`
+	OrganizationID uint         `gorm:"uniqueIndex:idx_memberships_organization_user;not null" json:"organization_id"` // 所属组织
+	UserID         uint         `gorm:"uniqueIndex:idx_memberships_organization_user;index;not null" json:"user_id"`   // 成员用户
+	RoleID         uint         `gorm:"not null" json:"role_id"`                                                       // 成员在该组织中的角色
+	Organization   Organization `json:"organization"`
+	User           User         `json:"user"`
+	Role           Role         `json:"role"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+}
+
+// TenantOwned 标记成员关系属于组织，查询会自动限定到当前组织。
+func (Membership) TenantOwned() {}
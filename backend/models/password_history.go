@@ -0,0 +1,16 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+)
+
+// PasswordHistory 保存用户之前使用过的密码哈希，用于禁止重复使用最近的密码。
+// 用户当前的密码保存在 User.Password 中，不在此表中。
+type PasswordHistory struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	UserID       uint      `gorm:"index;not null" json:"user_id"` // 所属用户ID
+	PasswordHash string    `gorm:"not null" json:"-"`             // 被替换的密码哈希
+	CreatedAt    time.Time `json:"created_at"`                    // 密码被替换的时间
+}
@@ -0,0 +1,19 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordResetToken 代表一次密码重置请求。
+// 与刷新令牌一样，数据库中只保存令牌的哈希值；令牌只能使用一次，并在过期后失效。
+type PasswordResetToken struct {
+	gorm.Model
+	UserID    uint       `gorm:"index;not null" json:"user_id"` // 申请重置密码的用户ID
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"` // 令牌明文的SHA-256哈希
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`    // 令牌的过期时间
+	UsedAt    *time.Time `json:"used_at,omitempty"`             // 令牌被使用（或作废）的时间，为空表示仍可使用
+}
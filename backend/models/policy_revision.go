@@ -0,0 +1,13 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import "time"
+
+// PolicyRevision 记录Casbin策略的修订号，表中只有一行。
+// 每次修改策略后修订号加一，使用轮询方式同步的实例发现修订号变化后重新加载策略。
+type PolicyRevision struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Revision  int64     `gorm:"not null;default:0" json:"revision"` // 策略的修订号
+	UpdatedAt time.Time `json:"updated_at"`
+}
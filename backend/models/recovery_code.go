@@ -0,0 +1,17 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+)
+
+// RecoveryCode 是用户丢失身份验证器时使用的一次性恢复码。
+// 与其他令牌一样，数据库中只保存恢复码的哈希值。
+type RecoveryCode struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	UserID    uint       `gorm:"index;not null" json:"user_id"` // 所属用户ID
+	CodeHash  string     `gorm:"index;not null" json:"-"`       // 恢复码明文的SHA-256哈希
+	UsedAt    *time.Time `json:"used_at,omitempty"`             // 恢复码被使用的时间，为空表示仍可使用
+	CreatedAt time.Time  `json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken 代表一个已签发的刷新令牌。
+// 数据库中只保存令牌的哈希值，明文只在签发时返回给客户端一次。
+// 同一次登录经过多次轮换产生的令牌共享同一个 FamilyID，检测到重放时可以整体吊销。
+type RefreshToken struct {
+	gorm.Model
+	UserID       uint       `gorm:"index;not null" json:"user_id"`   // 令牌所属的用户ID
+	TokenHash    string     `gorm:"uniqueIndex;not null" json:"-"`   // 令牌明文的SHA-256哈希
+	FamilyID     string     `gorm:"index;not null" json:"family_id"` // 令牌族ID，同一次登录的轮换链共享此值
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`      // 令牌的过期时间
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`            // 令牌被轮换或吊销的时间，为空表示仍然有效
+	ReplacedByID *uint      `json:"replaced_by_id,omitempty"`        // 轮换后取代此令牌的新令牌ID
+	TenantID     uint       `json:"tenant_id,omitempty"`             // 签发时的当前组织，刷新后的令牌沿用此组织
+}
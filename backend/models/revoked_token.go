@@ -0,0 +1,16 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+)
+
+// RevokedToken 记录一个在过期之前就被主动吊销的访问令牌（JWT）。
+// 记录只需要保留到令牌本身过期为止，之后会被后台任务清理，因此这里不使用软删除。
+type RevokedToken struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	JTI       string    `gorm:"uniqueIndex;not null" json:"jti"`  // 被吊销令牌的唯一标识（jti声明）
+	ExpiresAt time.Time `gorm:"index;not null" json:"expires_at"` // 令牌原本的过期时间，过后即可清理
+	CreatedAt time.Time `json:"created_at"`                       // 吊销时间
+}
@@ -0,0 +1,26 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+)
+
+// Session 代表一次登录（一个设备上的会话），与一个刷新令牌族一一对应。
+// 访问令牌通过 sid 声明绑定到会话，会话被吊销后其访问令牌和刷新令牌都不再有效。
+type Session struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	UserID     uint       `gorm:"index;not null" json:"user_id"`    // 所属用户ID
+	FamilyID   string     `gorm:"uniqueIndex;not null" json:"-"`    // 对应的刷新令牌族ID
+	UserAgent  string     `gorm:"size:512" json:"user_agent"`       // 登录时客户端的User-Agent
+	IPAddress  string     `gorm:"size:64" json:"ip_address"`        // 登录时客户端的IP地址
+	CreatedAt  time.Time  `json:"created_at"`                       // 登录时间
+	LastSeenAt time.Time  `json:"last_seen_at"`                     // 最近一次使用该会话的时间，按分钟精度更新
+	ExpiresAt  time.Time  `gorm:"index;not null" json:"expires_at"` // 当前刷新令牌的过期时间，之后会话自然结束
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`             // 会话被吊销（登出、修改密码或被管理员吊销）的时间
+}
+
+// IsActive 返回会话在 now 时是否仍然有效。
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,24 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+)
+
+// TOTPCredential 保存用户的TOTP（RFC 6238）两步验证密钥。
+// 每个用户最多一条记录；关闭两步验证时直接删除，因此这里不使用软删除。
+type TOTPCredential struct {
+	ID           uint       `gorm:"primarykey" json:"id"`
+	UserID       uint       `gorm:"uniqueIndex;not null" json:"user_id"` // 所属用户ID
+	Secret       string     `gorm:"not null" json:"-"`                   // Base32编码的共享密钥
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`              // 用户用验证码确认绑定的时间，为空表示尚未启用
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"`         // 最近一次成功使用的时间步，用于拒绝验证码重放
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// IsConfirmed 返回两步验证是否已经启用。
+func (c *TOTPCredential) IsConfirmed() bool {
+	return c.ConfirmedAt != nil
+}
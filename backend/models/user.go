@@ -3,15 +3,25 @@ package models
 // package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 // User 代表系统中的一个用户。
 type User struct {
 	gorm.Model
-	Username string `gorm:"uniqueIndex;not null" json:"username"` // 用户的唯一名称
-	Email    string `gorm:"uniqueIndex;not null" json:"email"`    // 用户的唯一电子邮件地址
-	Password string `gorm:"not null" json:"-"`                    // 用户的哈希密码，json:"-" 表示在JSON序列化时忽略此字段
-	RoleID   uint   `gorm:"not null" json:"role_id"`              // 关联的角色ID
-	Role     Role   `json:"role"`                                 // 用户所属的角色（通过RoleID进行关联）
+	Username string `gorm:"uniqueIndex:idx_users_live_username,where:deleted_at IS NULL;not null" json:"username"` // 用户的唯一名称，已删除的用户不占用
+	Email    string `gorm:"uniqueIndex:idx_users_live_email,where:deleted_at IS NULL;not null" json:"email"`       // 用户的唯一电子邮件地址，已删除的用户不占用
+	Password string `gorm:"not null" json:"-"`                                                                     // 用户的哈希密码，json:"-" 表示在JSON序列化时忽略此字段
+	RoleID   uint   `gorm:"not null" json:"role_id"`                                                               // 关联的角色ID
+	Role     Role   `json:"role"`                                                                                  // 用户所属的角色（通过RoleID进行关联）
+
+	EmailVerifiedAt   *time.Time `json:"email_verified_at"` // 邮箱通过验证的时间，为空表示尚未验证
+	PasswordChangedAt *time.Time `json:"-"`                 // 最近一次修改或重置密码的时间，之前签发的访问令牌不再有效
+}
+
+// IsEmailVerified 返回用户的邮箱是否已经通过验证。
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
 }
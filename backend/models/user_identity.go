@@ -0,0 +1,19 @@
+package models
+
+// package models 定义了应用程序中使用的数据结构，这些结构将映射到数据库中的表。
+
+import (
+	"time"
+)
+
+// UserIdentity 将第三方身份提供方中的用户（iss 对应的提供方 + sub）关联到本地用户。
+// 一个本地用户可以关联多个提供方的身份，同一个外部身份只能关联一个本地用户。
+type UserIdentity struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`                                            // 关联的本地用户ID
+	Provider  string    `gorm:"size:64;not null;uniqueIndex:idx_user_identities_subject" json:"provider"` // 身份提供方名称，对应配置中的 oidc.providers[].name
+	Subject   string    `gorm:"size:255;not null;uniqueIndex:idx_user_identities_subject" json:"subject"` // ID令牌中的sub声明
+	Email     string    `json:"email"`                                                                    // 关联时ID令牌中的邮箱，仅供展示
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
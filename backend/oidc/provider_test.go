@@ -0,0 +1,139 @@
+package oidc_test
+
+import (
+	"context"
+	"go-web/config"
+	"go-web/oidc"
+	"go-web/oidc/oidctest"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestProvider(t *testing.T) (*oidc.Provider, *oidctest.Issuer) {
+	issuer := oidctest.NewIssuer(t, "go-web", "client-secret")
+	provider := oidc.NewProvider(config.OIDCProviderConfig{
+		Name:         "stub",
+		Issuer:       issuer.URL(),
+		ClientID:     "go-web",
+		ClientSecret: "client-secret",
+		RedirectURL:  "http://localhost:3000/oidc/stub/callback",
+	}, nil)
+	return provider, issuer
+}
+
+func TestProvider_AuthorizationCodeFlow(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", oidc.CodeChallenge("verifier-1"))
+	assert.NoError(t, err)
+	u, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "openid email profile", u.Query().Get("scope"))
+	assert.Equal(t, "http://localhost:3000/oidc/stub/callback", u.Query().Get("redirect_uri"))
+
+	code, state := issuer.Authorize(t, authURL, oidctest.Identity{Subject: "user-1", Email: "alice@example.com", EmailVerified: true})
+	assert.Equal(t, "state-1", state)
+
+	// The code is bound to the PKCE verifier
+	_, err = provider.Exchange(ctx, code, "wrong-verifier")
+	assert.Error(t, err)
+
+	code, _ = issuer.Authorize(t, authURL, oidctest.Identity{Subject: "user-1", Email: "alice@example.com", EmailVerified: true})
+	rawIDToken, err := provider.Exchange(ctx, code, "verifier-1")
+	assert.NoError(t, err)
+
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, "nonce-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "user-1", claims.Subject)
+	assert.Equal(t, "alice@example.com", claims.Email)
+	assert.True(t, claims.EmailVerified)
+
+	// A token issued for another login attempt is rejected
+	_, err = provider.VerifyIDToken(ctx, rawIDToken, "nonce-2")
+	assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+}
+
+func TestProvider_VerifyIDToken_Rejects(t *testing.T) {
+	provider, issuer := newTestProvider(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	valid := func() *oidc.IDTokenClaims {
+		return &oidc.IDTokenClaims{
+			Nonce: "nonce",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    issuer.URL(),
+				Subject:   "user-1",
+				Audience:  jwt.ClaimStrings{"go-web"},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+			},
+		}
+	}
+	_, err := provider.VerifyIDToken(ctx, issuer.SignIDToken(valid()), "nonce")
+	assert.NoError(t, err)
+
+	cases := map[string]func(c *oidc.IDTokenClaims){
+		"wrong issuer":   func(c *oidc.IDTokenClaims) { c.Issuer = "https://evil.example.com" },
+		"wrong audience": func(c *oidc.IDTokenClaims) { c.Audience = jwt.ClaimStrings{"another-client"} },
+		"expired":        func(c *oidc.IDTokenClaims) { c.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Minute)) },
+		"missing sub":    func(c *oidc.IDTokenClaims) { c.Subject = "" },
+		"wrong azp": func(c *oidc.IDTokenClaims) {
+			c.Audience = jwt.ClaimStrings{"go-web", "another-client"}
+			c.AuthorizedParty = "another-client"
+		},
+	}
+	for name, mutate := range cases {
+		claims := valid()
+		mutate(claims)
+		_, err := provider.VerifyIDToken(ctx, issuer.SignIDToken(claims), "nonce")
+		assert.ErrorIs(t, err, oidc.ErrInvalidIDToken, name)
+	}
+
+	// Unsigned tokens are never accepted
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	assert.NoError(t, err)
+	_, err = provider.VerifyIDToken(ctx, unsigned, "nonce")
+	assert.ErrorIs(t, err, oidc.ErrInvalidIDToken)
+}
+
+func TestProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	issuer := oidctest.NewIssuer(t, "go-web", "")
+	// Serve the issuer's metadata from a different address
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := http.Get(issuer.URL() + r.URL.Path)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+
+	provider := oidc.NewProvider(config.OIDCProviderConfig{Name: "stub", Issuer: proxy.URL, ClientID: "go-web"}, nil)
+	_, err := provider.Discover(context.Background())
+	assert.Error(t, err)
+}
+
+func TestNewProviders_Validation(t *testing.T) {
+	_, err := oidc.NewProviders(config.OIDCConfig{Providers: []config.OIDCProviderConfig{{Name: "incomplete"}}}, nil)
+	assert.Error(t, err)
+
+	provider := config.OIDCProviderConfig{Name: "stub", Issuer: "https://issuer.example.com", ClientID: "go-web", RedirectURL: "http://localhost/cb"}
+	_, err = oidc.NewProviders(config.OIDCConfig{Providers: []config.OIDCProviderConfig{provider, provider}}, nil)
+	assert.Error(t, err)
+
+	providers, err := oidc.NewProviders(config.OIDCConfig{Providers: []config.OIDCProviderConfig{provider}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "stub", providers["stub"].Name())
+}
@@ -0,0 +1,105 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyRepository 定义了与API密钥相关的操作接口。
+// 除 FindByPrefix 外，所有查询都限定在所属用户范围内，避免访问其他用户的密钥。
+type APIKeyRepository interface {
+	// Create 保存一个新创建的API密钥。
+	Create(key *models.APIKey) error
+	// FindByPrefix 根据令牌前缀查找API密钥，用于认证。
+	FindByPrefix(prefix string) (*models.APIKey, error)
+	// FindByUserID 返回用户的所有API密钥，按创建时间排序。
+	FindByUserID(userID uint) ([]models.APIKey, error)
+	// FindByIDForUser 返回属于该用户的指定API密钥。
+	FindByIDForUser(id, userID uint) (*models.APIKey, error)
+	// CountByUserID 返回用户拥有的API密钥数量。
+	CountByUserID(userID uint) (int64, error)
+	// Update 更新API密钥的名称和权限范围。
+	Update(key *models.APIKey) error
+	// DeleteForUser 删除属于该用户的指定API密钥，密钥不存在时返回 gorm.ErrRecordNotFound。
+	DeleteForUser(id, userID uint) error
+	// TouchLastUsed 将最近使用时间更新为 usedAt，但只在上次记录早于 staleBefore 时写入，以减少每个请求的写操作。
+	TouchLastUsed(id uint, usedAt, staleBefore time.Time) error
+}
+
+// GormAPIKeyRepository 是 APIKeyRepository 的GORM实现。
+type GormAPIKeyRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormAPIKeyRepository 是一个构造函数，用于创建一个新的 GormAPIKeyRepository 实例。
+func NewGormAPIKeyRepository(db *gorm.DB) *GormAPIKeyRepository {
+	return &GormAPIKeyRepository{DB: db}
+}
+
+// Create 实现了 APIKeyRepository 接口的 Create 方法。
+func (r *GormAPIKeyRepository) Create(key *models.APIKey) error {
+	return r.DB.Create(key).Error
+}
+
+// FindByPrefix 实现了 APIKeyRepository 接口的 FindByPrefix 方法。
+func (r *GormAPIKeyRepository) FindByPrefix(prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.DB.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// FindByUserID 实现了 APIKeyRepository 接口的 FindByUserID 方法。
+func (r *GormAPIKeyRepository) FindByUserID(userID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := r.DB.Where("user_id = ?", userID).Order("id").Find(&keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// FindByIDForUser 实现了 APIKeyRepository 接口的 FindByIDForUser 方法。
+func (r *GormAPIKeyRepository) FindByIDForUser(id, userID uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.DB.Where("id = ? AND user_id = ?", id, userID).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CountByUserID 实现了 APIKeyRepository 接口的 CountByUserID 方法。
+func (r *GormAPIKeyRepository) CountByUserID(userID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.APIKey{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+// Update 实现了 APIKeyRepository 接口的 Update 方法。
+func (r *GormAPIKeyRepository) Update(key *models.APIKey) error {
+	return r.DB.Model(key).Select("name", "scopes").Updates(key).Error
+}
+
+// DeleteForUser 实现了 APIKeyRepository 接口的 DeleteForUser 方法。
+func (r *GormAPIKeyRepository) DeleteForUser(id, userID uint) error {
+	result := r.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&models.APIKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// TouchLastUsed 实现了 APIKeyRepository 接口的 TouchLastUsed 方法。
+func (r *GormAPIKeyRepository) TouchLastUsed(id uint, usedAt, staleBefore time.Time) error {
+	return r.DB.Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", id, staleBefore).
+		Update("last_used_at", usedAt).Error
+}
@@ -0,0 +1,88 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginFailureRepository 定义了与登录失败记录相关的操作接口。
+type LoginFailureRepository interface {
+	// FindByUsername 查找用户名的登录失败记录。
+	FindByUsername(username string) (*models.LoginFailure, error)
+	// Increment 将用户名的失败次数加一并返回更新后的记录，记录不存在时创建。
+	// 计数在数据库中原子递增，并发的失败请求不会丢失计数。
+	Increment(username string, failedAt time.Time) (*models.LoginFailure, error)
+	// Lock 将用户名锁定到给定时间。
+	Lock(username string, until time.Time) error
+	// FindLocked 返回在 now 时仍处于锁定状态的所有记录。
+	FindLocked(now time.Time) ([]models.LoginFailure, error)
+	// Delete 删除用户名的登录失败记录，即清除失败计数和锁定。
+	// 返回值表示是否真的删除了记录。
+	Delete(username string) (bool, error)
+}
+
+// GormLoginFailureRepository 是 LoginFailureRepository 的GORM实现。
+type GormLoginFailureRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormLoginFailureRepository 是一个构造函数，用于创建一个新的 GormLoginFailureRepository 实例。
+func NewGormLoginFailureRepository(db *gorm.DB) *GormLoginFailureRepository {
+	return &GormLoginFailureRepository{DB: db}
+}
+
+// FindByUsername 实现了 LoginFailureRepository 接口的 FindByUsername 方法。
+func (r *GormLoginFailureRepository) FindByUsername(username string) (*models.LoginFailure, error) {
+	var failure models.LoginFailure
+	if err := r.DB.Where("username = ?", username).First(&failure).Error; err != nil {
+		return nil, err
+	}
+	return &failure, nil
+}
+
+// Increment 实现了 LoginFailureRepository 接口的 Increment 方法。
+// 使用 INSERT ... ON CONFLICT DO UPDATE 在一条语句中完成创建或递增。
+func (r *GormLoginFailureRepository) Increment(username string, failedAt time.Time) (*models.LoginFailure, error) {
+	failure := &models.LoginFailure{Username: username, FailedCount: 1, LastFailedAt: failedAt}
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "username"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failed_count":   gorm.Expr("login_failures.failed_count + 1"),
+			"last_failed_at": failedAt,
+			"updated_at":     failedAt,
+		}),
+	}).Create(failure).Error
+	if err != nil {
+		return nil, err
+	}
+	return r.FindByUsername(username)
+}
+
+// Lock 实现了 LoginFailureRepository 接口的 Lock 方法。
+func (r *GormLoginFailureRepository) Lock(username string, until time.Time) error {
+	return r.DB.Model(&models.LoginFailure{}).Where("username = ?", username).Update("locked_until", until).Error
+}
+
+// FindLocked 实现了 LoginFailureRepository 接口的 FindLocked 方法。
+func (r *GormLoginFailureRepository) FindLocked(now time.Time) ([]models.LoginFailure, error) {
+	var failures []models.LoginFailure
+	if err := r.DB.Where("locked_until > ?", now).Order("locked_until DESC").Find(&failures).Error; err != nil {
+		return nil, err
+	}
+	return failures, nil
+}
+
+// Delete 实现了 LoginFailureRepository 接口的 Delete 方法。
+func (r *GormLoginFailureRepository) Delete(username string) (bool, error) {
+	result := r.DB.Where("username = ?", username).Delete(&models.LoginFailure{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -0,0 +1,66 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MFAChallengeRepository 定义了与两步验证挑战令牌相关的操作接口。
+type MFAChallengeRepository interface {
+	// Create 保存一个新签发的挑战令牌。
+	Create(challenge *models.MFAChallenge) error
+	// Attempt 为挑战令牌记录一次验证码尝试。
+	// 令牌不存在、不属于 userID、已经使用、已经过期或尝试次数达到 maxAttempts 时返回 false。
+	Attempt(jti string, userID uint, maxAttempts int, now time.Time) (bool, error)
+	// MarkUsed 将挑战令牌标记为已使用，已经被另一个请求使用时返回 false。
+	MarkUsed(jti string, now time.Time) (bool, error)
+	// DeleteExpired 删除所有已过期的挑战令牌，返回删除的数量。
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+// GormMFAChallengeRepository 是 MFAChallengeRepository 的GORM实现。
+type GormMFAChallengeRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormMFAChallengeRepository 是一个构造函数，用于创建一个新的 GormMFAChallengeRepository 实例。
+func NewGormMFAChallengeRepository(db *gorm.DB) *GormMFAChallengeRepository {
+	return &GormMFAChallengeRepository{DB: db}
+}
+
+// Create 实现了 MFAChallengeRepository 接口的 Create 方法。
+func (r *GormMFAChallengeRepository) Create(challenge *models.MFAChallenge) error {
+	return r.DB.Create(challenge).Error
+}
+
+// Attempt 实现了 MFAChallengeRepository 接口的 Attempt 方法。
+// 检查和计数在同一条UPDATE语句中完成，并发的请求不能超过尝试次数的上限。
+func (r *GormMFAChallengeRepository) Attempt(jti string, userID uint, maxAttempts int, now time.Time) (bool, error) {
+	result := r.DB.Model(&models.MFAChallenge{}).
+		Where("jti = ? AND user_id = ? AND used_at IS NULL AND expires_at > ? AND attempts < ?", jti, userID, now, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// MarkUsed 实现了 MFAChallengeRepository 接口的 MarkUsed 方法。
+func (r *GormMFAChallengeRepository) MarkUsed(jti string, now time.Time) (bool, error) {
+	result := r.DB.Model(&models.MFAChallenge{}).Where("jti = ? AND used_at IS NULL", jti).Update("used_at", now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteExpired 实现了 MFAChallengeRepository 接口的 DeleteExpired 方法。
+func (r *GormMFAChallengeRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.DB.Where("expires_at <= ?", now).Delete(&models.MFAChallenge{})
+	return result.RowsAffected, result.Error
+}
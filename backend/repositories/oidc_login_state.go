@@ -0,0 +1,60 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OIDCLoginStateRepository 定义了与进行中的第三方登录相关的操作接口。
+type OIDCLoginStateRepository interface {
+	// Create 保存一个新的登录状态。
+	Create(state *models.OIDCLoginState) error
+	// Consume 取出并删除 state 哈希对应的登录状态。
+	// 不存在或已被另一个请求取出时返回 gorm.ErrRecordNotFound，保证每个 state 只能使用一次。
+	Consume(stateHash string) (*models.OIDCLoginState, error)
+	// DeleteExpired 删除所有已过期的登录状态，返回删除的数量。
+	DeleteExpired(now time.Time) (int64, error)
+}
+
+// GormOIDCLoginStateRepository 是 OIDCLoginStateRepository 的GORM实现。
+type GormOIDCLoginStateRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormOIDCLoginStateRepository 是一个构造函数，用于创建一个新的 GormOIDCLoginStateRepository 实例。
+func NewGormOIDCLoginStateRepository(db *gorm.DB) *GormOIDCLoginStateRepository {
+	return &GormOIDCLoginStateRepository{DB: db}
+}
+
+// Create 实现了 OIDCLoginStateRepository 接口的 Create 方法。
+func (r *GormOIDCLoginStateRepository) Create(state *models.OIDCLoginState) error {
+	return r.DB.Create(state).Error
+}
+
+// Consume 实现了 OIDCLoginStateRepository 接口的 Consume 方法。
+// 只有删除成功（RowsAffected 为1）的请求才能得到登录状态，并发的重复回调只有一个会成功。
+func (r *GormOIDCLoginStateRepository) Consume(stateHash string) (*models.OIDCLoginState, error) {
+	var state models.OIDCLoginState
+	if err := r.DB.Where("state_hash = ?", stateHash).First(&state).Error; err != nil {
+		return nil, err
+	}
+	result := r.DB.Where("id = ?", state.ID).Delete(&models.OIDCLoginState{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &state, nil
+}
+
+// DeleteExpired 实现了 OIDCLoginStateRepository 接口的 DeleteExpired 方法。
+func (r *GormOIDCLoginStateRepository) DeleteExpired(now time.Time) (int64, error) {
+	result := r.DB.Where("expires_at <= ?", now).Delete(&models.OIDCLoginState{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,157 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"context"
+	"go-web/models"
+	"go-web/tenant"
+
+	"gorm.io/gorm"
+)
+
+// OrganizationRepository 定义了与组织相关的操作接口。
+type OrganizationRepository interface {
+	// Create 创建一个新的组织。
+	Create(organization *models.Organization) error
+	// FindByID 根据ID查找组织。
+	FindByID(id uint) (*models.Organization, error)
+	// FindBySlug 根据唯一标识查找组织。
+	FindBySlug(slug string) (*models.Organization, error)
+	// FindAll 获取所有组织，按ID排序。
+	FindAll() ([]models.Organization, error)
+}
+
+// GormOrganizationRepository 是 OrganizationRepository 的GORM实现。
+type GormOrganizationRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormOrganizationRepository 是一个构造函数，用于创建一个新的 GormOrganizationRepository 实例。
+func NewGormOrganizationRepository(db *gorm.DB) *GormOrganizationRepository {
+	return &GormOrganizationRepository{DB: db}
+}
+
+// Create 实现了 OrganizationRepository 接口的 Create 方法。
+func (r *GormOrganizationRepository) Create(organization *models.Organization) error {
+	return r.DB.Create(organization).Error
+}
+
+// FindByID 实现了 OrganizationRepository 接口的 FindByID 方法。
+func (r *GormOrganizationRepository) FindByID(id uint) (*models.Organization, error) {
+	var organization models.Organization
+	if err := r.DB.First(&organization, id).Error; err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// FindBySlug 实现了 OrganizationRepository 接口的 FindBySlug 方法。
+func (r *GormOrganizationRepository) FindBySlug(slug string) (*models.Organization, error) {
+	var organization models.Organization
+	if err := r.DB.Where("slug = ?", slug).First(&organization).Error; err != nil {
+		return nil, err
+	}
+	return &organization, nil
+}
+
+// FindAll 实现了 OrganizationRepository 接口的 FindAll 方法。
+func (r *GormOrganizationRepository) FindAll() ([]models.Organization, error) {
+	var organizations []models.Organization
+	err := r.DB.Order("id").Find(&organizations).Error
+	return organizations, err
+}
+
+// MembershipRepository 定义了与组织成员相关的操作接口。
+// 接收 ctx 的方法作用于上下文中的当前组织（见 tenant.NewContext），
+// 组织条件由 tenant 插件自动追加，实现中不需要也不应该手动过滤 organization_id；
+// 上下文中没有组织时这些方法返回 tenant.ErrNoTenant。不接收 ctx 的方法明确跨组织查询。
+type MembershipRepository interface {
+	// List 返回当前组织的所有成员，并预加载用户和角色。
+	List(ctx context.Context) ([]models.Membership, error)
+	// FindByUser 查找用户在当前组织中的成员关系。
+	FindByUser(ctx context.Context, userID uint) (*models.Membership, error)
+	// Create 将用户加入当前组织。
+	Create(ctx context.Context, membership *models.Membership) error
+	// UpdateRole 修改用户在当前组织中的角色，用户不是成员时返回 gorm.ErrRecordNotFound。
+	UpdateRole(ctx context.Context, userID, roleID uint) error
+	// Delete 将用户移出当前组织，用户不是成员时返回 gorm.ErrRecordNotFound。
+	Delete(ctx context.Context, userID uint) error
+	// FindByOrganizationAndUser 查找用户在指定组织中的成员关系，并预加载角色。
+	FindByOrganizationAndUser(organizationID, userID uint) (*models.Membership, error)
+	// FindByUserID 返回用户加入的所有组织，并预加载组织和角色。
+	FindByUserID(userID uint) ([]models.Membership, error)
+}
+
+// GormMembershipRepository 是 MembershipRepository 的GORM实现。
+type GormMembershipRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormMembershipRepository 是一个构造函数，用于创建一个新的 GormMembershipRepository 实例。
+func NewGormMembershipRepository(db *gorm.DB) *GormMembershipRepository {
+	return &GormMembershipRepository{DB: db}
+}
+
+// List 实现了 MembershipRepository 接口的 List 方法。
+func (r *GormMembershipRepository) List(ctx context.Context) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.DB.WithContext(ctx).Preload("User").Preload("Role").Order("id").Find(&memberships).Error
+	return memberships, err
+}
+
+// FindByUser 实现了 MembershipRepository 接口的 FindByUser 方法。
+func (r *GormMembershipRepository) FindByUser(ctx context.Context, userID uint) (*models.Membership, error) {
+	var membership models.Membership
+	if err := r.DB.WithContext(ctx).Preload("User").Preload("Role").Where("user_id = ?", userID).First(&membership).Error; err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// Create 实现了 MembershipRepository 接口的 Create 方法。
+func (r *GormMembershipRepository) Create(ctx context.Context, membership *models.Membership) error {
+	return r.DB.WithContext(ctx).Omit("Organization", "User", "Role").Create(membership).Error
+}
+
+// UpdateRole 实现了 MembershipRepository 接口的 UpdateRole 方法。
+func (r *GormMembershipRepository) UpdateRole(ctx context.Context, userID, roleID uint) error {
+	result := r.DB.WithContext(ctx).Model(&models.Membership{}).Where("user_id = ?", userID).Update("role_id", roleID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete 实现了 MembershipRepository 接口的 Delete 方法。
+func (r *GormMembershipRepository) Delete(ctx context.Context, userID uint) error {
+	result := r.DB.WithContext(ctx).Where("user_id = ?", userID).Delete(&models.Membership{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// FindByOrganizationAndUser 实现了 MembershipRepository 接口的 FindByOrganizationAndUser 方法。
+// 签发令牌时请求中还没有选择组织，因此取消组织限定，按参数中的组织查询。
+func (r *GormMembershipRepository) FindByOrganizationAndUser(organizationID, userID uint) (*models.Membership, error) {
+	var membership models.Membership
+	if err := r.DB.WithContext(tenant.Unscoped(context.Background())).Preload("Role").Where("organization_id = ? AND user_id = ?", organizationID, userID).First(&membership).Error; err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// FindByUserID 实现了 MembershipRepository 接口的 FindByUserID 方法。
+func (r *GormMembershipRepository) FindByUserID(userID uint) ([]models.Membership, error) {
+	var memberships []models.Membership
+	err := r.DB.WithContext(tenant.Unscoped(context.Background())).Preload("Organization").Preload("Role").Where("user_id = ?", userID).Order("id").Find(&memberships).Error
+	return memberships, err
+}
@@ -0,0 +1,64 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+
+	"gorm.io/gorm"
+)
+
+// PasswordHistoryRepository 定义了与历史密码相关的操作接口。
+type PasswordHistoryRepository interface {
+	// Create 保存一条历史密码记录。
+	Create(entry *models.PasswordHistory) error
+	// FindRecentByUserID 按时间倒序返回用户最近的 limit 条历史密码。
+	FindRecentByUserID(userID uint, limit int) ([]models.PasswordHistory, error)
+	// PruneForUser 删除用户较早的历史密码，只保留最近的 keep 条。
+	PruneForUser(userID uint, keep int) error
+}
+
+// GormPasswordHistoryRepository 是 PasswordHistoryRepository 的GORM实现。
+type GormPasswordHistoryRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormPasswordHistoryRepository 是一个构造函数，用于创建一个新的 GormPasswordHistoryRepository 实例。
+func NewGormPasswordHistoryRepository(db *gorm.DB) *GormPasswordHistoryRepository {
+	return &GormPasswordHistoryRepository{DB: db}
+}
+
+// Create 实现了 PasswordHistoryRepository 接口的 Create 方法。
+func (r *GormPasswordHistoryRepository) Create(entry *models.PasswordHistory) error {
+	return r.DB.Create(entry).Error
+}
+
+// FindRecentByUserID 实现了 PasswordHistoryRepository 接口的 FindRecentByUserID 方法。
+func (r *GormPasswordHistoryRepository) FindRecentByUserID(userID uint, limit int) ([]models.PasswordHistory, error) {
+	var entries []models.PasswordHistory
+	if limit <= 0 {
+		return entries, nil
+	}
+	err := r.DB.Where("user_id = ?", userID).Order("id DESC").Limit(limit).Find(&entries).Error
+	return entries, err
+}
+
+// PruneForUser 实现了 PasswordHistoryRepository 接口的 PruneForUser 方法。
+// 先查出需要保留的记录ID再删除其余记录，避免使用部分数据库不支持的带 LIMIT 的子查询。
+func (r *GormPasswordHistoryRepository) PruneForUser(userID uint, keep int) error {
+	var keepIDs []uint
+	if keep > 0 {
+		err := r.DB.Model(&models.PasswordHistory{}).Where("user_id = ?", userID).
+			Order("id DESC").Limit(keep).Pluck("id", &keepIDs).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	query := r.DB.Where("user_id = ?", userID)
+	if len(keepIDs) > 0 {
+		query = query.Where("id NOT IN ?", keepIDs)
+	}
+	return query.Delete(&models.PasswordHistory{}).Error
+}
@@ -0,0 +1,67 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordResetTokenRepository 定义了与密码重置令牌相关的操作接口。
+type PasswordResetTokenRepository interface {
+	// Create 保存一个新的密码重置令牌。
+	Create(token *models.PasswordResetToken) error
+	// FindByHash 根据令牌哈希查找密码重置令牌。
+	FindByHash(hash string) (*models.PasswordResetToken, error)
+	// MarkUsed 将一个尚未使用的令牌标记为已使用。
+	// 返回值表示本次调用是否真正完成了标记（令牌已被使用时返回 false）。
+	MarkUsed(id uint) (bool, error)
+	// InvalidateAllForUser 作废某个用户所有尚未使用的重置令牌。
+	InvalidateAllForUser(userID uint) error
+}
+
+// GormPasswordResetTokenRepository 是 PasswordResetTokenRepository 的GORM实现。
+type GormPasswordResetTokenRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormPasswordResetTokenRepository 是一个构造函数，用于创建一个新的 GormPasswordResetTokenRepository 实例。
+func NewGormPasswordResetTokenRepository(db *gorm.DB) *GormPasswordResetTokenRepository {
+	return &GormPasswordResetTokenRepository{DB: db}
+}
+
+// Create 实现了 PasswordResetTokenRepository 接口的 Create 方法。
+func (r *GormPasswordResetTokenRepository) Create(token *models.PasswordResetToken) error {
+	return r.DB.Create(token).Error
+}
+
+// FindByHash 实现了 PasswordResetTokenRepository 接口的 FindByHash 方法。
+func (r *GormPasswordResetTokenRepository) FindByHash(hash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	if err := r.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed 实现了 PasswordResetTokenRepository 接口的 MarkUsed 方法。
+// 通过 "used_at IS NULL" 条件更新，保证同一个令牌只能被成功使用一次。
+func (r *GormPasswordResetTokenRepository) MarkUsed(id uint) (bool, error) {
+	result := r.DB.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// InvalidateAllForUser 实现了 PasswordResetTokenRepository 接口的 InvalidateAllForUser 方法。
+func (r *GormPasswordResetTokenRepository) InvalidateAllForUser(userID uint) error {
+	return r.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
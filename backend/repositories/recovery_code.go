@@ -0,0 +1,76 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCodeRepository 定义了与两步验证恢复码相关的操作接口。
+type RecoveryCodeRepository interface {
+	// ReplaceForUser 删除用户现有的恢复码，并保存一组新的恢复码哈希。
+	ReplaceForUser(userID uint, hashes []string) error
+	// Use 将一个尚未使用的恢复码标记为已使用。
+	// 返回值表示本次调用是否真正完成了标记（恢复码不存在或已被使用时返回 false）。
+	Use(userID uint, hash string) (bool, error)
+	// CountUnused 返回用户剩余可用的恢复码数量。
+	CountUnused(userID uint) (int64, error)
+	// DeleteAllForUser 删除用户所有的恢复码。
+	DeleteAllForUser(userID uint) error
+}
+
+// GormRecoveryCodeRepository 是 RecoveryCodeRepository 的GORM实现。
+type GormRecoveryCodeRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormRecoveryCodeRepository 是一个构造函数，用于创建一个新的 GormRecoveryCodeRepository 实例。
+func NewGormRecoveryCodeRepository(db *gorm.DB) *GormRecoveryCodeRepository {
+	return &GormRecoveryCodeRepository{DB: db}
+}
+
+// ReplaceForUser 实现了 RecoveryCodeRepository 接口的 ReplaceForUser 方法。
+// 删除和插入在同一个事务中完成，不会出现用户同时没有新旧恢复码的情况。
+func (r *GormRecoveryCodeRepository) ReplaceForUser(userID uint, hashes []string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]models.RecoveryCode, 0, len(hashes))
+		for _, hash := range hashes {
+			codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// Use 实现了 RecoveryCodeRepository 接口的 Use 方法。
+// 通过 "used_at IS NULL" 条件更新，保证同一个恢复码只能被成功使用一次。
+func (r *GormRecoveryCodeRepository) Use(userID uint, hash string) (bool, error) {
+	result := r.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// CountUnused 实现了 RecoveryCodeRepository 接口的 CountUnused 方法。
+func (r *GormRecoveryCodeRepository) CountUnused(userID uint) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// DeleteAllForUser 实现了 RecoveryCodeRepository 接口的 DeleteAllForUser 方法。
+func (r *GormRecoveryCodeRepository) DeleteAllForUser(userID uint) error {
+	return r.DB.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+}
@@ -0,0 +1,87 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshTokenRepository 定义了与刷新令牌相关的操作接口。
+type RefreshTokenRepository interface {
+	// Create 保存一个新签发的刷新令牌。
+	Create(token *models.RefreshToken) error
+	// FindByHash 根据令牌哈希查找刷新令牌。
+	FindByHash(hash string) (*models.RefreshToken, error)
+	// Revoke 将一个仍然有效的令牌标记为已吊销，并记录取代它的令牌ID。
+	// 返回值表示本次调用是否真正完成了吊销（令牌已被吊销时返回 false）。
+	Revoke(id uint, replacedByID *uint) (bool, error)
+	// RevokeFamily 吊销同一令牌族中所有仍然有效的令牌。
+	RevokeFamily(familyID string) error
+	// RevokeAllForUser 吊销某个用户所有仍然有效的刷新令牌。
+	RevokeAllForUser(userID uint) error
+	// PurgeStale 彻底删除在 now 之前过期、或者在 revokedBefore 之前被吊销的刷新令牌，返回删除的数量。
+	PurgeStale(now, revokedBefore time.Time) (int64, error)
+}
+
+// GormRefreshTokenRepository 是 RefreshTokenRepository 的GORM实现。
+type GormRefreshTokenRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormRefreshTokenRepository 是一个构造函数，用于创建一个新的 GormRefreshTokenRepository 实例。
+func NewGormRefreshTokenRepository(db *gorm.DB) *GormRefreshTokenRepository {
+	return &GormRefreshTokenRepository{DB: db}
+}
+
+// Create 实现了 RefreshTokenRepository 接口的 Create 方法。
+func (r *GormRefreshTokenRepository) Create(token *models.RefreshToken) error {
+	return r.DB.Create(token).Error
+}
+
+// FindByHash 实现了 RefreshTokenRepository 接口的 FindByHash 方法。
+func (r *GormRefreshTokenRepository) FindByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.DB.Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Revoke 实现了 RefreshTokenRepository 接口的 Revoke 方法。
+// 通过 "revoked_at IS NULL" 条件更新，保证并发轮换同一个令牌时只有一个请求能够成功。
+func (r *GormRefreshTokenRepository) Revoke(id uint, replacedByID *uint) (bool, error) {
+	result := r.DB.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "replaced_by_id": replacedByID})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeFamily 实现了 RefreshTokenRepository 接口的 RevokeFamily 方法。
+func (r *GormRefreshTokenRepository) RevokeFamily(familyID string) error {
+	return r.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUser 实现了 RefreshTokenRepository 接口的 RevokeAllForUser 方法。
+func (r *GormRefreshTokenRepository) RevokeAllForUser(userID uint) error {
+	return r.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// PurgeStale 实现了 RefreshTokenRepository 接口的 PurgeStale 方法。
+// 记录直接从表中删除，不保留软删除的行。
+func (r *GormRefreshTokenRepository) PurgeStale(now, revokedBefore time.Time) (int64, error) {
+	result := r.DB.Unscoped().
+		Where("expires_at < ? OR (revoked_at IS NOT NULL AND revoked_at < ?)", now, revokedBefore).
+		Delete(&models.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
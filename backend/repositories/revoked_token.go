@@ -0,0 +1,99 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RevokedTokenRepository 定义了访问令牌吊销列表的操作接口。
+// 提供GORM和内存两种实现：前者适用于多实例部署，后者适用于单实例或测试环境。
+type RevokedTokenRepository interface {
+	// Revoke 将令牌加入吊销列表，直到 expiresAt 之后才可以被清理。
+	Revoke(jti string, expiresAt time.Time) error
+	// IsRevoked 检查令牌是否已被吊销。
+	IsRevoked(jti string) (bool, error)
+	// PurgeExpired 删除所有已经过期的吊销记录，返回删除的数量。
+	PurgeExpired() (int64, error)
+}
+
+// GormRevokedTokenRepository 是 RevokedTokenRepository 的GORM实现。
+type GormRevokedTokenRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormRevokedTokenRepository 是一个构造函数，用于创建一个新的 GormRevokedTokenRepository 实例。
+func NewGormRevokedTokenRepository(db *gorm.DB) *GormRevokedTokenRepository {
+	return &GormRevokedTokenRepository{DB: db}
+}
+
+// Revoke 实现了 RevokedTokenRepository 接口的 Revoke 方法。
+// 重复吊销同一个令牌不会报错。
+func (r *GormRevokedTokenRepository) Revoke(jti string, expiresAt time.Time) error {
+	token := &models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(token).Error
+}
+
+// IsRevoked 实现了 RevokedTokenRepository 接口的 IsRevoked 方法。
+func (r *GormRevokedTokenRepository) IsRevoked(jti string) (bool, error) {
+	var count int64
+	if err := r.DB.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// PurgeExpired 实现了 RevokedTokenRepository 接口的 PurgeExpired 方法。
+func (r *GormRevokedTokenRepository) PurgeExpired() (int64, error) {
+	result := r.DB.Where("expires_at < ?", time.Now()).Delete(&models.RevokedToken{})
+	return result.RowsAffected, result.Error
+}
+
+// MemoryRevokedTokenRepository 是 RevokedTokenRepository 的内存实现。
+// 吊销记录只保存在当前进程中，重启后丢失，也不会在多个实例之间共享。
+type MemoryRevokedTokenRepository struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+}
+
+// NewMemoryRevokedTokenRepository 是一个构造函数，用于创建一个新的 MemoryRevokedTokenRepository 实例。
+func NewMemoryRevokedTokenRepository() *MemoryRevokedTokenRepository {
+	return &MemoryRevokedTokenRepository{tokens: make(map[string]time.Time)}
+}
+
+// Revoke 实现了 RevokedTokenRepository 接口的 Revoke 方法。
+func (r *MemoryRevokedTokenRepository) Revoke(jti string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens[jti] = expiresAt
+	return nil
+}
+
+// IsRevoked 实现了 RevokedTokenRepository 接口的 IsRevoked 方法。
+func (r *MemoryRevokedTokenRepository) IsRevoked(jti string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.tokens[jti]
+	return ok, nil
+}
+
+// PurgeExpired 实现了 RevokedTokenRepository 接口的 PurgeExpired 方法。
+func (r *MemoryRevokedTokenRepository) PurgeExpired() (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var purged int64
+	for jti, expiresAt := range r.tokens {
+		if expiresAt.Before(now) {
+			delete(r.tokens, jti)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -4,9 +4,12 @@ package repositories
 // 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
 
 import (
+	"context"
 	"go-web/models"
+	"go-web/tenant"
 
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
 // RoleRepository 定义了与角色数据相关的操作接口。
@@ -16,6 +19,20 @@ type RoleRepository interface {
 	FindByName(name string) (*models.Role, error)
 	// Create 创建一个新的角色。
 	Create(role *models.Role) error
+	// FindAll 获取所有角色，按ID排序。
+	FindAll() ([]models.Role, error)
+	// FindByID 根据角色ID查找角色。
+	FindByID(id uint) (*models.Role, error)
+	// Update 更新角色的名称和描述。
+	Update(role *models.Role) error
+	// Delete 删除一个角色。
+	Delete(role *models.Role) error
+	// CountUsers 统计持有该角色的用户数量。
+	CountUsers(roleID uint) (int64, error)
+	// CountMemberships 统计在各个组织中持有该角色的成员数量。
+	CountMemberships(roleID uint) (int64, error)
+	// Primary 返回一个总是从主库读取的 RoleRepository，用于读取刚刚写入的数据。
+	Primary() RoleRepository
 }
 
 // GormRoleRepository 是 RoleRepository 的GORM实现。
@@ -29,6 +46,11 @@ func NewGormRoleRepository(db *gorm.DB) *GormRoleRepository {
 	return &GormRoleRepository{DB: db}
 }
 
+// Primary 实现了 RoleRepository 接口的 Primary 方法，返回的仓库把查询发往主库而不是只读副本。
+func (r *GormRoleRepository) Primary() RoleRepository {
+	return &GormRoleRepository{DB: r.DB.Clauses(dbresolver.Write).Session(&gorm.Session{})}
+}
+
 // FindByName 实现了 RoleRepository 接口的 FindByName 方法。
 // 它通过GORM的Where子句查询指定名称的角色。
 func (r *GormRoleRepository) FindByName(name string) (*models.Role, error) {
@@ -44,3 +66,49 @@ func (r *GormRoleRepository) FindByName(name string) (*models.Role, error) {
 func (r *GormRoleRepository) Create(role *models.Role) error {
 	return r.DB.Create(role).Error
 }
+
+// FindAll 实现了 RoleRepository 接口的 FindAll 方法。
+func (r *GormRoleRepository) FindAll() ([]models.Role, error) {
+	var roles []models.Role
+	if err := r.DB.Order("id").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+// FindByID 实现了 RoleRepository 接口的 FindByID 方法。
+func (r *GormRoleRepository) FindByID(id uint) (*models.Role, error) {
+	var role models.Role
+	if err := r.DB.First(&role, id).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// Update 实现了 RoleRepository 接口的 Update 方法。
+// 只写入名称和描述两列。
+func (r *GormRoleRepository) Update(role *models.Role) error {
+	return r.DB.Model(role).Select("name", "description").Updates(role).Error
+}
+
+// Delete 实现了 RoleRepository 接口的 Delete 方法。
+// 角色名称有唯一索引，因此直接删除记录而不是软删除，以便之后可以重新创建同名角色。
+func (r *GormRoleRepository) Delete(role *models.Role) error {
+	return r.DB.Unscoped().Delete(role).Error
+}
+
+// CountUsers 实现了 RoleRepository 接口的 CountUsers 方法。
+// 已被软删除的用户仍然引用角色，因此也计算在内。
+func (r *GormRoleRepository) CountUsers(roleID uint) (int64, error) {
+	var count int64
+	err := r.DB.Unscoped().Model(&models.User{}).Where("role_id = ?", roleID).Count(&count).Error
+	return count, err
+}
+
+// CountMemberships 实现了 RoleRepository 接口的 CountMemberships 方法。
+// 查询取消组织限定，因此统计所有组织中的成员。
+func (r *GormRoleRepository) CountMemberships(roleID uint) (int64, error) {
+	var count int64
+	err := r.DB.WithContext(tenant.Unscoped(context.Background())).Model(&models.Membership{}).Where("role_id = ?", roleID).Count(&count).Error
+	return count, err
+}
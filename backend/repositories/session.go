@@ -0,0 +1,115 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SessionRepository 定义了与登录会话相关的操作接口。
+type SessionRepository interface {
+	// Create 保存一个新的会话。
+	Create(session *models.Session) error
+	// FindByID 根据ID查找会话。
+	FindByID(id uint) (*models.Session, error)
+	// FindByFamilyID 根据刷新令牌族ID查找会话。
+	FindByFamilyID(familyID string) (*models.Session, error)
+	// FindActiveByUserID 返回用户所有未吊销且未过期的会话，最近使用的排在前面。
+	FindActiveByUserID(userID uint, now time.Time) ([]models.Session, error)
+	// Extend 在刷新令牌轮换后更新会话的过期时间和最近使用时间。
+	Extend(id uint, expiresAt, seenAt time.Time) error
+	// Touch 将最近使用时间更新为 seenAt，但只在上次记录早于 staleBefore 时写入，以减少每个请求的写操作。
+	Touch(id uint, seenAt, staleBefore time.Time) error
+	// Revoke 吊销一个会话，返回本次调用是否真正完成了吊销（会话已被吊销时返回 false）。
+	Revoke(id uint) (bool, error)
+	// RevokeAllForUser 吊销用户所有仍然有效的会话。
+	RevokeAllForUser(userID uint) error
+	// RevokeByFamilyID 吊销刷新令牌族对应的会话。
+	RevokeByFamilyID(familyID string) error
+}
+
+// GormSessionRepository 是 SessionRepository 的GORM实现。
+type GormSessionRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormSessionRepository 是一个构造函数，用于创建一个新的 GormSessionRepository 实例。
+func NewGormSessionRepository(db *gorm.DB) *GormSessionRepository {
+	return &GormSessionRepository{DB: db}
+}
+
+// Create 实现了 SessionRepository 接口的 Create 方法。
+func (r *GormSessionRepository) Create(session *models.Session) error {
+	return r.DB.Create(session).Error
+}
+
+// FindByID 实现了 SessionRepository 接口的 FindByID 方法。
+func (r *GormSessionRepository) FindByID(id uint) (*models.Session, error) {
+	var session models.Session
+	if err := r.DB.First(&session, id).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindByFamilyID 实现了 SessionRepository 接口的 FindByFamilyID 方法。
+func (r *GormSessionRepository) FindByFamilyID(familyID string) (*models.Session, error) {
+	var session models.Session
+	if err := r.DB.Where("family_id = ?", familyID).First(&session).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// FindActiveByUserID 实现了 SessionRepository 接口的 FindActiveByUserID 方法。
+func (r *GormSessionRepository) FindActiveByUserID(userID uint, now time.Time) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).
+		Order("last_seen_at DESC").Find(&sessions).Error
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Extend 实现了 SessionRepository 接口的 Extend 方法。
+func (r *GormSessionRepository) Extend(id uint, expiresAt, seenAt time.Time) error {
+	return r.DB.Model(&models.Session{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"expires_at": expiresAt, "last_seen_at": seenAt}).Error
+}
+
+// Touch 实现了 SessionRepository 接口的 Touch 方法。
+func (r *GormSessionRepository) Touch(id uint, seenAt, staleBefore time.Time) error {
+	return r.DB.Model(&models.Session{}).
+		Where("id = ? AND last_seen_at < ?", id, staleBefore).
+		Update("last_seen_at", seenAt).Error
+}
+
+// Revoke 实现了 SessionRepository 接口的 Revoke 方法。
+func (r *GormSessionRepository) Revoke(id uint) (bool, error) {
+	result := r.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeAllForUser 实现了 SessionRepository 接口的 RevokeAllForUser 方法。
+func (r *GormSessionRepository) RevokeAllForUser(userID uint) error {
+	return r.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeByFamilyID 实现了 SessionRepository 接口的 RevokeByFamilyID 方法。
+func (r *GormSessionRepository) RevokeByFamilyID(familyID string) error {
+	return r.DB.Model(&models.Session{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
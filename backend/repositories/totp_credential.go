@@ -0,0 +1,75 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TOTPCredentialRepository 定义了与TOTP密钥相关的操作接口。
+type TOTPCredentialRepository interface {
+	// FindByUserID 查找用户的TOTP密钥。
+	FindByUserID(userID uint) (*models.TOTPCredential, error)
+	// Save 创建或更新一条TOTP密钥记录。
+	Save(credential *models.TOTPCredential) error
+	// Confirm 将密钥标记为已确认，并记录确认时使用的时间步。
+	Confirm(id uint, step int64) error
+	// UseStep 记录一次成功校验的时间步。
+	// 只有当 step 大于上一次使用的时间步时才会更新，返回值表示本次是否更新成功，
+	// 从而保证同一个验证码不能被使用两次。
+	UseStep(id uint, step int64) (bool, error)
+	// DeleteByUserID 删除用户的TOTP密钥。
+	DeleteByUserID(userID uint) error
+}
+
+// GormTOTPCredentialRepository 是 TOTPCredentialRepository 的GORM实现。
+type GormTOTPCredentialRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormTOTPCredentialRepository 是一个构造函数，用于创建一个新的 GormTOTPCredentialRepository 实例。
+func NewGormTOTPCredentialRepository(db *gorm.DB) *GormTOTPCredentialRepository {
+	return &GormTOTPCredentialRepository{DB: db}
+}
+
+// FindByUserID 实现了 TOTPCredentialRepository 接口的 FindByUserID 方法。
+func (r *GormTOTPCredentialRepository) FindByUserID(userID uint) (*models.TOTPCredential, error) {
+	var credential models.TOTPCredential
+	if err := r.DB.Where("user_id = ?", userID).First(&credential).Error; err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+// Save 实现了 TOTPCredentialRepository 接口的 Save 方法。
+func (r *GormTOTPCredentialRepository) Save(credential *models.TOTPCredential) error {
+	return r.DB.Save(credential).Error
+}
+
+// Confirm 实现了 TOTPCredentialRepository 接口的 Confirm 方法。
+func (r *GormTOTPCredentialRepository) Confirm(id uint, step int64) error {
+	return r.DB.Model(&models.TOTPCredential{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"confirmed_at": time.Now(), "last_used_step": step}).Error
+}
+
+// UseStep 实现了 TOTPCredentialRepository 接口的 UseStep 方法。
+// 通过 "last_used_step < ?" 条件更新，并发请求中只有一个能使用同一个验证码。
+func (r *GormTOTPCredentialRepository) UseStep(id uint, step int64) (bool, error) {
+	result := r.DB.Model(&models.TOTPCredential{}).
+		Where("id = ? AND last_used_step < ?", id, step).
+		Update("last_used_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteByUserID 实现了 TOTPCredentialRepository 接口的 DeleteByUserID 方法。
+func (r *GormTOTPCredentialRepository) DeleteByUserID(userID uint) error {
+	return r.DB.Where("user_id = ?", userID).Delete(&models.TOTPCredential{}).Error
+}
@@ -4,9 +4,14 @@ package repositories
 // 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
 
 import (
+	"context"
 	"go-web/models"
+	"go-web/tenant"
+	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 )
 
 // UserRepository 定义了与用户数据相关的操作接口。
@@ -14,20 +19,43 @@ import (
 type UserRepository interface {
 	// FindByUsername 根据用户名查找用户。
 	FindByUsername(username string) (*models.User, error)
+	// FindByEmail 根据邮箱查找用户。
+	FindByEmail(email string) (*models.User, error)
 	// FindByUsernameOrEmail 根据用户名或邮箱查找用户。
 	FindByUsernameOrEmail(username, email string) (*models.User, error)
 	// Create 创建一个新用户。
 	Create(user *models.User) error
-	// FindAll 获取所有用户列表。
-	FindAll() ([]models.User, error)
+	// List 按条件分页查询用户，同时返回符合筛选条件的用户总数。
+	List(query UserQuery) ([]models.User, int64, error)
 	// FindByID 根据用户ID查找用户。
 	FindByID(id uint) (*models.User, error)
 	// Update 更新一个已存在的用户信息。
 	Update(user *models.User) error
-	// Delete 删除一个用户。
+	// UpdatePassword 只更新用户的密码哈希，用于升级哈希算法等不改变密码本身的场景。
+	UpdatePassword(userID uint, hashedPassword string) error
+	// ChangePassword 更新用户的密码哈希，并记录密码修改的时间。
+	ChangePassword(userID uint, hashedPassword string, changedAt time.Time) error
+	// FindPasswordChangedAt 返回用户最近一次修改密码的时间，从未修改过时返回 nil。
+	FindPasswordChangedAt(userID uint) (*time.Time, error)
+	// MarkEmailVerified 将用户的邮箱标记为已验证。
+	MarkEmailVerified(userID uint) error
+	// Delete 删除一个用户。用户只是被标记为已删除，可以恢复，直到被彻底清除。
 	Delete(user *models.User) error
+	// FindDeleted 返回所有已删除但尚未清除的用户，最近删除的在前。
+	FindDeleted() ([]models.User, error)
+	// FindDeletedByID 根据ID查找已删除的用户，用户不存在或没有被删除时返回 gorm.ErrRecordNotFound。
+	FindDeletedByID(id uint) (*models.User, error)
+	// Restore 恢复一个已删除的用户，用户不存在或没有被删除时返回 gorm.ErrRecordNotFound。
+	// 用户名或邮箱已经被其他用户使用时返回 gorm.ErrDuplicatedKey。
+	Restore(id uint) error
+	// Purge 彻底清除用户及其会话、令牌、外部身份等数据。
+	Purge(id uint) error
+	// FindDeletedBefore 返回在 cutoff 之前删除的用户的ID。
+	FindDeletedBefore(cutoff time.Time) ([]uint, error)
 	// LoadRole 加载用户的角色信息。
 	LoadRole(user *models.User) error
+	// Primary 返回一个总是从主库读取的 UserRepository，用于读取刚刚写入的数据。
+	Primary() UserRepository
 }
 
 // GormUserRepository 是 UserRepository 的GORM实现。
@@ -41,6 +69,13 @@ func NewGormUserRepository(db *gorm.DB) *GormUserRepository {
 	return &GormUserRepository{DB: db}
 }
 
+// Primary 实现了 UserRepository 接口的 Primary 方法。
+// 配置了只读副本时，查询默认发往副本，可能还看不到刚刚在主库上的写入；返回的仓库把查询也发往主库。
+// 写入和事务总是在主库上执行，不需要调用此方法。
+func (r *GormUserRepository) Primary() UserRepository {
+	return &GormUserRepository{DB: r.DB.Clauses(dbresolver.Write).Session(&gorm.Session{})}
+}
+
 // FindByUsername 实现了 UserRepository 接口的 FindByUsername 方法。
 func (r *GormUserRepository) FindByUsername(username string) (*models.User, error) {
 	var user models.User
@@ -50,6 +85,15 @@ func (r *GormUserRepository) FindByUsername(username string) (*models.User, erro
 	return &user, nil
 }
 
+// FindByEmail 实现了 UserRepository 接口的 FindByEmail 方法。
+func (r *GormUserRepository) FindByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 // FindByUsernameOrEmail 实现了 UserRepository 接口的 FindByUsernameOrEmail 方法。
 func (r *GormUserRepository) FindByUsernameOrEmail(username, email string) (*models.User, error) {
 	var user models.User
@@ -64,14 +108,72 @@ func (r *GormUserRepository) Create(user *models.User) error {
 	return r.DB.Create(user).Error
 }
 
-// FindAll 实现了 UserRepository 接口的 FindAll 方法。
-// 使用 Preload("Role") 来预加载关联的角色信息，避免N+1查询问题。
-func (r *GormUserRepository) FindAll() ([]models.User, error) {
+// List 实现了 UserRepository 接口的 List 方法。
+// 筛选条件只使用有索引的列（角色通过 roles.name 的唯一索引转换为 role_id），
+// 翻页时优先使用键集分页（WHERE (sort, id) > (?, ?)），可以沿索引读取而不需要跳过前面的行。
+func (r *GormUserRepository) List(query UserQuery) ([]models.User, int64, error) {
+	filtered := r.filter(r.DB.Model(&models.User{}), query)
+
+	var total int64
+	if err := filtered.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	column, unique := userSortColumn(query.SortField)
+	direction, compare := "ASC", ">"
+	if query.Descending {
+		direction, compare = "DESC", "<"
+	}
+
+	page := filtered.Session(&gorm.Session{}).Preload("Role").Order(column + " " + direction)
+	if !unique {
+		page = page.Order("id " + direction)
+	}
+	if after := query.After; after != nil {
+		value, err := after.value(query.SortField)
+		if err != nil {
+			return nil, 0, err
+		}
+		if unique {
+			page = page.Where(column+" "+compare+" ?", value)
+		} else {
+			page = page.Where("("+column+", id) "+compare+" (?, ?)", value, after.ID)
+		}
+	} else if query.Offset > 0 {
+		page = page.Offset(query.Offset)
+	}
+	if query.Limit > 0 {
+		page = page.Limit(query.Limit)
+	}
+
 	var users []models.User
-	if err := r.DB.Preload("Role").Find(&users).Error; err != nil {
-		return nil, err
+	if err := page.Find(&users).Error; err != nil {
+		return nil, 0, err
 	}
-	return users, nil
+	return users, total, nil
+}
+
+// filter 添加 query 中的筛选条件。
+func (r *GormUserRepository) filter(db *gorm.DB, query UserQuery) *gorm.DB {
+	if query.Role != "" {
+		db = db.Where("role_id IN (?)", r.DB.Model(&models.Role{}).Select("id").Where("name = ?", query.Role))
+	}
+	if query.Search != "" {
+		// PostgreSQL 的 LIKE 区分大小写，使用 ILIKE 以便与其他数据库的行为一致，并可以使用 pg_trgm 索引
+		like := "LIKE"
+		if r.DB.Dialector.Name() == "postgres" {
+			like = "ILIKE"
+		}
+		pattern := "%" + escapeLike(query.Search) + "%"
+		db = db.Where("username "+like+" ? ESCAPE '!' OR email "+like+" ? ESCAPE '!'", pattern, pattern)
+	}
+	if query.CreatedAfter != nil {
+		db = db.Where("created_at >= ?", query.CreatedAfter.Local())
+	}
+	if query.CreatedBefore != nil {
+		db = db.Where("created_at < ?", query.CreatedBefore.Local())
+	}
+	return db
 }
 
 // FindByID 实现了 UserRepository 接口的 FindByID 方法。
@@ -90,13 +192,197 @@ func (r *GormUserRepository) Update(user *models.User) error {
 	return r.DB.Save(user).Error
 }
 
+// UpdatePassword 实现了 UserRepository 接口的 UpdatePassword 方法。
+// 与 Update 不同，它只写入密码列，不会覆盖并发修改的其他字段。
+func (r *GormUserRepository) UpdatePassword(userID uint, hashedPassword string) error {
+	return r.DB.Model(&models.User{}).Where("id = ?", userID).Update("password", hashedPassword).Error
+}
+
+// ChangePassword 实现了 UserRepository 接口的 ChangePassword 方法。
+func (r *GormUserRepository) ChangePassword(userID uint, hashedPassword string, changedAt time.Time) error {
+	return r.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"password":            hashedPassword,
+		"password_changed_at": changedAt,
+	}).Error
+}
+
+// FindPasswordChangedAt 实现了 UserRepository 接口的 FindPasswordChangedAt 方法。
+// 认证中间件每个请求都会调用它，因此只查询这一列。
+func (r *GormUserRepository) FindPasswordChangedAt(userID uint) (*time.Time, error) {
+	var user models.User
+	if err := r.DB.Select("id", "password_changed_at").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	return user.PasswordChangedAt, nil
+}
+
+// MarkEmailVerified 实现了 UserRepository 接口的 MarkEmailVerified 方法。
+func (r *GormUserRepository) MarkEmailVerified(userID uint) error {
+	return r.DB.Model(&models.User{}).Where("id = ?", userID).Update("email_verified_at", time.Now()).Error
+}
+
 // Delete 实现了 UserRepository 接口的 Delete 方法。
 func (r *GormUserRepository) Delete(user *models.User) error {
 	return r.DB.Delete(user).Error
 }
 
+// FindDeleted 实现了 UserRepository 接口的 FindDeleted 方法。
+func (r *GormUserRepository) FindDeleted() ([]models.User, error) {
+	var users []models.User
+	if err := r.DB.Unscoped().Preload("Role").Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// FindDeletedByID 实现了 UserRepository 接口的 FindDeletedByID 方法。
+func (r *GormUserRepository) FindDeletedByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.DB.Unscoped().Preload("Role").Where("deleted_at IS NOT NULL").First(&user, id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Restore 实现了 UserRepository 接口的 Restore 方法。
+// 冲突检查和恢复在同一个事务中进行；并发注册在检查之后占用了用户名或邮箱时，
+// 唯一索引冲突同样转换为 gorm.ErrDuplicatedKey。
+func (r *GormUserRepository) Restore(id uint) error {
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Unscoped().Where("deleted_at IS NOT NULL").First(&user, id).Error; err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ? OR email = ?", user.Username, user.Email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return gorm.ErrDuplicatedKey
+		}
+		return tx.Unscoped().Model(&user).Update("deleted_at", nil).Error
+	})
+	if translator, ok := r.DB.Dialector.(gorm.ErrorTranslator); ok && err != nil {
+		err = translator.Translate(err)
+	}
+	return err
+}
+
+// Purge 实现了 UserRepository 接口的 Purge 方法。
+// 在一个事务中删除所有引用该用户的记录，其中部分模型也使用软删除，因此都使用 Unscoped。
+func (r *GormUserRepository) Purge(id uint) error {
+	return r.DB.WithContext(tenant.Unscoped(context.Background())).Transaction(func(tx *gorm.DB) error {
+		for _, owned := range userOwnedModels {
+			if err := tx.Unscoped().Where("user_id = ?", id).Delete(owned).Error; err != nil {
+				return err
+			}
+		}
+		result := tx.Unscoped().Delete(&models.User{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// FindDeletedBefore 实现了 UserRepository 接口的 FindDeletedBefore 方法。
+func (r *GormUserRepository) FindDeletedBefore(cutoff time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.DB.Unscoped().Model(&models.User{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Order("id").Pluck("id", &ids).Error
+	return ids, err
+}
+
+// userOwnedModels 是通过 user_id 引用用户的模型，彻底清除用户时一并删除。
+var userOwnedModels = []interface{}{
+	&models.RefreshToken{},
+	&models.Session{},
+	&models.APIKey{},
+	&models.PasswordResetToken{},
+	&models.PasswordHistory{},
+	&models.TOTPCredential{},
+	&models.RecoveryCode{},
+	&models.MFAChallenge{},
+	&models.UserIdentity{},
+	&models.Membership{},
+}
+
 // LoadRole 实现了 UserRepository 接口的 LoadRole 方法。
 // 它使用GORM的Association方法来显式加载用户关联的角色信息。
 func (r *GormUserRepository) LoadRole(user *models.User) error {
 	return r.DB.Model(user).Association("Role").Find(&user.Role)
 }
+
+// UserSortFields 是用户列表可以排序的字段。
+var UserSortFields = []string{"id", "username", "email", "created_at"}
+
+// UserQuery 是分页查询用户的条件，筛选条件为零值时不筛选。
+type UserQuery struct {
+	Role          string     // 角色名称
+	Search        string     // 用户名或邮箱中包含的子串，不区分大小写
+	CreatedAfter  *time.Time // 创建时间不早于该时间
+	CreatedBefore *time.Time // 创建时间早于该时间
+
+	SortField  string // UserSortFields 之一，为空时按ID排序
+	Descending bool
+
+	Limit  int         // 最多返回的数量，0表示不限制
+	Offset int         // 跳过的数量，After 不为空时忽略
+	After  *UserCursor // 只返回排在该位置之后的用户
+}
+
+// UserCursor 是用户列表中的一个位置，由排序字段的值和用户ID组成。
+type UserCursor struct {
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+}
+
+// NewUserCursor 返回按 sortField 排序时 user 所在的位置。
+func NewUserCursor(user *models.User, sortField string) UserCursor {
+	cursor := UserCursor{ID: user.ID}
+	switch sortField {
+	case "username":
+		cursor.Value = user.Username
+	case "email":
+		cursor.Value = user.Email
+	case "created_at":
+		cursor.Value = user.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return cursor
+}
+
+// value 返回游标中排序字段的值，用于和数据库中的列比较。
+func (c *UserCursor) value(sortField string) (interface{}, error) {
+	switch sortField {
+	case "username", "email":
+		return c.Value, nil
+	case "created_at":
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return nil, err
+		}
+		// SQLite 以文本保存时间，转换为保存时使用的本地时区才能正确比较
+		return t.Local(), nil
+	default:
+		return c.ID, nil
+	}
+}
+
+// userSortColumn 返回排序字段对应的列，以及该列是否唯一。不唯一的列需要再按ID排序以保证顺序确定。
+func userSortColumn(sortField string) (column string, unique bool) {
+	switch sortField {
+	case "username", "email":
+		return sortField, true
+	case "created_at":
+		return "created_at", false
+	default:
+		return "id", true
+	}
+}
+
+// escapeLike 转义 LIKE 模式中的通配符，转义字符为 "!"，在各个数据库中的含义相同。
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
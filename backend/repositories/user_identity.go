@@ -0,0 +1,51 @@
+package repositories
+
+// package repositories 提供了数据访问的抽象层。
+// 它定义了与数据库交互的接口，以及使用GORM实现的具体逻辑。
+
+import (
+	"go-web/models"
+
+	"gorm.io/gorm"
+)
+
+// UserIdentityRepository 定义了与第三方身份关联相关的操作接口。
+type UserIdentityRepository interface {
+	// FindByProviderSubject 根据身份提供方和外部用户标识查找关联。
+	FindByProviderSubject(provider, subject string) (*models.UserIdentity, error)
+	// FindByUserID 返回用户关联的所有外部身份。
+	FindByUserID(userID uint) ([]models.UserIdentity, error)
+	// Create 创建一个新的关联。
+	Create(identity *models.UserIdentity) error
+}
+
+// GormUserIdentityRepository 是 UserIdentityRepository 的GORM实现。
+type GormUserIdentityRepository struct {
+	DB *gorm.DB
+}
+
+// NewGormUserIdentityRepository 是一个构造函数，用于创建一个新的 GormUserIdentityRepository 实例。
+func NewGormUserIdentityRepository(db *gorm.DB) *GormUserIdentityRepository {
+	return &GormUserIdentityRepository{DB: db}
+}
+
+// FindByProviderSubject 实现了 UserIdentityRepository 接口的 FindByProviderSubject 方法。
+func (r *GormUserIdentityRepository) FindByProviderSubject(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.DB.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// FindByUserID 实现了 UserIdentityRepository 接口的 FindByUserID 方法。
+func (r *GormUserIdentityRepository) FindByUserID(userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := r.DB.Where("user_id = ?", userID).Order("id").Find(&identities).Error
+	return identities, err
+}
+
+// Create 实现了 UserIdentityRepository 接口的 Create 方法。
+func (r *GormUserIdentityRepository) Create(identity *models.UserIdentity) error {
+	return r.DB.Create(identity).Error
+}
@@ -0,0 +1,205 @@
+package repositories
+
+import (
+	"go-web/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newUserListDB 创建一个包含5个用户的数据库，用户每隔一小时创建一个，最后两个用户的创建时间相同。
+func newUserListDB(t *testing.T) (*gorm.DB, time.Time) {
+	db, err := gorm.Open(sqlite.Open("file:repositories_user_list?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Role{}, &models.User{}))
+	db.Exec("DELETE FROM users")
+	db.Exec("DELETE FROM roles")
+
+	admin := models.Role{Name: "admin"}
+	user := models.Role{Name: "user"}
+	require.NoError(t, db.Create(&admin).Error)
+	require.NoError(t, db.Create(&user).Error)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	users := []models.User{
+		{Username: "alice", Email: "alice@example.com", RoleID: admin.ID},
+		{Username: "bob", Email: "bob@example.org", RoleID: user.ID},
+		{Username: "carol", Email: "carol_100%@example.com", RoleID: user.ID},
+		{Username: "dave", Email: "dave@example.org", RoleID: user.ID},
+		{Username: "Erin", Email: "erin@example.com", RoleID: admin.ID},
+	}
+	for i := range users {
+		users[i].Password = "hashed"
+		users[i].CreatedAt = start.Add(time.Duration(min(i, 3)) * time.Hour)
+		require.NoError(t, db.Create(&users[i]).Error)
+	}
+	return db, start
+}
+
+// usernames 返回用户的用户名，便于比较顺序。
+func usernames(users []models.User) []string {
+	names := make([]string, 0, len(users))
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	return names
+}
+
+func TestGormUserRepository_ListFilters(t *testing.T) {
+	db, start := newUserListDB(t)
+	repo := NewGormUserRepository(db)
+
+	users, total, err := repo.List(UserQuery{Role: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []string{"alice", "Erin"}, usernames(users))
+	assert.Equal(t, "admin", users[0].Role.Name)
+
+	// 用户名和邮箱的子串不区分大小写，通配符按字面匹配
+	users, total, err = repo.List(UserQuery{Search: "ERIN"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, []string{"Erin"}, usernames(users))
+	users, _, err = repo.List(UserQuery{Search: "example.org"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob", "dave"}, usernames(users))
+	users, _, err = repo.List(UserQuery{Search: "_100%"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"carol"}, usernames(users))
+	_, total, err = repo.List(UserQuery{Search: "a%e"})
+	require.NoError(t, err)
+	assert.Zero(t, total)
+
+	after, before := start.Add(time.Hour), start.Add(3*time.Hour)
+	users, total, err = repo.List(UserQuery{CreatedAfter: &after, CreatedBefore: &before})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, []string{"bob", "carol"}, usernames(users))
+}
+
+func TestGormUserRepository_ListPages(t *testing.T) {
+	db, _ := newUserListDB(t)
+	repo := NewGormUserRepository(db)
+
+	// 总数不受分页影响
+	users, total, err := repo.List(UserQuery{SortField: "email", Limit: 2, Offset: 2})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), total)
+	assert.Equal(t, []string{"carol", "dave"}, usernames(users))
+
+	// 按每一种排序方式用游标逐页读取，结果与一次读取全部相同
+	for _, sortField := range UserSortFields {
+		for _, descending := range []bool{false, true} {
+			all, _, err := repo.List(UserQuery{SortField: sortField, Descending: descending})
+			require.NoError(t, err)
+
+			var paged []models.User
+			var after *UserCursor
+			for {
+				page, _, err := repo.List(UserQuery{SortField: sortField, Descending: descending, Limit: 2, After: after})
+				require.NoError(t, err)
+				if len(page) == 0 {
+					break
+				}
+				paged = append(paged, page...)
+				cursor := NewUserCursor(&page[len(page)-1], sortField)
+				after = &cursor
+			}
+			assert.Equal(t, usernames(all), usernames(paged), "%s descending=%v", sortField, descending)
+		}
+	}
+
+	// 创建时间相同的用户按ID区分顺序
+	users, _, err = repo.List(UserQuery{SortField: "created_at", Descending: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Erin", "dave", "carol", "bob", "alice"}, usernames(users))
+}
+
+func TestGormUserRepository_SoftDeleteLifecycle(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:repositories_user_lifecycle?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Role{}, &models.User{}, &models.Session{}, &models.RefreshToken{}, &models.APIKey{},
+		&models.PasswordResetToken{}, &models.PasswordHistory{}, &models.TOTPCredential{}, &models.RecoveryCode{}, &models.MFAChallenge{}, &models.UserIdentity{}, &models.Membership{}))
+	repo := NewGormUserRepository(db)
+
+	alice := &models.User{Username: "alice", Email: "alice@example.com", Password: "hashed", RoleID: 1}
+	require.NoError(t, repo.Create(alice))
+	require.NoError(t, db.Create(&models.Session{UserID: alice.ID}).Error)
+	require.NoError(t, db.Create(&models.RefreshToken{UserID: alice.ID, TokenHash: "hash"}).Error)
+	require.NoError(t, repo.Delete(alice))
+
+	// 已删除的用户不占用用户名和邮箱，但在恢复之前会被列出
+	again := &models.User{Username: "alice", Email: "alice@example.com", Password: "hashed", RoleID: 1}
+	require.NoError(t, repo.Create(again))
+	assert.Error(t, repo.Create(&models.User{Username: "alice", Email: "other@example.com", Password: "hashed", RoleID: 1}))
+
+	deleted, err := repo.FindDeleted()
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	assert.Equal(t, alice.ID, deleted[0].ID)
+	_, err = repo.FindDeletedByID(again.ID)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+	// 用户名已被其他用户使用时不能恢复
+	assert.ErrorIs(t, repo.Restore(alice.ID), gorm.ErrDuplicatedKey)
+
+	// 恢复后重新出现在用户列表中
+	require.NoError(t, repo.Delete(again))
+	require.NoError(t, repo.Restore(alice.ID))
+	assert.ErrorIs(t, repo.Restore(alice.ID), gorm.ErrRecordNotFound)
+	restored, err := repo.FindByID(alice.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", restored.Username)
+
+	// 按删除时间查找需要清除的用户
+	ids, err := repo.FindDeletedBefore(time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []uint{again.ID}, ids)
+	ids, err = repo.FindDeletedBefore(time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, ids)
+
+	// 彻底清除用户时一并删除其数据
+	require.NoError(t, repo.Purge(alice.ID))
+	assert.ErrorIs(t, repo.Purge(alice.ID), gorm.ErrRecordNotFound)
+	var count int64
+	require.NoError(t, db.Unscoped().Model(&models.User{}).Where("id = ?", alice.ID).Count(&count).Error)
+	assert.Zero(t, count)
+	require.NoError(t, db.Unscoped().Model(&models.Session{}).Where("user_id = ?", alice.ID).Count(&count).Error)
+	assert.Zero(t, count)
+	require.NoError(t, db.Unscoped().Model(&models.RefreshToken{}).Where("user_id = ?", alice.ID).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+// TestGormUserRepository_RestoreConcurrentRegistration 测试恢复期间用户名被并发注册占用时，
+// 唯一索引冲突被转换为 gorm.ErrDuplicatedKey，用户保持删除状态。
+func TestGormUserRepository_RestoreConcurrentRegistration(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:repositories_user_restore_race?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Role{}, &models.User{}))
+	repo := NewGormUserRepository(db)
+
+	alice := &models.User{Username: "alice", Email: "alice@example.com", Password: "hashed", RoleID: 1}
+	require.NoError(t, repo.Create(alice))
+	require.NoError(t, repo.Delete(alice))
+
+	// 在冲突检查之后、恢复之前插入一个同名用户
+	registered := false
+	require.NoError(t, db.Callback().Update().Before("gorm:update").Register("test:register", func(tx *gorm.DB) {
+		if registered {
+			return
+		}
+		registered = true
+		user := &models.User{Username: "alice", Email: "new@example.com", Password: "hashed", RoleID: 1}
+		require.NoError(t, tx.Session(&gorm.Session{NewDB: true}).Create(user).Error)
+	}))
+
+	assert.ErrorIs(t, repo.Restore(alice.ID), gorm.ErrDuplicatedKey)
+	assert.True(t, registered)
+	_, err = repo.FindDeletedByID(alice.ID)
+	assert.NoError(t, err)
+}
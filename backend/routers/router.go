@@ -1,14 +1,19 @@
 package routers
 
 import (
+	"context"
 	"go-web/config"
 	"go-web/controllers"
 	"go-web/database"
+	"go-web/mailer"
 	"go-web/middleware"
+	"go-web/oidc"
 	"go-web/repositories"
 	"go-web/services"
 	"go-web/utils"
+	"go-web/watcher"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -31,35 +36,174 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 		panic("Failed to initialize Casbin: " + err.Error())
 	}
 
+	// 多实例部署时，通过观察者将策略修改同步到其他实例
+	policyWatcher, err := watcher.NewWatcher(cfg, database.DB)
+	if err != nil {
+		panic("Failed to initialize Casbin watcher: " + err.Error())
+	}
+	if policyWatcher != nil {
+		if err := middleware.WatchPolicy(policyWatcher); err != nil {
+			panic("Failed to attach Casbin watcher: " + err.Error())
+		}
+	}
+
 	// 创建数据库连接
 	db := database.DB
 
 	// 创建仓储实例
 	userRepository := repositories.NewGormUserRepository(db)
 	roleRepository := repositories.NewGormRoleRepository(db)
+	refreshTokenRepository := repositories.NewGormRefreshTokenRepository(db)
+	passwordResetTokenRepository := repositories.NewGormPasswordResetTokenRepository(db)
+	totpCredentialRepository := repositories.NewGormTOTPCredentialRepository(db)
+	recoveryCodeRepository := repositories.NewGormRecoveryCodeRepository(db)
+	mfaChallengeRepository := repositories.NewGormMFAChallengeRepository(db)
+	loginFailureRepository := repositories.NewGormLoginFailureRepository(db)
+	passwordHistoryRepository := repositories.NewGormPasswordHistoryRepository(db)
+	userIdentityRepository := repositories.NewGormUserIdentityRepository(db)
+	oidcLoginStateRepository := repositories.NewGormOIDCLoginStateRepository(db)
+	apiKeyRepository := repositories.NewGormAPIKeyRepository(db)
+	sessionRepository := repositories.NewGormSessionRepository(db)
+	organizationRepository := repositories.NewGormOrganizationRepository(db)
+	membershipRepository := repositories.NewGormMembershipRepository(db)
+
+	// 访问令牌吊销列表，多实例部署时应使用数据库存储
+	var revokedTokenRepository repositories.RevokedTokenRepository
+	if cfg.JWT.RevocationStore == "memory" {
+		revokedTokenRepository = repositories.NewMemoryRevokedTokenRepository()
+	} else {
+		revokedTokenRepository = repositories.NewGormRevokedTokenRepository(db)
+	}
+	if cfg.JWT.RevocationPurgeInterval > 0 {
+		go services.PurgeTokensPeriodically(context.Background(), revokedTokenRepository, refreshTokenRepository, time.Duration(cfg.JWT.RefreshExpiration)*time.Second, time.Duration(cfg.JWT.RevocationPurgeInterval)*time.Second)
+	}
+
+	// 彻底清除超过保留期限的已删除用户
+	if cfg.App.DeletedUserRetention > 0 && cfg.App.DeletedUserPurgeInterval > 0 {
+		retention := time.Duration(cfg.App.DeletedUserRetention) * 24 * time.Hour
+		go services.PurgeDeletedUsersPeriodically(context.Background(), userRepository, retention, time.Duration(cfg.App.DeletedUserPurgeInterval)*time.Second)
+	}
+
+	// 创建邮件发送器
+	mail, err := mailer.NewMailer(cfg.Mail)
+	if err != nil {
+		panic("Failed to initialize mailer: " + err.Error())
+	}
+
+	// 创建第三方登录的身份提供方，服务发现在第一次使用时进行
+	oidcProviders, err := oidc.NewProviders(cfg.OIDC, nil)
+	if err != nil {
+		panic("Failed to initialize OIDC providers: " + err.Error())
+	}
 
 	// 创建服务实例
-	authService := services.NewAuthService(cfg, userRepository, roleRepository, db)
-	userService := services.NewUserService(userRepository)
+	authorizer := services.NewCasbinAuthorizer(middleware.Enforcer)
+	tokenService := services.NewTokenService(cfg, userRepository, refreshTokenRepository, revokedTokenRepository, sessionRepository, db)
+	emailVerificationService := services.NewEmailVerificationService(cfg, userRepository, mail)
+	mfaService := services.NewMFAService(cfg, userRepository, totpCredentialRepository, recoveryCodeRepository, mfaChallengeRepository)
+	lockoutService := services.NewLockoutService(cfg, loginFailureRepository)
+	passwordPolicyService := services.NewPasswordPolicyService(cfg, passwordHistoryRepository)
+	authService := services.NewAuthService(cfg, userRepository, roleRepository, tokenService, emailVerificationService, mfaService, lockoutService, passwordPolicyService, db)
+	userService := services.NewUserService(userRepository, authorizer)
+	passwordResetService := services.NewPasswordResetService(cfg, userRepository, passwordResetTokenRepository, tokenService, passwordPolicyService, mail, db)
+	sessionService := services.NewSessionService(userRepository, sessionRepository, refreshTokenRepository)
+	apiKeyService := services.NewAPIKeyService(cfg, userRepository, apiKeyRepository)
+	oidcService := services.NewOIDCService(cfg, oidcProviders, userRepository, roleRepository, userIdentityRepository, oidcLoginStateRepository, tokenService, mfaService, db)
+	policyService := services.NewPolicyService(middleware.Enforcer)
+	roleService := services.NewRoleService(cfg, roleRepository, middleware.Enforcer)
+	organizationService := services.NewOrganizationService(organizationRepository, membershipRepository, userRepository, roleRepository, tokenService, db)
+	permissionService := services.NewPermissionService(middleware.Enforcer)
+
+	// 创建管理员角色和新用户的默认角色，否则无法注册
+	if err := roleService.EnsureDefaultRoles(); err != nil {
+		panic("Failed to create default roles: " + err.Error())
+	}
 
 	// 创建控制器实例
 	authController := controllers.NewAuthController(authService)
 	userController := controllers.NewUserController(userService)
+	jwksController := controllers.NewJWKSController(cfg)
+	passwordResetController := controllers.NewPasswordResetController(passwordResetService)
+	emailVerificationController := controllers.NewEmailVerificationController(emailVerificationService)
+	mfaController := controllers.NewMFAController(mfaService)
+	accountLockController := controllers.NewAccountLockController(lockoutService)
+	oidcController := controllers.NewOIDCController(oidcService)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
+	sessionController := controllers.NewSessionController(sessionService)
+	policyController := controllers.NewPolicyController(policyService)
+	roleController := controllers.NewRoleController(roleService)
+	organizationController := controllers.NewOrganizationController(organizationService)
+	permissionController := controllers.NewPermissionController(permissionService)
+
+	// 认证中间件，拒绝已被吊销的令牌、已被吊销的会话和修改密码之前签发的令牌，同时接受个人API密钥。
+	// 密码修改时间从主库读取，只读副本的延迟不能让修改密码之前的令牌继续有效
+	authMiddleware := middleware.AuthMiddleware(cfg,
+		middleware.WithRevokedTokens(revokedTokenRepository),
+		middleware.WithPasswordChanges(userRepository.Primary()),
+		middleware.WithSessions(sessionRepository),
+		middleware.WithAPIKeys(apiKeyService),
+	)
 
 	// Public routes (no authentication required)
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"status": "ok"})
 	})
+	r.GET("/.well-known/jwks.json", jwksController.GetJWKS)
 	auth := r.Group("/auth")
 	auth.Use(middleware.RateLimiterMiddleware(cfg))
 	{
 		auth.POST("/register", authController.Register)
 		auth.POST("/login", authController.Login)
+		auth.POST("/mfa/verify", authController.VerifyMFA)
+		auth.POST("/refresh", authController.Refresh)
+		auth.POST("/logout", authMiddleware, authController.Logout)
+		auth.POST("/password/forgot", passwordResetController.ForgotPassword)
+		auth.POST("/password/reset", passwordResetController.ResetPassword)
+		auth.GET("/verify-email", emailVerificationController.VerifyEmail)
+		auth.POST("/verify-email/resend", emailVerificationController.ResendVerification)
+		auth.GET("/oidc/:provider/login", oidcController.Login)
+		auth.GET("/oidc/:provider/callback", oidcController.Callback)
+	}
+
+	// 当前用户的自助接口，只需要认证，不经过Casbin授权
+	me := r.Group("/users/me")
+	me.Use(authMiddleware)
+	{
+		me.PUT("/password", authController.ChangePassword)
+		me.POST("/mfa/totp", mfaController.EnrollTOTP)
+		me.POST("/mfa/totp/confirm", mfaController.ConfirmTOTP)
+		me.POST("/mfa/totp/disable", mfaController.DisableTOTP)
+		me.POST("/mfa/recovery-codes", mfaController.RegenerateRecoveryCodes)
+		me.GET("/identities", oidcController.GetIdentities)
+		me.POST("/identities/:provider", oidcController.Link)
+		me.GET("/sessions", sessionController.GetSessions)
+		me.DELETE("/sessions/:id", sessionController.DeleteSession)
+		me.GET("/organizations", organizationController.GetMyOrganizations)
+		me.POST("/tenant", middleware.RejectAPIKeys(), organizationController.SwitchTenant)
+		me.GET("/permissions", permissionController.GetMyPermissions)
+	}
+
+	// 权限检查只针对当前用户自己，不经过Casbin授权
+	authz := r.Group("/authz")
+	authz.Use(authMiddleware)
+	{
+		authz.POST("/check", permissionController.CheckPermissions)
+	}
+
+	// API密钥只能在交互式会话中管理
+	tokens := me.Group("/tokens")
+	tokens.Use(middleware.RejectAPIKeys())
+	{
+		tokens.POST("", apiKeyController.CreateAPIKey)
+		tokens.GET("", apiKeyController.GetAPIKeys)
+		tokens.GET("/:id", apiKeyController.GetAPIKey)
+		tokens.PATCH("/:id", apiKeyController.UpdateAPIKey)
+		tokens.DELETE("/:id", apiKeyController.DeleteAPIKey)
 	}
 
 	// 受保护的路由（需要认证和授权）
 	users := r.Group("/users")
-	users.Use(middleware.AuthMiddleware(cfg))
+	users.Use(authMiddleware)
 	users.Use(middleware.CasbinMiddleware())
 	{
 		users.GET("/", userController.GetUsers)
@@ -68,5 +212,48 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 		users.DELETE("/:id", userController.DeleteUser)
 	}
 
+	roles := r.Group("/roles")
+	roles.Use(authMiddleware)
+	roles.Use(middleware.CasbinMiddleware())
+	{
+		roles.GET("", roleController.GetRoles)
+		roles.POST("", roleController.CreateRole)
+		roles.GET("/:id", roleController.GetRole)
+		roles.PUT("/:id", roleController.UpdateRole)
+		roles.DELETE("/:id", roleController.DeleteRole)
+	}
+
+	// 组织管理，成员接口作用于令牌中的当前组织，在组织对应的域中授权
+	organizations := r.Group("/organizations")
+	organizations.Use(authMiddleware)
+	organizations.Use(middleware.CasbinMiddleware())
+	{
+		organizations.GET("", organizationController.GetOrganizations)
+		organizations.POST("", organizationController.CreateOrganization)
+		organizations.GET("/current/members", organizationController.GetMembers)
+		organizations.POST("/current/members", organizationController.AddMember)
+		organizations.PUT("/current/members/:user_id", organizationController.UpdateMember)
+		organizations.DELETE("/current/members/:user_id", organizationController.RemoveMember)
+	}
+
+	// 管理接口（需要认证和授权）
+	admin := r.Group("/admin")
+	admin.Use(authMiddleware)
+	admin.Use(middleware.CasbinMiddleware())
+	{
+		admin.GET("/locks", accountLockController.GetLocks)
+		admin.DELETE("/locks/:username", accountLockController.DeleteLock)
+		admin.DELETE("/users/:id/sessions", sessionController.DeleteUserSessions)
+		admin.GET("/deleted-users", userController.GetDeletedUsers)
+		admin.POST("/deleted-users/:id/restore", userController.RestoreUser)
+		admin.DELETE("/deleted-users/:id", userController.PurgeUser)
+		admin.GET("/policies", policyController.GetPolicies)
+		admin.POST("/policies", policyController.AddPolicy)
+		admin.DELETE("/policies", policyController.DeletePolicy)
+		admin.GET("/policies/groupings", policyController.GetGroupings)
+		admin.POST("/policies/groupings", policyController.AddGrouping)
+		admin.DELETE("/policies/groupings", policyController.DeleteGrouping)
+	}
+
 	return r
 }
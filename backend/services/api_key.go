@@ -0,0 +1,270 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责个人API密钥：创建、管理以及认证使用API密钥的请求。
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/utils"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidAPIKey 在API密钥格式错误、不存在、已过期或所属用户不存在时返回。
+	ErrInvalidAPIKey = errors.New("API密钥无效或已过期")
+	// ErrInvalidAPIKeyScope 在请求的权限范围不受支持时返回。
+	ErrInvalidAPIKeyScope = errors.New("不支持的API密钥权限范围")
+	// ErrInvalidAPIKeyExpiration 在请求的有效期超出 auth.api_key_max_lifetime 时返回。
+	ErrInvalidAPIKeyExpiration = errors.New("API密钥的有效期超出了允许的范围")
+	// ErrAPIKeyLimitReached 在用户的API密钥数量达到 auth.api_key_max_per_user 时返回。
+	ErrAPIKeyLimitReached = errors.New("API密钥数量已达上限，请先删除不再使用的密钥")
+	// ErrAPIKeyNotAllowed 在使用API密钥认证的请求访问只允许交互式会话的接口时返回，例如管理API密钥本身。
+	ErrAPIKeyNotAllowed = errors.New("该操作不能使用API密钥完成")
+)
+
+// APIKeyTokenPrefix 是所有API密钥明文的固定前缀，认证中间件据此区分API密钥和JWT，
+// 也便于密钥扫描工具识别泄露的密钥。
+const APIKeyTokenPrefix = "gwk_"
+
+const (
+	apiKeyIDBytes     = 6  // 公开前缀中随机部分的字节数
+	apiKeySecretBytes = 32 // 密钥中保密部分的字节数
+
+	// apiKeyLastUsedResolution 是最近使用时间的更新精度，同一个密钥在此时间内的请求不会重复写数据库。
+	apiKeyLastUsedResolution = time.Minute
+)
+
+// APIKeyAuthentication 是API密钥认证成功的结果。
+type APIKeyAuthentication struct {
+	Key  *models.APIKey
+	User *models.User
+	Role string // 请求使用的Casbin角色，与同一用户的访问令牌一致
+}
+
+// APIKeyServiceInterface 定义了API密钥服务应实现的功能契约。
+type APIKeyServiceInterface interface {
+	// Create 为用户创建一个API密钥，返回密钥记录和只显示一次的明文。
+	// expiresInDays 为0时使用 auth.api_key_max_lifetime（为0则永不过期）。
+	Create(userID uint, name string, scopes []string, expiresInDays int) (*models.APIKey, string, error)
+	// List 返回用户的所有API密钥。
+	List(userID uint) ([]models.APIKey, error)
+	// Get 返回用户的指定API密钥。
+	Get(userID, id uint) (*models.APIKey, error)
+	// Update 修改API密钥的名称和权限范围，有效期不能修改。
+	Update(userID, id uint, name string, scopes []string) (*models.APIKey, error)
+	// Delete 删除用户的指定API密钥，之后使用该密钥的请求会被拒绝。
+	Delete(userID, id uint) error
+	// Authenticate 验证API密钥明文，返回所属用户和请求使用的角色。
+	Authenticate(token string) (*APIKeyAuthentication, error)
+}
+
+// APIKeyService 提供了API密钥相关的业务逻辑实现。
+type APIKeyService struct {
+	Config           *config.Config
+	UserRepository   repositories.UserRepository
+	APIKeyRepository repositories.APIKeyRepository
+}
+
+// NewAPIKeyService 是 APIKeyService 的构造函数。
+func NewAPIKeyService(cfg *config.Config, userRepo repositories.UserRepository, apiKeyRepo repositories.APIKeyRepository) APIKeyServiceInterface {
+	return &APIKeyService{
+		Config:           cfg,
+		UserRepository:   userRepo,
+		APIKeyRepository: apiKeyRepo,
+	}
+}
+
+// IsAPIKey 返回凭据是否具有API密钥的格式。
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyTokenPrefix)
+}
+
+// Create 创建API密钥。明文的格式为 "gwk_<前缀随机部分>_<密钥>"，数据库中只保存前缀和完整明文的哈希。
+func (s *APIKeyService) Create(userID uint, name string, scopes []string, expiresInDays int) (*models.APIKey, string, error) {
+	scopes, err := normalizeAPIKeyScopes(scopes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	maxLifetime := s.Config.Auth.APIKeyMaxLifetime
+	if expiresInDays < 0 || (maxLifetime > 0 && expiresInDays > maxLifetime) {
+		return nil, "", ErrInvalidAPIKeyExpiration
+	}
+	if expiresInDays == 0 {
+		expiresInDays = maxLifetime
+	}
+
+	if limit := s.Config.Auth.APIKeyMaxPerUser; limit > 0 {
+		count, err := s.APIKeyRepository.CountByUserID(userID)
+		if err != nil {
+			return nil, "", err
+		}
+		if count >= int64(limit) {
+			return nil, "", ErrAPIKeyLimitReached
+		}
+	}
+
+	id, err := randomHex(apiKeyIDBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := utils.GenerateRandomToken(apiKeySecretBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	prefix := APIKeyTokenPrefix + id
+	token := prefix + "_" + secret
+
+	key := &models.APIKey{
+		UserID:     userID,
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: utils.HashToken(token),
+		Scopes:     strings.Join(scopes, " "),
+	}
+	if expiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, expiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := s.APIKeyRepository.Create(key); err != nil {
+		return nil, "", err
+	}
+
+	utils.Logger.Info("api key created",
+		zap.String("event", "api_key.created"),
+		zap.Uint("user_id", userID),
+		zap.Uint("api_key_id", key.ID),
+		zap.String("prefix", prefix),
+	)
+	return key, token, nil
+}
+
+// List 返回用户的所有API密钥。
+func (s *APIKeyService) List(userID uint) ([]models.APIKey, error) {
+	return s.APIKeyRepository.FindByUserID(userID)
+}
+
+// Get 返回用户的指定API密钥，密钥属于其他用户时与不存在一样返回 gorm.ErrRecordNotFound。
+func (s *APIKeyService) Get(userID, id uint) (*models.APIKey, error) {
+	return s.APIKeyRepository.FindByIDForUser(id, userID)
+}
+
+// Update 修改API密钥的名称和权限范围。
+func (s *APIKeyService) Update(userID, id uint, name string, scopes []string) (*models.APIKey, error) {
+	scopes, err := normalizeAPIKeyScopes(scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := s.APIKeyRepository.FindByIDForUser(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	key.Name = name
+	key.Scopes = strings.Join(scopes, " ")
+	if err := s.APIKeyRepository.Update(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Delete 删除用户的指定API密钥。
+func (s *APIKeyService) Delete(userID, id uint) error {
+	if err := s.APIKeyRepository.DeleteForUser(id, userID); err != nil {
+		return err
+	}
+
+	utils.Logger.Info("api key deleted",
+		zap.String("event", "api_key.deleted"),
+		zap.Uint("user_id", userID),
+		zap.Uint("api_key_id", id),
+	)
+	return nil
+}
+
+// Authenticate 验证API密钥。先按前缀查找，再以常量时间比较完整明文的哈希。
+// 请求的角色按用户当前的角色确定，因此修改用户角色会立即作用于其所有API密钥。
+func (s *APIKeyService) Authenticate(token string) (*APIKeyAuthentication, error) {
+	if !IsAPIKey(token) {
+		return nil, ErrInvalidAPIKey
+	}
+	rest := strings.TrimPrefix(token, APIKeyTokenPrefix)
+	id, _, found := strings.Cut(rest, "_")
+	if !found || len(id) != hex.EncodedLen(apiKeyIDBytes) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.APIKeyRepository.FindByPrefix(APIKeyTokenPrefix + id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(utils.HashToken(token))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	now := time.Now()
+	if key.IsExpired(now) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// 从主库读取用户，只读副本的延迟不能让刚被删除或降级的用户继续使用原来的角色
+	user, err := s.UserRepository.Primary().FindByID(key.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAPIKey
+		}
+		return nil, err
+	}
+
+	// 记录最近使用时间失败不影响本次请求
+	if err := s.APIKeyRepository.TouchLastUsed(key.ID, now, now.Add(-apiKeyLastUsedResolution)); err != nil {
+		utils.Logger.Warn("failed to record api key usage", zap.Uint("api_key_id", key.ID), zap.Error(err))
+	}
+
+	// 与访问令牌一致：restrict 模式下，未验证邮箱的用户只拥有受限角色
+	role := user.Role.Name
+	if s.Config.Auth.EmailVerification == config.EmailVerificationRestrict && !user.IsEmailVerified() {
+		role = s.Config.Auth.UnverifiedRole
+	}
+	return &APIKeyAuthentication{Key: key, User: user, Role: role}, nil
+}
+
+// normalizeAPIKeyScopes 校验权限范围并去除重复项，至少需要一个权限范围。
+func normalizeAPIKeyScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]bool, len(scopes))
+	var normalized []string
+	for _, scope := range scopes {
+		if scope != models.APIKeyScopeRead && scope != models.APIKeyScopeWrite {
+			return nil, ErrInvalidAPIKeyScope
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			normalized = append(normalized, scope)
+		}
+	}
+	if len(normalized) == 0 {
+		return nil, ErrInvalidAPIKeyScope
+	}
+	return normalized, nil
+}
+
+// randomHex 返回 n 个随机字节的十六进制编码。
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
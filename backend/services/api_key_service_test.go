@@ -0,0 +1,161 @@
+package services_test
+
+import (
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/services"
+	"go-web/utils"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// APIKeyServiceTestSuite 是一个测试套件，用于组织与API密钥相关的集成测试。
+type APIKeyServiceTestSuite struct {
+	suite.Suite
+	db         *gorm.DB
+	cfg        *config.Config
+	service    services.APIKeyServiceInterface
+	apiKeyRepo repositories.APIKeyRepository
+	user       *models.User
+}
+
+// SetupSuite 在测试套件开始时运行，用于初始化数据库、配置和服务。
+func (suite *APIKeyServiceTestSuite) SetupSuite() {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // 创建和删除密钥会记录审计日志
+
+	db, err := gorm.Open(sqlite.Open("file:api_keys?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		suite.T().Fatalf("无法连接到内存数据库: %v", err)
+	}
+	suite.db = db
+
+	err = suite.db.AutoMigrate(&models.User{}, &models.Role{}, &models.APIKey{})
+	if err != nil {
+		suite.T().Fatalf("数据库迁移失败: %v", err)
+	}
+
+	suite.cfg = &config.Config{
+		Auth: config.AuthConfig{
+			EmailVerification: config.EmailVerificationOff,
+			UnverifiedRole:    "unverified",
+			APIKeyMaxPerUser:  3,
+			APIKeyMaxLifetime: 90,
+		},
+	}
+	suite.apiKeyRepo = repositories.NewGormAPIKeyRepository(suite.db)
+	suite.service = services.NewAPIKeyService(suite.cfg, repositories.NewGormUserRepository(suite.db), suite.apiKeyRepo)
+}
+
+// SetupTest 在每个测试方法运行之前被调用，清理数据并创建测试用户。
+func (suite *APIKeyServiceTestSuite) SetupTest() {
+	suite.db.Exec("DELETE FROM users")
+	suite.db.Exec("DELETE FROM roles")
+	suite.db.Exec("DELETE FROM api_keys")
+
+	role := &models.Role{Name: "user", Description: "普通用户"}
+	suite.Require().NoError(suite.db.Create(role).Error)
+	suite.user = &models.User{Username: "alice", Email: "alice@example.com", Password: "x", RoleID: role.ID}
+	suite.Require().NoError(suite.db.Create(suite.user).Error)
+}
+
+// TestAPIKeyServiceTestSuite 运行测试套件。
+func TestAPIKeyServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(APIKeyServiceTestSuite))
+}
+
+// TestCreate_Authenticate 测试创建的密钥可以认证，且数据库中不保存明文。
+func (suite *APIKeyServiceTestSuite) TestCreate_Authenticate() {
+	key, token, err := suite.service.Create(suite.user.ID, "ci", []string{"read", "write", "read"}, 0)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), strings.HasPrefix(token, key.Prefix+"_"))
+	assert.NotContains(suite.T(), key.SecretHash, strings.TrimPrefix(token, key.Prefix+"_"))
+	assert.Equal(suite.T(), []string{"read", "write"}, key.ScopeList())
+	// 未指定有效期时使用允许的最长有效期
+	suite.Require().NotNil(key.ExpiresAt)
+	assert.WithinDuration(suite.T(), time.Now().AddDate(0, 0, 90), *key.ExpiresAt, time.Minute)
+
+	auth, err := suite.service.Authenticate(token)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), suite.user.ID, auth.User.ID)
+	assert.Equal(suite.T(), "user", auth.Role)
+
+	stored, err := suite.apiKeyRepo.FindByIDForUser(key.ID, suite.user.ID)
+	suite.Require().NoError(err)
+	assert.NotNil(suite.T(), stored.LastUsedAt)
+
+	for _, invalid := range []string{token + "x", key.Prefix + "_wrong", "gwk_", "gwk_nothex_secret", "not-a-key"} {
+		_, err := suite.service.Authenticate(invalid)
+		assert.ErrorIs(suite.T(), err, services.ErrInvalidAPIKey, invalid)
+	}
+}
+
+// TestCreate_Validation 测试权限范围、有效期和数量限制。
+func (suite *APIKeyServiceTestSuite) TestCreate_Validation() {
+	_, _, err := suite.service.Create(suite.user.ID, "ci", []string{"admin"}, 30)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidAPIKeyScope)
+	_, _, err = suite.service.Create(suite.user.ID, "ci", nil, 30)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidAPIKeyScope)
+	_, _, err = suite.service.Create(suite.user.ID, "ci", []string{"read"}, 91)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidAPIKeyExpiration)
+
+	for i := 0; i < 3; i++ {
+		_, _, err = suite.service.Create(suite.user.ID, "ci", []string{"read"}, 30)
+		suite.Require().NoError(err)
+	}
+	_, _, err = suite.service.Create(suite.user.ID, "ci", []string{"read"}, 30)
+	assert.ErrorIs(suite.T(), err, services.ErrAPIKeyLimitReached)
+}
+
+// TestAuthenticate_Expired 测试过期的密钥被拒绝。
+func (suite *APIKeyServiceTestSuite) TestAuthenticate_Expired() {
+	key, token, err := suite.service.Create(suite.user.ID, "ci", []string{"read"}, 1)
+	suite.Require().NoError(err)
+	suite.Require().NoError(suite.db.Model(key).Update("expires_at", time.Now().Add(-time.Second)).Error)
+
+	_, err = suite.service.Authenticate(token)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidAPIKey)
+}
+
+// TestAuthenticate_RestrictedRole 测试 restrict 模式下未验证邮箱的用户只能使用受限角色。
+func (suite *APIKeyServiceTestSuite) TestAuthenticate_RestrictedRole() {
+	suite.cfg.Auth.EmailVerification = config.EmailVerificationRestrict
+	defer func() { suite.cfg.Auth.EmailVerification = config.EmailVerificationOff }()
+
+	_, token, err := suite.service.Create(suite.user.ID, "ci", []string{"read"}, 1)
+	suite.Require().NoError(err)
+	auth, err := suite.service.Authenticate(token)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "unverified", auth.Role)
+}
+
+// TestManage_ScopedToOwner 测试用户只能查看、修改和删除自己的密钥，删除后密钥立即失效。
+func (suite *APIKeyServiceTestSuite) TestManage_ScopedToOwner() {
+	key, token, err := suite.service.Create(suite.user.ID, "ci", []string{"read"}, 30)
+	suite.Require().NoError(err)
+	otherUserID := suite.user.ID + 1
+
+	_, err = suite.service.Get(otherUserID, key.ID)
+	assert.ErrorIs(suite.T(), err, gorm.ErrRecordNotFound)
+	_, err = suite.service.Update(otherUserID, key.ID, "stolen", []string{"write"})
+	assert.ErrorIs(suite.T(), err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(suite.T(), suite.service.Delete(otherUserID, key.ID), gorm.ErrRecordNotFound)
+
+	updated, err := suite.service.Update(suite.user.ID, key.ID, "deploy", []string{"write"})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "deploy", updated.Name)
+	keys, err := suite.service.List(suite.user.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(keys, 1)
+	assert.Equal(suite.T(), []string{"write"}, keys[0].ScopeList())
+
+	suite.Require().NoError(suite.service.Delete(suite.user.ID, key.ID))
+	_, err = suite.service.Authenticate(token)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidAPIKey)
+}
@@ -4,10 +4,14 @@ package services
 // 它作为控制器和仓库之间的桥梁，处理如用户认证、注册等核心功能。
 
 import (
+	"errors"
 	"go-web/config"
 	"go-web/models"
 	"go-web/repositories"
 	"go-web/utils"
+	"time"
+
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -27,44 +31,77 @@ func (e *InvalidCredentialsError) Error() string {
 	return "无效的用户名或密码"
 }
 
+// ErrIncorrectPassword 在修改密码时提供的当前密码不正确时返回。
+var ErrIncorrectPassword = errors.New("当前密码不正确")
+
 // AuthServiceInterface 定义了认证服务应实现的功能契约。
 // 使用接口可以方便地在测试中替换真实的服务实现。
 type AuthServiceInterface interface {
 	// Register 处理新用户的注册逻辑。
-	Register(username, email, password string) (*models.User, string, error)
+	// 在 block 模式下邮箱验证之前不签发令牌，返回的令牌对为 nil。
+	Register(username, email, password string, client ClientInfo) (*models.User, *TokenPair, error)
 	// Login 处理用户的登录逻辑。
-	Login(username, password string) (*models.User, string, error)
+	// 启用了两步验证的用户会得到 *MFARequiredError，需要继续调用 VerifyMFA。
+	Login(username, password string, client ClientInfo) (*models.User, *TokenPair, error)
+	// Refresh 使用刷新令牌换取新的令牌对。
+	Refresh(refreshToken string) (*models.User, *TokenPair, error)
+	// VerifyMFA 使用登录时返回的两步验证挑战令牌和验证码换取令牌对。
+	VerifyMFA(mfaToken, code string, client ClientInfo) (*models.User, *TokenPair, error)
+	// ChangePassword 校验当前密码后修改密码，吊销用户所有的会话，并为当前设备签发新的令牌对。
+	ChangePassword(userID uint, currentPassword, newPassword string, client ClientInfo) (*models.User, *TokenPair, error)
+	// Logout 吊销当前访问令牌，以及（可选的）刷新令牌所在的令牌族。
+	Logout(claims *utils.Claims, refreshToken string) error
 }
 
 // AuthService 提供了认证相关的业务逻辑实现。
-// 它依赖于配置、用户仓库和角色仓库。
+// 它依赖于配置、用户仓库、角色仓库、令牌服务、邮箱验证服务、两步验证服务、登录锁定服务和密码策略服务。
 type AuthService struct {
-	Config         *config.Config
-	UserRepository repositories.UserRepository
-	RoleRepository repositories.RoleRepository
-	DB             *gorm.DB // 添加DB实例用于事务
+	Config                   *config.Config
+	UserRepository           repositories.UserRepository
+	RoleRepository           repositories.RoleRepository
+	TokenService             TokenServiceInterface
+	EmailVerificationService EmailVerificationServiceInterface
+	MFAService               MFAServiceInterface
+	LockoutService           LockoutServiceInterface
+	PasswordPolicyService    PasswordPolicyServiceInterface
+	DB                       *gorm.DB // 添加DB实例用于事务
 }
 
 // NewAuthService 是 AuthService 的构造函数。
-func NewAuthService(cfg *config.Config, userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, db *gorm.DB) AuthServiceInterface {
+func NewAuthService(cfg *config.Config, userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, tokenService TokenServiceInterface, verificationService EmailVerificationServiceInterface, mfaService MFAServiceInterface, lockoutService LockoutServiceInterface, passwordPolicyService PasswordPolicyServiceInterface, db *gorm.DB) AuthServiceInterface {
 	return &AuthService{
-		Config:         cfg,
-		UserRepository: userRepo,
-		RoleRepository: roleRepo,
-		DB:             db, // 注入DB实例
+		Config:                   cfg,
+		UserRepository:           userRepo,
+		RoleRepository:           roleRepo,
+		TokenService:             tokenService,
+		EmailVerificationService: verificationService,
+		MFAService:               mfaService,
+		LockoutService:           lockoutService,
+		PasswordPolicyService:    passwordPolicyService,
+		DB:                       db, // 注入DB实例
 	}
 }
 
 // Register 负责注册一个新用户。
-// 它会检查用户是否已存在，对密码进行哈希处理，分配默认角色，创建用户，并生成JWT。
+// 它会检查密码是否符合策略、用户是否已存在，对密码进行哈希处理，分配默认角色，创建用户，并签发令牌。
 // 整个注册过程在一个数据库事务中完成，以确保数据一致性。
-func (s *AuthService) Register(username, email, password string) (*models.User, string, error) {
+func (s *AuthService) Register(username, email, password string, client ClientInfo) (*models.User, *TokenPair, error) {
 	var user *models.User
 
+	// 密码策略的检查不依赖数据库事务，在事务开始之前完成
+	if err := s.PasswordPolicyService.Validate(&models.User{Username: username, Email: email}, password); err != nil {
+		return nil, nil, err
+	}
+
+	hasher, err := utils.PasswordHasherFor(s.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// 启动数据库事务
 	tx := s.DB.Begin()
 	if tx.Error != nil {
-		return nil, "", tx.Error
+		return nil, nil, tx.Error
 	}
 
 	// 使用 defer-recover 机制确保事务在发生 panic 时能够回滚
@@ -80,22 +117,22 @@ func (s *AuthService) Register(username, email, password string) (*models.User,
 
 	// 1. 检查用户名或邮箱是否已经被注册
 	// 在注册场景下，我们期望这里返回 "record not found" 错误
-	_, err := txUserRepo.FindByUsernameOrEmail(username, email)
+	_, err = txUserRepo.FindByUsernameOrEmail(username, email)
 	if err == nil {
 		tx.Rollback()
-		return nil, "", &UserExistsError{}
+		return nil, nil, &UserExistsError{}
 	}
 	if err != gorm.ErrRecordNotFound {
 		// 如果是其他类型的数据库错误，则回滚并返回错误
 		tx.Rollback()
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	// 2. 对用户密码进行哈希加密
-	hashedPassword, err := utils.HashPassword(password)
+	hashedPassword, err := hasher.Hash(password)
 	if err != nil {
 		tx.Rollback()
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	// 3. 获取默认角色
@@ -105,7 +142,7 @@ func (s *AuthService) Register(username, email, password string) (*models.User,
 	if err != nil {
 		// 如果角色不存在（这在正常情况下不应该发生），则回滚
 		tx.Rollback()
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	// 4. 创建新用户实例
@@ -119,52 +156,248 @@ func (s *AuthService) Register(username, email, password string) (*models.User,
 	// 5. 将新用户存入数据库
 	if err := txUserRepo.Create(user); err != nil {
 		tx.Rollback()
-		return nil, "", err
+		return nil, nil, err
 	}
 
 	// 6. 提交事务
 	if err := tx.Commit().Error; err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	// 7. 为新注册的用户生成JWT（在事务成功后执行）
+	// 7. 发送邮箱验证邮件。发送失败不影响注册，用户可以稍后申请重新发送
+	if err := s.EmailVerificationService.SendVerification(user); err != nil {
+		utils.Logger.Error("failed to send verification mail", zap.Uint("user_id", user.ID), zap.Error(err))
+	}
+
+	// 8. 为新注册的用户签发令牌（在事务成功后执行）
 	// 此时 user 对象已经包含了 RoleID，但 Role 对象本身需要从 role 变量中获取
 	user.Role = *role
-	token, err := utils.GenerateToken(user.ID, user.Role.Name, s.Config)
+	if s.Config.Auth.EmailVerification == config.EmailVerificationBlock {
+		// 邮箱验证之前不允许登录
+		return user, nil, nil
+	}
+	tokens, err := s.TokenService.IssueTokens(user, client)
 	if err != nil {
 		// 事务已经提交，但Token生成失败。这是一个边缘情况。
 		// 此时用户已创建成功，但无法立即登录。
 		// 我们可以选择返回错误，让用户稍后尝试登录。
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return user, token, nil
+	return user, tokens, nil
 }
 
 // Login 负责处理用户登录。
-// 它会验证用户名和密码，如果成功，则生成一个新的JWT。
-func (s *AuthService) Login(username, password string) (*models.User, string, error) {
-	// 1. 根据用户名查找用户
-	user, err := s.UserRepository.FindByUsername(username)
+// 它会验证用户名和密码，如果成功，则签发新的访问令牌和刷新令牌。
+// 连续失败会触发按用户名的退避和临时锁定，见 LockoutService。
+func (s *AuthService) Login(username, password string, client ClientInfo) (*models.User, *TokenPair, error) {
+	// 1. 用户名处于退避等待或锁定期间时直接拒绝，不再校验密码
+	if err := s.LockoutService.Check(username); err != nil {
+		return nil, nil, err
+	}
+
+	// 2. 根据用户名查找用户，并验证提供的密码是否与存储的哈希密码匹配
+	// 用户不存在时同样记录失败，避免通过锁定行为判断账户是否存在
+	hasher, err := utils.PasswordHasherFor(s.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 密码和角色从主库读取，只读副本的延迟不能让刚修改的密码或角色失效
+	user, findErr := s.UserRepository.Primary().FindByUsername(username)
+	var encoded string
+	if findErr == nil {
+		encoded = user.Password
+	} else if encoded, err = hasher.DummyHash(); err != nil {
+		return nil, nil, err
+	}
+	// 用户不存在时对预先生成的哈希校验，使耗时与用户存在时相同，避免通过响应时间判断用户名是否存在
+	if err := hasher.Verify(password, encoded); err != nil || findErr != nil {
+		if err := s.LockoutService.RecordFailure(username); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, &InvalidCredentialsError{}
+	}
+	if err := s.LockoutService.RecordSuccess(username); err != nil {
+		return nil, nil, err
+	}
+
+	// 3. 存储的哈希使用了过时的算法或参数时，趁持有明文密码透明地升级
+	if hasher.NeedsRehash(user.Password) {
+		s.rehashPassword(hasher, user, password)
+	}
+
+	// 4. block 模式下，未验证邮箱的用户不能登录
+	if s.Config.Auth.EmailVerification == config.EmailVerificationBlock && !user.IsEmailVerified() {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	// 5. 启用了两步验证的用户需要先完成第二步，这里只签发挑战令牌
+	mfaEnabled, err := s.MFAService.IsEnabled(user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mfaEnabled {
+		challenge, err := s.MFAService.IssueChallenge(user.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, challenge
+	}
+
+	// 6. 加载用户的角色信息
+	if err := s.UserRepository.Primary().LoadRole(user); err != nil {
+		return nil, nil, err
+	}
+
+	// 7. 签发令牌
+	tokens, err := s.TokenService.IssueTokens(user, client)
 	if err != nil {
-		return nil, "", &InvalidCredentialsError{}
+		return nil, nil, err
 	}
 
-	// 2. 验证提供的密码是否与存储的哈希密码匹配
-	if err := utils.CheckPasswordHash(password, user.Password); err != nil {
-		return nil, "", &InvalidCredentialsError{}
+	return user, tokens, nil
+}
+
+// VerifyMFA 完成两步登录的第二步。
+// 挑战令牌证明用户已经通过了密码校验，验证码（或恢复码）正确后签发真正的令牌对。
+// 验证码错误与登录失败一样计入登录锁定，持有密码的人不能无限次猜测验证码。
+func (s *AuthService) VerifyMFA(mfaToken, code string, client ClientInfo) (*models.User, *TokenPair, error) {
+	userID, err := s.MFAService.ChallengeUser(mfaToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// FindByID 会预加载角色信息，签发令牌使用的角色从主库读取
+	user, err := s.UserRepository.Primary().FindByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidMFAToken
+		}
+		return nil, nil, err
 	}
 
-	// 3. 加载用户的角色信息
-	if err := s.UserRepository.LoadRole(user); err != nil {
-		return nil, "", err
+	if err := s.LockoutService.Check(user.Username); err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.MFAService.VerifyChallenge(mfaToken, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if err := s.LockoutService.RecordFailure(user.Username); err != nil {
+				return nil, nil, err
+			}
+		}
+		return nil, nil, err
+	}
+	if err := s.LockoutService.RecordSuccess(user.Username); err != nil {
+		return nil, nil, err
 	}
 
-	// 4. 生成JWT
-	token, err := utils.GenerateToken(user.ID, user.Role.Name, s.Config)
+	tokens, err := s.TokenService.IssueTokens(user, client)
 	if err != nil {
-		return nil, "", err
+		return nil, nil, err
 	}
 
-	return user, token, nil
+	return user, tokens, nil
+}
+
+// ChangePassword 修改用户自己的密码。
+// 当前密码错误与登录失败一样计入登录锁定，防止持有被盗令牌的人猜测密码。
+// 修改成功后之前签发的访问令牌会被认证中间件拒绝，所有刷新令牌都被吊销，当前设备使用返回的新令牌对继续访问。
+func (s *AuthService) ChangePassword(userID uint, currentPassword, newPassword string, client ClientInfo) (*models.User, *TokenPair, error) {
+	// FindByID 会预加载角色信息；当前密码从主库读取，刚修改过的旧密码不能通过校验
+	user, err := s.UserRepository.Primary().FindByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 1. 校验当前密码
+	if err := s.LockoutService.Check(user.Username); err != nil {
+		return nil, nil, err
+	}
+	hasher, err := utils.PasswordHasherFor(s.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := hasher.Verify(currentPassword, user.Password); err != nil {
+		if !errors.Is(err, utils.ErrPasswordMismatch) {
+			return nil, nil, err
+		}
+		if err := s.LockoutService.RecordFailure(user.Username); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrIncorrectPassword
+	}
+	if err := s.LockoutService.RecordSuccess(user.Username); err != nil {
+		return nil, nil, err
+	}
+
+	// 2. 检查新密码是否符合策略
+	if err := s.PasswordPolicyService.Validate(user, newPassword); err != nil {
+		return nil, nil, err
+	}
+
+	// 3. 保存新密码和修改时间。访问令牌的签发时间精确到秒，修改时间同样截断到秒，
+	// 这样紧接着签发的新令牌不会被当作修改之前的令牌
+	hashedPassword, err := hasher.Hash(newPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+	changedAt := time.Now().Truncate(time.Second)
+	if err := s.UserRepository.ChangePassword(user.ID, hashedPassword, changedAt); err != nil {
+		return nil, nil, err
+	}
+	previousHash := user.Password
+	user.Password = hashedPassword
+	user.PasswordChangedAt = &changedAt
+
+	// 4. 记录旧密码。失败只记录日志，密码已经修改成功
+	if err := s.PasswordPolicyService.RecordHistory(user.ID, previousHash); err != nil {
+		utils.Logger.Error("failed to record password history", zap.Uint("user_id", user.ID), zap.Error(err))
+	}
+
+	// 5. 吊销所有会话，并为当前设备签发新的令牌对
+	if err := s.TokenService.RevokeAllForUser(user.ID); err != nil {
+		return nil, nil, err
+	}
+	tokens, err := s.TokenService.IssueTokens(user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, tokens, nil
+}
+
+// rehashPassword 使用当前配置重新生成用户的密码哈希。
+// 升级失败不影响本次登录，只记录日志，下次登录时会再次尝试。
+func (s *AuthService) rehashPassword(hasher utils.PasswordHasher, user *models.User, password string) {
+	hashedPassword, err := hasher.Hash(password)
+	if err == nil {
+		err = s.UserRepository.UpdatePassword(user.ID, hashedPassword)
+	}
+	if err != nil {
+		utils.Logger.Error("failed to upgrade password hash", zap.Uint("user_id", user.ID), zap.Error(err))
+		return
+	}
+	user.Password = hashedPassword
+}
+
+// Refresh 使用刷新令牌换取新的令牌对，具体的轮换和重放检测由令牌服务完成。
+func (s *AuthService) Refresh(refreshToken string) (*models.User, *TokenPair, error) {
+	return s.TokenService.Refresh(refreshToken)
+}
+
+// Logout 处理用户登出。
+// 当前访问令牌会被加入吊销列表；如果同时提供了属于当前用户的刷新令牌，其所在的令牌族也会被吊销。
+// 未知、已失效或属于其他用户的刷新令牌会被忽略，保证重复登出不会报错。
+func (s *AuthService) Logout(claims *utils.Claims, refreshToken string) error {
+	if err := s.TokenService.RevokeAccessToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+	if err := s.TokenService.RevokeRefreshToken(claims.UserID, refreshToken); err != nil && !errors.Is(err, ErrInvalidRefreshToken) {
+		return err
+	}
+	return nil
 }
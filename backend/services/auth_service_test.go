@@ -8,7 +8,9 @@ import (
 	"go-web/repositories"
 	"go-web/services"
 	"go-web/utils"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -24,7 +26,9 @@ type AuthServiceTestSuite struct {
 	service services.AuthServiceInterface
 	// 用于集成测试的真实仓库
 	userRepo repositories.UserRepository
-	roleRepo repositories.RoleRepository
+	roleRepo         repositories.RoleRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	revokedTokenRepo repositories.RevokedTokenRepository
 }
 
 // SetupSuite 在测试套件开始时运行，用于初始化数据库和配置。
@@ -37,7 +41,7 @@ func (suite *AuthServiceTestSuite) SetupSuite() {
 	suite.db = db
 
 	// 自动迁移数据库模式
-	err = suite.db.AutoMigrate(&models.User{}, &models.Role{})
+	err = suite.db.AutoMigrate(&models.User{}, &models.Role{}, &models.RefreshToken{}, &models.TOTPCredential{}, &models.RecoveryCode{}, &models.LoginFailure{}, &models.PasswordHistory{}, &models.Session{})
 	if err != nil {
 		suite.T().Fatalf("数据库迁移失败: %v", err)
 	}
@@ -48,17 +52,23 @@ func (suite *AuthServiceTestSuite) SetupSuite() {
 			DefaultRole: "user",
 		},
 		JWT: config.JWTConfig{
-			Secret:     "test-secret",
-			Expiration: 3600,
+			Secret:            "test-secret",
+			Expiration:        3600,
+			RefreshExpiration: 7200,
 		},
 	}
 
 	// 初始化用于集成测试的真实仓库
 	suite.userRepo = repositories.NewGormUserRepository(suite.db)
 	suite.roleRepo = repositories.NewGormRoleRepository(suite.db)
+	suite.refreshTokenRepo = repositories.NewGormRefreshTokenRepository(suite.db)
+	suite.revokedTokenRepo = repositories.NewMemoryRevokedTokenRepository()
 
 	// 初始化服务
-	suite.service = services.NewAuthService(suite.cfg, suite.userRepo, suite.roleRepo, suite.db)
+	tokenService := services.NewTokenService(suite.cfg, suite.userRepo, suite.refreshTokenRepo, suite.revokedTokenRepo, repositories.NewGormSessionRepository(suite.db), suite.db)
+	verificationService := services.NewEmailVerificationService(suite.cfg, suite.userRepo, &recordingMailer{})
+	mfaService := services.NewMFAService(suite.cfg, suite.userRepo, repositories.NewGormTOTPCredentialRepository(suite.db), repositories.NewGormRecoveryCodeRepository(suite.db), repositories.NewGormMFAChallengeRepository(suite.db))
+	suite.service = services.NewAuthService(suite.cfg, suite.userRepo, suite.roleRepo, tokenService, verificationService, mfaService, services.NewLockoutService(suite.cfg, repositories.NewGormLoginFailureRepository(suite.db)), services.NewPasswordPolicyService(suite.cfg, repositories.NewGormPasswordHistoryRepository(suite.db)), suite.db)
 }
 
 // SetupTest 在每个测试方法运行之前被调用。
@@ -67,6 +77,7 @@ func (suite *AuthServiceTestSuite) SetupTest() {
 	// 清理所有表以确保测试隔离
 	suite.db.Exec("DELETE FROM users")
 	suite.db.Exec("DELETE FROM roles")
+	suite.db.Exec("DELETE FROM refresh_tokens")
 
 	// 创建测试所需的基础数据
 	userRole := models.Role{Name: "user", Description: "普通用户"}
@@ -82,12 +93,13 @@ func TestAuthServiceTestSuite(t *testing.T) {
 // TestRegister_Success 测试新用户成功注册的场景（集成测试）。
 func (suite *AuthServiceTestSuite) TestRegister_Success() {
 	// 执行
-	user, token, err := suite.service.Register("testuser", "test@example.com", "password123")
+	user, tokens, err := suite.service.Register("testuser", "test@example.com", "password123", testClient)
 
 	// 断言
 	assert.NoError(suite.T(), err)
 	assert.NotNil(suite.T(), user)
-	assert.NotEmpty(suite.T(), token)
+	assert.NotEmpty(suite.T(), tokens.AccessToken)
+	assert.NotEmpty(suite.T(), tokens.RefreshToken)
 	assert.Equal(suite.T(), "testuser", user.Username)
 	assert.Equal(suite.T(), "user", user.Role.Name)
 
@@ -111,13 +123,13 @@ func (suite *AuthServiceTestSuite) TestRegister_UserExists() {
 	assert.NoError(suite.T(), err)
 
 	// 执行：尝试用相同的用户名再次注册
-	user, token, err := suite.service.Register("existinguser", "another@example.com", "password123")
+	user, tokens, err := suite.service.Register("existinguser", "another@example.com", "password123", testClient)
 
 	// 断言
 	assert.Error(suite.T(), err)
 	assert.IsType(suite.T(), &services.UserExistsError{}, err)
 	assert.Nil(suite.T(), user)
-	assert.Empty(suite.T(), token)
+	assert.Nil(suite.T(), tokens)
 }
 
 // TestLogin_Success 测试用户成功登录的场景。
@@ -135,16 +147,41 @@ func (suite *AuthServiceTestSuite) TestLogin_Success() {
 	suite.userRepo.Create(user)
 
 	// 执行
-	loggedInUser, token, err := suite.service.Login("loginuser", "password123")
+	loggedInUser, tokens, err := suite.service.Login("loginuser", "password123", testClient)
 
 	// 断言
 	assert.NoError(suite.T(), err)
 	assert.NotNil(suite.T(), loggedInUser)
-	assert.NotEmpty(suite.T(), token)
+	assert.NotEmpty(suite.T(), tokens.AccessToken)
+	assert.NotEmpty(suite.T(), tokens.RefreshToken)
 	assert.Equal(suite.T(), "loginuser", loggedInUser.Username)
 	assert.Equal(suite.T(), "admin", loggedInUser.Role.Name)
 }
 
+// TestLogin_RehashesLegacyPassword 测试使用旧算法哈希的密码在登录成功后被透明地升级为当前算法。
+func (suite *AuthServiceTestSuite) TestLogin_RehashesLegacyPassword() {
+	// 准备：使用bcrypt哈希的旧密码，当前配置默认为argon2id
+	hashedPassword, _ := utils.HashPassword("password123")
+	role := models.Role{Name: "user", Description: "普通用户"}
+	suite.roleRepo.Create(&role)
+	user := &models.User{Username: "legacyuser", Email: "legacy@example.com", Password: hashedPassword, RoleID: role.ID}
+	suite.userRepo.Create(user)
+
+	// 执行
+	_, _, err := suite.service.Login("legacyuser", "password123", testClient)
+
+	// 断言
+	suite.Require().NoError(err)
+	dbUser, err := suite.userRepo.FindByID(user.ID)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), strings.HasPrefix(dbUser.Password, "$argon2id$"))
+
+	// 升级后的哈希可以继续用于登录
+	_, tokens, err := suite.service.Login("legacyuser", "password123", testClient)
+	suite.Require().NoError(err)
+	assert.NotEmpty(suite.T(), tokens.AccessToken)
+}
+
 // TestLogin_InvalidCredentials 测试使用错误密码登录失败的场景。
 func (suite *AuthServiceTestSuite) TestLogin_InvalidCredentials() {
 	// 准备
@@ -153,23 +190,212 @@ func (suite *AuthServiceTestSuite) TestLogin_InvalidCredentials() {
 	suite.userRepo.Create(user)
 
 	// 执行
-	loggedInUser, token, err := suite.service.Login("loginuser", "wrong-password")
+	loggedInUser, tokens, err := suite.service.Login("loginuser", "wrong-password", testClient)
 
 	// 断言
 	assert.Error(suite.T(), err)
 	assert.IsType(suite.T(), &services.InvalidCredentialsError{}, err)
 	assert.Nil(suite.T(), loggedInUser)
-	assert.Empty(suite.T(), token)
+	assert.Nil(suite.T(), tokens)
 }
 
 // TestLogin_UserNotFound 测试用户不存在时登录失败的场景。
 func (suite *AuthServiceTestSuite) TestLogin_UserNotFound() {
 	// 执行
-	user, token, err := suite.service.Login("nonexistentuser", "password")
+	user, tokens, err := suite.service.Login("nonexistentuser", "password", testClient)
 
 	// 断言
 	assert.Error(suite.T(), err)
 	assert.IsType(suite.T(), &services.InvalidCredentialsError{}, err) // 服务返回相同的错误类型以避免泄露用户信息
 	assert.Nil(suite.T(), user)
-	assert.Empty(suite.T(), token)
+	assert.Nil(suite.T(), tokens)
+}
+
+// TestRefresh_RotatesToken 测试刷新令牌在使用后被轮换的场景。
+func (suite *AuthServiceTestSuite) TestRefresh_RotatesToken() {
+	// 准备
+	_, tokens, err := suite.service.Register("refreshuser", "refresh@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+
+	// 执行
+	user, refreshed, err := suite.service.Refresh(tokens.RefreshToken)
+
+	// 断言
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "refreshuser", user.Username)
+	assert.NotEmpty(suite.T(), refreshed.AccessToken)
+	assert.NotEqual(suite.T(), tokens.RefreshToken, refreshed.RefreshToken)
+
+	// 旧令牌已被吊销，并指向新令牌；两者属于同一令牌族
+	oldRecord, err := suite.refreshTokenRepo.FindByHash(utils.HashToken(tokens.RefreshToken))
+	suite.Require().NoError(err)
+	newRecord, err := suite.refreshTokenRepo.FindByHash(utils.HashToken(refreshed.RefreshToken))
+	suite.Require().NoError(err)
+	assert.NotNil(suite.T(), oldRecord.RevokedAt)
+	assert.Equal(suite.T(), newRecord.ID, *oldRecord.ReplacedByID)
+	assert.Equal(suite.T(), oldRecord.FamilyID, newRecord.FamilyID)
+	assert.Nil(suite.T(), newRecord.RevokedAt)
+}
+
+// TestRefresh_ReuseRevokesFamily 测试已轮换的刷新令牌被重复使用时整个令牌族被吊销的场景。
+func (suite *AuthServiceTestSuite) TestRefresh_ReuseRevokesFamily() {
+	// 准备：注册并完成一次轮换
+	_, tokens, err := suite.service.Register("reuseuser", "reuse@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	_, refreshed, err := suite.service.Refresh(tokens.RefreshToken)
+	suite.Require().NoError(err)
+
+	// 执行：再次使用已经轮换过的旧令牌
+	user, reused, err := suite.service.Refresh(tokens.RefreshToken)
+
+	// 断言
+	assert.ErrorIs(suite.T(), err, services.ErrRefreshTokenReused)
+	assert.Nil(suite.T(), user)
+	assert.Nil(suite.T(), reused)
+
+	// 轮换得到的新令牌也随整个令牌族一起失效
+	_, _, err = suite.service.Refresh(refreshed.RefreshToken)
+	assert.ErrorIs(suite.T(), err, services.ErrRefreshTokenReused)
+}
+
+// TestRefresh_InvalidToken 测试使用未知刷新令牌时失败的场景。
+func (suite *AuthServiceTestSuite) TestRefresh_InvalidToken() {
+	// 执行
+	user, tokens, err := suite.service.Refresh("not-a-real-token")
+
+	// 断言
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidRefreshToken)
+	assert.Nil(suite.T(), user)
+	assert.Nil(suite.T(), tokens)
+}
+
+// TestLogout_RevokesTokens 测试登出后访问令牌进入吊销列表、刷新令牌失效的场景。
+func (suite *AuthServiceTestSuite) TestLogout_RevokesTokens() {
+	// 准备
+	_, tokens, err := suite.service.Register("logoutuser", "logout@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	claims, err := utils.ValidateToken(tokens.AccessToken, suite.cfg)
+	suite.Require().NoError(err)
+
+	// 执行
+	err = suite.service.Logout(claims, tokens.RefreshToken)
+
+	// 断言
+	assert.NoError(suite.T(), err)
+
+	revoked, err := suite.revokedTokenRepo.IsRevoked(claims.ID)
+	assert.NoError(suite.T(), err)
+	assert.True(suite.T(), revoked)
+
+	_, _, err = suite.service.Refresh(tokens.RefreshToken)
+	assert.Error(suite.T(), err)
+
+	// 重复登出不会报错
+	assert.NoError(suite.T(), suite.service.Logout(claims, tokens.RefreshToken))
+}
+
+// TestLogout_IgnoresOtherUsersRefreshToken 测试登出时提交其他用户的刷新令牌不会吊销其会话。
+func (suite *AuthServiceTestSuite) TestLogout_IgnoresOtherUsersRefreshToken() {
+	// 准备
+	_, attacker, err := suite.service.Register("attacker", "attacker@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	_, victim, err := suite.service.Register("victim", "victim@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	claims, err := utils.ValidateToken(attacker.AccessToken, suite.cfg)
+	suite.Require().NoError(err)
+
+	// 执行
+	err = suite.service.Logout(claims, victim.RefreshToken)
+
+	// 断言：登出本身成功，但被害者的刷新令牌仍然有效
+	assert.NoError(suite.T(), err)
+	_, _, err = suite.service.Refresh(victim.RefreshToken)
+	assert.NoError(suite.T(), err)
+}
+
+// TestPurgeTokens 测试清理任务删除过期的吊销记录、过期的刷新令牌和吊销超过一个有效期的刷新令牌。
+func (suite *AuthServiceTestSuite) TestPurgeTokens() {
+	// 准备：一次登录轮换一次，另一次登录的令牌已经过期
+	_, tokens, err := suite.service.Register("purgeuser", "purge@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	_, rotated, err := suite.service.Refresh(tokens.RefreshToken)
+	suite.Require().NoError(err)
+	now := time.Now()
+	revokedAt := now.Add(-3 * time.Hour)
+	suite.Require().NoError(suite.refreshTokenRepo.Create(&models.RefreshToken{UserID: 1, TokenHash: "expired", FamilyID: "expired", ExpiresAt: now.Add(-time.Minute)}))
+	suite.Require().NoError(suite.refreshTokenRepo.Create(&models.RefreshToken{UserID: 1, TokenHash: "revoked-long-ago", FamilyID: "old", ExpiresAt: now.Add(time.Hour), RevokedAt: &revokedAt}))
+	revokedRepo := repositories.NewMemoryRevokedTokenRepository()
+	suite.Require().NoError(revokedRepo.Revoke("expired-jti", now.Add(-time.Minute)))
+	suite.Require().NoError(revokedRepo.Revoke("live-jti", now.Add(time.Hour)))
+
+	// 执行
+	revoked, refresh, err := services.PurgeTokens(revokedRepo, suite.refreshTokenRepo, 2*time.Hour)
+
+	// 断言：刚刚被轮换的令牌保留用于检测重放，当前的令牌仍然可用
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), int64(1), revoked)
+	assert.Equal(suite.T(), int64(2), refresh)
+	var remaining int64
+	suite.Require().NoError(suite.db.Unscoped().Model(&models.RefreshToken{}).Count(&remaining).Error)
+	assert.Equal(suite.T(), int64(2), remaining)
+	_, err = suite.refreshTokenRepo.FindByHash(utils.HashToken(tokens.RefreshToken))
+	assert.NoError(suite.T(), err)
+	_, _, err = suite.service.Refresh(rotated.RefreshToken)
+	assert.NoError(suite.T(), err)
+	isRevoked, err := revokedRepo.IsRevoked("live-jti")
+	suite.Require().NoError(err)
+	assert.True(suite.T(), isRevoked)
+}
+
+// TestChangePassword_Success 测试修改密码后旧会话失效、新令牌可用的场景。
+func (suite *AuthServiceTestSuite) TestChangePassword_Success() {
+	// 准备
+	user, oldTokens, err := suite.service.Register("changeuser", "change@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+
+	// 执行
+	_, tokens, err := suite.service.ChangePassword(user.ID, "password123", "new-password456", testClient)
+
+	// 断言
+	suite.Require().NoError(err)
+	assert.NotEmpty(suite.T(), tokens.AccessToken)
+
+	dbUser, err := suite.userRepo.FindByID(user.ID)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(dbUser.PasswordChangedAt)
+
+	// 新令牌的签发时间不早于修改时间，旧的刷新令牌已被吊销
+	claims, err := utils.ValidateToken(tokens.AccessToken, suite.cfg)
+	suite.Require().NoError(err)
+	assert.False(suite.T(), claims.IssuedAt.Time.Before(*dbUser.PasswordChangedAt))
+	_, _, err = suite.service.Refresh(oldTokens.RefreshToken)
+	assert.Error(suite.T(), err)
+	_, _, err = suite.service.Refresh(tokens.RefreshToken)
+	assert.NoError(suite.T(), err)
+
+	// 只能使用新密码登录
+	_, _, err = suite.service.Login("changeuser", "password123", testClient)
+	assert.IsType(suite.T(), &services.InvalidCredentialsError{}, err)
+	_, _, err = suite.service.Login("changeuser", "new-password456", testClient)
+	assert.NoError(suite.T(), err)
+}
+
+// TestChangePassword_IncorrectPassword 测试当前密码错误时不修改密码的场景。
+func (suite *AuthServiceTestSuite) TestChangePassword_IncorrectPassword() {
+	// 准备
+	user, _, err := suite.service.Register("changeuser", "change@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+
+	// 执行
+	_, tokens, err := suite.service.ChangePassword(user.ID, "wrong-password", "new-password456", testClient)
+
+	// 断言
+	assert.ErrorIs(suite.T(), err, services.ErrIncorrectPassword)
+	assert.Nil(suite.T(), tokens)
+
+	dbUser, err := suite.userRepo.FindByID(user.ID)
+	suite.Require().NoError(err)
+	assert.Nil(suite.T(), dbUser.PasswordChangedAt)
+	_, _, err = suite.service.Login("changeuser", "password123", testClient)
+	assert.NoError(suite.T(), err)
 }
@@ -0,0 +1,80 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件定义了基于属性的授权（ABAC）：服务在操作资源之前询问 Authorizer，
+// 规则保存在Casbin的 p2 策略中，可以通过策略管理接口修改。
+
+import (
+	"go-web/tenant"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// 资源类型。
+const (
+	ResourceUser = "user"
+)
+
+// 对资源的操作。
+const (
+	ActionUpdate     = "update"
+	ActionDelete     = "delete"
+	ActionChangeRole = "change_role"
+)
+
+// ownerRule 是“资源属于请求者”的规则表达式。
+const ownerRule = "r2.sub.ID == r2.obj.OwnerID"
+
+// DefaultAuthorizationRules 是默认的属性授权规则（p2 = sub, dom, obj, act, rule）。
+// dom 是规则生效的域，与 p 一样可以使用 "*" 或 "org:*" 这样的模式；
+// rule 是一个表达式，可以引用请求者 r2.sub（ID、Role、Domain）和资源 r2.obj（Type、OwnerID），
+// 例如管理员可以修改任何用户，普通用户只能修改自己。
+// 管理员规则只在全局域中生效：组织中的 admin 是成员角色，不能修改其他组织的用户。
+var DefaultAuthorizationRules = [][]string{
+	{"admin", tenant.GlobalDomain, ResourceUser, ActionUpdate, "true"},
+	{"admin", tenant.GlobalDomain, ResourceUser, ActionDelete, "true"},
+	{"admin", tenant.GlobalDomain, ResourceUser, ActionChangeRole, "true"},
+	{"user", tenant.AllDomains, ResourceUser, ActionUpdate, ownerRule},
+	{"user", tenant.AllDomains, ResourceUser, ActionDelete, ownerRule},
+}
+
+// Subject 是发起操作的用户。字段会在规则表达式中通过 r2.sub 引用。
+type Subject struct {
+	ID     uint
+	Role   string // 用户在 Domain 中的角色：全局域中是用户的角色，组织中是成员角色
+	Domain string // 请求所在的域，见 tenant.Domain
+}
+
+// Resource 是被操作的资源。字段会在规则表达式中通过 r2.obj 引用。
+type Resource struct {
+	Type    string
+	OwnerID uint
+}
+
+// Authorizer 定义了基于属性的授权检查。
+type Authorizer interface {
+	// Authorize 检查 subject 是否可以对 resource 执行 action，不允许时返回 ErrPermissionDenied。
+	Authorize(subject Subject, action string, resource Resource) error
+}
+
+// CasbinAuthorizer 使用Casbin模型中的 r2、p2、e2、m2 定义进行授权。
+type CasbinAuthorizer struct {
+	Enforcer *casbin.SyncedEnforcer
+}
+
+// NewCasbinAuthorizer 是 CasbinAuthorizer 的构造函数。
+func NewCasbinAuthorizer(enforcer *casbin.SyncedEnforcer) Authorizer {
+	return &CasbinAuthorizer{Enforcer: enforcer}
+}
+
+// Authorize 检查 subject 是否可以对 resource 执行 action。
+func (a *CasbinAuthorizer) Authorize(subject Subject, action string, resource Resource) error {
+	ok, err := a.Enforcer.Enforce(casbin.NewEnforceContext("2"), subject, resource, action)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPermissionDenied
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责邮箱验证流程：签发验证链接、发送邮件以及确认邮箱。
+
+import (
+	"errors"
+	"fmt"
+	"go-web/config"
+	"go-web/mailer"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/utils"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidVerificationToken 在邮箱验证链接签名无效、已过期或与当前邮箱不符时返回。
+var ErrInvalidVerificationToken = errors.New("邮箱验证链接无效或已过期")
+
+// ErrEmailNotVerified 在 block 模式下未验证邮箱的用户尝试登录时返回。
+var ErrEmailNotVerified = errors.New("邮箱尚未验证，请先完成邮箱验证")
+
+// emailVerificationClaims 是邮箱验证令牌中携带的声明。
+// 令牌中记录了签发时的邮箱，用户修改邮箱后旧的链接自动失效。
+type emailVerificationClaims struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// EmailVerificationServiceInterface 定义了邮箱验证服务应实现的功能契约。
+type EmailVerificationServiceInterface interface {
+	// SendVerification 为用户签发验证链接并发送邮件。
+	SendVerification(user *models.User) error
+	// VerifyEmail 校验验证令牌并将对应用户的邮箱标记为已验证。
+	VerifyEmail(token string) (*models.User, error)
+	// ResendVerification 重新向邮箱发送验证链接。
+	// 无论邮箱是否注册或是否已验证都返回成功，避免泄露账户信息。
+	ResendVerification(email string) error
+}
+
+// EmailVerificationService 提供了邮箱验证相关的业务逻辑实现。
+// 验证令牌是无状态的签名JWT，不需要在数据库中保存。
+type EmailVerificationService struct {
+	Config         *config.Config
+	UserRepository repositories.UserRepository
+	Mailer         mailer.Mailer
+}
+
+// NewEmailVerificationService 是 EmailVerificationService 的构造函数。
+func NewEmailVerificationService(cfg *config.Config, userRepo repositories.UserRepository, m mailer.Mailer) EmailVerificationServiceInterface {
+	return &EmailVerificationService{
+		Config:         cfg,
+		UserRepository: userRepo,
+		Mailer:         m,
+	}
+}
+
+// SendVerification 为用户签发验证令牌并通过邮件发送验证链接。
+func (s *EmailVerificationService) SendVerification(user *models.User) error {
+	expiration := time.Duration(s.Config.Auth.EmailVerificationExpiration) * time.Second
+	now := time.Now()
+	claims := &emailVerificationClaims{
+		UserID: user.ID,
+		Email:  user.Email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiration)),
+		},
+	}
+	token, err := utils.SignToken(claims, utils.TokenTypeEmailVerification, s.Config)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimRight(s.Config.App.FrontendURL, "/"), url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "验证您的邮箱",
+		Body: fmt.Sprintf("%s，您好：\n\n感谢您的注册。请在 %d 小时内访问以下链接验证您的邮箱：\n\n%s\n\n如果您没有注册过账户，请忽略此邮件。",
+			user.Username, int(expiration.Hours()), link),
+	}
+	return s.Mailer.Send(msg)
+}
+
+// VerifyEmail 校验验证令牌并将邮箱标记为已验证。
+// 重复验证同一个邮箱不会报错，直接返回用户。
+func (s *EmailVerificationService) VerifyEmail(token string) (*models.User, error) {
+	// 1. 校验签名、过期时间和令牌类型
+	claims := &emailVerificationClaims{}
+	if err := utils.ParseSignedToken(token, claims, utils.TokenTypeEmailVerification, s.Config); err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	// 2. 查找用户，邮箱已被修改时旧链接无效
+	user, err := s.UserRepository.FindByID(claims.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidVerificationToken
+		}
+		return nil, err
+	}
+	if !strings.EqualFold(user.Email, claims.Email) {
+		return nil, ErrInvalidVerificationToken
+	}
+	if user.IsEmailVerified() {
+		return user, nil
+	}
+
+	// 3. 标记为已验证并从主库重新加载
+	if err := s.UserRepository.MarkEmailVerified(user.ID); err != nil {
+		return nil, err
+	}
+	return s.UserRepository.Primary().FindByID(user.ID)
+}
+
+// ResendVerification 重新发送验证邮件。
+// 邮箱未注册或已验证时静默返回；邮件在后台发送，失败只记录日志，避免响应内容和响应时间的差异暴露账户是否存在。
+func (s *EmailVerificationService) ResendVerification(email string) error {
+	user, err := s.UserRepository.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.IsEmailVerified() {
+		return nil
+	}
+
+	sendInBackground("failed to send verification mail", user.ID, func() error {
+		return s.SendVerification(user)
+	})
+	return nil
+}
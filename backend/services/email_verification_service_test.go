@@ -0,0 +1,230 @@
+package services_test
+
+import (
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/services"
+	"go-web/tenant"
+	"go-web/utils"
+	"testing"
+	"time"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// EmailVerificationServiceTestSuite 是一个测试套件，用于组织与邮箱验证相关的集成测试。
+type EmailVerificationServiceTestSuite struct {
+	suite.Suite
+	db           *gorm.DB
+	cfg          *config.Config
+	mailer       *recordingMailer
+	service      services.EmailVerificationServiceInterface
+	authService  services.AuthServiceInterface
+	userService  services.UserServiceInterface
+	userRepo     repositories.UserRepository
+	roleRepo     repositories.RoleRepository
+	tokenService services.TokenServiceInterface
+}
+
+// SetupSuite 在测试套件开始时运行，用于初始化数据库和配置。
+func (suite *EmailVerificationServiceTestSuite) SetupSuite() {
+	db, err := gorm.Open(sqlite.Open("file:email_verification?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		suite.T().Fatalf("无法连接到内存数据库: %v", err)
+	}
+	suite.db = db
+
+	err = suite.db.AutoMigrate(&models.User{}, &models.Role{}, &models.RefreshToken{}, &models.TOTPCredential{}, &models.RecoveryCode{}, &models.LoginFailure{}, &models.PasswordHistory{}, &models.Session{})
+	if err != nil {
+		suite.T().Fatalf("数据库迁移失败: %v", err)
+	}
+
+	suite.cfg = &config.Config{
+		App: config.AppConfig{
+			DefaultRole: "user",
+			FrontendURL: "http://localhost:3000",
+		},
+		JWT: config.JWTConfig{
+			Secret:            "test-secret",
+			Expiration:        3600,
+			RefreshExpiration: 7200,
+		},
+		Auth: config.AuthConfig{
+			EmailVerificationExpiration: 86400,
+			UnverifiedRole:              "unverified",
+		},
+	}
+
+	suite.userRepo = repositories.NewGormUserRepository(suite.db)
+	suite.roleRepo = repositories.NewGormRoleRepository(suite.db)
+	suite.tokenService = services.NewTokenService(suite.cfg, suite.userRepo, repositories.NewGormRefreshTokenRepository(suite.db), repositories.NewMemoryRevokedTokenRepository(), repositories.NewGormSessionRepository(suite.db), suite.db)
+
+	// 用户服务使用默认的属性授权规则
+	casbinModel, err := model.NewModelFromString(config.DefaultCasbinModel)
+	suite.Require().NoError(err)
+	enforcer, err := casbin.NewSyncedEnforcer(casbinModel)
+	suite.Require().NoError(err)
+	_, err = enforcer.AddNamedPolicies("p2", services.DefaultAuthorizationRules)
+	suite.Require().NoError(err)
+	suite.userService = services.NewUserService(suite.userRepo, services.NewCasbinAuthorizer(enforcer))
+}
+
+// SetupTest 在每个测试方法运行之前被调用，清理数据并重新创建服务。
+func (suite *EmailVerificationServiceTestSuite) SetupTest() {
+	suite.db.Exec("DELETE FROM users")
+	suite.db.Exec("DELETE FROM roles")
+	suite.db.Exec("DELETE FROM refresh_tokens")
+	suite.Require().NoError(suite.roleRepo.Create(&models.Role{Name: "user", Description: "普通用户"}))
+
+	suite.cfg.Auth.EmailVerification = config.EmailVerificationOff
+	suite.mailer = &recordingMailer{}
+	suite.service = services.NewEmailVerificationService(suite.cfg, suite.userRepo, suite.mailer)
+	mfaService := services.NewMFAService(suite.cfg, suite.userRepo, repositories.NewGormTOTPCredentialRepository(suite.db), repositories.NewGormRecoveryCodeRepository(suite.db), repositories.NewGormMFAChallengeRepository(suite.db))
+	suite.authService = services.NewAuthService(suite.cfg, suite.userRepo, suite.roleRepo, suite.tokenService, suite.service, mfaService, services.NewLockoutService(suite.cfg, repositories.NewGormLoginFailureRepository(suite.db)), services.NewPasswordPolicyService(suite.cfg, repositories.NewGormPasswordHistoryRepository(suite.db)), suite.db)
+}
+
+// TestEmailVerificationServiceTestSuite 运行测试套件。
+func TestEmailVerificationServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(EmailVerificationServiceTestSuite))
+}
+
+// TestVerifyEmail_Success 测试通过注册邮件中的链接成功验证邮箱的场景。
+func (suite *EmailVerificationServiceTestSuite) TestVerifyEmail_Success() {
+	// 准备：注册时发送验证邮件
+	user, _, err := suite.authService.Register("verifyuser", "verify@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	suite.Require().Len(suite.mailer.messages, 1)
+	assert.Equal(suite.T(), "verify@example.com", suite.mailer.last().To)
+	assert.False(suite.T(), user.IsEmailVerified())
+
+	// 执行
+	token := tokenFromLink(suite.mailer.last().Body)
+	suite.Require().NotEmpty(token)
+	verified, err := suite.service.VerifyEmail(token)
+
+	// 断言
+	suite.Require().NoError(err)
+	assert.True(suite.T(), verified.IsEmailVerified())
+
+	// 重复访问链接不会报错
+	_, err = suite.service.VerifyEmail(token)
+	assert.NoError(suite.T(), err)
+}
+
+// TestVerifyEmail_InvalidToken 测试伪造的令牌和其他用途的令牌都无法用于验证邮箱。
+func (suite *EmailVerificationServiceTestSuite) TestVerifyEmail_InvalidToken() {
+	user, tokens, err := suite.authService.Register("verifyuser", "verify@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+
+	_, err = suite.service.VerifyEmail("not-a-token")
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidVerificationToken)
+
+	// 访问令牌使用相同的密钥签名，但类型不同
+	_, err = suite.service.VerifyEmail(tokens.AccessToken)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidVerificationToken)
+
+	dbUser, err := suite.userRepo.FindByID(user.ID)
+	suite.Require().NoError(err)
+	assert.False(suite.T(), dbUser.IsEmailVerified())
+}
+
+// TestVerifyEmail_EmailChanged 测试修改邮箱后旧的验证链接失效的场景。
+func (suite *EmailVerificationServiceTestSuite) TestVerifyEmail_EmailChanged() {
+	user, _, err := suite.authService.Register("verifyuser", "verify@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	oldToken := tokenFromLink(suite.mailer.last().Body)
+
+	_, err = suite.userService.UpdateUser(user.ID, services.Subject{ID: user.ID, Role: "user", Domain: tenant.GlobalDomain}, &models.User{Email: "changed@example.com"})
+	suite.Require().NoError(err)
+
+	_, err = suite.service.VerifyEmail(oldToken)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidVerificationToken)
+
+	// 重新发送的链接指向新邮箱
+	sent := suite.mailer.count()
+	suite.Require().NoError(suite.service.ResendVerification("changed@example.com"))
+	suite.mailer.waitFor(suite.T(), sent+1)
+	assert.Equal(suite.T(), "changed@example.com", suite.mailer.last().To)
+	_, err = suite.service.VerifyEmail(tokenFromLink(suite.mailer.last().Body))
+	assert.NoError(suite.T(), err)
+}
+
+// TestResendVerification_Silent 测试邮箱未注册或已验证时静默成功且不发送邮件的场景。
+func (suite *EmailVerificationServiceTestSuite) TestResendVerification_Silent() {
+	_, _, err := suite.authService.Register("verifyuser", "verify@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	_, err = suite.service.VerifyEmail(tokenFromLink(suite.mailer.last().Body))
+	suite.Require().NoError(err)
+	sent := len(suite.mailer.messages)
+
+	assert.NoError(suite.T(), suite.service.ResendVerification("nobody@example.com"))
+	assert.NoError(suite.T(), suite.service.ResendVerification("verify@example.com"))
+	assert.Len(suite.T(), suite.mailer.messages, sent)
+}
+
+// TestResendVerification_SendsInBackground 测试重新发送验证邮件不等待邮件发送完成。
+func (suite *EmailVerificationServiceTestSuite) TestResendVerification_SendsInBackground() {
+	_, _, err := suite.authService.Register("verifyuser", "verify@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	m := newBlockingMailer()
+	service := services.NewEmailVerificationService(suite.cfg, suite.userRepo, m)
+
+	// 邮件发送被阻塞时请求仍然立即返回
+	suite.Require().NoError(service.ResendVerification("verify@example.com"))
+	close(m.release)
+	select {
+	case msg := <-m.sent:
+		assert.Equal(suite.T(), "verify@example.com", msg.To)
+		_, err = service.VerifyEmail(tokenFromLink(msg.Body))
+		assert.NoError(suite.T(), err)
+	case <-time.After(time.Second):
+		suite.T().Fatal("验证邮件没有发送")
+	}
+}
+
+// TestBlockMode 测试 block 模式下邮箱验证之前无法登录的场景。
+func (suite *EmailVerificationServiceTestSuite) TestBlockMode() {
+	suite.cfg.Auth.EmailVerification = config.EmailVerificationBlock
+
+	// 注册成功但不签发令牌
+	user, tokens, err := suite.authService.Register("verifyuser", "verify@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	assert.NotNil(suite.T(), user)
+	assert.Nil(suite.T(), tokens)
+
+	_, _, err = suite.authService.Login("verifyuser", "password123", testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrEmailNotVerified)
+
+	// 验证之后可以正常登录
+	_, err = suite.service.VerifyEmail(tokenFromLink(suite.mailer.last().Body))
+	suite.Require().NoError(err)
+	_, tokens, err = suite.authService.Login("verifyuser", "password123", testClient)
+	suite.Require().NoError(err)
+	assert.NotEmpty(suite.T(), tokens.AccessToken)
+}
+
+// TestRestrictMode 测试 restrict 模式下未验证用户只获得受限角色，验证后刷新令牌恢复原角色的场景。
+func (suite *EmailVerificationServiceTestSuite) TestRestrictMode() {
+	suite.cfg.Auth.EmailVerification = config.EmailVerificationRestrict
+
+	_, tokens, err := suite.authService.Register("verifyuser", "verify@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	claims, err := utils.ValidateToken(tokens.AccessToken, suite.cfg)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "unverified", claims.Role)
+
+	_, err = suite.service.VerifyEmail(tokenFromLink(suite.mailer.last().Body))
+	suite.Require().NoError(err)
+
+	_, tokens, err = suite.authService.Refresh(tokens.RefreshToken)
+	suite.Require().NoError(err)
+	claims, err = utils.ValidateToken(tokens.AccessToken, suite.cfg)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "user", claims.Role)
+}
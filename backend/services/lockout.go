@@ -0,0 +1,198 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责按用户名的暴力破解防护：失败退避、临时锁定以及管理员解锁。
+
+import (
+	"errors"
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/utils"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrAccountLocked 是所有 *AccountLockedError 都匹配的哨兵错误，便于统一映射HTTP状态码。
+var ErrAccountLocked = errors.New("账户已被临时锁定，请稍后再试")
+
+// AccountLockedError 在用户名处于退避等待或锁定期间尝试登录时返回。
+type AccountLockedError struct {
+	Locked     bool          // true 表示达到失败上限被锁定，false 表示处于两次尝试之间的退避等待
+	RetryAfter time.Duration // 距离可以再次尝试的时间
+}
+
+// Error 实现了 error 接口。
+func (e *AccountLockedError) Error() string {
+	if e.Locked {
+		return ErrAccountLocked.Error()
+	}
+	return "登录尝试过于频繁，请稍后再试"
+}
+
+// Is 使 errors.Is(err, ErrAccountLocked) 对所有 *AccountLockedError 成立。
+func (e *AccountLockedError) Is(target error) bool {
+	return target == ErrAccountLocked
+}
+
+// RetryAfterSeconds 返回客户端应等待的秒数（向上取整），用于设置 Retry-After 响应头。
+func (e *AccountLockedError) RetryAfterSeconds() int {
+	return int((e.RetryAfter + time.Second - 1) / time.Second)
+}
+
+// LockoutServiceInterface 定义了登录锁定服务应实现的功能契约。
+type LockoutServiceInterface interface {
+	// Check 在校验密码之前调用，用户名处于退避或锁定期间时返回 *AccountLockedError。
+	Check(username string) error
+	// RecordFailure 记录一次登录失败，失败次数达到上限时锁定账户。
+	RecordFailure(username string) error
+	// RecordSuccess 在登录成功后清除失败记录。
+	RecordSuccess(username string) error
+	// ListLocked 返回当前所有被锁定的用户名。
+	ListLocked() ([]models.LoginFailure, error)
+	// Unlock 由管理员清除用户名的锁定和失败计数。
+	Unlock(username string, adminID uint) error
+}
+
+// LockoutService 提供了登录锁定相关的业务逻辑实现。
+type LockoutService struct {
+	Config                 *config.Config
+	LoginFailureRepository repositories.LoginFailureRepository
+}
+
+// NewLockoutService 是 LockoutService 的构造函数。
+func NewLockoutService(cfg *config.Config, loginFailureRepo repositories.LoginFailureRepository) LockoutServiceInterface {
+	return &LockoutService{
+		Config:                 cfg,
+		LoginFailureRepository: loginFailureRepo,
+	}
+}
+
+// Check 检查用户名当前是否允许尝试登录。
+func (s *LockoutService) Check(username string) error {
+	if !s.enabled() {
+		return nil
+	}
+
+	failure, err := s.LoginFailureRepository.FindByUsername(normalizeUsername(username))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	now := time.Now()
+	if failure.IsLocked(now) {
+		return &AccountLockedError{Locked: true, RetryAfter: failure.LockedUntil.Sub(now)}
+	}
+	if failure.LockedUntil == nil && failure.FailedCount > 0 {
+		next := failure.LastFailedAt.Add(s.backoff(failure.FailedCount))
+		if now.Before(next) {
+			return &AccountLockedError{RetryAfter: next.Sub(now)}
+		}
+	}
+	return nil
+}
+
+// RecordFailure 记录一次登录失败。
+// 锁定过期或距上次失败已超过锁定时长的旧记录会先被清除，重新开始计数。
+func (s *LockoutService) RecordFailure(username string) error {
+	if !s.enabled() {
+		return nil
+	}
+
+	username = normalizeUsername(username)
+	now := time.Now()
+	lockDuration := time.Duration(s.Config.Auth.LockoutDuration) * time.Second
+
+	previous, err := s.LoginFailureRepository.FindByUsername(username)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if previous != nil && !previous.IsLocked(now) &&
+		(previous.LockedUntil != nil || now.Sub(previous.LastFailedAt) > lockDuration) {
+		if _, err := s.LoginFailureRepository.Delete(username); err != nil {
+			return err
+		}
+	}
+
+	failure, err := s.LoginFailureRepository.Increment(username, now)
+	if err != nil {
+		return err
+	}
+	if failure.FailedCount < s.Config.Auth.LockoutThreshold || failure.IsLocked(now) {
+		return nil
+	}
+
+	until := now.Add(lockDuration)
+	if err := s.LoginFailureRepository.Lock(username, until); err != nil {
+		return err
+	}
+	utils.Logger.Warn("account locked",
+		zap.String("event", "account.locked"),
+		zap.String("username", username),
+		zap.Int("failed_count", failure.FailedCount),
+		zap.Time("locked_until", until),
+	)
+	return nil
+}
+
+// RecordSuccess 清除用户名的失败记录。
+func (s *LockoutService) RecordSuccess(username string) error {
+	if !s.enabled() {
+		return nil
+	}
+	_, err := s.LoginFailureRepository.Delete(normalizeUsername(username))
+	return err
+}
+
+// ListLocked 返回当前所有被锁定的用户名。
+func (s *LockoutService) ListLocked() ([]models.LoginFailure, error) {
+	return s.LoginFailureRepository.FindLocked(time.Now())
+}
+
+// Unlock 清除用户名的锁定和失败计数。没有记录时返回 gorm.ErrRecordNotFound。
+func (s *LockoutService) Unlock(username string, adminID uint) error {
+	username = normalizeUsername(username)
+	deleted, err := s.LoginFailureRepository.Delete(username)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return gorm.ErrRecordNotFound
+	}
+	utils.Logger.Info("account unlocked",
+		zap.String("event", "account.unlocked"),
+		zap.String("username", username),
+		zap.Uint("admin_id", adminID),
+	)
+	return nil
+}
+
+// enabled 返回是否开启了登录锁定。
+func (s *LockoutService) enabled() bool {
+	return s.Config.Auth.LockoutThreshold > 0
+}
+
+// backoff 返回第 failedCount 次失败之后需要等待的时间：base * 2^(failedCount-1)，不超过锁定时长。
+func (s *LockoutService) backoff(failedCount int) time.Duration {
+	base := time.Duration(s.Config.Auth.LockoutBackoffBase) * time.Second
+	limit := time.Duration(s.Config.Auth.LockoutDuration) * time.Second
+	delay := base
+	for i := 1; i < failedCount && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// normalizeUsername 统一用户名的大小写和空白，避免通过变换大小写绕过计数。
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
@@ -0,0 +1,169 @@
+package services_test
+
+import (
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/services"
+	"go-web/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// LockoutServiceTestSuite 是一个测试套件，用于组织与登录锁定相关的集成测试。
+type LockoutServiceTestSuite struct {
+	suite.Suite
+	db          *gorm.DB
+	cfg         *config.Config
+	service     services.LockoutServiceInterface
+	authService services.AuthServiceInterface
+	userRepo    repositories.UserRepository
+	roleRepo    repositories.RoleRepository
+}
+
+// SetupSuite 在测试套件开始时运行，用于初始化数据库、配置和服务。
+func (suite *LockoutServiceTestSuite) SetupSuite() {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // 锁定和解锁会记录审计日志
+
+	db, err := gorm.Open(sqlite.Open("file:lockout?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		suite.T().Fatalf("无法连接到内存数据库: %v", err)
+	}
+	suite.db = db
+
+	err = suite.db.AutoMigrate(&models.User{}, &models.Role{}, &models.RefreshToken{}, &models.TOTPCredential{}, &models.RecoveryCode{}, &models.LoginFailure{}, &models.PasswordHistory{}, &models.Session{})
+	if err != nil {
+		suite.T().Fatalf("数据库迁移失败: %v", err)
+	}
+
+	suite.cfg = &config.Config{
+		App: config.AppConfig{
+			DefaultRole: "user",
+		},
+		JWT: config.JWTConfig{
+			Secret:            "test-secret",
+			Expiration:        3600,
+			RefreshExpiration: 7200,
+		},
+	}
+
+	suite.userRepo = repositories.NewGormUserRepository(suite.db)
+	suite.roleRepo = repositories.NewGormRoleRepository(suite.db)
+	tokenService := services.NewTokenService(suite.cfg, suite.userRepo, repositories.NewGormRefreshTokenRepository(suite.db), repositories.NewMemoryRevokedTokenRepository(), repositories.NewGormSessionRepository(suite.db), suite.db)
+	verificationService := services.NewEmailVerificationService(suite.cfg, suite.userRepo, &recordingMailer{})
+	mfaService := services.NewMFAService(suite.cfg, suite.userRepo, repositories.NewGormTOTPCredentialRepository(suite.db), repositories.NewGormRecoveryCodeRepository(suite.db), repositories.NewGormMFAChallengeRepository(suite.db))
+	suite.service = services.NewLockoutService(suite.cfg, repositories.NewGormLoginFailureRepository(suite.db))
+	suite.authService = services.NewAuthService(suite.cfg, suite.userRepo, suite.roleRepo, tokenService, verificationService, mfaService, suite.service, services.NewPasswordPolicyService(suite.cfg, repositories.NewGormPasswordHistoryRepository(suite.db)), suite.db)
+}
+
+// SetupTest 在每个测试方法运行之前被调用，清理数据并创建测试用户。
+// 默认不启用退避等待，每个测试可以按需修改配置。
+func (suite *LockoutServiceTestSuite) SetupTest() {
+	suite.db.Exec("DELETE FROM users")
+	suite.db.Exec("DELETE FROM roles")
+	suite.db.Exec("DELETE FROM login_failures")
+
+	suite.cfg.Auth.LockoutThreshold = 3
+	suite.cfg.Auth.LockoutDuration = 900
+	suite.cfg.Auth.LockoutBackoffBase = 0
+
+	suite.Require().NoError(suite.roleRepo.Create(&models.Role{Name: "user", Description: "普通用户"}))
+	_, _, err := suite.authService.Register("lockuser", "lock@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+}
+
+// TestLockoutServiceTestSuite 运行测试套件。
+func TestLockoutServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(LockoutServiceTestSuite))
+}
+
+// failLogin 使用错误的密码登录 n 次。
+func (suite *LockoutServiceTestSuite) failLogin(username string, n int) {
+	for i := 0; i < n; i++ {
+		_, _, err := suite.authService.Login(username, "wrong-password", testClient)
+		suite.Require().IsType(&services.InvalidCredentialsError{}, err)
+	}
+}
+
+// TestLogin_LocksAfterThreshold 测试连续失败达到上限后，正确的密码也无法登录，直到管理员解锁。
+func (suite *LockoutServiceTestSuite) TestLogin_LocksAfterThreshold() {
+	suite.failLogin("lockuser", 3)
+
+	_, _, err := suite.authService.Login("lockuser", "password123", testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrAccountLocked)
+	var lockedErr *services.AccountLockedError
+	suite.Require().ErrorAs(err, &lockedErr)
+	assert.True(suite.T(), lockedErr.Locked)
+	assert.InDelta(suite.T(), 900, lockedErr.RetryAfterSeconds(), 2)
+
+	locks, err := suite.service.ListLocked()
+	suite.Require().NoError(err)
+	suite.Require().Len(locks, 1)
+	assert.Equal(suite.T(), "lockuser", locks[0].Username)
+	assert.Equal(suite.T(), 3, locks[0].FailedCount)
+
+	suite.Require().NoError(suite.service.Unlock("lockuser", 1))
+	_, tokens, err := suite.authService.Login("lockuser", "password123", testClient)
+	suite.Require().NoError(err)
+	assert.NotEmpty(suite.T(), tokens.AccessToken)
+}
+
+// TestLogin_Backoff 测试两次失败之间需要等待的退避时间。
+func (suite *LockoutServiceTestSuite) TestLogin_Backoff() {
+	suite.cfg.Auth.LockoutBackoffBase = 60
+	suite.failLogin("lockuser", 1)
+
+	_, _, err := suite.authService.Login("lockuser", "password123", testClient)
+	var lockedErr *services.AccountLockedError
+	suite.Require().ErrorAs(err, &lockedErr)
+	assert.False(suite.T(), lockedErr.Locked)
+	assert.LessOrEqual(suite.T(), lockedErr.RetryAfter, 60*time.Second)
+
+	// 退避期间的请求不计入失败次数
+	locks, err := suite.service.ListLocked()
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), locks)
+}
+
+// TestLogin_SuccessResetsFailures 测试登录成功后失败计数被清零。
+func (suite *LockoutServiceTestSuite) TestLogin_SuccessResetsFailures() {
+	suite.failLogin("lockuser", 2)
+	_, _, err := suite.authService.Login("lockuser", "password123", testClient)
+	suite.Require().NoError(err)
+
+	suite.failLogin("lockuser", 2)
+	_, _, err = suite.authService.Login("lockuser", "password123", testClient)
+	assert.NoError(suite.T(), err)
+}
+
+// TestLogin_UnknownAndMixedCaseUsernames 测试不存在的用户名同样会被锁定，且大小写不同的用户名共用计数。
+func (suite *LockoutServiceTestSuite) TestLogin_UnknownAndMixedCaseUsernames() {
+	suite.failLogin("ghost", 3)
+	_, _, err := suite.authService.Login("ghost", "whatever", testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrAccountLocked)
+
+	suite.failLogin("LockUser", 2)
+	suite.failLogin("lockuser", 1)
+	_, _, err = suite.authService.Login("lockuser", "password123", testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrAccountLocked)
+}
+
+// TestLogin_Disabled 测试阈值为0时不做任何限制。
+func (suite *LockoutServiceTestSuite) TestLogin_Disabled() {
+	suite.cfg.Auth.LockoutThreshold = 0
+	suite.failLogin("lockuser", 5)
+
+	_, _, err := suite.authService.Login("lockuser", "password123", testClient)
+	assert.NoError(suite.T(), err)
+}
+
+// TestUnlock_NotLocked 测试解锁不存在的记录时返回未找到。
+func (suite *LockoutServiceTestSuite) TestUnlock_NotLocked() {
+	err := suite.service.Unlock("nobody", 1)
+	assert.ErrorIs(suite.T(), err, gorm.ErrRecordNotFound)
+}
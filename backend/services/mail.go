@@ -0,0 +1,21 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责在后台发送邮件。
+
+import (
+	"go-web/utils"
+
+	"go.uber.org/zap"
+)
+
+// sendInBackground 在新的goroutine中执行 send，发送失败只记录日志。
+// 用于邮箱是否注册不能从响应中看出的接口：发送邮件（尤其是SMTP）耗时较长，
+// 同步发送时响应时间会暴露是否真的发送了邮件。进程退出时尚未发送完的邮件会丢失。
+func sendInBackground(logMessage string, userID uint, send func() error) {
+	go func() {
+		if err := send(); err != nil {
+			utils.Logger.Error(logMessage, zap.Uint("user_id", userID), zap.Error(err))
+		}
+	}()
+}
@@ -0,0 +1,342 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责TOTP两步验证：绑定、确认、关闭、恢复码以及登录时的挑战令牌。
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/utils"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidMFACode 在两步验证码或恢复码错误、已被使用时返回。
+var ErrInvalidMFACode = errors.New("无效的两步验证码")
+
+// ErrInvalidMFAToken 在两步验证挑战令牌无效或已过期时返回。
+var ErrInvalidMFAToken = errors.New("两步验证已超时，请重新登录")
+
+// ErrMFAAlreadyEnabled 在已启用两步验证的用户再次绑定时返回。
+var ErrMFAAlreadyEnabled = errors.New("两步验证已启用")
+
+// ErrMFANotEnabled 在未启用两步验证的用户尝试确认或关闭时返回。
+var ErrMFANotEnabled = errors.New("两步验证未启用")
+
+// DefaultMFAMaxAttempts 是没有配置 auth.mfa_max_attempts 时每个挑战令牌允许尝试的次数。
+const DefaultMFAMaxAttempts = 5
+
+// 恢复码的数量和格式
+const (
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 5 // 每个恢复码40位随机数，编码为 xxxx-xxxx 形式
+)
+
+// MFARequiredError 在密码校验通过、但用户启用了两步验证时由 Login 返回。
+// 客户端需要使用其中的挑战令牌和验证码调用 VerifyMFA 换取真正的访问令牌。
+type MFARequiredError struct {
+	MFAToken  string // 短期有效的挑战令牌
+	ExpiresIn int    // 挑战令牌的有效期（以秒为单位）
+}
+
+// Error 实现了 error 接口。
+func (e *MFARequiredError) Error() string {
+	return "需要进行两步验证"
+}
+
+// mfaChallengeClaims 是两步验证挑战令牌中携带的声明。
+type mfaChallengeClaims struct {
+	UserID uint `json:"user_id"`
+	jwt.RegisteredClaims
+}
+
+// TOTPEnrollment 是开始绑定TOTP时返回给用户的信息。
+type TOTPEnrollment struct {
+	Secret     string // Base32编码的密钥，供无法扫码时手动输入
+	OTPAuthURI string // otpauth:// URI，可直接生成二维码
+}
+
+// MFAServiceInterface 定义了两步验证服务应实现的功能契约。
+type MFAServiceInterface interface {
+	// EnrollTOTP 为用户生成新的TOTP密钥。密钥在 ConfirmTOTP 之前不会生效。
+	EnrollTOTP(userID uint) (*TOTPEnrollment, error)
+	// ConfirmTOTP 使用身份验证器生成的验证码确认绑定，返回一组新的一次性恢复码。
+	ConfirmTOTP(userID uint, code string) ([]string, error)
+	// DisableTOTP 校验验证码（或恢复码）后关闭两步验证。
+	DisableTOTP(userID uint, code string) error
+	// RegenerateRecoveryCodes 校验验证码后作废旧的恢复码并生成一组新的。
+	RegenerateRecoveryCodes(userID uint, code string) ([]string, error)
+	// IsEnabled 返回用户是否已启用两步验证。
+	IsEnabled(userID uint) (bool, error)
+	// IssueChallenge 为已通过密码校验的用户签发两步验证挑战令牌。
+	IssueChallenge(userID uint) (*MFARequiredError, error)
+	// ChallengeUser 校验挑战令牌的签名和有效期，返回令牌所属的用户ID，不消耗尝试次数。
+	ChallengeUser(mfaToken string) (uint, error)
+	// VerifyChallenge 校验挑战令牌和验证码，成功时返回用户ID。挑战令牌只能成功使用一次。
+	VerifyChallenge(mfaToken, code string) (uint, error)
+}
+
+// MFAService 提供了两步验证相关的业务逻辑实现。
+type MFAService struct {
+	Config                   *config.Config
+	UserRepository           repositories.UserRepository
+	TOTPCredentialRepository repositories.TOTPCredentialRepository
+	RecoveryCodeRepository   repositories.RecoveryCodeRepository
+	MFAChallengeRepository   repositories.MFAChallengeRepository
+}
+
+// NewMFAService 是 MFAService 的构造函数。
+func NewMFAService(cfg *config.Config, userRepo repositories.UserRepository, totpRepo repositories.TOTPCredentialRepository, recoveryCodeRepo repositories.RecoveryCodeRepository, challengeRepo repositories.MFAChallengeRepository) MFAServiceInterface {
+	return &MFAService{
+		Config:                   cfg,
+		UserRepository:           userRepo,
+		TOTPCredentialRepository: totpRepo,
+		RecoveryCodeRepository:   recoveryCodeRepo,
+		MFAChallengeRepository:   challengeRepo,
+	}
+}
+
+// EnrollTOTP 生成新的TOTP密钥。
+// 尚未确认的旧密钥会被替换，因此用户可以重新扫码；已启用的用户需要先关闭两步验证。
+func (s *MFAService) EnrollTOTP(userID uint) (*TOTPEnrollment, error) {
+	user, err := s.UserRepository.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := s.TOTPCredentialRepository.FindByUserID(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if credential != nil && credential.IsConfirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if credential == nil {
+		credential = &models.TOTPCredential{UserID: userID}
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	credential.Secret = secret
+	credential.LastUsedStep = 0
+	if err := s.TOTPCredentialRepository.Save(credential); err != nil {
+		return nil, err
+	}
+
+	return &TOTPEnrollment{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(s.Config.Auth.MFAIssuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTP 确认TOTP绑定并生成恢复码。恢复码明文只在这里返回一次。
+func (s *MFAService) ConfirmTOTP(userID uint, code string) ([]string, error) {
+	credential, err := s.TOTPCredentialRepository.FindByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMFANotEnabled
+		}
+		return nil, err
+	}
+	if credential.IsConfirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := utils.ValidateTOTP(credential.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+	if err := s.TOTPCredentialRepository.Confirm(credential.ID, step); err != nil {
+		return nil, err
+	}
+
+	return s.generateRecoveryCodes(userID)
+}
+
+// DisableTOTP 关闭两步验证，同时删除密钥和所有恢复码。
+func (s *MFAService) DisableTOTP(userID uint, code string) error {
+	if err := s.verifyCode(userID, code); err != nil {
+		return err
+	}
+	if err := s.RecoveryCodeRepository.DeleteAllForUser(userID); err != nil {
+		return err
+	}
+	return s.TOTPCredentialRepository.DeleteByUserID(userID)
+}
+
+// RegenerateRecoveryCodes 作废旧的恢复码并生成一组新的。
+func (s *MFAService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	if err := s.verifyCode(userID, code); err != nil {
+		return nil, err
+	}
+	return s.generateRecoveryCodes(userID)
+}
+
+// IsEnabled 返回用户是否已确认绑定TOTP。
+func (s *MFAService) IsEnabled(userID uint) (bool, error) {
+	credential, err := s.TOTPCredentialRepository.FindByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return credential.IsConfirmed(), nil
+}
+
+// IssueChallenge 签发短期有效的挑战令牌。
+// 挑战令牌使用独立的令牌类型签名，不能被当作访问令牌使用；它的jti记录在数据库中，用于限制尝试次数和防止重放。
+func (s *MFAService) IssueChallenge(userID uint) (*MFARequiredError, error) {
+	now := time.Now()
+	// 顺便清理过期的挑战令牌，防止表无限增长
+	if _, err := s.MFAChallengeRepository.DeleteExpired(now); err != nil {
+		return nil, err
+	}
+
+	jti, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := now.Add(time.Duration(s.Config.Auth.MFAChallengeExpiration) * time.Second)
+	claims := &mfaChallengeClaims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token, err := utils.SignToken(claims, utils.TokenTypeMFAChallenge, s.Config)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.MFAChallengeRepository.Create(&models.MFAChallenge{JTI: jti, UserID: userID, ExpiresAt: expiresAt}); err != nil {
+		return nil, err
+	}
+	return &MFARequiredError{MFAToken: token, ExpiresIn: s.Config.Auth.MFAChallengeExpiration}, nil
+}
+
+// ChallengeUser 解析挑战令牌，返回令牌所属的用户ID。
+func (s *MFAService) ChallengeUser(mfaToken string) (uint, error) {
+	claims, err := s.parseChallenge(mfaToken)
+	if err != nil {
+		return 0, err
+	}
+	return claims.UserID, nil
+}
+
+// VerifyChallenge 校验挑战令牌和验证码（或恢复码）。
+// 每次校验都消耗一次尝试次数，次数用完或验证成功之后挑战令牌作废，用户需要重新输入密码。
+func (s *MFAService) VerifyChallenge(mfaToken, code string) (uint, error) {
+	claims, err := s.parseChallenge(mfaToken)
+	if err != nil {
+		return 0, err
+	}
+	maxAttempts := s.Config.Auth.MFAMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMFAMaxAttempts
+	}
+	ok, err := s.MFAChallengeRepository.Attempt(claims.ID, claims.UserID, maxAttempts, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, ErrInvalidMFAToken
+	}
+
+	if err := s.verifyCode(claims.UserID, code); err != nil {
+		return 0, err
+	}
+	used, err := s.MFAChallengeRepository.MarkUsed(claims.ID, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if !used {
+		return 0, ErrInvalidMFAToken
+	}
+	return claims.UserID, nil
+}
+
+// parseChallenge 校验挑战令牌的签名、类型和有效期。
+func (s *MFAService) parseChallenge(mfaToken string) (*mfaChallengeClaims, error) {
+	claims := &mfaChallengeClaims{}
+	if err := utils.ParseSignedToken(mfaToken, claims, utils.TokenTypeMFAChallenge, s.Config); err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	if claims.ID == "" {
+		return nil, ErrInvalidMFAToken
+	}
+	return claims, nil
+}
+
+// verifyCode 校验TOTP验证码或恢复码。
+// TOTP验证码在同一时间步内只能使用一次，恢复码用过即作废。
+func (s *MFAService) verifyCode(userID uint, code string) error {
+	credential, err := s.TOTPCredentialRepository.FindByUserID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMFANotEnabled
+		}
+		return err
+	}
+	if !credential.IsConfirmed() {
+		return ErrMFANotEnabled
+	}
+
+	if step, ok := utils.ValidateTOTP(credential.Secret, code, time.Now()); ok {
+		used, err := s.TOTPCredentialRepository.UseStep(credential.ID, step)
+		if err != nil {
+			return err
+		}
+		if !used {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := s.RecoveryCodeRepository.Use(userID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// generateRecoveryCodes 生成一组新的恢复码，只保存其哈希并返回明文。
+func (s *MFAService) generateRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		code := fmt.Sprintf("%s-%s", raw[:4], raw[4:])
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(normalizeRecoveryCode(code)))
+	}
+
+	if err := s.RecoveryCodeRepository.ReplaceForUser(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格和连字符，方便用户手动输入。
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
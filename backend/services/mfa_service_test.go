@@ -0,0 +1,271 @@
+package services_test
+
+import (
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/services"
+	"go-web/utils"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// MFAServiceTestSuite 是一个测试套件，用于组织与两步验证相关的集成测试。
+type MFAServiceTestSuite struct {
+	suite.Suite
+	db          *gorm.DB
+	cfg         *config.Config
+	service     services.MFAServiceInterface
+	authService services.AuthServiceInterface
+	userRepo    repositories.UserRepository
+	roleRepo    repositories.RoleRepository
+}
+
+// SetupSuite 在测试套件开始时运行，用于初始化数据库、配置和服务。
+func (suite *MFAServiceTestSuite) SetupSuite() {
+	db, err := gorm.Open(sqlite.Open("file:mfa?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		suite.T().Fatalf("无法连接到内存数据库: %v", err)
+	}
+	suite.db = db
+
+	err = suite.db.AutoMigrate(&models.User{}, &models.Role{}, &models.RefreshToken{}, &models.TOTPCredential{}, &models.RecoveryCode{}, &models.MFAChallenge{}, &models.LoginFailure{}, &models.PasswordHistory{}, &models.Session{})
+	if err != nil {
+		suite.T().Fatalf("数据库迁移失败: %v", err)
+	}
+
+	suite.cfg = &config.Config{
+		App: config.AppConfig{
+			DefaultRole: "user",
+		},
+		JWT: config.JWTConfig{
+			Secret:            "test-secret",
+			Expiration:        3600,
+			RefreshExpiration: 7200,
+		},
+		Auth: config.AuthConfig{
+			MFAIssuer:              "go-web",
+			MFAChallengeExpiration: 300,
+		},
+	}
+
+	suite.userRepo = repositories.NewGormUserRepository(suite.db)
+	suite.roleRepo = repositories.NewGormRoleRepository(suite.db)
+	tokenService := services.NewTokenService(suite.cfg, suite.userRepo, repositories.NewGormRefreshTokenRepository(suite.db), repositories.NewMemoryRevokedTokenRepository(), repositories.NewGormSessionRepository(suite.db), suite.db)
+	verificationService := services.NewEmailVerificationService(suite.cfg, suite.userRepo, &recordingMailer{})
+	suite.service = services.NewMFAService(suite.cfg, suite.userRepo, repositories.NewGormTOTPCredentialRepository(suite.db), repositories.NewGormRecoveryCodeRepository(suite.db), repositories.NewGormMFAChallengeRepository(suite.db))
+	suite.authService = services.NewAuthService(suite.cfg, suite.userRepo, suite.roleRepo, tokenService, verificationService, suite.service, services.NewLockoutService(suite.cfg, repositories.NewGormLoginFailureRepository(suite.db)), services.NewPasswordPolicyService(suite.cfg, repositories.NewGormPasswordHistoryRepository(suite.db)), suite.db)
+}
+
+// SetupTest 在每个测试方法运行之前被调用，清理数据并创建默认角色。
+func (suite *MFAServiceTestSuite) SetupTest() {
+	suite.db.Exec("DELETE FROM users")
+	suite.db.Exec("DELETE FROM roles")
+	suite.db.Exec("DELETE FROM refresh_tokens")
+	suite.db.Exec("DELETE FROM totp_credentials")
+	suite.db.Exec("DELETE FROM recovery_codes")
+	suite.db.Exec("DELETE FROM mfa_challenges")
+	suite.db.Exec("DELETE FROM login_failures")
+	suite.Require().NoError(suite.roleRepo.Create(&models.Role{Name: "user", Description: "普通用户"}))
+}
+
+// TestMFAServiceTestSuite 运行测试套件。
+func TestMFAServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(MFAServiceTestSuite))
+}
+
+// totpCode 计算密钥在当前时间之后第 offset 个时间步的验证码。
+func (suite *MFAServiceTestSuite) totpCode(secret string, offset int64) string {
+	code, err := utils.TOTPCode(secret, utils.TOTPStep(time.Now())+offset)
+	suite.Require().NoError(err)
+	return code
+}
+
+// enroll 注册一个用户并为其启用两步验证，返回用户、TOTP密钥和恢复码。
+func (suite *MFAServiceTestSuite) enroll() (*models.User, string, []string) {
+	user, _, err := suite.authService.Register("mfauser", "mfa@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+
+	enrollment, err := suite.service.EnrollTOTP(user.ID)
+	suite.Require().NoError(err)
+	recoveryCodes, err := suite.service.ConfirmTOTP(user.ID, suite.totpCode(enrollment.Secret, 0))
+	suite.Require().NoError(err)
+
+	return user, enrollment.Secret, recoveryCodes
+}
+
+// TestEnrollTOTP 测试绑定TOTP时返回的密钥和 otpauth URI。
+func (suite *MFAServiceTestSuite) TestEnrollTOTP() {
+	user, _, err := suite.authService.Register("mfauser", "mfa@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+
+	enrollment, err := suite.service.EnrollTOTP(user.ID)
+	suite.Require().NoError(err)
+	uri, err := url.Parse(enrollment.OTPAuthURI)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "otpauth", uri.Scheme)
+	assert.Equal(suite.T(), enrollment.Secret, uri.Query().Get("secret"))
+
+	// 确认之前两步验证不生效
+	enabled, err := suite.service.IsEnabled(user.ID)
+	suite.Require().NoError(err)
+	assert.False(suite.T(), enabled)
+
+	// 错误的验证码无法确认
+	_, err = suite.service.ConfirmTOTP(user.ID, "000000")
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidMFACode)
+
+	recoveryCodes, err := suite.service.ConfirmTOTP(user.ID, suite.totpCode(enrollment.Secret, 0))
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), recoveryCodes, 10)
+
+	// 已启用时不能重复绑定
+	_, err = suite.service.EnrollTOTP(user.ID)
+	assert.ErrorIs(suite.T(), err, services.ErrMFAAlreadyEnabled)
+}
+
+// TestLogin_TwoStep 测试启用两步验证后登录需要先换取挑战令牌的场景。
+func (suite *MFAServiceTestSuite) TestLogin_TwoStep() {
+	_, secret, _ := suite.enroll()
+
+	// 第一步：密码正确时只返回挑战令牌
+	user, tokens, err := suite.authService.Login("mfauser", "password123", testClient)
+	assert.Nil(suite.T(), user)
+	assert.Nil(suite.T(), tokens)
+	var challenge *services.MFARequiredError
+	suite.Require().ErrorAs(err, &challenge)
+	assert.Equal(suite.T(), 300, challenge.ExpiresIn)
+
+	// 挑战令牌不能当作访问令牌使用
+	_, err = utils.ValidateToken(challenge.MFAToken, suite.cfg)
+	assert.Error(suite.T(), err)
+
+	// 确认绑定时已经使用过的验证码不能再次使用
+	_, _, err = suite.authService.VerifyMFA(challenge.MFAToken, suite.totpCode(secret, 0), testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidMFACode)
+
+	// 第二步：新的验证码换取真正的令牌
+	user, tokens, err = suite.authService.VerifyMFA(challenge.MFAToken, suite.totpCode(secret, 1), testClient)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "mfauser", user.Username)
+	claims, err := utils.ValidateToken(tokens.AccessToken, suite.cfg)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "user", claims.Role)
+}
+
+// TestVerifyMFA_InvalidToken 测试伪造或其他用途的挑战令牌被拒绝的场景。
+func (suite *MFAServiceTestSuite) TestVerifyMFA_InvalidToken() {
+	user, secret, _ := suite.enroll()
+
+	_, _, err := suite.authService.VerifyMFA("not-a-token", suite.totpCode(secret, 1), testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidMFAToken)
+
+	// 访问令牌不能当作挑战令牌使用
+	accessToken, err := utils.GenerateToken(user.ID, "user", suite.cfg)
+	suite.Require().NoError(err)
+	_, _, err = suite.authService.VerifyMFA(accessToken, suite.totpCode(secret, 1), testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidMFAToken)
+}
+
+// TestRecoveryCode_SingleUse 测试恢复码可以代替验证码登录，但只能使用一次。
+func (suite *MFAServiceTestSuite) TestRecoveryCode_SingleUse() {
+	_, _, recoveryCodes := suite.enroll()
+
+	_, _, err := suite.authService.Login("mfauser", "password123", testClient)
+	var challenge *services.MFARequiredError
+	suite.Require().ErrorAs(err, &challenge)
+
+	_, tokens, err := suite.authService.VerifyMFA(challenge.MFAToken, recoveryCodes[0], testClient)
+	suite.Require().NoError(err)
+	assert.NotEmpty(suite.T(), tokens.AccessToken)
+
+	// 挑战令牌已经使用过，不能再次使用
+	_, _, err = suite.authService.VerifyMFA(challenge.MFAToken, recoveryCodes[1], testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidMFAToken)
+
+	// 用过的恢复码在新的挑战中同样无效
+	_, _, err = suite.authService.Login("mfauser", "password123", testClient)
+	suite.Require().ErrorAs(err, &challenge)
+	_, _, err = suite.authService.VerifyMFA(challenge.MFAToken, recoveryCodes[0], testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidMFACode)
+}
+
+// TestVerifyMFA_MaxAttempts 测试验证码错误次数达到上限后挑战令牌作废，需要重新输入密码。
+func (suite *MFAServiceTestSuite) TestVerifyMFA_MaxAttempts() {
+	_, secret, _ := suite.enroll()
+
+	_, _, err := suite.authService.Login("mfauser", "password123", testClient)
+	var challenge *services.MFARequiredError
+	suite.Require().ErrorAs(err, &challenge)
+
+	for i := 0; i < services.DefaultMFAMaxAttempts; i++ {
+		_, _, err = suite.authService.VerifyMFA(challenge.MFAToken, "000000", testClient)
+		assert.ErrorIs(suite.T(), err, services.ErrInvalidMFACode)
+	}
+	// 正确的验证码也不能再使用这个挑战令牌
+	_, _, err = suite.authService.VerifyMFA(challenge.MFAToken, suite.totpCode(secret, 1), testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidMFAToken)
+
+	_, _, err = suite.authService.Login("mfauser", "password123", testClient)
+	suite.Require().ErrorAs(err, &challenge)
+	_, tokens, err := suite.authService.VerifyMFA(challenge.MFAToken, suite.totpCode(secret, 1), testClient)
+	suite.Require().NoError(err)
+	assert.NotEmpty(suite.T(), tokens.AccessToken)
+}
+
+// TestVerifyMFA_Lockout 测试错误的验证码计入登录锁定，锁定期间不再校验验证码。
+func (suite *MFAServiceTestSuite) TestVerifyMFA_Lockout() {
+	suite.cfg.Auth.LockoutThreshold = 2
+	suite.cfg.Auth.LockoutDuration = 900
+	defer func() { suite.cfg.Auth.LockoutThreshold = 0 }()
+	_, secret, _ := suite.enroll()
+
+	_, _, err := suite.authService.Login("mfauser", "password123", testClient)
+	var challenge *services.MFARequiredError
+	suite.Require().ErrorAs(err, &challenge)
+
+	for i := 0; i < 2; i++ {
+		_, _, err = suite.authService.VerifyMFA(challenge.MFAToken, "000000", testClient)
+		assert.ErrorIs(suite.T(), err, services.ErrInvalidMFACode)
+	}
+
+	// 达到失败上限后账户被锁定，正确的验证码也被拒绝
+	_, _, err = suite.authService.VerifyMFA(challenge.MFAToken, suite.totpCode(secret, 1), testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrAccountLocked)
+	_, _, err = suite.authService.Login("mfauser", "password123", testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrAccountLocked)
+}
+
+// TestRegenerateRecoveryCodes 测试重新生成恢复码后旧的恢复码失效。
+func (suite *MFAServiceTestSuite) TestRegenerateRecoveryCodes() {
+	user, _, oldCodes := suite.enroll()
+
+	newCodes, err := suite.service.RegenerateRecoveryCodes(user.ID, oldCodes[0])
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), newCodes, 10)
+
+	assert.ErrorIs(suite.T(), suite.service.DisableTOTP(user.ID, oldCodes[1]), services.ErrInvalidMFACode)
+	assert.NoError(suite.T(), suite.service.DisableTOTP(user.ID, newCodes[0]))
+}
+
+// TestDisableTOTP 测试关闭两步验证后登录恢复为一步。
+func (suite *MFAServiceTestSuite) TestDisableTOTP() {
+	user, secret, _ := suite.enroll()
+
+	assert.ErrorIs(suite.T(), suite.service.DisableTOTP(user.ID, "000000"), services.ErrInvalidMFACode)
+	suite.Require().NoError(suite.service.DisableTOTP(user.ID, suite.totpCode(secret, 1)))
+
+	enabled, err := suite.service.IsEnabled(user.ID)
+	suite.Require().NoError(err)
+	assert.False(suite.T(), enabled)
+
+	_, tokens, err := suite.authService.Login("mfauser", "password123", testClient)
+	suite.Require().NoError(err)
+	assert.NotEmpty(suite.T(), tokens.AccessToken)
+}
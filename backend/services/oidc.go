@@ -0,0 +1,361 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责第三方登录（OpenID Connect）：发起授权、处理回调、按需创建用户以及关联外部身份。
+
+import (
+	"context"
+	"errors"
+	"go-web/config"
+	"go-web/models"
+	"go-web/oidc"
+	"go-web/repositories"
+	"go-web/utils"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownOIDCProvider 在请求的身份提供方没有配置时返回。
+	ErrUnknownOIDCProvider = errors.New("未知的身份提供方")
+	// ErrInvalidOIDCState 在回调的 state 不存在、已过期、已被使用或属于其他身份提供方时返回。
+	ErrInvalidOIDCState = errors.New("第三方登录请求无效或已过期，请重新登录")
+	// ErrOIDCAuthenticationFailed 在身份提供方拒绝授权、换取令牌失败或ID令牌无效时返回，具体原因只记录日志。
+	ErrOIDCAuthenticationFailed = errors.New("第三方登录失败")
+	// ErrOIDCEmailInUse 在首次使用第三方登录、但邮箱已被本地账户使用时返回，需要先登录原账户再关联。
+	ErrOIDCEmailInUse = errors.New("该邮箱已注册，请使用原账户登录后再关联第三方账号")
+	// ErrIdentityAlreadyLinked 在外部身份已经关联到另一个本地用户时返回。
+	ErrIdentityAlreadyLinked = errors.New("该第三方账号已关联到其他用户")
+)
+
+// oidcSecretBytes 是 state、nonce 和 PKCE code_verifier 的随机字节数。
+const oidcSecretBytes = 32
+
+// usernamePattern 匹配用户名中不允许出现的字符，与注册接口的用户名规则保持一致。
+var usernamePattern = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// 自动创建的用户名长度限制，与 dtos.RegisterRequest 一致。
+const (
+	usernameMinLength = 3
+	usernameMaxLength = 20
+)
+
+// OIDCServiceInterface 定义了第三方登录服务应实现的功能契约。
+type OIDCServiceInterface interface {
+	// BeginLogin 发起第三方登录，返回将用户重定向到身份提供方的授权URL。
+	BeginLogin(ctx context.Context, provider string) (string, error)
+	// BeginLink 为已登录的用户发起关联外部身份的流程，返回授权URL。
+	BeginLink(ctx context.Context, provider string, userID uint) (string, error)
+	// Callback 处理身份提供方的回调，完成登录或关联，并签发令牌对。
+	// 启用了两步验证的用户登录时会得到 *MFARequiredError。
+	Callback(ctx context.Context, provider, code, state string, client ClientInfo) (*models.User, *TokenPair, error)
+	// ListIdentities 返回用户关联的所有外部身份。
+	ListIdentities(userID uint) ([]models.UserIdentity, error)
+}
+
+// OIDCService 提供了第三方登录相关的业务逻辑实现。
+type OIDCService struct {
+	Config                   *config.Config
+	Providers                map[string]*oidc.Provider
+	UserRepository           repositories.UserRepository
+	RoleRepository           repositories.RoleRepository
+	UserIdentityRepository   repositories.UserIdentityRepository
+	OIDCLoginStateRepository repositories.OIDCLoginStateRepository
+	TokenService             TokenServiceInterface
+	MFAService               MFAServiceInterface
+	DB                       *gorm.DB // 用于自动创建用户时的事务
+}
+
+// NewOIDCService 是 OIDCService 的构造函数。
+func NewOIDCService(cfg *config.Config, providers map[string]*oidc.Provider, userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, identityRepo repositories.UserIdentityRepository, stateRepo repositories.OIDCLoginStateRepository, tokenService TokenServiceInterface, mfaService MFAServiceInterface, db *gorm.DB) OIDCServiceInterface {
+	return &OIDCService{
+		Config:                   cfg,
+		Providers:                providers,
+		UserRepository:           userRepo,
+		RoleRepository:           roleRepo,
+		UserIdentityRepository:   identityRepo,
+		OIDCLoginStateRepository: stateRepo,
+		TokenService:             tokenService,
+		MFAService:               mfaService,
+		DB:                       db,
+	}
+}
+
+// BeginLogin 发起第三方登录。
+func (s *OIDCService) BeginLogin(ctx context.Context, provider string) (string, error) {
+	return s.begin(ctx, provider, nil)
+}
+
+// BeginLink 发起关联外部身份的流程，回调时外部身份会关联到 userID 对应的用户。
+func (s *OIDCService) BeginLink(ctx context.Context, provider string, userID uint) (string, error) {
+	return s.begin(ctx, provider, &userID)
+}
+
+// begin 生成 state、nonce 和 PKCE code_verifier，保存后返回授权URL。
+// 数据库中只保存 state 的哈希，code_verifier 不会出现在任何URL中。
+func (s *OIDCService) begin(ctx context.Context, providerName string, linkUserID *uint) (string, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return "", ErrUnknownOIDCProvider
+	}
+
+	// 顺便清理过期的登录状态，失败不影响本次登录
+	if _, err := s.OIDCLoginStateRepository.DeleteExpired(time.Now()); err != nil {
+		utils.Logger.Warn("failed to purge expired oidc login states", zap.Error(err))
+	}
+
+	var secrets [3]string
+	for i := range secrets {
+		secret, err := utils.GenerateRandomToken(oidcSecretBytes)
+		if err != nil {
+			return "", err
+		}
+		secrets[i] = secret
+	}
+	state, nonce, codeVerifier := secrets[0], secrets[1], secrets[2]
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, oidc.CodeChallenge(codeVerifier))
+	if err != nil {
+		return "", err
+	}
+
+	record := &models.OIDCLoginState{
+		StateHash:    utils.HashToken(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(time.Duration(s.Config.OIDC.StateExpiration) * time.Second),
+	}
+	if err := s.OIDCLoginStateRepository.Create(record); err != nil {
+		return "", err
+	}
+	return authURL, nil
+}
+
+// Callback 处理身份提供方的回调。
+func (s *OIDCService) Callback(ctx context.Context, providerName, code, state string, client ClientInfo) (*models.User, *TokenPair, error) {
+	provider, ok := s.Providers[providerName]
+	if !ok {
+		return nil, nil, ErrUnknownOIDCProvider
+	}
+
+	// 1. 取出登录状态，每个 state 只能使用一次
+	record, err := s.OIDCLoginStateRepository.Consume(utils.HashToken(state))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidOIDCState
+		}
+		return nil, nil, err
+	}
+	if record.Provider != providerName || time.Now().After(record.ExpiresAt) {
+		return nil, nil, ErrInvalidOIDCState
+	}
+
+	// 2. 使用授权码和 code_verifier 换取ID令牌，并校验签名、受众和nonce
+	rawIDToken, err := provider.Exchange(ctx, code, record.CodeVerifier)
+	if err != nil {
+		utils.Logger.Warn("oidc code exchange failed", zap.String("provider", providerName), zap.Error(err))
+		return nil, nil, ErrOIDCAuthenticationFailed
+	}
+	claims, err := provider.VerifyIDToken(ctx, rawIDToken, record.Nonce)
+	if err != nil {
+		utils.Logger.Warn("oidc id token rejected", zap.String("provider", providerName), zap.Error(err))
+		return nil, nil, ErrOIDCAuthenticationFailed
+	}
+
+	// 3. 关联或登录
+	if record.LinkUserID != nil {
+		return s.link(providerName, claims, *record.LinkUserID, client)
+	}
+	return s.login(providerName, claims, client)
+}
+
+// ListIdentities 返回用户关联的所有外部身份。
+func (s *OIDCService) ListIdentities(userID uint) ([]models.UserIdentity, error) {
+	return s.UserIdentityRepository.FindByUserID(userID)
+}
+
+// link 将外部身份关联到已登录的用户，并为其签发新的令牌对。重复关联同一个身份不会报错。
+func (s *OIDCService) link(providerName string, claims *oidc.IDTokenClaims, userID uint, client ClientInfo) (*models.User, *TokenPair, error) {
+	identity, err := s.UserIdentityRepository.FindByProviderSubject(providerName, claims.Subject)
+	switch {
+	case err == nil && identity.UserID != userID:
+		return nil, nil, ErrIdentityAlreadyLinked
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		identity = &models.UserIdentity{UserID: userID, Provider: providerName, Subject: claims.Subject, Email: claims.Email}
+		if err := s.UserIdentityRepository.Create(identity); err != nil {
+			return nil, nil, err
+		}
+	case err != nil:
+		return nil, nil, err
+	}
+
+	// FindByID 会预加载角色信息，签发令牌使用的角色从主库读取
+	user, err := s.UserRepository.Primary().FindByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tokens, err := s.TokenService.IssueTokens(user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// login 使用外部身份登录。首次登录时自动创建本地用户，之后的检查与密码登录相同。
+func (s *OIDCService) login(providerName string, claims *oidc.IDTokenClaims, client ClientInfo) (*models.User, *TokenPair, error) {
+	var user *models.User
+	identity, err := s.UserIdentityRepository.FindByProviderSubject(providerName, claims.Subject)
+	switch {
+	case err == nil:
+		if user, err = s.UserRepository.Primary().FindByID(identity.UserID); err != nil {
+			return nil, nil, err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if user, err = s.provision(providerName, claims); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, err
+	}
+
+	// block 模式下，未验证邮箱的用户不能登录
+	if s.Config.Auth.EmailVerification == config.EmailVerificationBlock && !user.IsEmailVerified() {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	// 启用了两步验证的用户同样需要完成第二步
+	mfaEnabled, err := s.MFAService.IsEnabled(user.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mfaEnabled {
+		challenge, err := s.MFAService.IssueChallenge(user.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, challenge
+	}
+
+	tokens, err := s.TokenService.IssueTokens(user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// provision 为首次登录的外部身份创建本地用户（即时开通），角色为 app.default_role。
+// 邮箱已被本地账户使用时不会自动合并，否则控制了身份提供方账户的人就能接管本地账户。
+// 新用户的密码是随机生成的，之后可以通过"忘记密码"设置自己的密码。
+func (s *OIDCService) provision(providerName string, claims *oidc.IDTokenClaims) (*models.User, error) {
+	if claims.Email == "" {
+		utils.Logger.Warn("oidc id token has no email claim", zap.String("provider", providerName), zap.String("subject", claims.Subject))
+		return nil, ErrOIDCAuthenticationFailed
+	}
+	if _, err := s.UserRepository.FindByEmail(claims.Email); err == nil {
+		return nil, ErrOIDCEmailInUse
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	username, err := s.availableUsername(claims)
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := s.randomPasswordHash()
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{Username: username, Email: claims.Email, Password: hashedPassword}
+	if claims.EmailVerified {
+		now := time.Now()
+		user.EmailVerifiedAt = &now
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		role, err := repositories.NewGormRoleRepository(tx).FindByName(s.Config.App.DefaultRole)
+		if err != nil {
+			return err
+		}
+		user.RoleID = role.ID
+		user.Role = *role
+		if err := repositories.NewGormUserRepository(tx).Create(user); err != nil {
+			return err
+		}
+		return repositories.NewGormUserIdentityRepository(tx).Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: providerName,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info("user provisioned from oidc login",
+		zap.String("event", "user.provisioned"),
+		zap.Uint("user_id", user.ID),
+		zap.String("provider", providerName),
+	)
+	return user, nil
+}
+
+// availableUsername 根据 preferred_username 或邮箱生成一个未被使用的用户名，冲突时追加随机后缀。
+func (s *OIDCService) availableUsername(claims *oidc.IDTokenClaims) (string, error) {
+	base := ""
+	local, _, _ := strings.Cut(claims.Email, "@")
+	for _, candidate := range []string{claims.PreferredUsername, local} {
+		candidate = usernamePattern.ReplaceAllString(candidate, "")
+		if len(candidate) > usernameMaxLength {
+			candidate = candidate[:usernameMaxLength]
+		}
+		if len(candidate) >= usernameMinLength {
+			base = candidate
+			break
+		}
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	username := base
+	for attempt := 0; attempt < 5; attempt++ {
+		_, err := s.UserRepository.FindByUsername(username)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return username, nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		suffix, err := utils.GenerateRandomToken(3)
+		if err != nil {
+			return "", err
+		}
+		if len(base) > usernameMaxLength-len(suffix)-1 {
+			base = base[:usernameMaxLength-len(suffix)-1]
+		}
+		username = base + "_" + suffix
+	}
+	return "", errors.New("unable to find an available username")
+}
+
+// randomPasswordHash 返回一个随机密码的哈希，没有人知道这个密码。
+func (s *OIDCService) randomPasswordHash() (string, error) {
+	password, err := utils.GenerateRandomToken(oidcSecretBytes)
+	if err != nil {
+		return "", err
+	}
+	hasher, err := utils.PasswordHasherFor(s.Config)
+	if err != nil {
+		return "", err
+	}
+	return hasher.Hash(password)
+}
@@ -0,0 +1,227 @@
+package services_test
+
+import (
+	"context"
+	"go-web/config"
+	"go-web/models"
+	"go-web/oidc"
+	"go-web/oidc/oidctest"
+	"go-web/repositories"
+	"go-web/services"
+	"go-web/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// OIDCServiceTestSuite 是一个测试套件，用于组织与第三方登录相关的集成测试。
+// 身份提供方由 oidctest 在本地模拟。
+type OIDCServiceTestSuite struct {
+	suite.Suite
+	db           *gorm.DB
+	cfg          *config.Config
+	issuer       *oidctest.Issuer
+	service      services.OIDCServiceInterface
+	authService  services.AuthServiceInterface
+	userRepo     repositories.UserRepository
+	roleRepo     repositories.RoleRepository
+	identityRepo repositories.UserIdentityRepository
+}
+
+// SetupSuite 在测试套件开始时运行，用于初始化数据库、身份提供方和服务。
+func (suite *OIDCServiceTestSuite) SetupSuite() {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // 第三方登录失败的原因只记录在日志中
+
+	db, err := gorm.Open(sqlite.Open("file:oidc?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		suite.T().Fatalf("无法连接到内存数据库: %v", err)
+	}
+	suite.db = db
+
+	err = suite.db.AutoMigrate(&models.User{}, &models.Role{}, &models.RefreshToken{}, &models.TOTPCredential{}, &models.RecoveryCode{}, &models.MFAChallenge{}, &models.LoginFailure{}, &models.PasswordHistory{}, &models.UserIdentity{}, &models.OIDCLoginState{}, &models.Session{})
+	if err != nil {
+		suite.T().Fatalf("数据库迁移失败: %v", err)
+	}
+
+	suite.issuer = oidctest.NewIssuer(suite.T(), "go-web", "client-secret")
+	suite.cfg = &config.Config{
+		App: config.AppConfig{
+			DefaultRole: "user",
+		},
+		JWT: config.JWTConfig{
+			Secret:            "test-secret",
+			Expiration:        3600,
+			RefreshExpiration: 7200,
+		},
+		Auth: config.AuthConfig{
+			MFAIssuer:              "go-web",
+			MFAChallengeExpiration: 300,
+		},
+		OIDC: config.OIDCConfig{
+			Providers: []config.OIDCProviderConfig{{
+				Name:         "stub",
+				Issuer:       suite.issuer.URL(),
+				ClientID:     "go-web",
+				ClientSecret: "client-secret",
+				RedirectURL:  "http://localhost:3000/oidc/stub/callback",
+			}},
+			StateExpiration: 600,
+		},
+	}
+	providers, err := oidc.NewProviders(suite.cfg.OIDC, nil)
+	suite.Require().NoError(err)
+
+	suite.userRepo = repositories.NewGormUserRepository(suite.db)
+	suite.roleRepo = repositories.NewGormRoleRepository(suite.db)
+	suite.identityRepo = repositories.NewGormUserIdentityRepository(suite.db)
+	tokenService := services.NewTokenService(suite.cfg, suite.userRepo, repositories.NewGormRefreshTokenRepository(suite.db), repositories.NewMemoryRevokedTokenRepository(), repositories.NewGormSessionRepository(suite.db), suite.db)
+	mfaService := services.NewMFAService(suite.cfg, suite.userRepo, repositories.NewGormTOTPCredentialRepository(suite.db), repositories.NewGormRecoveryCodeRepository(suite.db), repositories.NewGormMFAChallengeRepository(suite.db))
+	verificationService := services.NewEmailVerificationService(suite.cfg, suite.userRepo, &recordingMailer{})
+	suite.service = services.NewOIDCService(suite.cfg, providers, suite.userRepo, suite.roleRepo, suite.identityRepo, repositories.NewGormOIDCLoginStateRepository(suite.db), tokenService, mfaService, suite.db)
+	suite.authService = services.NewAuthService(suite.cfg, suite.userRepo, suite.roleRepo, tokenService, verificationService, mfaService, services.NewLockoutService(suite.cfg, repositories.NewGormLoginFailureRepository(suite.db)), services.NewPasswordPolicyService(suite.cfg, repositories.NewGormPasswordHistoryRepository(suite.db)), suite.db)
+}
+
+// SetupTest 在每个测试方法运行之前被调用，清理数据并创建默认角色。
+func (suite *OIDCServiceTestSuite) SetupTest() {
+	suite.db.Exec("DELETE FROM users")
+	suite.db.Exec("DELETE FROM roles")
+	suite.db.Exec("DELETE FROM user_identities")
+	suite.db.Exec("DELETE FROM oidc_login_states")
+	suite.db.Exec("DELETE FROM totp_credentials")
+	suite.Require().NoError(suite.roleRepo.Create(&models.Role{Name: "user", Description: "普通用户"}))
+}
+
+// TestOIDCServiceTestSuite 运行测试套件。
+func TestOIDCServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(OIDCServiceTestSuite))
+}
+
+// login 完成一次第三方登录：发起授权、在身份提供方登录、处理回调。
+func (suite *OIDCServiceTestSuite) login(identity oidctest.Identity) (*models.User, *services.TokenPair, error) {
+	authURL, err := suite.service.BeginLogin(context.Background(), "stub")
+	suite.Require().NoError(err)
+	code, state := suite.issuer.Authorize(suite.T(), authURL, identity)
+	return suite.service.Callback(context.Background(), "stub", code, state, testClient)
+}
+
+// TestCallback_ProvisionsUser 测试首次登录时创建用户，再次登录时复用同一个用户。
+func (suite *OIDCServiceTestSuite) TestCallback_ProvisionsUser() {
+	identity := oidctest.Identity{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true, PreferredUsername: "alice smith"}
+
+	user, tokens, err := suite.login(identity)
+	suite.Require().NoError(err)
+	assert.NotEmpty(suite.T(), tokens.AccessToken)
+	assert.Equal(suite.T(), "alicesmith", user.Username)
+	assert.Equal(suite.T(), "alice@example.com", user.Email)
+	assert.Equal(suite.T(), "user", user.Role.Name)
+	assert.True(suite.T(), user.IsEmailVerified())
+
+	again, _, err := suite.login(identity)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), user.ID, again.ID)
+
+	identities, err := suite.service.ListIdentities(user.ID)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), identities, 1)
+	assert.Equal(suite.T(), "sub-1", identities[0].Subject)
+}
+
+// TestCallback_UsernameConflict 测试用户名已被使用时追加后缀。
+func (suite *OIDCServiceTestSuite) TestCallback_UsernameConflict() {
+	_, _, err := suite.authService.Register("bob", "bob@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+
+	user, _, err := suite.login(oidctest.Identity{Subject: "sub-2", Email: "bob@other.example.com"})
+	suite.Require().NoError(err)
+	assert.NotEqual(suite.T(), "bob", user.Username)
+	assert.Regexp(suite.T(), `^bob_`, user.Username)
+	assert.False(suite.T(), user.IsEmailVerified())
+}
+
+// TestCallback_EmailInUse 测试不会把外部身份自动合并到使用相同邮箱的本地账户。
+func (suite *OIDCServiceTestSuite) TestCallback_EmailInUse() {
+	_, _, err := suite.authService.Register("carol", "carol@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+
+	_, _, err = suite.login(oidctest.Identity{Subject: "sub-3", Email: "carol@example.com", EmailVerified: true})
+	assert.ErrorIs(suite.T(), err, services.ErrOIDCEmailInUse)
+}
+
+// TestCallback_InvalidState 测试 state 只能使用一次，且不能用于其他身份提供方。
+func (suite *OIDCServiceTestSuite) TestCallback_InvalidState() {
+	ctx := context.Background()
+	authURL, err := suite.service.BeginLogin(ctx, "stub")
+	suite.Require().NoError(err)
+	identity := oidctest.Identity{Subject: "sub-4", Email: "dave@example.com"}
+
+	code, _ := suite.issuer.Authorize(suite.T(), authURL, identity)
+	_, _, err = suite.service.Callback(ctx, "stub", code, "forged-state", testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidOIDCState)
+
+	code, state := suite.issuer.Authorize(suite.T(), authURL, identity)
+	_, _, err = suite.service.Callback(ctx, "stub", code, state, testClient)
+	suite.Require().NoError(err)
+
+	code, _ = suite.issuer.Authorize(suite.T(), authURL, identity)
+	_, _, err = suite.service.Callback(ctx, "stub", code, state, testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidOIDCState)
+
+	_, _, err = suite.service.Callback(ctx, "unknown", code, state, testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrUnknownOIDCProvider)
+}
+
+// TestCallback_InvalidCode 测试授权码无效时登录失败。
+func (suite *OIDCServiceTestSuite) TestCallback_InvalidCode() {
+	authURL, err := suite.service.BeginLogin(context.Background(), "stub")
+	suite.Require().NoError(err)
+	_, state := suite.issuer.Authorize(suite.T(), authURL, oidctest.Identity{Subject: "sub-5", Email: "erin@example.com"})
+
+	_, _, err = suite.service.Callback(context.Background(), "stub", "invalid-code", state, testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrOIDCAuthenticationFailed)
+}
+
+// TestBeginLink_LinksIdentity 测试已登录用户关联外部身份后可以使用它登录。
+func (suite *OIDCServiceTestSuite) TestBeginLink_LinksIdentity() {
+	ctx := context.Background()
+	local, _, err := suite.authService.Register("frank", "frank@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	identity := oidctest.Identity{Subject: "sub-6", Email: "frank@example.com", EmailVerified: true}
+
+	authURL, err := suite.service.BeginLink(ctx, "stub", local.ID)
+	suite.Require().NoError(err)
+	code, state := suite.issuer.Authorize(suite.T(), authURL, identity)
+	linked, _, err := suite.service.Callback(ctx, "stub", code, state, testClient)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), local.ID, linked.ID)
+
+	user, _, err := suite.login(identity)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), local.ID, user.ID)
+
+	// 同一个外部身份不能再关联到其他用户
+	other, _, err := suite.authService.Register("grace", "grace@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	authURL, err = suite.service.BeginLink(ctx, "stub", other.ID)
+	suite.Require().NoError(err)
+	code, state = suite.issuer.Authorize(suite.T(), authURL, identity)
+	_, _, err = suite.service.Callback(ctx, "stub", code, state, testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrIdentityAlreadyLinked)
+}
+
+// TestCallback_MFARequired 测试启用了两步验证的用户通过第三方登录时同样需要验证码。
+func (suite *OIDCServiceTestSuite) TestCallback_MFARequired() {
+	identity := oidctest.Identity{Subject: "sub-7", Email: "heidi@example.com", EmailVerified: true}
+	user, _, err := suite.login(identity)
+	suite.Require().NoError(err)
+	now := time.Now()
+	suite.Require().NoError(suite.db.Create(&models.TOTPCredential{UserID: user.ID, Secret: "JBSWY3DPEHPK3PXP", ConfirmedAt: &now}).Error)
+
+	_, tokens, err := suite.login(identity)
+	var mfaRequired *services.MFARequiredError
+	assert.ErrorAs(suite.T(), err, &mfaRequired)
+	assert.Nil(suite.T(), tokens)
+}
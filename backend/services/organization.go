@@ -0,0 +1,254 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责组织（租户）、组织成员以及切换当前组织。
+// 成员相关的方法作用于 ctx 中的当前组织，数据隔离由 tenant 插件在仓库层完成。
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/tenant"
+	"go-web/utils"
+	"regexp"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNoActiveTenant 在需要当前组织的操作中，令牌没有选择组织时返回。
+	ErrNoActiveTenant = errors.New("当前令牌没有选择组织，请先切换到一个组织")
+	// ErrNotMember 在切换到用户没有加入的组织时返回。
+	ErrNotMember = errors.New("用户不是该组织的成员")
+	// ErrOrganizationExists 在创建组织时标识已被占用时返回。
+	ErrOrganizationExists = errors.New("组织标识已存在")
+	// ErrAlreadyMember 在添加已经是组织成员的用户时返回。
+	ErrAlreadyMember = errors.New("用户已经是该组织的成员")
+	// ErrInvalidOrganizationSlug 在组织标识不符合格式时返回。
+	ErrInvalidOrganizationSlug = errors.New("组织标识只能包含小写字母、数字和连字符")
+)
+
+// OrganizationRoles 是可以分配给组织成员的角色。默认策略在 org:* 域中只为这些角色授权；
+// 其他角色（例如管理员创建的自定义角色）可能在所有域中拥有权限，不能由组织管理员分配。
+var OrganizationRoles = []string{AdminRole, "user"}
+
+// organizationSlugPattern 是组织标识的格式，如 "acme" 或 "acme-labs"。
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// OrganizationServiceInterface 定义了组织服务应实现的功能契约。
+type OrganizationServiceInterface interface {
+	// Create 创建一个组织，并以管理员角色将创建者加入组织。
+	Create(name, slug string, creatorID uint) (*models.Organization, error)
+	// List 返回所有组织。
+	List() ([]models.Organization, error)
+	// ListForUser 返回用户加入的所有组织及其在组织中的角色。
+	ListForUser(userID uint) ([]models.Membership, error)
+	// ListMembers 返回当前组织的所有成员。
+	ListMembers(ctx context.Context) ([]models.Membership, error)
+	// AddMember 以指定角色将用户加入当前组织。
+	AddMember(ctx context.Context, userID uint, roleName string) (*models.Membership, error)
+	// UpdateMember 修改用户在当前组织中的角色。
+	UpdateMember(ctx context.Context, userID uint, roleName string) (*models.Membership, error)
+	// RemoveMember 将用户移出当前组织，该组织的刷新令牌在下次刷新时失效。
+	RemoveMember(ctx context.Context, userID uint) error
+	// SwitchTenant 为用户签发以 organizationID 为当前组织的令牌对，organizationID 为0时切换回全局。
+	SwitchTenant(userID, organizationID uint, client ClientInfo) (*models.User, *TokenPair, error)
+}
+
+// OrganizationService 提供了组织相关的业务逻辑实现。
+type OrganizationService struct {
+	OrganizationRepository repositories.OrganizationRepository
+	MembershipRepository   repositories.MembershipRepository
+	UserRepository         repositories.UserRepository
+	RoleRepository         repositories.RoleRepository
+	TokenService           TokenServiceInterface
+	DB                     *gorm.DB // 用于创建组织时的事务
+}
+
+// NewOrganizationService 是 OrganizationService 的构造函数。
+func NewOrganizationService(organizationRepo repositories.OrganizationRepository, membershipRepo repositories.MembershipRepository, userRepo repositories.UserRepository, roleRepo repositories.RoleRepository, tokenService TokenServiceInterface, db *gorm.DB) OrganizationServiceInterface {
+	return &OrganizationService{
+		OrganizationRepository: organizationRepo,
+		MembershipRepository:   membershipRepo,
+		UserRepository:         userRepo,
+		RoleRepository:         roleRepo,
+		TokenService:           tokenService,
+		DB:                     db,
+	}
+}
+
+// Create 创建一个组织，并以管理员角色将创建者加入组织。
+func (s *OrganizationService) Create(name, slug string, creatorID uint) (*models.Organization, error) {
+	if !organizationSlugPattern.MatchString(slug) {
+		return nil, ErrInvalidOrganizationSlug
+	}
+	if _, err := s.OrganizationRepository.FindBySlug(slug); err == nil {
+		return nil, ErrOrganizationExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	adminRole, err := s.RoleRepository.FindByName(AdminRole)
+	if err != nil {
+		return nil, err
+	}
+
+	organization := &models.Organization{Name: name, Slug: slug}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := repositories.NewGormOrganizationRepository(tx).Create(organization); err != nil {
+			return err
+		}
+		membership := &models.Membership{UserID: creatorID, RoleID: adminRole.ID}
+		return repositories.NewGormMembershipRepository(tx).Create(tenant.NewContext(context.Background(), organization.ID), membership)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info("organization created",
+		zap.String("event", "organization.created"),
+		zap.Uint("organization_id", organization.ID),
+		zap.String("slug", organization.Slug),
+		zap.Uint("creator_id", creatorID),
+	)
+	return organization, nil
+}
+
+// List 返回所有组织。
+func (s *OrganizationService) List() ([]models.Organization, error) {
+	return s.OrganizationRepository.FindAll()
+}
+
+// ListForUser 返回用户加入的所有组织及其在组织中的角色。
+func (s *OrganizationService) ListForUser(userID uint) ([]models.Membership, error) {
+	return s.MembershipRepository.FindByUserID(userID)
+}
+
+// ListMembers 返回当前组织的所有成员。
+func (s *OrganizationService) ListMembers(ctx context.Context) ([]models.Membership, error) {
+	if err := requireTenant(ctx); err != nil {
+		return nil, err
+	}
+	return s.MembershipRepository.List(ctx)
+}
+
+// AddMember 以指定角色将用户加入当前组织。用户不存在时返回 gorm.ErrRecordNotFound。
+func (s *OrganizationService) AddMember(ctx context.Context, userID uint, roleName string) (*models.Membership, error) {
+	if err := requireTenant(ctx); err != nil {
+		return nil, err
+	}
+	role, err := s.findRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.UserRepository.FindByID(userID); err != nil {
+		return nil, err
+	}
+	if _, err := s.MembershipRepository.FindByUser(ctx, userID); err == nil {
+		return nil, ErrAlreadyMember
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if err := s.MembershipRepository.Create(ctx, &models.Membership{UserID: userID, RoleID: role.ID}); err != nil {
+		return nil, err
+	}
+	s.logMemberEvent(ctx, "organization.member_added", userID, role.Name)
+	return s.MembershipRepository.FindByUser(ctx, userID)
+}
+
+// UpdateMember 修改用户在当前组织中的角色。用户不是成员时返回 gorm.ErrRecordNotFound。
+// 新角色在用户下次刷新令牌或切换组织后生效。
+func (s *OrganizationService) UpdateMember(ctx context.Context, userID uint, roleName string) (*models.Membership, error) {
+	if err := requireTenant(ctx); err != nil {
+		return nil, err
+	}
+	role, err := s.findRole(roleName)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.MembershipRepository.UpdateRole(ctx, userID, role.ID); err != nil {
+		return nil, err
+	}
+	s.logMemberEvent(ctx, "organization.member_updated", userID, role.Name)
+	return s.MembershipRepository.FindByUser(ctx, userID)
+}
+
+// RemoveMember 将用户移出当前组织。用户不是成员时返回 gorm.ErrRecordNotFound。
+func (s *OrganizationService) RemoveMember(ctx context.Context, userID uint) error {
+	if err := requireTenant(ctx); err != nil {
+		return err
+	}
+	if err := s.MembershipRepository.Delete(ctx, userID); err != nil {
+		return err
+	}
+	s.logMemberEvent(ctx, "organization.member_removed", userID, "")
+	return nil
+}
+
+// SwitchTenant 为用户签发以 organizationID 为当前组织的令牌对，并开启一个新的登录会话。
+func (s *OrganizationService) SwitchTenant(userID, organizationID uint, client ClientInfo) (*models.User, *TokenPair, error) {
+	// 签发令牌使用的角色从主库读取
+	user, err := s.UserRepository.Primary().FindByID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var tokens *TokenPair
+	if organizationID == 0 {
+		tokens, err = s.TokenService.IssueTokens(user, client)
+	} else {
+		tokens, err = s.TokenService.IssueTenantTokens(user, organizationID, client)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// findRole 按名称查找组织成员使用的角色，角色必须在 OrganizationRoles 中。
+func (s *OrganizationService) findRole(name string) (*models.Role, error) {
+	if !isOrganizationRole(name) {
+		return nil, fmt.Errorf("%w: 角色 %s 不能分配给组织成员", ErrInvalidRoleName, name)
+	}
+	role, err := s.RoleRepository.FindByName(name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: 角色 %s 不存在", ErrInvalidRoleName, name)
+		}
+		return nil, err
+	}
+	return role, nil
+}
+
+// isOrganizationRole 返回角色是否可以分配给组织成员。
+func isOrganizationRole(name string) bool {
+	for _, role := range OrganizationRoles {
+		if role == name {
+			return true
+		}
+	}
+	return false
+}
+
+// logMemberEvent 记录组织成员变更的审计日志。
+func (s *OrganizationService) logMemberEvent(ctx context.Context, event string, userID uint, role string) {
+	organizationID, _ := tenant.FromContext(ctx)
+	utils.Logger.Info("organization membership changed",
+		zap.String("event", event),
+		zap.Uint("organization_id", organizationID),
+		zap.Uint("user_id", userID),
+		zap.String("role", role),
+	)
+}
+
+// requireTenant 检查上下文中是否选择了组织。
+// 没有当前组织时 tenant 插件不会限定查询，因此成员相关的操作必须先检查。
+func requireTenant(ctx context.Context) error {
+	if _, ok := tenant.FromContext(ctx); !ok {
+		return ErrNoActiveTenant
+	}
+	return nil
+}
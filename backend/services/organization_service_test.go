@@ -0,0 +1,222 @@
+package services_test
+
+import (
+	"context"
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/services"
+	"go-web/tenant"
+	"go-web/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// OrganizationServiceTestSuite 是一个测试套件，用于组织与多租户相关的集成测试。
+type OrganizationServiceTestSuite struct {
+	suite.Suite
+	db           *gorm.DB
+	cfg          *config.Config
+	service      services.OrganizationServiceInterface
+	tokenService services.TokenServiceInterface
+	admin        *models.User
+	alice        *models.User
+	bob          *models.User
+}
+
+// SetupSuite 在测试套件开始时运行，用于初始化数据库、租户插件和服务。
+func (suite *OrganizationServiceTestSuite) SetupSuite() {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // 组织变更会记录审计日志
+
+	db, err := gorm.Open(sqlite.Open("file:organizations?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		suite.T().Fatalf("无法连接到内存数据库: %v", err)
+	}
+	suite.Require().NoError(db.Use(tenant.Plugin{}))
+	suite.db = db
+
+	err = suite.db.AutoMigrate(&models.User{}, &models.Role{}, &models.RefreshToken{}, &models.Session{}, &models.Organization{}, &models.Membership{})
+	if err != nil {
+		suite.T().Fatalf("数据库迁移失败: %v", err)
+	}
+
+	suite.cfg = &config.Config{
+		App: config.AppConfig{DefaultRole: "user"},
+		JWT: config.JWTConfig{
+			Secret:            "test-secret",
+			Expiration:        3600,
+			RefreshExpiration: 7200,
+		},
+	}
+
+	userRepo := repositories.NewGormUserRepository(suite.db)
+	sessionRepo := repositories.NewGormSessionRepository(suite.db)
+	suite.tokenService = services.NewTokenService(suite.cfg, userRepo, repositories.NewGormRefreshTokenRepository(suite.db), repositories.NewMemoryRevokedTokenRepository(), sessionRepo, suite.db)
+	suite.service = services.NewOrganizationService(repositories.NewGormOrganizationRepository(suite.db), repositories.NewGormMembershipRepository(suite.db), userRepo, repositories.NewGormRoleRepository(suite.db), suite.tokenService, suite.db)
+}
+
+// SetupTest 在每个测试方法运行之前被调用，清理数据并创建角色和用户。
+func (suite *OrganizationServiceTestSuite) SetupTest() {
+	for _, table := range []string{"users", "roles", "refresh_tokens", "sessions", "organizations", "memberships"} {
+		suite.db.Exec("DELETE FROM " + table)
+	}
+
+	admin := &models.Role{Name: "admin", Description: "管理员"}
+	user := &models.Role{Name: "user", Description: "普通用户"}
+	suite.Require().NoError(suite.db.Create(admin).Error)
+	suite.Require().NoError(suite.db.Create(user).Error)
+
+	suite.admin = suite.createUser("root", admin.ID)
+	suite.alice = suite.createUser("alice", user.ID)
+	suite.bob = suite.createUser("bob", user.ID)
+}
+
+// TestOrganizationServiceTestSuite 运行测试套件。
+func TestOrganizationServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(OrganizationServiceTestSuite))
+}
+
+// createUser 创建一个拥有指定全局角色的用户。
+func (suite *OrganizationServiceTestSuite) createUser(username string, roleID uint) *models.User {
+	user := &models.User{Username: username, Email: username + "@example.com", Password: "x", RoleID: roleID}
+	suite.Require().NoError(suite.db.Create(user).Error)
+	return user
+}
+
+// createOrganization 由管理员创建组织，并返回以该组织为当前组织的上下文。
+func (suite *OrganizationServiceTestSuite) createOrganization(slug string) (*models.Organization, context.Context) {
+	organization, err := suite.service.Create(slug, slug, suite.admin.ID)
+	suite.Require().NoError(err)
+	return organization, tenant.NewContext(context.Background(), organization.ID)
+}
+
+// TestCreate_AddsCreatorAsAdmin 测试创建组织时创建者以管理员角色加入，且组织标识唯一。
+func (suite *OrganizationServiceTestSuite) TestCreate_AddsCreatorAsAdmin() {
+	organization, ctx := suite.createOrganization("acme")
+
+	members, err := suite.service.ListMembers(ctx)
+	suite.Require().NoError(err)
+	suite.Require().Len(members, 1)
+	assert.Equal(suite.T(), suite.admin.ID, members[0].UserID)
+	assert.Equal(suite.T(), organization.ID, members[0].OrganizationID)
+	assert.Equal(suite.T(), "admin", members[0].Role.Name)
+
+	_, err = suite.service.Create("Acme", "acme", suite.admin.ID)
+	assert.ErrorIs(suite.T(), err, services.ErrOrganizationExists)
+	_, err = suite.service.Create("Acme", "Acme Labs", suite.admin.ID)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidOrganizationSlug)
+}
+
+// TestMembers_ScopedToCurrentTenant 测试成员操作只作用于上下文中的当前组织。
+func (suite *OrganizationServiceTestSuite) TestMembers_ScopedToCurrentTenant() {
+	_, acme := suite.createOrganization("acme")
+	globex, globexCtx := suite.createOrganization("globex")
+
+	_, err := suite.service.AddMember(acme, suite.alice.ID, "user")
+	suite.Require().NoError(err)
+	member, err := suite.service.AddMember(globexCtx, suite.bob.ID, "user")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), globex.ID, member.OrganizationID)
+	assert.Equal(suite.T(), "bob", member.User.Username)
+
+	_, err = suite.service.AddMember(acme, suite.alice.ID, "admin")
+	assert.ErrorIs(suite.T(), err, services.ErrAlreadyMember)
+	_, err = suite.service.AddMember(acme, suite.bob.ID, "missing")
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidRoleName)
+	// 只有组织角色可以分配给成员，即使角色存在
+	suite.Require().NoError(suite.db.Create(&models.Role{Name: "auditor"}).Error)
+	_, err = suite.service.AddMember(acme, suite.bob.ID, "auditor")
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidRoleName)
+
+	members, err := suite.service.ListMembers(acme)
+	suite.Require().NoError(err)
+	usernames := make([]string, 0, len(members))
+	for _, m := range members {
+		usernames = append(usernames, m.User.Username)
+	}
+	assert.ElementsMatch(suite.T(), []string{"root", "alice"}, usernames)
+
+	// 其他组织的成员在当前组织中不可见
+	_, err = suite.service.UpdateMember(acme, suite.bob.ID, "admin")
+	assert.ErrorIs(suite.T(), err, gorm.ErrRecordNotFound)
+	assert.ErrorIs(suite.T(), suite.service.RemoveMember(acme, suite.bob.ID), gorm.ErrRecordNotFound)
+
+	_, err = suite.service.UpdateMember(acme, suite.alice.ID, "auditor")
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidRoleName)
+	updated, err := suite.service.UpdateMember(acme, suite.alice.ID, "admin")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "admin", updated.Role.Name)
+	suite.Require().NoError(suite.service.RemoveMember(acme, suite.alice.ID))
+
+	// bob 仍然是 globex 的成员
+	memberships, err := suite.service.ListForUser(suite.bob.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(memberships, 1)
+	assert.Equal(suite.T(), "globex", memberships[0].Organization.Slug)
+
+	_, err = suite.service.ListMembers(context.Background())
+	assert.ErrorIs(suite.T(), err, services.ErrNoActiveTenant)
+}
+
+// TestSwitchTenant 测试切换组织后令牌携带组织和组织内的角色，刷新时沿用组织，被移出组织后刷新失败。
+func (suite *OrganizationServiceTestSuite) TestSwitchTenant() {
+	organization, ctx := suite.createOrganization("acme")
+	_, err := suite.service.AddMember(ctx, suite.alice.ID, "admin")
+	suite.Require().NoError(err)
+
+	_, _, err = suite.service.SwitchTenant(suite.bob.ID, organization.ID, testClient)
+	assert.ErrorIs(suite.T(), err, services.ErrNotMember)
+
+	_, tokens, err := suite.service.SwitchTenant(suite.alice.ID, organization.ID, testClient)
+	suite.Require().NoError(err)
+	claims, err := utils.ValidateToken(tokens.AccessToken, suite.cfg)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), organization.ID, claims.TenantID)
+	assert.Equal(suite.T(), "admin", claims.Role)
+
+	_, refreshed, err := suite.tokenService.Refresh(tokens.RefreshToken)
+	suite.Require().NoError(err)
+	claims, err = utils.ValidateToken(refreshed.AccessToken, suite.cfg)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), organization.ID, claims.TenantID)
+
+	// 切换回全局后使用全局角色
+	_, global, err := suite.service.SwitchTenant(suite.alice.ID, 0, testClient)
+	suite.Require().NoError(err)
+	claims, err = utils.ValidateToken(global.AccessToken, suite.cfg)
+	suite.Require().NoError(err)
+	assert.Zero(suite.T(), claims.TenantID)
+	assert.Equal(suite.T(), "user", claims.Role)
+
+	suite.Require().NoError(suite.service.RemoveMember(ctx, suite.alice.ID))
+	_, _, err = suite.tokenService.Refresh(refreshed.RefreshToken)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidRefreshToken)
+}
+
+// TestCrossTenantQueries 测试明确跨组织的仓库方法在租户插件下查询所有组织，其他查询没有组织时失败。
+func (suite *OrganizationServiceTestSuite) TestCrossTenantQueries() {
+	_, acme := suite.createOrganization("acme")
+	_, globex := suite.createOrganization("globex")
+	_, err := suite.service.AddMember(acme, suite.bob.ID, "user")
+	suite.Require().NoError(err)
+	_, err = suite.service.AddMember(globex, suite.bob.ID, "user")
+	suite.Require().NoError(err)
+
+	memberships, err := repositories.NewGormMembershipRepository(suite.db).List(context.Background())
+	assert.ErrorIs(suite.T(), err, tenant.ErrNoTenant)
+	assert.Empty(suite.T(), memberships)
+
+	var userRole models.Role
+	suite.Require().NoError(suite.db.Where("name = ?", "user").First(&userRole).Error)
+	count, err := repositories.NewGormRoleRepository(suite.db).CountMemberships(userRole.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), int64(2), count)
+
+	memberships, err = suite.service.ListForUser(suite.bob.ID)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), memberships, 2)
+}
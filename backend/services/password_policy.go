@@ -0,0 +1,219 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责密码策略：长度、字符类型、用户信息、历史密码和泄露密码的检查。
+
+import (
+	"errors"
+	"fmt"
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/utils"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// ErrPasswordPolicy 是所有 *PasswordPolicyError 都匹配的哨兵错误，便于统一映射HTTP状态码。
+var ErrPasswordPolicy = errors.New("密码不符合安全策略")
+
+// 密码策略违规的代码，客户端可以据此显示本地化的提示。
+const (
+	PasswordTooShort         = "too_short"
+	PasswordTooLong          = "too_long"
+	PasswordMissingUppercase = "missing_uppercase"
+	PasswordMissingLowercase = "missing_lowercase"
+	PasswordMissingDigit     = "missing_digit"
+	PasswordMissingSymbol    = "missing_symbol"
+	PasswordContainsUserInfo = "contains_user_info"
+	PasswordReused           = "reused"
+	PasswordBreached         = "breached"
+)
+
+// userInfoMinLength 是检查密码是否包含用户名或邮箱时，用户信息的最小长度，过短的片段容易误判。
+const userInfoMinLength = 3
+
+// PasswordViolation 描述密码违反的一条策略。
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError 在密码不符合策略时返回，包含所有违反的规则。
+type PasswordPolicyError struct {
+	Violations []PasswordViolation
+}
+
+// Error 实现了 error 接口。
+func (e *PasswordPolicyError) Error() string {
+	return ErrPasswordPolicy.Error()
+}
+
+// Is 使 errors.Is(err, ErrPasswordPolicy) 对所有 *PasswordPolicyError 成立。
+func (e *PasswordPolicyError) Is(target error) bool {
+	return target == ErrPasswordPolicy
+}
+
+// PasswordPolicyServiceInterface 定义了密码策略服务应实现的功能契约。
+type PasswordPolicyServiceInterface interface {
+	// Validate 检查 user 的新密码是否符合策略，不符合时返回 *PasswordPolicyError。
+	// 注册时 user 尚未保存（ID 为0），不检查历史密码。
+	Validate(user *models.User, password string) error
+	// RecordHistory 在用户的密码被替换之后保存旧的密码哈希，并删除超出策略需要的记录。
+	RecordHistory(userID uint, previousHash string) error
+}
+
+// PasswordPolicyService 提供了密码策略相关的业务逻辑实现。
+type PasswordPolicyService struct {
+	Config                    *config.Config
+	PasswordHistoryRepository repositories.PasswordHistoryRepository
+}
+
+// NewPasswordPolicyService 是 PasswordPolicyService 的构造函数。
+func NewPasswordPolicyService(cfg *config.Config, historyRepo repositories.PasswordHistoryRepository) PasswordPolicyServiceInterface {
+	return &PasswordPolicyService{
+		Config:                    cfg,
+		PasswordHistoryRepository: historyRepo,
+	}
+}
+
+// Validate 依次检查所有规则，一次返回全部违规，而不是只返回第一条。
+func (s *PasswordPolicyService) Validate(user *models.User, password string) error {
+	policy := s.Config.Password
+	var violations []PasswordViolation
+	add := func(code, message string) {
+		violations = append(violations, PasswordViolation{Code: code, Message: message})
+	}
+
+	// 1. 长度
+	length := utf8.RuneCountInString(password)
+	if policy.MinLength > 0 && length < policy.MinLength {
+		add(PasswordTooShort, fmt.Sprintf("密码长度不能少于 %d 个字符", policy.MinLength))
+	}
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		add(PasswordTooLong, fmt.Sprintf("密码长度不能超过 %d 个字符", policy.MaxLength))
+	}
+
+	// 2. 字符类型
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r):
+			hasSymbol = true
+		}
+	}
+	if policy.RequireUppercase && !hasUpper {
+		add(PasswordMissingUppercase, "密码必须包含大写字母")
+	}
+	if policy.RequireLowercase && !hasLower {
+		add(PasswordMissingLowercase, "密码必须包含小写字母")
+	}
+	if policy.RequireDigit && !hasDigit {
+		add(PasswordMissingDigit, "密码必须包含数字")
+	}
+	if policy.RequireSymbol && !hasSymbol {
+		add(PasswordMissingSymbol, "密码必须包含特殊字符")
+	}
+
+	// 3. 用户名和邮箱
+	if policy.DisallowUserInfo && containsUserInfo(user, password) {
+		add(PasswordContainsUserInfo, "密码不能包含用户名或邮箱")
+	}
+
+	// 4. 历史密码
+	if policy.History > 0 && user.ID != 0 {
+		reused, err := s.isReused(user, password)
+		if err != nil {
+			return err
+		}
+		if reused {
+			add(PasswordReused, fmt.Sprintf("不能使用最近 %d 次使用过的密码", policy.History))
+		}
+	}
+
+	// 5. 泄露密码
+	breachedList, err := utils.BreachedPasswordListFor(s.Config)
+	if err != nil {
+		return err
+	}
+	if breachedList != nil {
+		breached, err := utils.IsBreachedPassword(breachedList, password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			add(PasswordBreached, "该密码已出现在公开泄露的密码库中，请更换")
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// RecordHistory 保存被替换的密码哈希。当前密码本身也计入 History，因此只需保留 History-1 条旧密码。
+func (s *PasswordPolicyService) RecordHistory(userID uint, previousHash string) error {
+	keep := s.Config.Password.History - 1
+	if keep <= 0 || previousHash == "" {
+		return s.PasswordHistoryRepository.PruneForUser(userID, 0)
+	}
+	if err := s.PasswordHistoryRepository.Create(&models.PasswordHistory{UserID: userID, PasswordHash: previousHash}); err != nil {
+		return err
+	}
+	return s.PasswordHistoryRepository.PruneForUser(userID, keep)
+}
+
+// isReused 检查密码是否与当前密码或最近的历史密码相同。
+func (s *PasswordPolicyService) isReused(user *models.User, password string) (bool, error) {
+	hasher, err := utils.PasswordHasherFor(s.Config)
+	if err != nil {
+		return false, err
+	}
+
+	hashes := []string{user.Password}
+	history, err := s.PasswordHistoryRepository.FindRecentByUserID(user.ID, s.Config.Password.History-1)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range history {
+		hashes = append(hashes, entry.PasswordHash)
+	}
+
+	for _, hash := range hashes {
+		if hash == "" {
+			continue
+		}
+		err := hasher.Verify(password, hash)
+		if err == nil {
+			return true, nil
+		}
+		if !errors.Is(err, utils.ErrPasswordMismatch) {
+			// 无法识别的旧哈希不应阻止用户修改密码
+			utils.Logger.Warn("failed to compare password history", zap.Uint("user_id", user.ID), zap.Error(err))
+		}
+	}
+	return false, nil
+}
+
+// containsUserInfo 检查密码是否包含用户名、邮箱或邮箱的用户名部分（不区分大小写）。
+func containsUserInfo(user *models.User, password string) bool {
+	password = strings.ToLower(password)
+	email := strings.ToLower(user.Email)
+	local, _, _ := strings.Cut(email, "@")
+	for _, info := range []string{strings.ToLower(user.Username), email, local} {
+		if utf8.RuneCountInString(info) >= userInfoMinLength && strings.Contains(password, info) {
+			return true
+		}
+	}
+	return false
+}
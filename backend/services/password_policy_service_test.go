@@ -0,0 +1,194 @@
+package services_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/services"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// PasswordPolicyServiceTestSuite 是一个测试套件，用于组织与密码策略相关的集成测试。
+type PasswordPolicyServiceTestSuite struct {
+	suite.Suite
+	db           *gorm.DB
+	cfg          *config.Config
+	mailer       *recordingMailer
+	service      services.PasswordPolicyServiceInterface
+	authService  services.AuthServiceInterface
+	resetService services.PasswordResetServiceInterface
+	userRepo     repositories.UserRepository
+	roleRepo     repositories.RoleRepository
+	historyRepo  repositories.PasswordHistoryRepository
+}
+
+// SetupSuite 在测试套件开始时运行，用于初始化数据库、配置和服务。
+func (suite *PasswordPolicyServiceTestSuite) SetupSuite() {
+	db, err := gorm.Open(sqlite.Open("file:password_policy?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		suite.T().Fatalf("无法连接到内存数据库: %v", err)
+	}
+	suite.db = db
+
+	err = suite.db.AutoMigrate(&models.User{}, &models.Role{}, &models.RefreshToken{}, &models.PasswordResetToken{}, &models.TOTPCredential{}, &models.RecoveryCode{}, &models.LoginFailure{}, &models.PasswordHistory{}, &models.Session{})
+	if err != nil {
+		suite.T().Fatalf("数据库迁移失败: %v", err)
+	}
+
+	// 泄露密码列表中包含 "password123"
+	sum := sha1.Sum([]byte("password123"))
+	breachedFile := filepath.Join(suite.T().TempDir(), "breached.txt")
+	content := "# test list\n" + strings.ToUpper(hex.EncodeToString(sum[:])) + ":2254650\n"
+	suite.Require().NoError(os.WriteFile(breachedFile, []byte(content), 0o600))
+
+	suite.cfg = &config.Config{
+		App: config.AppConfig{
+			DefaultRole: "user",
+			FrontendURL: "http://localhost:3000",
+		},
+		JWT: config.JWTConfig{
+			Secret:            "test-secret",
+			Expiration:        3600,
+			RefreshExpiration: 7200,
+		},
+		Auth: config.AuthConfig{
+			PasswordResetExpiration: 3600,
+		},
+		Password: config.PasswordConfig{
+			MinLength:        8,
+			MaxLength:        64,
+			RequireUppercase: true,
+			RequireDigit:     true,
+			DisallowUserInfo: true,
+			History:          3,
+			BreachedListFile: breachedFile,
+		},
+	}
+
+	suite.userRepo = repositories.NewGormUserRepository(suite.db)
+	suite.roleRepo = repositories.NewGormRoleRepository(suite.db)
+	suite.historyRepo = repositories.NewGormPasswordHistoryRepository(suite.db)
+	suite.service = services.NewPasswordPolicyService(suite.cfg, suite.historyRepo)
+
+	tokenService := services.NewTokenService(suite.cfg, suite.userRepo, repositories.NewGormRefreshTokenRepository(suite.db), repositories.NewMemoryRevokedTokenRepository(), repositories.NewGormSessionRepository(suite.db), suite.db)
+	suite.mailer = &recordingMailer{}
+	verificationService := services.NewEmailVerificationService(suite.cfg, suite.userRepo, suite.mailer)
+	mfaService := services.NewMFAService(suite.cfg, suite.userRepo, repositories.NewGormTOTPCredentialRepository(suite.db), repositories.NewGormRecoveryCodeRepository(suite.db), repositories.NewGormMFAChallengeRepository(suite.db))
+	lockoutService := services.NewLockoutService(suite.cfg, repositories.NewGormLoginFailureRepository(suite.db))
+	suite.authService = services.NewAuthService(suite.cfg, suite.userRepo, suite.roleRepo, tokenService, verificationService, mfaService, lockoutService, suite.service, suite.db)
+	suite.resetService = services.NewPasswordResetService(suite.cfg, suite.userRepo, repositories.NewGormPasswordResetTokenRepository(suite.db), tokenService, suite.service, suite.mailer, suite.db)
+}
+
+// SetupTest 在每个测试方法运行之前被调用，清理数据并创建默认角色。
+func (suite *PasswordPolicyServiceTestSuite) SetupTest() {
+	suite.db.Exec("DELETE FROM users")
+	suite.db.Exec("DELETE FROM roles")
+	suite.db.Exec("DELETE FROM password_reset_tokens")
+	suite.db.Exec("DELETE FROM password_histories")
+	suite.Require().NoError(suite.roleRepo.Create(&models.Role{Name: "user", Description: "普通用户"}))
+}
+
+// TestPasswordPolicyServiceTestSuite 运行测试套件。
+func TestPasswordPolicyServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(PasswordPolicyServiceTestSuite))
+}
+
+// violationCodes 从策略错误中取出所有违规代码。
+func (suite *PasswordPolicyServiceTestSuite) violationCodes(err error) []string {
+	var policyErr *services.PasswordPolicyError
+	suite.Require().ErrorAs(err, &policyErr)
+	var codes []string
+	for _, v := range policyErr.Violations {
+		codes = append(codes, v.Code)
+	}
+	return codes
+}
+
+// resetPassword 通过忘记密码流程把用户的密码重置为 password。
+func (suite *PasswordPolicyServiceTestSuite) resetPassword(email, password string) error {
+	sent := suite.mailer.count()
+	suite.Require().NoError(suite.resetService.RequestReset(email))
+	suite.mailer.waitFor(suite.T(), sent+1)
+	return suite.resetService.ResetPassword(tokenFromLink(suite.mailer.last().Body), password)
+}
+
+// TestValidate_ReportsAllViolations 测试一次返回密码违反的所有规则。
+func (suite *PasswordPolicyServiceTestSuite) TestValidate_ReportsAllViolations() {
+	user := &models.User{Username: "alice", Email: "alice@example.com"}
+
+	err := suite.service.Validate(user, "alice")
+	assert.ErrorIs(suite.T(), err, services.ErrPasswordPolicy)
+	assert.ElementsMatch(suite.T(), []string{
+		services.PasswordTooShort,
+		services.PasswordMissingUppercase,
+		services.PasswordMissingDigit,
+		services.PasswordContainsUserInfo,
+	}, suite.violationCodes(err))
+
+	err = suite.service.Validate(user, strings.Repeat("Ab1", 22))
+	assert.Equal(suite.T(), []string{services.PasswordTooLong}, suite.violationCodes(err))
+
+	assert.NoError(suite.T(), suite.service.Validate(user, "Correct-Horse-9"))
+}
+
+// TestValidate_UserInfoIgnoresCase 测试检查用户名和邮箱时不区分大小写。
+func (suite *PasswordPolicyServiceTestSuite) TestValidate_UserInfoIgnoresCase() {
+	user := &models.User{Username: "bob", Email: "Robert.Smith@example.com"}
+
+	err := suite.service.Validate(user, "Xrobert.smith9")
+	assert.Equal(suite.T(), []string{services.PasswordContainsUserInfo}, suite.violationCodes(err))
+	err = suite.service.Validate(user, "MyBOB-2024")
+	assert.Equal(suite.T(), []string{services.PasswordContainsUserInfo}, suite.violationCodes(err))
+}
+
+// TestRegister_BreachedPassword 测试注册时拒绝出现在泄露列表中的密码。
+func (suite *PasswordPolicyServiceTestSuite) TestRegister_BreachedPassword() {
+	suite.cfg.Password.RequireUppercase = false
+	defer func() { suite.cfg.Password.RequireUppercase = true }()
+
+	_, _, err := suite.authService.Register("carol", "carol@example.com", "password123", testClient)
+	assert.Equal(suite.T(), []string{services.PasswordBreached}, suite.violationCodes(err))
+
+	_, err = suite.userRepo.FindByUsername("carol")
+	assert.ErrorIs(suite.T(), err, gorm.ErrRecordNotFound)
+
+	_, _, err = suite.authService.Register("carol", "carol@example.com", "password1234", testClient)
+	assert.NoError(suite.T(), err)
+}
+
+// TestResetPassword_History 测试重置密码时不能使用最近的密码，且只保留策略需要的历史记录。
+func (suite *PasswordPolicyServiceTestSuite) TestResetPassword_History() {
+	user, _, err := suite.authService.Register("dave", "dave@example.com", "Password-1", testClient)
+	suite.Require().NoError(err)
+
+	// 当前密码不能重复使用
+	err = suite.resetPassword("dave@example.com", "Password-1")
+	assert.Equal(suite.T(), []string{services.PasswordReused}, suite.violationCodes(err))
+
+	suite.Require().NoError(suite.resetPassword("dave@example.com", "Password-2"))
+	suite.Require().NoError(suite.resetPassword("dave@example.com", "Password-3"))
+
+	// History 为3：当前密码加上最近两个旧密码
+	err = suite.resetPassword("dave@example.com", "Password-1")
+	assert.Equal(suite.T(), []string{services.PasswordReused}, suite.violationCodes(err))
+	err = suite.resetPassword("dave@example.com", "Password-2")
+	assert.Equal(suite.T(), []string{services.PasswordReused}, suite.violationCodes(err))
+
+	suite.Require().NoError(suite.resetPassword("dave@example.com", "Password-4"))
+	history, err := suite.historyRepo.FindRecentByUserID(user.ID, 10)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), history, 2)
+
+	// 第一个密码已经超出历史范围，可以再次使用
+	assert.NoError(suite.T(), suite.resetPassword("dave@example.com", "Password-1"))
+}
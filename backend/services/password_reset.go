@@ -0,0 +1,183 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责"忘记密码"流程：签发一次性重置令牌、发送邮件以及重置密码。
+
+import (
+	"errors"
+	"fmt"
+	"go-web/config"
+	"go-web/mailer"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/utils"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidResetToken 在密码重置令牌不存在、已过期或已被使用时返回。
+var ErrInvalidResetToken = errors.New("密码重置链接无效或已过期")
+
+// resetTokenBytes 是密码重置令牌明文的随机字节数。
+const resetTokenBytes = 32
+
+// PasswordResetServiceInterface 定义了密码重置服务应实现的功能契约。
+type PasswordResetServiceInterface interface {
+	// RequestReset 为邮箱对应的用户签发重置令牌并发送邮件。
+	// 无论邮箱是否注册都返回成功，避免泄露账户是否存在。
+	RequestReset(email string) error
+	// ResetPassword 使用重置令牌设置新密码，并吊销该用户现有的所有会话。
+	ResetPassword(token, newPassword string) error
+}
+
+// PasswordResetService 提供了密码重置相关的业务逻辑实现。
+type PasswordResetService struct {
+	Config                       *config.Config
+	UserRepository               repositories.UserRepository
+	PasswordResetTokenRepository repositories.PasswordResetTokenRepository
+	TokenService                 TokenServiceInterface
+	PasswordPolicyService        PasswordPolicyServiceInterface
+	Mailer                       mailer.Mailer
+	DB                           *gorm.DB // 用于重置密码时的事务
+}
+
+// NewPasswordResetService 是 PasswordResetService 的构造函数。
+func NewPasswordResetService(cfg *config.Config, userRepo repositories.UserRepository, resetTokenRepo repositories.PasswordResetTokenRepository, tokenService TokenServiceInterface, passwordPolicyService PasswordPolicyServiceInterface, m mailer.Mailer, db *gorm.DB) PasswordResetServiceInterface {
+	return &PasswordResetService{
+		Config:                       cfg,
+		UserRepository:               userRepo,
+		PasswordResetTokenRepository: resetTokenRepo,
+		TokenService:                 tokenService,
+		PasswordPolicyService:        passwordPolicyService,
+		Mailer:                       m,
+		DB:                           db,
+	}
+}
+
+// RequestReset 为用户签发新的重置令牌并通过邮件发送重置链接。
+// 同一用户之前尚未使用的令牌会被作废，只有最新的链接有效。
+func (s *PasswordResetService) RequestReset(email string) error {
+	// 1. 查找用户，邮箱未注册时静默返回
+	user, err := s.UserRepository.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// 2. 作废之前的重置令牌
+	if err := s.PasswordResetTokenRepository.InvalidateAllForUser(user.ID); err != nil {
+		return err
+	}
+
+	// 3. 生成并保存新的令牌
+	token, err := utils.GenerateRandomToken(resetTokenBytes)
+	if err != nil {
+		return err
+	}
+	expiration := time.Duration(s.Config.Auth.PasswordResetExpiration) * time.Second
+	record := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(expiration),
+	}
+	if err := s.PasswordResetTokenRepository.Create(record); err != nil {
+		return err
+	}
+
+	// 4. 在后台发送邮件。发送失败只记录日志，否则响应的差异会暴露邮箱是否已注册
+	link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(s.Config.App.FrontendURL, "/"), url.QueryEscape(token))
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "重置您的密码",
+		Body: fmt.Sprintf("%s，您好：\n\n我们收到了重置您账户密码的请求。请在 %d 分钟内访问以下链接设置新密码：\n\n%s\n\n如果这不是您本人的操作，请忽略此邮件，您的密码不会被修改。",
+			user.Username, int(expiration.Minutes()), link),
+	}
+	sendInBackground("failed to send password reset mail", user.ID, func() error {
+		return s.Mailer.Send(msg)
+	})
+
+	return nil
+}
+
+// ResetPassword 使用重置令牌设置新密码。
+// 令牌的消费和密码的更新在同一个事务中完成；成功后用户所有的刷新令牌都会被吊销。
+func (s *PasswordResetService) ResetPassword(token, newPassword string) error {
+	// 1. 查找并校验令牌
+	record, err := s.PasswordResetTokenRepository.FindByHash(utils.HashToken(token))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidResetToken
+		}
+		return err
+	}
+	if record.UsedAt != nil || time.Now().After(record.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	// 2. 从主库读取用户，检查新密码是否符合策略，并对其进行哈希加密。
+	// 旧密码会写入历史记录，不能使用可能落后的从库中的数据
+	user, err := s.UserRepository.Primary().FindByID(record.UserID)
+	if err != nil {
+		return err
+	}
+	if err := s.PasswordPolicyService.Validate(user, newPassword); err != nil {
+		return err
+	}
+	hasher, err := utils.PasswordHasherFor(s.Config)
+	if err != nil {
+		return err
+	}
+	hashedPassword, err := hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	// 3. 在事务中消费令牌并更新密码
+	tx := s.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	txResetTokenRepo := repositories.NewGormPasswordResetTokenRepository(tx)
+	txUserRepo := repositories.NewGormUserRepository(tx)
+
+	used, err := txResetTokenRepo.MarkUsed(record.ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if !used {
+		// 令牌已被另一个并发请求使用
+		tx.Rollback()
+		return ErrInvalidResetToken
+	}
+
+	// 记录修改时间，之前签发的访问令牌随之失效
+	if err := txUserRepo.ChangePassword(record.UserID, hashedPassword, time.Now().Truncate(time.Second)); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	// 4. 记录旧密码。失败只记录日志，密码已经重置成功
+	if err := s.PasswordPolicyService.RecordHistory(user.ID, user.Password); err != nil {
+		utils.Logger.Error("failed to record password history", zap.Uint("user_id", user.ID), zap.Error(err))
+	}
+
+	// 5. 吊销现有会话，持有旧凭据的设备需要重新登录
+	return s.TokenService.RevokeAllForUser(record.UserID)
+}
@@ -0,0 +1,239 @@
+package services_test
+
+import (
+	"go-web/config"
+	"go-web/mailer"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/services"
+	"go-web/utils"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// recordingMailer 记录所有发送的邮件，供测试断言使用。
+// 部分邮件在后台发送，读取之前先用 waitFor 等待。
+type recordingMailer struct {
+	mu       sync.Mutex
+	messages []mailer.Message
+}
+
+func (m *recordingMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// count 返回已经发送的邮件数量。
+func (m *recordingMailer) count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.messages)
+}
+
+// waitFor 等待直到至少发送了 n 封邮件。
+func (m *recordingMailer) waitFor(t *testing.T, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool { return m.count() >= n }, time.Second, time.Millisecond)
+}
+
+// last 返回最近发送的一封邮件。
+func (m *recordingMailer) last() mailer.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.messages[len(m.messages)-1]
+}
+
+// blockingMailer 在 release 关闭之前阻塞发送，用于确认邮件在后台发送。
+type blockingMailer struct {
+	release chan struct{}
+	sent    chan mailer.Message
+}
+
+func newBlockingMailer() *blockingMailer {
+	return &blockingMailer{release: make(chan struct{}), sent: make(chan mailer.Message, 1)}
+}
+
+func (m *blockingMailer) Send(msg mailer.Message) error {
+	<-m.release
+	m.sent <- msg
+	return nil
+}
+
+// tokenFromLink 从邮件正文的链接中提取 token 查询参数。
+func tokenFromLink(body string) string {
+	link := regexp.MustCompile(`https?://\S+`).FindString(body)
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return u.Query().Get("token")
+}
+
+// PasswordResetServiceTestSuite 是一个测试套件，用于组织与PasswordResetService相关的集成测试。
+type PasswordResetServiceTestSuite struct {
+	suite.Suite
+	db               *gorm.DB
+	cfg              *config.Config
+	mailer           *recordingMailer
+	service          services.PasswordResetServiceInterface
+	userRepo         repositories.UserRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	tokenService     services.TokenServiceInterface
+}
+
+// SetupSuite 在测试套件开始时运行，用于初始化数据库和配置。
+func (suite *PasswordResetServiceTestSuite) SetupSuite() {
+	db, err := gorm.Open(sqlite.Open("file:password_reset?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		suite.T().Fatalf("无法连接到内存数据库: %v", err)
+	}
+	suite.db = db
+
+	err = suite.db.AutoMigrate(&models.User{}, &models.Role{}, &models.RefreshToken{}, &models.PasswordResetToken{}, &models.PasswordHistory{}, &models.Session{})
+	if err != nil {
+		suite.T().Fatalf("数据库迁移失败: %v", err)
+	}
+
+	suite.cfg = &config.Config{
+		App: config.AppConfig{
+			DefaultRole: "user",
+			FrontendURL: "http://localhost:3000",
+		},
+		JWT: config.JWTConfig{
+			Secret:            "test-secret",
+			Expiration:        3600,
+			RefreshExpiration: 7200,
+		},
+		Auth: config.AuthConfig{
+			PasswordResetExpiration: 3600,
+		},
+	}
+
+	suite.userRepo = repositories.NewGormUserRepository(suite.db)
+	suite.refreshTokenRepo = repositories.NewGormRefreshTokenRepository(suite.db)
+	suite.tokenService = services.NewTokenService(suite.cfg, suite.userRepo, suite.refreshTokenRepo, repositories.NewMemoryRevokedTokenRepository(), repositories.NewGormSessionRepository(suite.db), suite.db)
+}
+
+// SetupTest 在每个测试方法运行之前被调用，清理数据并重新创建服务。
+func (suite *PasswordResetServiceTestSuite) SetupTest() {
+	suite.db.Exec("DELETE FROM users")
+	suite.db.Exec("DELETE FROM refresh_tokens")
+	suite.db.Exec("DELETE FROM password_reset_tokens")
+
+	suite.mailer = &recordingMailer{}
+	suite.service = services.NewPasswordResetService(suite.cfg, suite.userRepo, repositories.NewGormPasswordResetTokenRepository(suite.db), suite.tokenService, services.NewPasswordPolicyService(suite.cfg, repositories.NewGormPasswordHistoryRepository(suite.db)), suite.mailer, suite.db)
+}
+
+// TestPasswordResetServiceTestSuite 运行测试套件。
+func TestPasswordResetServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(PasswordResetServiceTestSuite))
+}
+
+// createUser 创建一个使用给定密码的测试用户。
+func (suite *PasswordResetServiceTestSuite) createUser(password string) *models.User {
+	hashedPassword, err := utils.HashPassword(password)
+	suite.Require().NoError(err)
+	user := &models.User{Username: "resetuser", Email: "reset@example.com", Password: hashedPassword}
+	suite.Require().NoError(suite.userRepo.Create(user))
+	return user
+}
+
+// TestResetPassword_Success 测试通过邮件中的链接成功重置密码的场景。
+func (suite *PasswordResetServiceTestSuite) TestResetPassword_Success() {
+	// 准备：用户已登录，持有一个刷新令牌
+	user := suite.createUser("old-password")
+	tokens, err := suite.tokenService.IssueTokens(user, testClient)
+	suite.Require().NoError(err)
+
+	// 执行
+	err = suite.service.RequestReset("reset@example.com")
+	suite.Require().NoError(err)
+	suite.mailer.waitFor(suite.T(), 1)
+	suite.Require().Len(suite.mailer.messages, 1)
+	assert.Equal(suite.T(), "reset@example.com", suite.mailer.last().To)
+
+	token := tokenFromLink(suite.mailer.last().Body)
+	suite.Require().NotEmpty(token)
+	err = suite.service.ResetPassword(token, "new-password")
+
+	// 断言：密码已更新
+	assert.NoError(suite.T(), err)
+	dbUser, err := suite.userRepo.FindByID(user.ID)
+	suite.Require().NoError(err)
+	assert.NoError(suite.T(), utils.CheckPasswordHash("new-password", dbUser.Password))
+
+	// 现有会话已被吊销
+	_, _, err = suite.tokenService.Refresh(tokens.RefreshToken)
+	assert.Error(suite.T(), err)
+
+	// 令牌只能使用一次
+	err = suite.service.ResetPassword(token, "another-password")
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidResetToken)
+}
+
+// TestRequestReset_UnknownEmail 测试邮箱未注册时静默成功且不发送邮件的场景。
+func (suite *PasswordResetServiceTestSuite) TestRequestReset_UnknownEmail() {
+	err := suite.service.RequestReset("nobody@example.com")
+
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), suite.mailer.messages)
+}
+
+// TestRequestReset_SendsInBackground 测试申请重置不等待邮件发送完成，注册和未注册的邮箱响应时间相同。
+func (suite *PasswordResetServiceTestSuite) TestRequestReset_SendsInBackground() {
+	suite.createUser("old-password")
+	m := newBlockingMailer()
+	service := services.NewPasswordResetService(suite.cfg, suite.userRepo, repositories.NewGormPasswordResetTokenRepository(suite.db), suite.tokenService, services.NewPasswordPolicyService(suite.cfg, repositories.NewGormPasswordHistoryRepository(suite.db)), m, suite.db)
+
+	// 邮件发送被阻塞时请求仍然立即返回
+	suite.Require().NoError(service.RequestReset("reset@example.com"))
+	close(m.release)
+	select {
+	case msg := <-m.sent:
+		assert.Equal(suite.T(), "reset@example.com", msg.To)
+		assert.NoError(suite.T(), service.ResetPassword(tokenFromLink(msg.Body), "new-password"))
+	case <-time.After(time.Second):
+		suite.T().Fatal("重置邮件没有发送")
+	}
+}
+
+// TestRequestReset_InvalidatesPreviousToken 测试再次申请后旧链接失效的场景。
+func (suite *PasswordResetServiceTestSuite) TestRequestReset_InvalidatesPreviousToken() {
+	suite.createUser("old-password")
+
+	suite.Require().NoError(suite.service.RequestReset("reset@example.com"))
+	suite.mailer.waitFor(suite.T(), 1)
+	firstToken := tokenFromLink(suite.mailer.last().Body)
+	suite.Require().NoError(suite.service.RequestReset("reset@example.com"))
+	suite.mailer.waitFor(suite.T(), 2)
+	secondToken := tokenFromLink(suite.mailer.last().Body)
+
+	assert.ErrorIs(suite.T(), suite.service.ResetPassword(firstToken, "new-password"), services.ErrInvalidResetToken)
+	assert.NoError(suite.T(), suite.service.ResetPassword(secondToken, "new-password"))
+}
+
+// TestResetPassword_Expired 测试过期令牌无法使用的场景。
+func (suite *PasswordResetServiceTestSuite) TestResetPassword_Expired() {
+	user := suite.createUser("old-password")
+	repo := repositories.NewGormPasswordResetTokenRepository(suite.db)
+	suite.Require().NoError(repo.Create(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken("expired-token"),
+		ExpiresAt: time.Now().Add(-time.Minute),
+	}))
+
+	err := suite.service.ResetPassword("expired-token", "new-password")
+
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidResetToken)
+}
@@ -0,0 +1,87 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责查询请求者的有效权限，供前端决定显示哪些功能。
+// 权限即Casbin的访问策略（p），包括通过角色继承关系获得的权限，在当前组织对应的域中计算。
+
+import (
+	"go-web/tenant"
+	"sort"
+
+	"github.com/casbin/casbin/v2"
+)
+
+// Permission 是角色可以访问的一条路由。Object 是路由模板（如 "/users/:id"）或 "*"，Action 是HTTP方法或 "*"。
+type Permission struct {
+	Object string
+	Action string
+}
+
+// PermissionCheck 是一项待检查的访问。Object 是实际的请求路径（如 "/users/42"），Action 是HTTP方法。
+type PermissionCheck struct {
+	Object string
+	Action string
+}
+
+// PermissionServiceInterface 定义了权限查询服务应实现的功能契约。
+type PermissionServiceInterface interface {
+	// GetPermissions 返回角色在组织 tenantID 中的有效权限，tenantID 为0时为全局。
+	GetPermissions(role string, tenantID uint) ([]Permission, error)
+	// Check 按顺序检查角色在组织 tenantID 中是否可以进行每一项访问，与Casbin中间件的判断相同。
+	Check(role string, tenantID uint, checks []PermissionCheck) ([]bool, error)
+}
+
+// PermissionService 提供了权限查询相关的业务逻辑实现。
+type PermissionService struct {
+	Enforcer *casbin.SyncedEnforcer
+}
+
+// NewPermissionService 是 PermissionService 的构造函数。
+func NewPermissionService(enforcer *casbin.SyncedEnforcer) PermissionServiceInterface {
+	return &PermissionService{Enforcer: enforcer}
+}
+
+// GetPermissions 返回角色在组织 tenantID 中的有效权限，按路由和方法排序。
+// 同一条权限可能由多个角色或多个域模式（如 "*" 和 "org:*"）的策略得到，只返回一次。
+func (s *PermissionService) GetPermissions(role string, tenantID uint) ([]Permission, error) {
+	domain := tenant.Domain(tenantID)
+	rules, err := s.Enforcer.GetImplicitPermissionsForUser(role, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[Permission]bool, len(rules))
+	permissions := []Permission{}
+	for _, rule := range rules {
+		// 规则的字段为 sub, dom, obj, act
+		if len(rule) < 4 {
+			continue
+		}
+		permission := Permission{Object: rule[2], Action: rule[3]}
+		if seen[permission] {
+			continue
+		}
+		seen[permission] = true
+		permissions = append(permissions, permission)
+	}
+	sort.Slice(permissions, func(i, j int) bool {
+		if permissions[i].Object != permissions[j].Object {
+			return permissions[i].Object < permissions[j].Object
+		}
+		return permissions[i].Action < permissions[j].Action
+	})
+	return permissions, nil
+}
+
+// Check 按顺序检查角色在组织 tenantID 中是否可以进行每一项访问。
+func (s *PermissionService) Check(role string, tenantID uint, checks []PermissionCheck) ([]bool, error) {
+	domain := tenant.Domain(tenantID)
+	requests := make([][]interface{}, 0, len(checks))
+	for _, check := range checks {
+		requests = append(requests, []interface{}{role, domain, check.Object, check.Action})
+	}
+	if len(requests) == 0 {
+		return []bool{}, nil
+	}
+	return s.Enforcer.BatchEnforce(requests)
+}
@@ -0,0 +1,98 @@
+package services_test
+
+import (
+	"go-web/config"
+	"go-web/services"
+	"go-web/tenant"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPermissionService 创建一个使用默认模型的内存执行器：editor 继承 user，owner 只在组织3中继承 admin。
+func newPermissionService(t *testing.T) services.PermissionServiceInterface {
+	m, err := model.NewModelFromString(config.DefaultCasbinModel)
+	require.NoError(t, err)
+	e, err := casbin.NewSyncedEnforcer(m)
+	require.NoError(t, err)
+	tenant.ConfigureEnforcer(e.Enforcer)
+
+	_, err = e.AddPolicies([][]string{
+		{"admin", tenant.GlobalDomain, "*", "*"},
+		{"user", tenant.AllDomains, "/users/:id", "GET"},
+		{"user", "org:*", "/users/:id", "GET"},
+		{"editor", tenant.AllDomains, "/articles/:id", "PUT"},
+		{"admin", "org:*", "/organizations/current/members", "*"},
+	})
+	require.NoError(t, err)
+	_, err = e.AddGroupingPolicies([][]string{
+		{"editor", "user", tenant.AllDomains},
+		{"owner", "admin", "org:3"},
+	})
+	require.NoError(t, err)
+	return services.NewPermissionService(e)
+}
+
+// TestGetPermissions_IncludesInheritedPermissions 测试有效权限包括继承得到的权限，并且不重复。
+func TestGetPermissions_IncludesInheritedPermissions(t *testing.T) {
+	service := newPermissionService(t)
+
+	permissions, err := service.GetPermissions("editor", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []services.Permission{
+		{Object: "/articles/:id", Action: "PUT"},
+		{Object: "/users/:id", Action: "GET"},
+	}, permissions)
+
+	permissions, err = service.GetPermissions("editor", 5)
+	require.NoError(t, err)
+	assert.Len(t, permissions, 2)
+}
+
+// TestGetPermissions_DependsOnDomain 测试组织域中的角色继承关系只在该组织中生效。
+func TestGetPermissions_DependsOnDomain(t *testing.T) {
+	service := newPermissionService(t)
+
+	permissions, err := service.GetPermissions("owner", 3)
+	require.NoError(t, err)
+	assert.Equal(t, []services.Permission{{Object: "/organizations/current/members", Action: "*"}}, permissions)
+
+	permissions, err = service.GetPermissions("owner", 5)
+	require.NoError(t, err)
+	assert.Empty(t, permissions)
+
+	permissions, err = service.GetPermissions("admin", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []services.Permission{{Object: "*", Action: "*"}}, permissions)
+
+	permissions, err = service.GetPermissions("nobody", 0)
+	require.NoError(t, err)
+	assert.Empty(t, permissions)
+}
+
+// TestCheckPermissions 测试批量检查的结果与请求的顺序一致，并使用实际的请求路径匹配路由模板。
+func TestCheckPermissions(t *testing.T) {
+	service := newPermissionService(t)
+
+	allowed, err := service.Check("editor", 0, []services.PermissionCheck{
+		{Object: "/users/42", Action: "GET"},
+		{Object: "/users/42", Action: "DELETE"},
+		{Object: "/articles/7", Action: "PUT"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false, true}, allowed)
+
+	allowed, err = service.Check("owner", 3, []services.PermissionCheck{
+		{Object: "/organizations/current/members", Action: "POST"},
+		{Object: "/admin/locks", Action: "GET"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []bool{true, false}, allowed)
+
+	allowed, err = service.Check("editor", 0, nil)
+	require.NoError(t, err)
+	assert.Empty(t, allowed)
+}
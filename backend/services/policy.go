@@ -0,0 +1,183 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责在运行时管理Casbin的访问策略（p）和角色继承关系（g）。
+
+import (
+	"errors"
+	"fmt"
+	"go-web/utils"
+	"sort"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidPolicy 在策略类型不存在于模型中、字段数量与模型定义不符或字段为空时返回。
+	ErrInvalidPolicy = errors.New("策略规则无效")
+	// ErrPolicyExists 在添加已经存在的策略时返回。
+	ErrPolicyExists = errors.New("策略已存在")
+	// ErrPolicyNotFound 在删除不存在的策略时返回。
+	ErrPolicyNotFound = errors.New("策略不存在")
+)
+
+// 模型中的策略段和角色段。
+const (
+	policySection   = "p"
+	groupingSection = "g"
+)
+
+// PolicyRule 是一条Casbin规则。PType 是模型中的策略类型（如 "p"、"g"），Rule 是按模型定义排列的字段。
+type PolicyRule struct {
+	PType string
+	Rule  []string
+}
+
+// PolicyServiceInterface 定义了策略管理服务应实现的功能契约。
+// 所有修改都通过执行器完成：立即生效，并由适配器持久化到数据库。
+type PolicyServiceInterface interface {
+	// GetPolicies 返回所有访问策略（p 段）。
+	GetPolicies() ([]PolicyRule, error)
+	// AddPolicy 添加一条访问策略。
+	AddPolicy(rule PolicyRule) error
+	// RemovePolicy 删除一条访问策略。
+	RemovePolicy(rule PolicyRule) error
+	// GetGroupings 返回所有角色继承关系（g 段）。
+	GetGroupings() ([]PolicyRule, error)
+	// AddGrouping 添加一条角色继承关系。
+	AddGrouping(rule PolicyRule) error
+	// RemoveGrouping 删除一条角色继承关系。
+	RemoveGrouping(rule PolicyRule) error
+}
+
+// PolicyService 提供了策略管理相关的业务逻辑实现。
+type PolicyService struct {
+	Enforcer *casbin.SyncedEnforcer
+}
+
+// NewPolicyService 是 PolicyService 的构造函数。
+func NewPolicyService(enforcer *casbin.SyncedEnforcer) PolicyServiceInterface {
+	return &PolicyService{Enforcer: enforcer}
+}
+
+// GetPolicies 返回所有访问策略。
+func (s *PolicyService) GetPolicies() ([]PolicyRule, error) {
+	return s.list(policySection, s.Enforcer.GetNamedPolicy)
+}
+
+// AddPolicy 添加一条访问策略。
+func (s *PolicyService) AddPolicy(rule PolicyRule) error {
+	return s.modify(policySection, rule, "policy.added", func(ptype string, params ...interface{}) (bool, error) {
+		return s.Enforcer.AddNamedPolicy(ptype, params...)
+	}, ErrPolicyExists)
+}
+
+// RemovePolicy 删除一条访问策略。
+func (s *PolicyService) RemovePolicy(rule PolicyRule) error {
+	return s.modify(policySection, rule, "policy.removed", func(ptype string, params ...interface{}) (bool, error) {
+		return s.Enforcer.RemoveNamedPolicy(ptype, params...)
+	}, ErrPolicyNotFound)
+}
+
+// GetGroupings 返回所有角色继承关系。
+func (s *PolicyService) GetGroupings() ([]PolicyRule, error) {
+	return s.list(groupingSection, s.Enforcer.GetNamedGroupingPolicy)
+}
+
+// AddGrouping 添加一条角色继承关系，执行器会同时更新角色管理器。
+func (s *PolicyService) AddGrouping(rule PolicyRule) error {
+	return s.modify(groupingSection, rule, "grouping.added", func(ptype string, params ...interface{}) (bool, error) {
+		return s.Enforcer.AddNamedGroupingPolicy(ptype, params...)
+	}, ErrPolicyExists)
+}
+
+// RemoveGrouping 删除一条角色继承关系。
+func (s *PolicyService) RemoveGrouping(rule PolicyRule) error {
+	return s.modify(groupingSection, rule, "grouping.removed", func(ptype string, params ...interface{}) (bool, error) {
+		return s.Enforcer.RemoveNamedGroupingPolicy(ptype, params...)
+	}, ErrPolicyNotFound)
+}
+
+// list 返回模型中某一段下所有策略类型的规则。
+func (s *PolicyService) list(section string, get func(ptype string) ([][]string, error)) ([]PolicyRule, error) {
+	rules := []PolicyRule{}
+	for _, ptype := range policyTypes(s.Enforcer, section) {
+		named, err := get(ptype)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range named {
+			rules = append(rules, PolicyRule{PType: ptype, Rule: rule})
+		}
+	}
+	return rules, nil
+}
+
+// modify 校验规则后执行添加或删除。apply 返回 false 表示没有发生变化，此时返回 unchanged。
+func (s *PolicyService) modify(section string, rule PolicyRule, event string, apply func(ptype string, params ...interface{}) (bool, error), unchanged error) error {
+	if rule.PType == "" {
+		rule.PType = section
+	}
+	if err := s.validate(section, rule); err != nil {
+		return err
+	}
+
+	params := make([]interface{}, len(rule.Rule))
+	for i, field := range rule.Rule {
+		params[i] = field
+	}
+	changed, err := apply(rule.PType, params...)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return unchanged
+	}
+
+	utils.Logger.Info("casbin rule changed",
+		zap.String("event", event),
+		zap.String("ptype", rule.PType),
+		zap.Strings("rule", rule.Rule),
+	)
+	return nil
+}
+
+// validate 根据已加载的模型校验规则：策略类型必须在对应的段中定义，字段数量必须与定义一致，且不能为空。
+func (s *PolicyService) validate(section string, rule PolicyRule) error {
+	// 重新加载策略时会替换模型，读取模型需要持有执行器的读锁
+	s.Enforcer.GetLock().RLock()
+	assertion, ok := s.Enforcer.GetModel()[section][rule.PType]
+	s.Enforcer.GetLock().RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: 模型中没有定义策略类型 %q", ErrInvalidPolicy, rule.PType)
+	}
+
+	// 角色定义形如 "g = _, _"，字段数量等于 "_" 的数量
+	arity := len(assertion.Tokens)
+	if section == groupingSection {
+		arity = strings.Count(assertion.Value, "_")
+	}
+	if len(rule.Rule) != arity {
+		return fmt.Errorf("%w: %s 规则需要 %d 个字段，实际为 %d 个", ErrInvalidPolicy, rule.PType, arity, len(rule.Rule))
+	}
+	for _, field := range rule.Rule {
+		if field == "" || strings.TrimSpace(field) != field {
+			return fmt.Errorf("%w: 字段不能为空，也不能以空白字符开头或结尾", ErrInvalidPolicy)
+		}
+	}
+	return nil
+}
+
+// policyTypes 返回模型中某一段下定义的所有策略类型（如 p、p2），按名称排序以保证顺序稳定。
+func policyTypes(enforcer *casbin.SyncedEnforcer, section string) []string {
+	enforcer.GetLock().RLock()
+	defer enforcer.GetLock().RUnlock()
+	ptypes := make([]string, 0, len(enforcer.GetModel()[section]))
+	for ptype := range enforcer.GetModel()[section] {
+		ptypes = append(ptypes, ptype)
+	}
+	sort.Strings(ptypes)
+	return ptypes
+}
@@ -0,0 +1,145 @@
+package services_test
+
+import (
+	"go-web/services"
+	"go-web/utils"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// policyTestModel 是测试使用的Casbin模型，带有角色继承。
+const policyTestModel = `[request_definition]
+r = sub, obj, act
+[policy_definition]
+p = sub, obj, act
+[role_definition]
+g = _, _
+[policy_effect]
+e = some(where (p.eft == allow))
+[matchers]
+m = g(r.sub, p.sub) && r.obj == p.obj && r.act == p.act`
+
+// PolicyServiceTestSuite 是一个测试套件，用于组织与策略管理相关的集成测试。
+type PolicyServiceTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	enforcer *casbin.SyncedEnforcer
+	service  services.PolicyServiceInterface
+}
+
+// SetupSuite 在测试套件开始时运行，用于初始化数据库。
+func (suite *PolicyServiceTestSuite) SetupSuite() {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // 修改策略会记录审计日志
+
+	db, err := gorm.Open(sqlite.Open("file:policies?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		suite.T().Fatalf("无法连接到内存数据库: %v", err)
+	}
+	suite.db = db
+}
+
+// SetupTest 在每个测试方法运行之前被调用，清空策略表并创建新的执行器。
+func (suite *PolicyServiceTestSuite) SetupTest() {
+	suite.db.Exec("DELETE FROM casbin_rule")
+	suite.enforcer = suite.newEnforcer()
+	suite.service = services.NewPolicyService(suite.enforcer)
+}
+
+// TestPolicyServiceTestSuite 运行测试套件。
+func TestPolicyServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(PolicyServiceTestSuite))
+}
+
+// newEnforcer 创建一个从数据库加载策略的执行器，模拟应用重启。
+func (suite *PolicyServiceTestSuite) newEnforcer() *casbin.SyncedEnforcer {
+	m, err := model.NewModelFromString(policyTestModel)
+	suite.Require().NoError(err)
+	a, err := gormadapter.NewAdapterByDB(suite.db)
+	suite.Require().NoError(err)
+	e, err := casbin.NewSyncedEnforcer(m, a)
+	suite.Require().NoError(err)
+	return e
+}
+
+// TestAddPolicy_TakesEffectAndPersists 测试添加的策略立即生效，并在重新加载后仍然存在。
+func (suite *PolicyServiceTestSuite) TestAddPolicy_TakesEffectAndPersists() {
+	suite.Require().NoError(suite.service.AddPolicy(services.PolicyRule{Rule: []string{"editor", "/articles", "GET"}}))
+
+	ok, err := suite.enforcer.Enforce("editor", "/articles", "GET")
+	suite.Require().NoError(err)
+	assert.True(suite.T(), ok)
+
+	policies, err := suite.service.GetPolicies()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []services.PolicyRule{{PType: "p", Rule: []string{"editor", "/articles", "GET"}}}, policies)
+
+	reloaded, err := services.NewPolicyService(suite.newEnforcer()).GetPolicies()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), policies, reloaded)
+
+	assert.ErrorIs(suite.T(), suite.service.AddPolicy(services.PolicyRule{Rule: []string{"editor", "/articles", "GET"}}), services.ErrPolicyExists)
+}
+
+// TestRemovePolicy 测试删除策略后立即失去权限，删除不存在的策略返回错误。
+func (suite *PolicyServiceTestSuite) TestRemovePolicy() {
+	rule := services.PolicyRule{PType: "p", Rule: []string{"editor", "/articles", "GET"}}
+	suite.Require().NoError(suite.service.AddPolicy(rule))
+	suite.Require().NoError(suite.service.RemovePolicy(rule))
+
+	ok, err := suite.enforcer.Enforce("editor", "/articles", "GET")
+	suite.Require().NoError(err)
+	assert.False(suite.T(), ok)
+	assert.ErrorIs(suite.T(), suite.service.RemovePolicy(rule), services.ErrPolicyNotFound)
+
+	reloaded, err := services.NewPolicyService(suite.newEnforcer()).GetPolicies()
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), reloaded)
+}
+
+// TestGrouping_InheritsPermissions 测试添加角色继承关系后继承上级角色的权限。
+func (suite *PolicyServiceTestSuite) TestGrouping_InheritsPermissions() {
+	suite.Require().NoError(suite.service.AddPolicy(services.PolicyRule{Rule: []string{"editor", "/articles", "GET"}}))
+	suite.Require().NoError(suite.service.AddGrouping(services.PolicyRule{Rule: []string{"alice", "editor"}}))
+
+	ok, err := suite.enforcer.Enforce("alice", "/articles", "GET")
+	suite.Require().NoError(err)
+	assert.True(suite.T(), ok)
+
+	groupings, err := suite.service.GetGroupings()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []services.PolicyRule{{PType: "g", Rule: []string{"alice", "editor"}}}, groupings)
+
+	suite.Require().NoError(suite.service.RemoveGrouping(services.PolicyRule{PType: "g", Rule: []string{"alice", "editor"}}))
+	ok, err = suite.enforcer.Enforce("alice", "/articles", "GET")
+	suite.Require().NoError(err)
+	assert.False(suite.T(), ok)
+}
+
+// TestValidation 测试根据模型校验策略类型、字段数量和字段内容。
+func (suite *PolicyServiceTestSuite) TestValidation() {
+	invalid := []services.PolicyRule{
+		{PType: "p2", Rule: []string{"editor", "/articles", "GET"}},
+		{PType: "g", Rule: []string{"editor", "/articles", "GET"}}, // 访问策略接口不接受角色类型
+		{Rule: []string{"editor", "/articles"}},
+		{Rule: []string{"editor", "/articles", "GET", "allow"}},
+		{Rule: []string{"editor", "", "GET"}},
+		{Rule: []string{"editor ", "/articles", "GET"}},
+	}
+	for _, rule := range invalid {
+		assert.ErrorIs(suite.T(), suite.service.AddPolicy(rule), services.ErrInvalidPolicy, rule)
+	}
+
+	assert.ErrorIs(suite.T(), suite.service.AddGrouping(services.PolicyRule{Rule: []string{"alice"}}), services.ErrInvalidPolicy)
+	assert.ErrorIs(suite.T(), suite.service.AddGrouping(services.PolicyRule{PType: "g2", Rule: []string{"alice", "editor"}}), services.ErrInvalidPolicy)
+
+	policies, err := suite.service.GetPolicies()
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), policies)
+}
@@ -0,0 +1,404 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责角色的管理以及角色之间的继承关系。
+
+import (
+	"errors"
+	"fmt"
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/tenant"
+	"go-web/utils"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrRoleExists 在创建或重命名角色时名称已被占用时返回。
+	ErrRoleExists = errors.New("角色名称已存在")
+	// ErrRoleInUse 在删除仍被用户持有或被其他角色继承的角色时返回。
+	ErrRoleInUse = errors.New("角色仍在使用中")
+	// ErrRoleProtected 在重命名或删除管理员角色或配置中引用的角色时返回。
+	ErrRoleProtected = errors.New("该角色是内置角色，不能重命名或删除")
+	// ErrInvalidRoleName 在角色名称为空或包含空白字符时返回。
+	ErrInvalidRoleName = errors.New("角色名称无效")
+	// ErrInvalidRoleParent 在继承的角色不存在或继承关系形成环时返回。
+	ErrInvalidRoleParent = errors.New("继承的角色无效")
+)
+
+// AdminRole 是拥有全部权限的管理员角色，启动时自动创建。
+const AdminRole = "admin"
+
+// RoleDetails 是角色及其直接继承的角色。
+type RoleDetails struct {
+	models.Role
+	Parents []string
+}
+
+// RoleServiceInterface 定义了角色服务应实现的功能契约。
+// 角色继承关系保存为Casbin的 g 规则（子角色, 父角色, "*"），在所有域中生效，子角色拥有父角色的全部权限。
+// 只在某个组织中生效的继承关系可以通过策略管理接口直接添加。
+type RoleServiceInterface interface {
+	// List 返回所有角色。
+	List() ([]RoleDetails, error)
+	// Get 返回指定的角色。
+	Get(id uint) (*RoleDetails, error)
+	// Create 创建一个角色，并设置它继承的角色。
+	Create(name, description string, parents []string) (*RoleDetails, error)
+	// Update 修改角色的名称、描述和继承的角色。重命名时同时更新引用该角色的Casbin规则。
+	Update(id uint, name, description string, parents []string) (*RoleDetails, error)
+	// Delete 删除一个没有用户或组织成员持有、也没有被其他角色继承的角色，以及它的Casbin规则。
+	Delete(id uint) error
+	// EnsureDefaultRoles 创建管理员角色和新用户的默认角色（如果不存在）。
+	EnsureDefaultRoles() error
+}
+
+// RoleService 提供了角色管理相关的业务逻辑实现。
+type RoleService struct {
+	Config         *config.Config
+	RoleRepository repositories.RoleRepository
+	Enforcer       *casbin.SyncedEnforcer
+}
+
+// NewRoleService 是 RoleService 的构造函数。
+func NewRoleService(cfg *config.Config, roleRepo repositories.RoleRepository, enforcer *casbin.SyncedEnforcer) RoleServiceInterface {
+	return &RoleService{
+		Config:         cfg,
+		RoleRepository: roleRepo,
+		Enforcer:       enforcer,
+	}
+}
+
+// List 返回所有角色。
+func (s *RoleService) List() ([]RoleDetails, error) {
+	roles, err := s.RoleRepository.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]RoleDetails, 0, len(roles))
+	for _, role := range roles {
+		d, err := s.details(role)
+		if err != nil {
+			return nil, err
+		}
+		details = append(details, *d)
+	}
+	return details, nil
+}
+
+// Get 返回指定的角色。
+func (s *RoleService) Get(id uint) (*RoleDetails, error) {
+	role, err := s.RoleRepository.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.details(*role)
+}
+
+// Create 创建一个角色，并设置它继承的角色。
+func (s *RoleService) Create(name, description string, parents []string) (*RoleDetails, error) {
+	if err := validateRoleName(name); err != nil {
+		return nil, err
+	}
+	if err := s.ensureNameAvailable(name); err != nil {
+		return nil, err
+	}
+	if err := s.validateParents(name, parents); err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{Name: name, Description: description}
+	if err := s.RoleRepository.Create(role); err != nil {
+		return nil, err
+	}
+	if err := s.setParents(name, parents); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info("role created",
+		zap.String("event", "role.created"),
+		zap.String("role", name),
+		zap.Strings("parents", parents),
+	)
+	return s.details(*role)
+}
+
+// Update 修改角色的名称、描述和继承的角色。
+// 已签发的访问令牌仍带有旧的角色名称，刷新令牌后才会使用新名称。
+func (s *RoleService) Update(id uint, name, description string, parents []string) (*RoleDetails, error) {
+	if err := validateRoleName(name); err != nil {
+		return nil, err
+	}
+	role, err := s.RoleRepository.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	oldName := role.Name
+	if name != oldName {
+		if s.isProtected(oldName) {
+			return nil, ErrRoleProtected
+		}
+		if err := s.ensureNameAvailable(name); err != nil {
+			return nil, err
+		}
+	}
+	// 继承关系按重命名之前的名称校验，此时Casbin中的规则还没有改名
+	if err := s.validateParents(oldName, parents); err != nil {
+		return nil, err
+	}
+
+	role.Name = name
+	role.Description = description
+	if err := s.RoleRepository.Update(role); err != nil {
+		return nil, err
+	}
+	if name != oldName {
+		if err := s.renameRules(oldName, name); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.setParents(name, parents); err != nil {
+		return nil, err
+	}
+
+	utils.Logger.Info("role updated",
+		zap.String("event", "role.updated"),
+		zap.Uint("role_id", role.ID),
+		zap.String("old_name", oldName),
+		zap.String("role", name),
+		zap.Strings("parents", parents),
+	)
+	return s.details(*role)
+}
+
+// Delete 删除一个角色以及它的策略和继承关系。
+func (s *RoleService) Delete(id uint) error {
+	role, err := s.RoleRepository.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if s.isProtected(role.Name) {
+		return ErrRoleProtected
+	}
+
+	// 从主库统计，避免只读副本的延迟导致删除刚刚分配给用户的角色
+	count, err := s.RoleRepository.Primary().CountUsers(role.ID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: 仍有 %d 个用户持有该角色", ErrRoleInUse, count)
+	}
+	count, err = s.RoleRepository.Primary().CountMemberships(role.ID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: 仍有 %d 个组织成员持有该角色", ErrRoleInUse, count)
+	}
+	children, err := s.Enforcer.GetFilteredNamedGroupingPolicy(groupingSection, 1, role.Name)
+	if err != nil {
+		return err
+	}
+	if len(children) > 0 {
+		return fmt.Errorf("%w: 角色 %s 继承了该角色", ErrRoleInUse, children[0][0])
+	}
+
+	if err := s.RoleRepository.Delete(role); err != nil {
+		return err
+	}
+	// DeleteRole 只清理 p 和 g，其他类型的策略（如属性授权规则 p2）单独删除
+	if _, err := s.Enforcer.DeleteRole(role.Name); err != nil {
+		return err
+	}
+	for _, ptype := range policyTypes(s.Enforcer, policySection) {
+		if ptype == policySection {
+			continue
+		}
+		if _, err := s.Enforcer.RemoveFilteredNamedPolicy(ptype, 0, role.Name); err != nil {
+			return err
+		}
+	}
+
+	utils.Logger.Info("role deleted",
+		zap.String("event", "role.deleted"),
+		zap.Uint("role_id", role.ID),
+		zap.String("role", role.Name),
+	)
+	return nil
+}
+
+// EnsureDefaultRoles 创建管理员角色和新用户的默认角色（如果不存在）。
+func (s *RoleService) EnsureDefaultRoles() error {
+	defaults := []models.Role{
+		{Name: AdminRole, Description: "管理员"},
+		{Name: s.Config.App.DefaultRole, Description: "普通用户"},
+	}
+	for i := range defaults {
+		role := &defaults[i]
+		if role.Name == "" {
+			continue
+		}
+		_, err := s.RoleRepository.FindByName(role.Name)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err := s.RoleRepository.Create(role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// details 查询角色在所有域中直接继承的角色。
+func (s *RoleService) details(role models.Role) (*RoleDetails, error) {
+	rules, err := s.Enforcer.GetFilteredNamedGroupingPolicy(groupingSection, 0, role.Name, "", tenant.AllDomains)
+	if err != nil {
+		return nil, err
+	}
+	parents := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		parents = append(parents, rule[1])
+	}
+	return &RoleDetails{Role: role, Parents: parents}, nil
+}
+
+// isProtected 判断角色是否按名称被引用：管理员角色、注册时使用的默认角色和 restrict 模式下的受限角色。
+func (s *RoleService) isProtected(name string) bool {
+	return name == AdminRole || name == s.Config.App.DefaultRole || name == s.Config.Auth.UnverifiedRole
+}
+
+// ensureNameAvailable 检查角色名称是否已被占用。
+func (s *RoleService) ensureNameAvailable(name string) error {
+	_, err := s.RoleRepository.FindByName(name)
+	if err == nil {
+		return ErrRoleExists
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return nil
+}
+
+// validateParents 检查继承的角色都存在，并且不会形成继承环。
+func (s *RoleService) validateParents(name string, parents []string) error {
+	for _, parent := range parents {
+		if parent == name {
+			return fmt.Errorf("%w: 角色不能继承自己", ErrInvalidRoleParent)
+		}
+		if _, err := s.RoleRepository.FindByName(parent); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("%w: 角色 %s 不存在", ErrInvalidRoleParent, parent)
+			}
+			return err
+		}
+		// 父角色直接或间接继承了当前角色时会形成环
+		ancestors, err := s.Enforcer.GetImplicitRolesForUser(parent, tenant.AllDomains)
+		if err != nil {
+			return err
+		}
+		for _, ancestor := range ancestors {
+			if ancestor == name {
+				return fmt.Errorf("%w: 角色 %s 已经继承了 %s", ErrInvalidRoleParent, parent, name)
+			}
+		}
+	}
+	return nil
+}
+
+// setParents 将角色在所有域中的继承关系替换为 parents，只在某个组织中生效的继承关系保持不变。
+func (s *RoleService) setParents(name string, parents []string) error {
+	current, err := s.Enforcer.GetFilteredNamedGroupingPolicy(groupingSection, 0, name, "", tenant.AllDomains)
+	if err != nil {
+		return err
+	}
+
+	wanted := make(map[string]bool, len(parents))
+	for _, parent := range parents {
+		wanted[parent] = true
+	}
+	for _, rule := range current {
+		if wanted[rule[1]] {
+			delete(wanted, rule[1])
+			continue
+		}
+		if _, err := s.Enforcer.RemoveNamedGroupingPolicy(groupingSection, name, rule[1], tenant.AllDomains); err != nil {
+			return err
+		}
+	}
+	for _, parent := range parents {
+		if !wanted[parent] {
+			continue
+		}
+		delete(wanted, parent)
+		if _, err := s.Enforcer.AddNamedGroupingPolicy(groupingSection, name, parent, tenant.AllDomains); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameRules 将引用旧角色名称的策略（包括属性授权规则）和继承关系改为新名称。
+func (s *RoleService) renameRules(oldName, newName string) error {
+	for _, ptype := range policyTypes(s.Enforcer, policySection) {
+		policies, err := s.Enforcer.GetFilteredNamedPolicy(ptype, 0, oldName)
+		if err != nil {
+			return err
+		}
+		if len(policies) > 0 {
+			if _, err := s.Enforcer.UpdateNamedPolicies(ptype, policies, replaceField(policies, oldName, newName)); err != nil {
+				return err
+			}
+		}
+	}
+
+	groupings, err := s.Enforcer.GetNamedGroupingPolicy(groupingSection)
+	if err != nil {
+		return err
+	}
+	var affected [][]string
+	for _, rule := range groupings {
+		if rule[0] == oldName || rule[1] == oldName {
+			affected = append(affected, rule)
+		}
+	}
+	if len(affected) > 0 {
+		if _, err := s.Enforcer.UpdateNamedGroupingPolicies(groupingSection, affected, replaceField(affected, oldName, newName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaceField 返回将规则中等于 oldName 的字段替换为 newName 后的副本。
+func replaceField(rules [][]string, oldName, newName string) [][]string {
+	renamed := make([][]string, len(rules))
+	for i, rule := range rules {
+		renamed[i] = make([]string, len(rule))
+		for j, field := range rule {
+			if field == oldName {
+				field = newName
+			}
+			renamed[i][j] = field
+		}
+	}
+	return renamed
+}
+
+// validateRoleName 检查角色名称不为空且不含空白字符。
+func validateRoleName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t\r\n") {
+		return ErrInvalidRoleName
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package services_test
+
+import (
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/services"
+	"go-web/tenant"
+	"go-web/utils"
+	"testing"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
+	gormadapter "github.com/casbin/gorm-adapter/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// RoleServiceTestSuite 是一个测试套件，用于组织与角色管理相关的集成测试。
+type RoleServiceTestSuite struct {
+	suite.Suite
+	db       *gorm.DB
+	cfg      *config.Config
+	enforcer *casbin.SyncedEnforcer
+	service  services.RoleServiceInterface
+}
+
+// SetupSuite 在测试套件开始时运行，用于初始化数据库和配置。
+func (suite *RoleServiceTestSuite) SetupSuite() {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // 修改角色会记录审计日志
+
+	db, err := gorm.Open(sqlite.Open("file:roles?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		suite.T().Fatalf("无法连接到内存数据库: %v", err)
+	}
+	suite.db = db
+
+	err = suite.db.AutoMigrate(&models.User{}, &models.Role{}, &models.Membership{})
+	if err != nil {
+		suite.T().Fatalf("数据库迁移失败: %v", err)
+	}
+
+	suite.cfg = &config.Config{
+		App:  config.AppConfig{DefaultRole: "user"},
+		Auth: config.AuthConfig{UnverifiedRole: "unverified"},
+	}
+}
+
+// SetupTest 在每个测试方法运行之前被调用，清理数据并创建默认角色。
+func (suite *RoleServiceTestSuite) SetupTest() {
+	suite.db.Exec("DELETE FROM users")
+	suite.db.Exec("DELETE FROM memberships")
+	suite.db.Exec("DELETE FROM roles")
+	suite.db.Exec("DELETE FROM casbin_rule")
+
+	m, err := model.NewModelFromString(config.DefaultCasbinModel)
+	suite.Require().NoError(err)
+	a, err := gormadapter.NewAdapterByDB(suite.db)
+	suite.Require().NoError(err)
+	suite.enforcer, err = casbin.NewSyncedEnforcer(m, a)
+	suite.Require().NoError(err)
+	tenant.ConfigureEnforcer(suite.enforcer.Enforcer)
+
+	suite.service = services.NewRoleService(suite.cfg, repositories.NewGormRoleRepository(suite.db), suite.enforcer)
+	suite.Require().NoError(suite.service.EnsureDefaultRoles())
+}
+
+// TestRoleServiceTestSuite 运行测试套件。
+func TestRoleServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(RoleServiceTestSuite))
+}
+
+// TestEnsureDefaultRoles 测试默认角色只创建一次。
+func (suite *RoleServiceTestSuite) TestEnsureDefaultRoles() {
+	suite.Require().NoError(suite.service.EnsureDefaultRoles())
+
+	roles, err := suite.service.List()
+	suite.Require().NoError(err)
+	suite.Require().Len(roles, 2)
+	assert.Equal(suite.T(), "admin", roles[0].Name)
+	assert.Equal(suite.T(), "user", roles[1].Name)
+}
+
+// TestCreate_InheritsPermissions 测试子角色通过 g 规则继承父角色的权限。
+func (suite *RoleServiceTestSuite) TestCreate_InheritsPermissions() {
+	_, err := suite.enforcer.AddPolicy("user", tenant.AllDomains, "/articles", "GET")
+	suite.Require().NoError(err)
+
+	role, err := suite.service.Create("moderator", "版主", []string{"user"})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []string{"user"}, role.Parents)
+
+	// 继承关系在所有域中生效
+	for _, domain := range []string{tenant.GlobalDomain, tenant.Domain(1)} {
+		ok, err := suite.enforcer.Enforce("moderator", domain, "/articles", "GET")
+		suite.Require().NoError(err)
+		assert.True(suite.T(), ok, domain)
+	}
+
+	_, err = suite.service.Create("moderator", "", nil)
+	assert.ErrorIs(suite.T(), err, services.ErrRoleExists)
+	_, err = suite.service.Create("editor", "", []string{"missing"})
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidRoleParent)
+	_, err = suite.service.Create("two words", "", nil)
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidRoleName)
+}
+
+// TestUpdate_RejectsCycles 测试继承关系不能形成环，并且可以替换继承的角色。
+func (suite *RoleServiceTestSuite) TestUpdate_RejectsCycles() {
+	moderator, err := suite.service.Create("moderator", "", []string{"user"})
+	suite.Require().NoError(err)
+	senior, err := suite.service.Create("senior", "", []string{"moderator"})
+	suite.Require().NoError(err)
+
+	_, err = suite.service.Update(moderator.ID, "moderator", "", []string{"senior"})
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidRoleParent)
+	_, err = suite.service.Update(moderator.ID, "moderator", "", []string{"moderator"})
+	assert.ErrorIs(suite.T(), err, services.ErrInvalidRoleParent)
+
+	updated, err := suite.service.Update(senior.ID, "senior", "资深版主", []string{"user"})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []string{"user"}, updated.Parents)
+	assert.Equal(suite.T(), "资深版主", updated.Description)
+}
+
+// TestUpdate_RenameUpdatesRules 测试重命名角色时同时更新它的访问策略和继承关系。
+func (suite *RoleServiceTestSuite) TestUpdate_RenameUpdatesRules() {
+	moderator, err := suite.service.Create("moderator", "", []string{"user"})
+	suite.Require().NoError(err)
+	_, err = suite.service.Create("senior", "", []string{"moderator"})
+	suite.Require().NoError(err)
+	_, err = suite.enforcer.AddPolicy("moderator", tenant.AllDomains, "/reports", "GET")
+	suite.Require().NoError(err)
+	_, err = suite.enforcer.AddNamedPolicy("p2", "moderator", tenant.AllDomains, services.ResourceUser, services.ActionUpdate, "true")
+	suite.Require().NoError(err)
+
+	renamed, err := suite.service.Update(moderator.ID, "mod", "", []string{"user"})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "mod", renamed.Name)
+
+	ok, err := suite.enforcer.Enforce("mod", tenant.GlobalDomain, "/reports", "GET")
+	suite.Require().NoError(err)
+	assert.True(suite.T(), ok)
+	ok, err = suite.enforcer.Enforce("senior", tenant.GlobalDomain, "/reports", "GET")
+	suite.Require().NoError(err)
+	assert.True(suite.T(), ok)
+	ok, err = suite.enforcer.Enforce("moderator", tenant.GlobalDomain, "/reports", "GET")
+	suite.Require().NoError(err)
+	assert.False(suite.T(), ok)
+	rules, err := suite.enforcer.GetNamedPolicy("p2")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), [][]string{{"mod", tenant.AllDomains, services.ResourceUser, services.ActionUpdate, "true"}}, rules)
+
+	user, err := repositories.NewGormRoleRepository(suite.db).FindByName("user")
+	suite.Require().NoError(err)
+	_, err = suite.service.Update(user.ID, "member", "", nil)
+	assert.ErrorIs(suite.T(), err, services.ErrRoleProtected)
+}
+
+// TestDelete 测试仍被用户、组织成员持有或被其他角色继承的角色不能删除，删除时同时移除它的规则。
+func (suite *RoleServiceTestSuite) TestDelete() {
+	moderator, err := suite.service.Create("moderator", "", []string{"user"})
+	suite.Require().NoError(err)
+	senior, err := suite.service.Create("senior", "", []string{"moderator"})
+	suite.Require().NoError(err)
+	_, err = suite.enforcer.AddPolicy("moderator", tenant.AllDomains, "/reports", "GET")
+	suite.Require().NoError(err)
+	_, err = suite.enforcer.AddNamedPolicy("p2", "moderator", tenant.AllDomains, services.ResourceUser, services.ActionUpdate, "true")
+	suite.Require().NoError(err)
+
+	assert.ErrorIs(suite.T(), suite.service.Delete(moderator.ID), services.ErrRoleInUse)
+	suite.Require().NoError(suite.service.Delete(senior.ID))
+
+	suite.Require().NoError(suite.db.Create(&models.User{Username: "alice", Email: "alice@example.com", Password: "x", RoleID: moderator.ID}).Error)
+	assert.ErrorIs(suite.T(), suite.service.Delete(moderator.ID), services.ErrRoleInUse)
+
+	suite.db.Exec("DELETE FROM users")
+
+	// 只被组织成员持有的角色同样不能删除
+	suite.Require().NoError(suite.db.Create(&models.Membership{OrganizationID: 1, UserID: 1, RoleID: moderator.ID}).Error)
+	assert.ErrorIs(suite.T(), suite.service.Delete(moderator.ID), services.ErrRoleInUse)
+
+	suite.db.Exec("DELETE FROM memberships")
+	suite.Require().NoError(suite.service.Delete(moderator.ID))
+	_, err = suite.service.Get(moderator.ID)
+	assert.ErrorIs(suite.T(), err, gorm.ErrRecordNotFound)
+
+	policies, err := suite.enforcer.GetPolicy()
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), policies)
+	rules, err := suite.enforcer.GetNamedPolicy("p2")
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), rules)
+	groupings, err := suite.enforcer.GetGroupingPolicy()
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), groupings)
+
+	// 删除后可以重新创建同名角色
+	_, err = suite.service.Create("moderator", "", nil)
+	suite.Require().NoError(err)
+
+	admin, err := repositories.NewGormRoleRepository(suite.db).FindByName("admin")
+	suite.Require().NoError(err)
+	assert.ErrorIs(suite.T(), suite.service.Delete(admin.ID), services.ErrRoleProtected)
+}
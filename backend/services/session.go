@@ -0,0 +1,111 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责登录会话的查询和吊销。
+
+import (
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/utils"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxUserAgentLength 是会话中保存的User-Agent的最大长度。
+const maxUserAgentLength = 512
+
+// ClientInfo 描述发起登录的客户端，保存在登录会话中供用户辨认自己的设备。
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// SessionServiceInterface 定义了登录会话服务应实现的功能契约。
+type SessionServiceInterface interface {
+	// List 返回用户所有仍然有效的会话。
+	List(userID uint) ([]models.Session, error)
+	// Revoke 吊销用户的指定会话，该会话的访问令牌和刷新令牌立即失效。
+	Revoke(userID, sessionID uint) error
+	// RevokeAllForUser 吊销用户的所有会话，用于管理员强制用户下线。
+	RevokeAllForUser(userID uint) error
+}
+
+// SessionService 提供了登录会话相关的业务逻辑实现。
+type SessionService struct {
+	UserRepository         repositories.UserRepository
+	SessionRepository      repositories.SessionRepository
+	RefreshTokenRepository repositories.RefreshTokenRepository
+}
+
+// NewSessionService 是 SessionService 的构造函数。
+func NewSessionService(userRepo repositories.UserRepository, sessionRepo repositories.SessionRepository, refreshTokenRepo repositories.RefreshTokenRepository) SessionServiceInterface {
+	return &SessionService{
+		UserRepository:         userRepo,
+		SessionRepository:      sessionRepo,
+		RefreshTokenRepository: refreshTokenRepo,
+	}
+}
+
+// List 返回用户所有仍然有效的会话。
+func (s *SessionService) List(userID uint) ([]models.Session, error) {
+	return s.SessionRepository.FindActiveByUserID(userID, time.Now())
+}
+
+// Revoke 吊销用户的指定会话。会话不存在、属于其他用户或已经失效时返回 gorm.ErrRecordNotFound。
+func (s *SessionService) Revoke(userID, sessionID uint) error {
+	session, err := s.SessionRepository.FindByID(sessionID)
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID || !session.IsActive(time.Now()) {
+		return gorm.ErrRecordNotFound
+	}
+
+	if _, err := s.SessionRepository.Revoke(session.ID); err != nil {
+		return err
+	}
+	if err := s.RefreshTokenRepository.RevokeFamily(session.FamilyID); err != nil {
+		return err
+	}
+
+	utils.Logger.Info("session revoked",
+		zap.String("event", "session.revoked"),
+		zap.Uint("user_id", userID),
+		zap.Uint("session_id", sessionID),
+	)
+	return nil
+}
+
+// RevokeAllForUser 吊销用户的所有会话。用户不存在时返回 gorm.ErrRecordNotFound。
+func (s *SessionService) RevokeAllForUser(userID uint) error {
+	if _, err := s.UserRepository.FindByID(userID); err != nil {
+		return err
+	}
+
+	if err := s.SessionRepository.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	if err := s.RefreshTokenRepository.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+
+	utils.Logger.Info("all sessions revoked",
+		zap.String("event", "session.revoked_all"),
+		zap.Uint("user_id", userID),
+	)
+	return nil
+}
+
+// truncate 将字符串截断到最多 n 个字节，并保证不会截断多字节字符。
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
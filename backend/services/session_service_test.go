@@ -0,0 +1,165 @@
+package services_test
+
+import (
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/services"
+	"go-web/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// testClient 是测试中登录使用的客户端信息。
+var testClient = services.ClientInfo{UserAgent: "go-test", IPAddress: "127.0.0.1"}
+
+// SessionServiceTestSuite 是一个测试套件，用于组织与登录会话相关的集成测试。
+type SessionServiceTestSuite struct {
+	suite.Suite
+	db           *gorm.DB
+	cfg          *config.Config
+	service      services.SessionServiceInterface
+	tokenService services.TokenServiceInterface
+	authService  services.AuthServiceInterface
+	sessionRepo  repositories.SessionRepository
+}
+
+// SetupSuite 在测试套件开始时运行，用于初始化数据库、配置和服务。
+func (suite *SessionServiceTestSuite) SetupSuite() {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // 吊销会话会记录审计日志
+
+	db, err := gorm.Open(sqlite.Open("file:sessions?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		suite.T().Fatalf("无法连接到内存数据库: %v", err)
+	}
+	suite.db = db
+
+	err = suite.db.AutoMigrate(&models.User{}, &models.Role{}, &models.RefreshToken{}, &models.TOTPCredential{}, &models.RecoveryCode{}, &models.LoginFailure{}, &models.PasswordHistory{}, &models.Session{})
+	if err != nil {
+		suite.T().Fatalf("数据库迁移失败: %v", err)
+	}
+
+	suite.cfg = &config.Config{
+		App: config.AppConfig{
+			DefaultRole: "user",
+		},
+		JWT: config.JWTConfig{
+			Secret:            "test-secret",
+			Expiration:        3600,
+			RefreshExpiration: 7200,
+		},
+	}
+
+	userRepo := repositories.NewGormUserRepository(suite.db)
+	roleRepo := repositories.NewGormRoleRepository(suite.db)
+	refreshTokenRepo := repositories.NewGormRefreshTokenRepository(suite.db)
+	suite.sessionRepo = repositories.NewGormSessionRepository(suite.db)
+	suite.tokenService = services.NewTokenService(suite.cfg, userRepo, refreshTokenRepo, repositories.NewMemoryRevokedTokenRepository(), suite.sessionRepo, suite.db)
+	suite.service = services.NewSessionService(userRepo, suite.sessionRepo, refreshTokenRepo)
+	mfaService := services.NewMFAService(suite.cfg, userRepo, repositories.NewGormTOTPCredentialRepository(suite.db), repositories.NewGormRecoveryCodeRepository(suite.db), repositories.NewGormMFAChallengeRepository(suite.db))
+	suite.authService = services.NewAuthService(suite.cfg, userRepo, roleRepo, suite.tokenService, services.NewEmailVerificationService(suite.cfg, userRepo, &recordingMailer{}), mfaService, services.NewLockoutService(suite.cfg, repositories.NewGormLoginFailureRepository(suite.db)), services.NewPasswordPolicyService(suite.cfg, repositories.NewGormPasswordHistoryRepository(suite.db)), suite.db)
+}
+
+// SetupTest 在每个测试方法运行之前被调用，清理数据并创建默认角色。
+func (suite *SessionServiceTestSuite) SetupTest() {
+	suite.db.Exec("DELETE FROM users")
+	suite.db.Exec("DELETE FROM roles")
+	suite.db.Exec("DELETE FROM refresh_tokens")
+	suite.db.Exec("DELETE FROM sessions")
+	suite.Require().NoError(suite.db.Create(&models.Role{Name: "user", Description: "普通用户"}).Error)
+}
+
+// TestSessionServiceTestSuite 运行测试套件。
+func TestSessionServiceTestSuite(t *testing.T) {
+	suite.Run(t, new(SessionServiceTestSuite))
+}
+
+// sessionID 从访问令牌中取出会话ID。
+func (suite *SessionServiceTestSuite) sessionID(tokens *services.TokenPair) uint {
+	claims, err := utils.ValidateToken(tokens.AccessToken, suite.cfg)
+	suite.Require().NoError(err)
+	suite.Require().NotZero(claims.SessionID)
+	return claims.SessionID
+}
+
+// TestLogin_CreatesSession 测试每次登录创建一个会话，刷新令牌轮换后仍属于同一个会话。
+func (suite *SessionServiceTestSuite) TestLogin_CreatesSession() {
+	user, registered, err := suite.authService.Register("alice", "alice@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	_, loggedIn, err := suite.authService.Login("alice", "password123", services.ClientInfo{UserAgent: "curl/8.0", IPAddress: "10.0.0.2"})
+	suite.Require().NoError(err)
+
+	sessions, err := suite.service.List(user.ID)
+	suite.Require().NoError(err)
+	suite.Require().Len(sessions, 2)
+	byID := map[uint]models.Session{sessions[0].ID: sessions[0], sessions[1].ID: sessions[1]}
+	assert.Equal(suite.T(), "go-test", byID[suite.sessionID(registered)].UserAgent)
+	assert.Equal(suite.T(), "10.0.0.2", byID[suite.sessionID(loggedIn)].IPAddress)
+
+	_, refreshed, err := suite.tokenService.Refresh(loggedIn.RefreshToken)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), suite.sessionID(loggedIn), suite.sessionID(refreshed))
+}
+
+// TestRevoke_InvalidatesSession 测试吊销会话后其刷新令牌失效，且不能吊销其他用户的会话。
+func (suite *SessionServiceTestSuite) TestRevoke_InvalidatesSession() {
+	alice, aliceTokens, err := suite.authService.Register("alice", "alice@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	bob, _, err := suite.authService.Register("bob", "bob@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	sessionID := suite.sessionID(aliceTokens)
+
+	assert.ErrorIs(suite.T(), suite.service.Revoke(bob.ID, sessionID), gorm.ErrRecordNotFound)
+
+	suite.Require().NoError(suite.service.Revoke(alice.ID, sessionID))
+	session, err := suite.sessionRepo.FindByID(sessionID)
+	suite.Require().NoError(err)
+	assert.NotNil(suite.T(), session.RevokedAt)
+
+	_, _, err = suite.tokenService.Refresh(aliceTokens.RefreshToken)
+	assert.Error(suite.T(), err)
+	sessions, err := suite.service.List(alice.ID)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), sessions)
+
+	// 已经吊销的会话不能再次吊销
+	assert.ErrorIs(suite.T(), suite.service.Revoke(alice.ID, sessionID), gorm.ErrRecordNotFound)
+}
+
+// TestRevokeAllForUser 测试管理员吊销用户的所有会话。
+func (suite *SessionServiceTestSuite) TestRevokeAllForUser() {
+	user, first, err := suite.authService.Register("alice", "alice@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	_, second, err := suite.authService.Login("alice", "password123", testClient)
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(suite.service.RevokeAllForUser(user.ID))
+
+	sessions, err := suite.service.List(user.ID)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), sessions)
+	for _, tokens := range []*services.TokenPair{first, second} {
+		_, _, err := suite.tokenService.Refresh(tokens.RefreshToken)
+		assert.Error(suite.T(), err)
+	}
+
+	assert.ErrorIs(suite.T(), suite.service.RevokeAllForUser(user.ID+100), gorm.ErrRecordNotFound)
+}
+
+// TestLogout_RevokesSession 测试登出时同时吊销当前会话。
+func (suite *SessionServiceTestSuite) TestLogout_RevokesSession() {
+	user, tokens, err := suite.authService.Register("alice", "alice@example.com", "password123", testClient)
+	suite.Require().NoError(err)
+	claims, err := utils.ValidateToken(tokens.AccessToken, suite.cfg)
+	suite.Require().NoError(err)
+
+	suite.Require().NoError(suite.authService.Logout(claims, tokens.RefreshToken))
+
+	sessions, err := suite.service.List(user.ID)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), sessions)
+}
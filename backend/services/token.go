@@ -0,0 +1,366 @@
+package services
+
+// package services 包含了应用程序的业务逻辑。
+// 这个文件负责访问令牌与刷新令牌的签发、轮换和吊销。
+
+import (
+	"context"
+	"errors"
+	"go-web/config"
+	"go-web/models"
+	"go-web/repositories"
+	"go-web/utils"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidRefreshToken 在刷新令牌不存在、已过期或已被吊销时返回。
+var ErrInvalidRefreshToken = errors.New("无效的刷新令牌")
+
+// ErrRefreshTokenReused 在一个已经轮换过的刷新令牌被再次使用时返回。
+// 这通常意味着令牌已经泄露，整个令牌族会被吊销。
+var ErrRefreshTokenReused = errors.New("刷新令牌已被使用，请重新登录")
+
+// refreshTokenBytes 是刷新令牌明文的随机字节数。
+const refreshTokenBytes = 32
+
+// TokenPair 是一次成功认证后返回给客户端的令牌组合。
+type TokenPair struct {
+	AccessToken  string // 短期有效的JWT访问令牌
+	RefreshToken string // 长期有效的不透明刷新令牌
+	ExpiresIn    int    // 访问令牌的有效期（以秒为单位）
+}
+
+// TokenServiceInterface 定义了令牌服务应实现的功能契约。
+type TokenServiceInterface interface {
+	// IssueTokens 为用户签发一对新的访问令牌和刷新令牌，开启一个新的令牌族和对应的登录会话。
+	IssueTokens(user *models.User, client ClientInfo) (*TokenPair, error)
+	// IssueTenantTokens 为组织成员签发以该组织为当前组织的令牌对，令牌中的角色是用户在组织内的角色。
+	// 用户不是组织成员时返回 ErrNotMember。
+	IssueTenantTokens(user *models.User, organizationID uint, client ClientInfo) (*TokenPair, error)
+	// Refresh 使用刷新令牌换取新的令牌对，并轮换刷新令牌。
+	Refresh(refreshToken string) (*models.User, *TokenPair, error)
+	// RevokeRefreshToken 吊销用户 userID 的刷新令牌所在的整个令牌族及其会话。
+	RevokeRefreshToken(userID uint, refreshToken string) error
+	// RevokeAllForUser 吊销用户所有的刷新令牌和会话。
+	RevokeAllForUser(userID uint) error
+	// RevokeAccessToken 将访问令牌加入吊销列表，直到它原本的过期时间。
+	RevokeAccessToken(jti string, expiresAt time.Time) error
+}
+
+// TokenService 提供了令牌相关的业务逻辑实现。
+type TokenService struct {
+	Config                 *config.Config
+	UserRepository         repositories.UserRepository
+	RefreshTokenRepository repositories.RefreshTokenRepository
+	RevokedTokenRepository repositories.RevokedTokenRepository
+	SessionRepository      repositories.SessionRepository
+	DB                     *gorm.DB // 用于签发和轮换时的事务
+}
+
+// NewTokenService 是 TokenService 的构造函数。
+func NewTokenService(cfg *config.Config, userRepo repositories.UserRepository, refreshTokenRepo repositories.RefreshTokenRepository, revokedTokenRepo repositories.RevokedTokenRepository, sessionRepo repositories.SessionRepository, db *gorm.DB) TokenServiceInterface {
+	return &TokenService{
+		Config:                 cfg,
+		UserRepository:         userRepo,
+		RefreshTokenRepository: refreshTokenRepo,
+		RevokedTokenRepository: revokedTokenRepo,
+		SessionRepository:      sessionRepo,
+		DB:                     db,
+	}
+}
+
+// IssueTokens 为用户签发一对新的令牌，并记录一个新的登录会话。
+// 调用方需要保证 user.Role 已经加载。
+func (s *TokenService) IssueTokens(user *models.User, client ClientInfo) (*TokenPair, error) {
+	return s.issue(user, nil, client)
+}
+
+// IssueTenantTokens 为组织成员签发以该组织为当前组织的令牌对，并记录一个新的登录会话。
+func (s *TokenService) IssueTenantTokens(user *models.User, organizationID uint, client ClientInfo) (*TokenPair, error) {
+	membership, err := repositories.NewGormMembershipRepository(s.DB).FindByOrganizationAndUser(organizationID, user.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotMember
+		}
+		return nil, err
+	}
+	return s.issue(user, membership, client)
+}
+
+// issue 开启一个新的令牌族和登录会话。membership 为 nil 时令牌不属于任何组织。
+func (s *TokenService) issue(user *models.User, membership *models.Membership, client ClientInfo) (*TokenPair, error) {
+	familyID, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	var refreshToken string
+	session := &models.Session{
+		UserID:     user.ID,
+		FamilyID:   familyID,
+		UserAgent:  truncate(client.UserAgent, maxUserAgentLength),
+		IPAddress:  client.IPAddress,
+		LastSeenAt: time.Now(),
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		plain, record, err := s.createRefreshToken(repositories.NewGormRefreshTokenRepository(tx), user.ID, familyID, tenantOf(membership))
+		if err != nil {
+			return err
+		}
+		refreshToken = plain
+		session.ExpiresAt = record.ExpiresAt
+		return repositories.NewGormSessionRepository(tx).Create(session)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newTokenPair(user, membership, refreshToken, session.ID)
+}
+
+// Refresh 使用刷新令牌换取新的令牌对。
+// 每次使用都会轮换刷新令牌：旧令牌被标记为已吊销，并签发同一令牌族中的新令牌。
+// 如果一个已经被轮换的令牌再次出现，说明它可能已被窃取，此时整个令牌族都会被吊销。
+func (s *TokenService) Refresh(refreshToken string) (*models.User, *TokenPair, error) {
+	// 1. 根据哈希查找刷新令牌
+	current, err := s.RefreshTokenRepository.FindByHash(utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+
+	// 2. 已吊销的令牌被再次使用，视为重放攻击
+	if current.RevokedAt != nil {
+		if err := s.revokeFamily(current.FamilyID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+
+	// 3. 检查是否过期
+	if time.Now().After(current.ExpiresAt) {
+		return nil, nil, ErrInvalidRefreshToken
+	}
+
+	// 4. 从主库加载用户及其角色，用户已被删除时令牌同样无效；只读副本的延迟不能让刚被删除或降级的用户继续刷新
+	user, err := s.UserRepository.Primary().FindByID(current.UserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrInvalidRefreshToken
+		}
+		return nil, nil, err
+	}
+
+	// 5. 令牌属于某个组织时，用户必须仍是该组织的成员，否则整个令牌族失效
+	var membership *models.Membership
+	if current.TenantID != 0 {
+		membership, err = repositories.NewGormMembershipRepository(s.DB).FindByOrganizationAndUser(current.TenantID, user.ID)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil, err
+			}
+			if err := s.revokeFamily(current.FamilyID); err != nil {
+				return nil, nil, err
+			}
+			return nil, nil, ErrInvalidRefreshToken
+		}
+	}
+
+	// 6. 查找令牌族对应的会话，升级之前签发的令牌族没有会话记录
+	var sessionID uint
+	session, err := s.SessionRepository.FindByFamilyID(current.FamilyID)
+	switch {
+	case err == nil:
+		sessionID = session.ID
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, nil, err
+	}
+
+	// 7. 在事务中签发新令牌并吊销旧令牌
+	tx := s.DB.Begin()
+	if tx.Error != nil {
+		return nil, nil, tx.Error
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	txRefreshTokenRepo := repositories.NewGormRefreshTokenRepository(tx)
+
+	newRefreshToken, newRecord, err := s.createRefreshToken(txRefreshTokenRepo, user.ID, current.FamilyID, current.TenantID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+
+	revoked, err := txRefreshTokenRepo.Revoke(current.ID, &newRecord.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, err
+	}
+	if !revoked {
+		// 另一个并发请求已经轮换了这个令牌，同样按重放处理
+		tx.Rollback()
+		if err := s.revokeFamily(current.FamilyID); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrRefreshTokenReused
+	}
+
+	if sessionID != 0 {
+		if err := repositories.NewGormSessionRepository(tx).Extend(sessionID, newRecord.ExpiresAt, time.Now()); err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, nil, err
+	}
+
+	tokens, err := s.newTokenPair(user, membership, newRefreshToken, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return user, tokens, nil
+}
+
+// RevokeRefreshToken 吊销刷新令牌所在的整个令牌族。
+// 未知的令牌以及属于其他用户的令牌都返回 ErrInvalidRefreshToken，不能用别人的令牌让其会话下线。
+func (s *TokenService) RevokeRefreshToken(userID uint, refreshToken string) error {
+	current, err := s.RefreshTokenRepository.FindByHash(utils.HashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+	if current.UserID != userID {
+		return ErrInvalidRefreshToken
+	}
+	return s.revokeFamily(current.FamilyID)
+}
+
+// RevokeAllForUser 吊销用户所有的刷新令牌和会话，会话中的访问令牌随之失效。
+func (s *TokenService) RevokeAllForUser(userID uint) error {
+	if err := s.RefreshTokenRepository.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	return s.SessionRepository.RevokeAllForUser(userID)
+}
+
+// revokeFamily 吊销令牌族中所有的刷新令牌以及对应的会话。
+func (s *TokenService) revokeFamily(familyID string) error {
+	if err := s.RefreshTokenRepository.RevokeFamily(familyID); err != nil {
+		return err
+	}
+	return s.SessionRepository.RevokeByFamilyID(familyID)
+}
+
+// RevokeAccessToken 将访问令牌加入吊销列表。
+// 已经过期的令牌本身就无法通过校验，不需要再记录。
+func (s *TokenService) RevokeAccessToken(jti string, expiresAt time.Time) error {
+	if jti == "" || time.Now().After(expiresAt) {
+		return nil
+	}
+	return s.RevokedTokenRepository.Revoke(jti, expiresAt)
+}
+
+// PurgeTokens 清理吊销列表中已经过期的记录，以及不再能够使用的刷新令牌：
+// 已经过期的，和吊销时间早于 refreshTTL 之前的。被轮换的令牌在一个有效期内保留，用于检测重放。
+func PurgeTokens(revokedRepo repositories.RevokedTokenRepository, refreshRepo repositories.RefreshTokenRepository, refreshTTL time.Duration) (revoked, refresh int64, err error) {
+	revoked, err = revokedRepo.PurgeExpired()
+	if err != nil {
+		return 0, 0, err
+	}
+	now := time.Now()
+	refresh, err = refreshRepo.PurgeStale(now, now.Add(-refreshTTL))
+	if err != nil {
+		return revoked, 0, err
+	}
+	return revoked, refresh, nil
+}
+
+// PurgeTokensPeriodically 按固定间隔执行 PurgeTokens，直到 ctx 被取消。
+// 它会阻塞当前协程，通常以 go 语句启动。
+func PurgeTokensPeriodically(ctx context.Context, revokedRepo repositories.RevokedTokenRepository, refreshRepo repositories.RefreshTokenRepository, refreshTTL, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			revoked, refresh, err := PurgeTokens(revokedRepo, refreshRepo, refreshTTL)
+			if err != nil {
+				utils.Logger.Error("failed to purge tokens", zap.Error(err))
+				continue
+			}
+			if revoked > 0 || refresh > 0 {
+				utils.Logger.Info("purged expired tokens", zap.Int64("revoked_tokens", revoked), zap.Int64("refresh_tokens", refresh))
+			}
+		}
+	}
+}
+
+// createRefreshToken 生成刷新令牌明文，并通过给定的仓库保存其哈希。
+func (s *TokenService) createRefreshToken(repo repositories.RefreshTokenRepository, userID uint, familyID string, tenantID uint) (string, *models.RefreshToken, error) {
+	plain, err := utils.GenerateRandomToken(refreshTokenBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	record := &models.RefreshToken{
+		UserID:    userID,
+		TokenHash: utils.HashToken(plain),
+		FamilyID:  familyID,
+		TenantID:  tenantID,
+		ExpiresAt: time.Now().Add(time.Duration(s.Config.JWT.RefreshExpiration) * time.Second),
+	}
+	if err := repo.Create(record); err != nil {
+		return "", nil, err
+	}
+
+	return plain, record, nil
+}
+
+// newTokenPair 为用户生成访问令牌，并与刷新令牌组合返回。
+// membership 不为 nil 时，令牌携带该组织和用户在组织内的角色。
+// restrict 模式下，未验证邮箱的用户的访问令牌只携带受限角色；验证后刷新令牌即可获得原本的角色。
+func (s *TokenService) newTokenPair(user *models.User, membership *models.Membership, refreshToken string, sessionID uint) (*TokenPair, error) {
+	role := user.Role.Name
+	if membership != nil {
+		role = membership.Role.Name
+	}
+	if s.Config.Auth.EmailVerification == config.EmailVerificationRestrict && !user.IsEmailVerified() {
+		role = s.Config.Auth.UnverifiedRole
+	}
+
+	accessToken, err := utils.GenerateTenantToken(user.ID, role, sessionID, tenantOf(membership), s.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    s.Config.JWT.Expiration,
+	}, nil
+}
+
+// tenantOf 返回成员关系所属的组织ID，membership 为 nil 时返回0。
+func tenantOf(membership *models.Membership) uint {
+	if membership == nil {
+		return 0
+	}
+	return membership.OrganizationID
+}
@@ -4,40 +4,134 @@ package services
 // 这个文件特别关注与用户管理相关的功能。
 
 import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"go-web/models"
 	"go-web/repositories"
+	"go-web/utils"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrPermissionDenied 在用户尝试执行未授权的操作时返回。
+	ErrPermissionDenied = errors.New("权限不足")
+	// ErrInvalidUserQuery 在用户列表的排序字段不受支持时返回。
+	ErrInvalidUserQuery = errors.New("用户查询参数无效")
+	// ErrInvalidCursor 在分页游标无法解析，或与当前的排序方式不一致时返回。
+	ErrInvalidCursor = errors.New("分页游标无效")
+)
+
+// 用户列表每页的数量。
+const (
+	DefaultUserPageSize = 20
+	MaxUserPageSize     = 100
 )
 
-// ErrPermissionDenied 在用户尝试执行未授权的操作时返回。
-var ErrPermissionDenied = errors.New("权限不足")
+// UserListOptions 是查询用户列表的参数，筛选条件为零值时不筛选。
+type UserListOptions struct {
+	Page   int    // 从1开始的页码，Cursor 不为空时忽略
+	Size   int    // 每页的数量，默认为 DefaultUserPageSize，最大为 MaxUserPageSize
+	Cursor string // 上一页返回的 NextCursor，用于键集分页
+	Sort   string // 排序字段，前缀 "-" 表示倒序，如 "-created_at"，默认按ID排序
+
+	Role          string
+	Search        string // 用户名或邮箱中包含的子串
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+}
+
+// UserList 是一页用户。
+type UserList struct {
+	Users      []models.User
+	Total      int64  // 符合筛选条件的用户总数
+	NextCursor string // 下一页的游标，为空表示没有更多用户
+}
 
 // UserServiceInterface 定义了用户服务应实现的功能契约。
 type UserServiceInterface interface {
-	// GetUsers 获取所有用户的列表。
-	GetUsers() ([]models.User, error)
+	// GetUsers 按条件分页获取用户列表。
+	GetUsers(opts UserListOptions) (*UserList, error)
 	// GetUser 根据ID获取单个用户的详细信息。
 	GetUser(id uint) (*models.User, error)
 	// UpdateUser 更新指定ID的用户信息。
-	UpdateUser(targetUserID, currentUserID uint, currentUserRole string, updateUser *models.User) (*models.User, error)
+	UpdateUser(targetUserID uint, subject Subject, updateUser *models.User) (*models.User, error)
 	// DeleteUser 删除指定ID的用户。
-	DeleteUser(id uint) error
+	DeleteUser(targetUserID uint, subject Subject) error
+	// GetDeletedUsers 获取已删除但尚未清除的用户，最近删除的在前。
+	GetDeletedUsers() ([]models.User, error)
+	// RestoreUser 恢复一个已删除的用户。
+	RestoreUser(id, adminID uint) (*models.User, error)
+	// PurgeUser 彻底清除一个已删除的用户及其数据，无法恢复。
+	PurgeUser(id, adminID uint) error
 }
 
 // UserService 提供了用户管理相关的业务逻辑实现。
-// 它依赖于用户仓库。
+// 它依赖于用户仓库和授权检查。
 type UserService struct {
 	UserRepository repositories.UserRepository
+	Authorizer     Authorizer
 }
 
 // NewUserService 是 UserService 的构造函数。
-func NewUserService(userRepo repositories.UserRepository) UserServiceInterface {
-	return &UserService{UserRepository: userRepo}
+func NewUserService(userRepo repositories.UserRepository, authorizer Authorizer) UserServiceInterface {
+	return &UserService{UserRepository: userRepo, Authorizer: authorizer}
 }
 
-// GetUsers 获取所有用户的列表。
-func (s *UserService) GetUsers() ([]models.User, error) {
-	return s.UserRepository.FindAll()
+// GetUsers 按条件分页获取用户列表。
+// 可以使用页码翻页，也可以使用上一页返回的游标翻页；游标不受翻页期间新增或删除用户的影响，适合较大的偏移。
+func (s *UserService) GetUsers(opts UserListOptions) (*UserList, error) {
+	sortField, descending := strings.TrimPrefix(opts.Sort, "-"), strings.HasPrefix(opts.Sort, "-")
+	if sortField == "" {
+		sortField = "id"
+	}
+	if !isUserSortField(sortField) {
+		return nil, fmt.Errorf("%w: 不支持按 %s 排序", ErrInvalidUserQuery, sortField)
+	}
+	size := opts.Size
+	if size <= 0 {
+		size = DefaultUserPageSize
+	} else if size > MaxUserPageSize {
+		size = MaxUserPageSize
+	}
+
+	// 多查询一个用户，用于判断是否还有下一页
+	query := repositories.UserQuery{
+		Role:          opts.Role,
+		Search:        opts.Search,
+		CreatedAfter:  opts.CreatedAfter,
+		CreatedBefore: opts.CreatedBefore,
+		SortField:     sortField,
+		Descending:    descending,
+		Limit:         size + 1,
+	}
+	sort := userSort(sortField, descending)
+	if opts.Cursor != "" {
+		cursor, err := decodeUserCursor(opts.Cursor, sort)
+		if err != nil {
+			return nil, err
+		}
+		query.After = cursor
+	} else if opts.Page > 1 {
+		query.Offset = (opts.Page - 1) * size
+	}
+
+	users, total, err := s.UserRepository.List(query)
+	if err != nil {
+		return nil, err
+	}
+	list := &UserList{Users: users, Total: total}
+	if len(users) > size {
+		list.Users = users[:size]
+		list.NextCursor = encodeUserCursor(repositories.NewUserCursor(&users[size-1], sortField), sort)
+	}
+	return list, nil
 }
 
 // GetUser 获取单个用户的详细信息。
@@ -45,15 +139,15 @@ func (s *UserService) GetUser(id uint) (*models.User, error) {
 	return s.UserRepository.FindByID(id)
 }
 
-// UpdateUser 更新用户信息。
-// 它包含了权限检查逻辑：
+// UpdateUser 以 subject 的身份更新用户信息。
+// 权限由 Authorizer 检查，默认规则下：
 // - 用户可以更新自己的信息。
-// - 管理员（admin）可以更新任何人的信息。
-// - 只有管理员可以更改用户的角色。
-func (s *UserService) UpdateUser(targetUserID, currentUserID uint, currentUserRole string, updateUser *models.User) (*models.User, error) {
-	// 权限检查：如果目标用户不是当前用户，并且当前用户不是管理员，则拒绝访问。
-	if targetUserID != currentUserID && currentUserRole != "admin" {
-		return nil, ErrPermissionDenied
+// - 管理员（全局域中的 admin）可以更新任何人的信息。
+// - 只有管理员可以更改用户的角色，其他人提交的角色会被忽略。
+func (s *UserService) UpdateUser(targetUserID uint, subject Subject, updateUser *models.User) (*models.User, error) {
+	resource := Resource{Type: ResourceUser, OwnerID: targetUserID}
+	if err := s.Authorizer.Authorize(subject, ActionUpdate, resource); err != nil {
+		return nil, err
 	}
 
 	// 从数据库获取最新的用户信息
@@ -66,13 +160,19 @@ func (s *UserService) UpdateUser(targetUserID, currentUserID uint, currentUserRo
 	if updateUser.Username != "" {
 		user.Username = updateUser.Username
 	}
-	if updateUser.Email != "" {
+	if updateUser.Email != "" && updateUser.Email != user.Email {
+		// 新邮箱需要重新验证
 		user.Email = updateUser.Email
+		user.EmailVerifiedAt = nil
 	}
 	if updateUser.RoleID != 0 {
-		// 只有管理员可以修改角色ID
-		if currentUserRole == "admin" {
+		// 只有被授权的用户可以修改角色ID
+		err := s.Authorizer.Authorize(subject, ActionChangeRole, resource)
+		switch {
+		case err == nil:
 			user.RoleID = updateUser.RoleID
+		case !errors.Is(err, ErrPermissionDenied):
+			return nil, err
 		}
 	}
 
@@ -81,18 +181,22 @@ func (s *UserService) UpdateUser(targetUserID, currentUserID uint, currentUserRo
 		return nil, err
 	}
 
-	// 重新加载用户的角色信息，以确保返回的数据是完整的
-	if err := s.UserRepository.LoadRole(user); err != nil {
+	// 重新加载用户的角色信息，以确保返回的数据是完整的；新角色可能刚刚创建，需要从主库读取
+	if err := s.UserRepository.Primary().LoadRole(user); err != nil {
 		return nil, err
 	}
 
 	return user, nil
 }
 
-// DeleteUser 删除一个用户。
-func (s *UserService) DeleteUser(id uint) error {
+// DeleteUser 以 subject 的身份删除一个用户。权限由 Authorizer 检查，默认规则下用户只能删除自己，管理员可以删除任何人。
+func (s *UserService) DeleteUser(targetUserID uint, subject Subject) error {
+	if err := s.Authorizer.Authorize(subject, ActionDelete, Resource{Type: ResourceUser, OwnerID: targetUserID}); err != nil {
+		return err
+	}
+
 	// 首先需要根据ID找到对应的用户实体
-	user, err := s.UserRepository.FindByID(id)
+	user, err := s.UserRepository.FindByID(targetUserID)
 	if err != nil {
 		return err
 	}
@@ -100,3 +204,130 @@ func (s *UserService) DeleteUser(id uint) error {
 	// 然后删除该用户
 	return s.UserRepository.Delete(user)
 }
+
+// GetDeletedUsers 获取已删除但尚未清除的用户。
+func (s *UserService) GetDeletedUsers() ([]models.User, error) {
+	return s.UserRepository.FindDeleted()
+}
+
+// RestoreUser 恢复一个已删除的用户。用户不存在或没有被删除时返回 gorm.ErrRecordNotFound；
+// 删除之后用户名或邮箱已经被其他用户使用时返回 UserExistsError。
+func (s *UserService) RestoreUser(id, adminID uint) (*models.User, error) {
+	if err := s.UserRepository.Restore(id); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, &UserExistsError{}
+		}
+		return nil, err
+	}
+	utils.Logger.Info("user restored",
+		zap.String("event", "user.restored"),
+		zap.Uint("user_id", id),
+		zap.Uint("admin_id", adminID),
+	)
+	return s.UserRepository.Primary().FindByID(id)
+}
+
+// PurgeUser 彻底清除一个已删除的用户。用户必须先被删除，不存在或没有被删除时返回 gorm.ErrRecordNotFound。
+func (s *UserService) PurgeUser(id, adminID uint) error {
+	if _, err := s.UserRepository.FindDeletedByID(id); err != nil {
+		return err
+	}
+	if err := s.UserRepository.Purge(id); err != nil {
+		return err
+	}
+	utils.Logger.Info("user purged",
+		zap.String("event", "user.purged"),
+		zap.Uint("user_id", id),
+		zap.Uint("admin_id", adminID),
+	)
+	return nil
+}
+
+// PurgeDeletedUsersPeriodically 按固定间隔彻底清除删除时间超过 retention 的用户，直到 ctx 被取消。
+func PurgeDeletedUsersPeriodically(ctx context.Context, repo repositories.UserRepository, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := PurgeDeletedUsers(repo, time.Now().Add(-retention))
+			if err != nil {
+				utils.Logger.Error("failed to purge deleted users", zap.Error(err))
+			}
+			if purged > 0 {
+				utils.Logger.Info("purged deleted users", zap.Int("count", purged))
+			}
+		}
+	}
+}
+
+// PurgeDeletedUsers 彻底清除在 cutoff 之前删除的用户，返回清除的数量。
+// 每个用户在单独的事务中清除，出错时返回已经清除的数量和错误。
+func PurgeDeletedUsers(repo repositories.UserRepository, cutoff time.Time) (int, error) {
+	ids, err := repo.FindDeletedBefore(cutoff)
+	if err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if err := repo.Purge(id); err != nil {
+			return i, err
+		}
+		utils.Logger.Info("user purged",
+			zap.String("event", "user.purged"),
+			zap.Uint("user_id", id),
+			zap.String("reason", "retention"),
+		)
+	}
+	return len(ids), nil
+}
+
+// userCursor 是分页游标的内容。游标记录了排序方式，换用其他排序方式时不能继续使用。
+type userCursor struct {
+	Sort string `json:"s"`
+	repositories.UserCursor
+}
+
+// encodeUserCursor 将列表中的位置编码为不透明的游标。
+func encodeUserCursor(cursor repositories.UserCursor, sort string) string {
+	data, _ := json.Marshal(userCursor{Sort: sort, UserCursor: cursor})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeUserCursor 解析游标，游标的排序方式必须与 sort 相同。
+func decodeUserCursor(encoded, sort string) (*repositories.UserCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor userCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Sort != sort || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	if strings.TrimPrefix(sort, "-") == "created_at" {
+		if _, err := time.Parse(time.RFC3339Nano, cursor.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &cursor.UserCursor, nil
+}
+
+// userSort 返回排序方式的规范形式，如 "-created_at"。
+func userSort(field string, descending bool) string {
+	if descending {
+		return "-" + field
+	}
+	return field
+}
+
+// isUserSortField 检查 field 是否是可以排序的字段。
+func isUserSortField(field string) bool {
+	for _, f := range repositories.UserSortFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
@@ -3,43 +3,118 @@ package services
 // package services_test 包含了对services包的单元测试。
 
 import (
+	"errors"
+	"go-web/config"
 	"go-web/mocks"
 	"go-web/models"
+	"go-web/repositories"
+	"go-web/tenant"
+	"go-web/utils"
 	"testing"
+	"time"
 
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"gorm.io/gorm"
 )
 
+// newTestEnforcer 创建一个使用默认模型和默认属性授权规则的内存执行器。
+func newTestEnforcer(t *testing.T) *casbin.SyncedEnforcer {
+	m, err := model.NewModelFromString(config.DefaultCasbinModel)
+	require.NoError(t, err)
+	e, err := casbin.NewSyncedEnforcer(m)
+	require.NoError(t, err)
+	tenant.ConfigureEnforcer(e.Enforcer)
+	_, err = e.AddNamedPolicies("p2", DefaultAuthorizationRules)
+	require.NoError(t, err)
+	return e
+}
+
+// newTestAuthorizer 创建一个使用默认属性授权规则的 Authorizer。
+func newTestAuthorizer(t *testing.T) Authorizer {
+	return NewCasbinAuthorizer(newTestEnforcer(t))
+}
+
 // TestGetUsers_Success 测试成功获取用户列表的场景。
 func TestGetUsers_Success(t *testing.T) {
 	// 1. 准备阶段
 	mockUserRepo := new(mocks.MockUserRepository)
-	userService := NewUserService(mockUserRepo)
+	userService := NewUserService(mockUserRepo, newTestAuthorizer(t))
 
-	// 2. 定义模拟期望
+	// 2. 定义模拟期望：默认每页20个并按ID排序，多查询一个用户判断是否有下一页
 	mockedUsers := []models.User{
 		{Model: gorm.Model{ID: 1}, Username: "user1"},
 		{Model: gorm.Model{ID: 2}, Username: "user2"},
 	}
-	mockUserRepo.On("FindAll").Return(mockedUsers, nil)
+	mockUserRepo.On("List", repositories.UserQuery{SortField: "id", Limit: DefaultUserPageSize + 1}).Return(mockedUsers, int64(2), nil)
 
 	// 3. 执行阶段
-	users, err := userService.GetUsers()
+	list, err := userService.GetUsers(UserListOptions{})
 
 	// 4. 断言阶段
 	assert.NoError(t, err)
-	assert.NotNil(t, users)
-	assert.Len(t, users, 2)
-	assert.Equal(t, "user1", users[0].Username)
+	assert.Len(t, list.Users, 2)
+	assert.Equal(t, "user1", list.Users[0].Username)
+	assert.Equal(t, int64(2), list.Total)
+	assert.Empty(t, list.NextCursor)
 	mockUserRepo.AssertExpectations(t)
 }
 
+// TestGetUsers_Pagination 测试页码转换为偏移量，还有更多用户时返回可以继续翻页的游标。
+func TestGetUsers_Pagination(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, newTestAuthorizer(t))
+
+	mockedUsers := []models.User{
+		{Model: gorm.Model{ID: 7}, Username: "zoe"},
+		{Model: gorm.Model{ID: 3}, Username: "yan"},
+		{Model: gorm.Model{ID: 9}, Username: "xia"},
+	}
+	mockUserRepo.On("List", repositories.UserQuery{Role: "user", SortField: "username", Descending: true, Limit: 3, Offset: 4}).Return(mockedUsers, int64(10), nil)
+
+	list, err := userService.GetUsers(UserListOptions{Page: 3, Size: 2, Sort: "-username", Role: "user"})
+	require.NoError(t, err)
+	assert.Len(t, list.Users, 2)
+	require.NotEmpty(t, list.NextCursor)
+
+	// 游标记录了第二页最后一个用户的位置
+	mockUserRepo.On("List", repositories.UserQuery{SortField: "username", Descending: true, Limit: 3, After: &repositories.UserCursor{Value: "yan", ID: 3}}).Return(mockedUsers[2:], int64(10), nil)
+	list, err = userService.GetUsers(UserListOptions{Size: 2, Sort: "-username", Cursor: list.NextCursor})
+	require.NoError(t, err)
+	assert.Len(t, list.Users, 1)
+	assert.Empty(t, list.NextCursor)
+	mockUserRepo.AssertExpectations(t)
+}
+
+// TestGetUsers_InvalidQuery 测试不支持的排序字段、无法解析或排序方式不同的游标被拒绝。
+func TestGetUsers_InvalidQuery(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, newTestAuthorizer(t))
+
+	_, err := userService.GetUsers(UserListOptions{Sort: "password"})
+	assert.ErrorIs(t, err, ErrInvalidUserQuery)
+
+	_, err = userService.GetUsers(UserListOptions{Cursor: "not a cursor"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	cursor := encodeUserCursor(repositories.UserCursor{Value: "alice", ID: 1}, "username")
+	_, err = userService.GetUsers(UserListOptions{Sort: "-username", Cursor: cursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	cursor = encodeUserCursor(repositories.UserCursor{Value: "yesterday", ID: 1}, "created_at")
+	_, err = userService.GetUsers(UserListOptions{Sort: "created_at", Cursor: cursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	mockUserRepo.AssertNotCalled(t, "List", mock.Anything)
+}
+
 // TestGetUser_Success 测试成功获取单个用户信息的场景。
 func TestGetUser_Success(t *testing.T) {
 	// 1. 准备阶段
 	mockUserRepo := new(mocks.MockUserRepository)
-	userService := NewUserService(mockUserRepo)
+	userService := NewUserService(mockUserRepo, newTestAuthorizer(t))
 
 	userID := uint(1)
 
@@ -61,7 +136,7 @@ func TestGetUser_Success(t *testing.T) {
 func TestUpdateUser_Success_AsAdmin(t *testing.T) {
 	// 1. 准备阶段
 	mockUserRepo := new(mocks.MockUserRepository)
-	userService := NewUserService(mockUserRepo)
+	userService := NewUserService(mockUserRepo, newTestAuthorizer(t))
 
 	targetUserID := uint(2)
 	adminUserID := uint(1)
@@ -86,7 +161,7 @@ func TestUpdateUser_Success_AsAdmin(t *testing.T) {
 	mockUserRepo.On("LoadRole", originalUser).Return(nil)
 
 	// 3. 执行阶段
-	updatedUser, err := userService.UpdateUser(targetUserID, adminUserID, adminUserRole, updateData)
+	updatedUser, err := userService.UpdateUser(targetUserID, Subject{ID: adminUserID, Role: adminUserRole, Domain: tenant.GlobalDomain}, updateData)
 
 	// 4. 断言阶段
 	assert.NoError(t, err)
@@ -101,7 +176,7 @@ func TestUpdateUser_Success_AsAdmin(t *testing.T) {
 func TestUpdateUser_PermissionDenied(t *testing.T) {
 	// 1. 准备阶段
 	mockUserRepo := new(mocks.MockUserRepository)
-	userService := NewUserService(mockUserRepo)
+	userService := NewUserService(mockUserRepo, newTestAuthorizer(t))
 
 	targetUserID := uint(2)
 	requestingUserID := uint(3)  // 一个不同的用户ID
@@ -110,7 +185,7 @@ func TestUpdateUser_PermissionDenied(t *testing.T) {
 	updateData := &models.User{Username: "new_name"}
 
 	// 2. 执行阶段
-	updatedUser, err := userService.UpdateUser(targetUserID, requestingUserID, requestingUserRole, updateData)
+	updatedUser, err := userService.UpdateUser(targetUserID, Subject{ID: requestingUserID, Role: requestingUserRole, Domain: tenant.GlobalDomain}, updateData)
 
 	// 3. 断言阶段
 	assert.Error(t, err)                      // 期望有错误发生
@@ -126,7 +201,7 @@ func TestUpdateUser_PermissionDenied(t *testing.T) {
 func TestDeleteUser_Success(t *testing.T) {
 	// 1. 准备阶段
 	mockUserRepo := new(mocks.MockUserRepository)
-	userService := NewUserService(mockUserRepo)
+	userService := NewUserService(mockUserRepo, newTestAuthorizer(t))
 
 	userID := uint(1)
 	userToDelete := &models.User{Model: gorm.Model{ID: userID}}
@@ -136,9 +211,136 @@ func TestDeleteUser_Success(t *testing.T) {
 	mockUserRepo.On("Delete", userToDelete).Return(nil)
 
 	// 3. 执行阶段
-	err := userService.DeleteUser(userID)
+	err := userService.DeleteUser(userID, Subject{ID: 99, Role: "admin", Domain: tenant.GlobalDomain})
 
 	// 4. 断言阶段
 	assert.NoError(t, err)
 	mockUserRepo.AssertExpectations(t)
 }
+
+// TestUpdateUser_OwnerCannotChangeRole 测试用户可以更新自己的信息，但提交的角色会被忽略。
+func TestUpdateUser_OwnerCannotChangeRole(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, newTestAuthorizer(t))
+
+	userID := uint(2)
+	originalUser := &models.User{Model: gorm.Model{ID: userID}, Username: "original_username", RoleID: 2}
+	mockUserRepo.On("FindByID", userID).Return(originalUser, nil)
+	mockUserRepo.On("Update", originalUser).Return(nil)
+	mockUserRepo.On("LoadRole", originalUser).Return(nil)
+
+	updatedUser, err := userService.UpdateUser(userID, Subject{ID: userID, Role: "user", Domain: tenant.GlobalDomain}, &models.User{Username: "renamed", RoleID: 1})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", updatedUser.Username)
+	assert.Equal(t, uint(2), updatedUser.RoleID)
+	mockUserRepo.AssertExpectations(t)
+}
+
+// TestUpdateUser_InheritedRule 测试规则对继承了 user 的角色同样生效，并且可以通过添加规则放宽限制。
+func TestUpdateUser_InheritedRule(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	enforcer := newTestEnforcer(t)
+	_, err := enforcer.AddGroupingPolicy("moderator", "user", tenant.AllDomains)
+	require.NoError(t, err)
+	userService := NewUserService(mockUserRepo, NewCasbinAuthorizer(enforcer))
+
+	_, err = userService.UpdateUser(2, Subject{ID: 3, Role: "moderator", Domain: tenant.GlobalDomain}, &models.User{Username: "new_name"})
+	assert.Equal(t, ErrPermissionDenied, err)
+
+	// 允许版主修改任何用户
+	_, err = enforcer.AddNamedPolicy("p2", "moderator", tenant.AllDomains, ResourceUser, ActionUpdate, "true")
+	require.NoError(t, err)
+	targetUser := &models.User{Model: gorm.Model{ID: 2}, Username: "original_username"}
+	mockUserRepo.On("FindByID", uint(2)).Return(targetUser, nil)
+	mockUserRepo.On("Update", targetUser).Return(nil)
+	mockUserRepo.On("LoadRole", targetUser).Return(nil)
+
+	updatedUser, err := userService.UpdateUser(2, Subject{ID: 3, Role: "moderator", Domain: tenant.GlobalDomain}, &models.User{Username: "new_name"})
+	assert.NoError(t, err)
+	assert.Equal(t, "new_name", updatedUser.Username)
+}
+
+// TestDeleteUser_PermissionDenied 测试普通用户不能删除其他用户。
+func TestDeleteUser_PermissionDenied(t *testing.T) {
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, newTestAuthorizer(t))
+
+	err := userService.DeleteUser(uint(2), Subject{ID: 3, Role: "user", Domain: tenant.GlobalDomain})
+
+	assert.Equal(t, ErrPermissionDenied, err)
+	mockUserRepo.AssertNotCalled(t, "FindByID")
+	mockUserRepo.AssertNotCalled(t, "Delete")
+}
+
+// TestAuthorize_AdminRulesOnlyInGlobalDomain 测试组织中的 admin 成员角色不能使用全局管理员的规则，
+// 但普通用户的规则在所有域中生效。
+func TestAuthorize_AdminRulesOnlyInGlobalDomain(t *testing.T) {
+	authorizer := newTestAuthorizer(t)
+	other := Resource{Type: ResourceUser, OwnerID: 2}
+	own := Resource{Type: ResourceUser, OwnerID: 3}
+
+	assert.NoError(t, authorizer.Authorize(Subject{ID: 3, Role: "admin", Domain: tenant.GlobalDomain}, ActionDelete, other))
+	for _, action := range []string{ActionUpdate, ActionDelete, ActionChangeRole} {
+		err := authorizer.Authorize(Subject{ID: 3, Role: "admin", Domain: tenant.Domain(1)}, action, other)
+		assert.ErrorIs(t, err, ErrPermissionDenied, action)
+	}
+	assert.NoError(t, authorizer.Authorize(Subject{ID: 3, Role: "user", Domain: tenant.Domain(1)}, ActionUpdate, own))
+}
+
+// TestRestoreUser 测试恢复已删除的用户，用户名或邮箱已被其他用户使用时拒绝恢复。
+func TestRestoreUser(t *testing.T) {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // 恢复用户会记录审计日志
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, newTestAuthorizer(t))
+
+	deleted := &models.User{Model: gorm.Model{ID: 5}, Username: "alice", Email: "alice@example.com"}
+	mockUserRepo.On("Restore", uint(4)).Return(gorm.ErrRecordNotFound)
+	mockUserRepo.On("Restore", uint(5)).Return(gorm.ErrDuplicatedKey).Once()
+
+	_, err := userService.RestoreUser(4, 1)
+	assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	_, err = userService.RestoreUser(5, 1)
+	assert.IsType(t, &UserExistsError{}, err)
+	mockUserRepo.AssertNotCalled(t, "FindByID", mock.Anything)
+
+	mockUserRepo.On("Restore", uint(5)).Return(nil)
+	mockUserRepo.On("FindByID", uint(5)).Return(deleted, nil)
+
+	user, err := userService.RestoreUser(5, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+	mockUserRepo.AssertExpectations(t)
+}
+
+// TestPurgeUser_RequiresDeletedUser 测试只能彻底清除已删除的用户。
+func TestPurgeUser_RequiresDeletedUser(t *testing.T) {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // 清除用户会记录审计日志
+	mockUserRepo := new(mocks.MockUserRepository)
+	userService := NewUserService(mockUserRepo, newTestAuthorizer(t))
+
+	mockUserRepo.On("FindDeletedByID", uint(2)).Return(nil, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, userService.PurgeUser(2, 1), gorm.ErrRecordNotFound)
+	mockUserRepo.AssertNotCalled(t, "Purge", mock.Anything)
+
+	mockUserRepo.On("FindDeletedByID", uint(3)).Return(&models.User{Model: gorm.Model{ID: 3}}, nil)
+	mockUserRepo.On("Purge", uint(3)).Return(nil)
+	assert.NoError(t, userService.PurgeUser(3, 1))
+	mockUserRepo.AssertExpectations(t)
+}
+
+// TestPurgeDeletedUsers 测试清除超过保留期限的用户，出错时返回已经清除的数量。
+func TestPurgeDeletedUsers(t *testing.T) {
+	utils.InitLogger("debug", "", 100, 3, 7, false) // 清除用户会记录审计日志
+	mockUserRepo := new(mocks.MockUserRepository)
+	cutoff := time.Now().Add(-30 * 24 * time.Hour)
+
+	mockUserRepo.On("FindDeletedBefore", cutoff).Return([]uint{4, 6, 9}, nil)
+	mockUserRepo.On("Purge", uint(4)).Return(nil)
+	mockUserRepo.On("Purge", uint(6)).Return(errors.New("connection reset"))
+
+	purged, err := PurgeDeletedUsers(mockUserRepo, cutoff)
+	assert.Error(t, err)
+	assert.Equal(t, 1, purged)
+	mockUserRepo.AssertNotCalled(t, "Purge", uint(9))
+}
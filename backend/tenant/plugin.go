@@ -0,0 +1,99 @@
+package tenant
+
+import (
+	"go-web/models"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// organizationField 是属于组织的模型中保存组织ID的字段。
+const organizationField = "OrganizationID"
+
+// Plugin 是一个GORM插件，对实现了 models.TenantOwned 的模型：
+//   - 查询、更新和删除时自动追加 organization_id = 当前组织 的条件；
+//   - 创建时自动填充当前组织ID。
+//
+// 当前组织通过 db.WithContext(NewContext(ctx, id)) 传入。上下文中没有组织时语句以 ErrNoTenant 失败，
+// 需要跨组织访问时必须使用 db.WithContext(Unscoped(ctx)) 明确取消限定。
+// 没有实现 models.TenantOwned 的模型（用户、角色、会话等）不受影响，哪些数据属于组织见 models.TenantOwned。
+type Plugin struct{}
+
+// Name 实现了 gorm.Plugin 接口。
+func (Plugin) Name() string {
+	return "tenant"
+}
+
+// Initialize 实现了 gorm.Plugin 接口，注册回调。
+func (Plugin) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Query().Before("gorm:query").Register("tenant:scope_query", scope); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("tenant:scope_row", scope); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("tenant:scope_update", scope); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("tenant:scope_delete", scope); err != nil {
+		return err
+	}
+	return callbacks.Create().Before("gorm:create").Register("tenant:assign", assign)
+}
+
+// tenantField 返回语句对应模型的组织ID字段，模型不属于组织或上下文取消了组织限定时返回 nil。
+// 模型属于组织但上下文中没有组织时，语句被标记为 ErrNoTenant 错误，不会被执行。
+func tenantField(db *gorm.DB) (*schema.Field, uint) {
+	if db.Statement.Schema == nil {
+		return nil, 0
+	}
+	if _, owned := reflect.New(db.Statement.Schema.ModelType).Interface().(models.TenantOwned); !owned {
+		return nil, 0
+	}
+	if IsUnscoped(db.Statement.Context) {
+		return nil, 0
+	}
+	organizationID, ok := FromContext(db.Statement.Context)
+	if !ok {
+		_ = db.AddError(ErrNoTenant)
+		return nil, 0
+	}
+	return db.Statement.Schema.LookUpField(organizationField), organizationID
+}
+
+// scope 追加当前组织的查询条件。
+func scope(db *gorm.DB) {
+	field, organizationID := tenantField(db)
+	if field == nil {
+		return
+	}
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: organizationID},
+	}})
+}
+
+// assign 在创建记录时填充当前组织ID，支持单条和批量创建。
+func assign(db *gorm.DB) {
+	field, organizationID := tenantField(db)
+	if field == nil {
+		return
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := field.Set(db.Statement.Context, reflect.Indirect(rv.Index(i)), organizationID); err != nil {
+				_ = db.AddError(err)
+				return
+			}
+		}
+	case reflect.Struct:
+		if err := field.Set(db.Statement.Context, rv, organizationID); err != nil {
+			_ = db.AddError(err)
+		}
+	}
+}
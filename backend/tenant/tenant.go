@@ -0,0 +1,86 @@
+package tenant
+
+// package tenant 提供了多租户支持：在请求上下文中传递当前组织、
+// 为Casbin生成组织对应的域，以及自动将查询限定到当前组织的GORM插件。
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/util"
+)
+
+// Casbin中的域。
+const (
+	// GlobalDomain 是没有选择组织时使用的域。
+	GlobalDomain = "global"
+	// AllDomains 匹配所有域，包括 GlobalDomain。
+	AllDomains = "*"
+	// domainPrefix 是组织域的前缀，组织域形如 "org:42"，策略中可以使用 "org:*" 匹配所有组织。
+	domainPrefix = "org:"
+)
+
+// ErrNoTenant 在上下文中既没有当前组织、也没有通过 Unscoped 明确跨组织时，访问属于组织的数据返回。
+var ErrNoTenant = errors.New("tenant: no organization in context")
+
+type contextKey struct{}
+
+type unscopedKey struct{}
+
+// NewContext 返回携带当前组织ID的上下文，organizationID 为0表示没有选择组织。
+func NewContext(ctx context.Context, organizationID uint) context.Context {
+	return context.WithValue(ctx, contextKey{}, organizationID)
+}
+
+// FromContext 返回上下文中的当前组织ID，没有选择组织时 ok 为 false。
+func FromContext(ctx context.Context) (organizationID uint, ok bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	organizationID, _ = ctx.Value(contextKey{}).(uint)
+	return organizationID, organizationID != 0
+}
+
+// Unscoped 返回不限定组织的上下文，用于需要跨组织访问数据的查询，例如列出用户加入的所有组织。
+// 只应在仓库中明确需要跨组织的方法里使用，其他查询必须通过 NewContext 选择组织。
+func Unscoped(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unscopedKey{}, true)
+}
+
+// IsUnscoped 返回上下文是否通过 Unscoped 取消了组织限定。
+func IsUnscoped(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	unscoped, _ := ctx.Value(unscopedKey{}).(bool)
+	return unscoped
+}
+
+// Domain 返回组织在Casbin中对应的域，organizationID 为0时返回 GlobalDomain。
+func Domain(organizationID uint) string {
+	if organizationID == 0 {
+		return GlobalDomain
+	}
+	return domainPrefix + strconv.FormatUint(uint64(organizationID), 10)
+}
+
+// ParseDomain 是 Domain 的逆操作，无法识别的域返回 ok 为 false。
+func ParseDomain(domain string) (organizationID uint, ok bool) {
+	if domain == GlobalDomain {
+		return 0, true
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(domain, domainPrefix), 10, 64)
+	if err != nil || !strings.HasPrefix(domain, domainPrefix) || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// ConfigureEnforcer 让角色继承关系（g）中的域支持通配符：
+// 域为 "*" 的继承关系在所有组织中生效，"org:*" 在所有组织中生效但不包括 GlobalDomain。
+func ConfigureEnforcer(e *casbin.Enforcer) {
+	e.AddNamedDomainMatchingFunc("g", "keyMatch", util.KeyMatch)
+}
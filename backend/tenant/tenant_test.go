@@ -0,0 +1,130 @@
+package tenant
+
+import (
+	"context"
+	"go-web/models"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestDomain(t *testing.T) {
+	assert.Equal(t, GlobalDomain, Domain(0))
+	assert.Equal(t, "org:42", Domain(42))
+
+	id, ok := ParseDomain("org:42")
+	assert.True(t, ok)
+	assert.Equal(t, uint(42), id)
+	id, ok = ParseDomain(GlobalDomain)
+	assert.True(t, ok)
+	assert.Zero(t, id)
+	for _, invalid := range []string{"42", "org:", "org:0", "org:*", "team:1"} {
+		_, ok := ParseDomain(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
+
+func TestPlugin_ScopesTenantOwnedModels(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:tenant_plugin?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(Plugin{}))
+	require.NoError(t, db.AutoMigrate(&models.Role{}, &models.Membership{}))
+
+	first := NewContext(context.Background(), 1)
+	second := NewContext(context.Background(), 2)
+
+	// Creating assigns the organisation from the context, for single records and batches
+	require.NoError(t, db.WithContext(first).Create(&models.Membership{UserID: 10, RoleID: 1}).Error)
+	batch := []models.Membership{{UserID: 20, RoleID: 1}, {UserID: 30, RoleID: 1}}
+	require.NoError(t, db.WithContext(second).Create(&batch).Error)
+	assert.Equal(t, uint(2), batch[1].OrganizationID)
+
+	var count int64
+	require.NoError(t, db.WithContext(first).Model(&models.Membership{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	var memberships []models.Membership
+	require.NoError(t, db.WithContext(second).Find(&memberships).Error)
+	assert.Len(t, memberships, 2)
+
+	// Updates and deletes cannot reach another organisation's rows
+	result := db.WithContext(first).Where("user_id = ?", 20).Delete(&models.Membership{})
+	require.NoError(t, result.Error)
+	assert.Zero(t, result.RowsAffected)
+	result = db.WithContext(first).Model(&models.Membership{}).Where("user_id = ?", 30).Update("role_id", 2)
+	require.NoError(t, result.Error)
+	assert.Zero(t, result.RowsAffected)
+
+	// An explicitly unscoped context reaches every organisation, and other models are untouched
+	require.NoError(t, db.WithContext(Unscoped(context.Background())).Model(&models.Membership{}).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+	require.NoError(t, db.WithContext(first).Create(&models.Role{Name: "user"}).Error)
+	require.NoError(t, db.WithContext(second).Model(&models.Role{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestPlugin_FailsWithoutTenant(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:tenant_no_tenant?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(Plugin{}))
+	require.NoError(t, db.AutoMigrate(&models.Role{}, &models.Membership{}))
+
+	first := NewContext(context.Background(), 1)
+	require.NoError(t, db.WithContext(first).Create(&models.Membership{UserID: 10, RoleID: 1}).Error)
+
+	// Neither a missing context nor one without an organisation reaches tenant-owned rows
+	for _, ctx := range []context.Context{context.Background(), NewContext(context.Background(), 0)} {
+		var memberships []models.Membership
+		assert.ErrorIs(t, db.WithContext(ctx).Find(&memberships).Error, ErrNoTenant)
+		var count int64
+		assert.ErrorIs(t, db.WithContext(ctx).Model(&models.Membership{}).Count(&count).Error, ErrNoTenant)
+		var userIDs []uint
+		assert.ErrorIs(t, db.WithContext(ctx).Model(&models.Membership{}).Pluck("user_id", &userIDs).Error, ErrNoTenant)
+		assert.ErrorIs(t, db.WithContext(ctx).Model(&models.Membership{}).Where("user_id = ?", 10).Update("role_id", 2).Error, ErrNoTenant)
+		assert.ErrorIs(t, db.WithContext(ctx).Where("user_id = ?", 10).Delete(&models.Membership{}).Error, ErrNoTenant)
+		assert.ErrorIs(t, db.WithContext(ctx).Create(&models.Membership{UserID: 20, RoleID: 1}).Error, ErrNoTenant)
+	}
+	var memberships []models.Membership
+	assert.ErrorIs(t, db.Find(&memberships).Error, ErrNoTenant)
+
+	// Nothing was changed by the rejected statements
+	var found models.Membership
+	require.NoError(t, db.WithContext(first).Where("user_id = ?", 10).Take(&found).Error)
+	assert.Equal(t, uint(1), found.RoleID)
+	var count int64
+	require.NoError(t, db.WithContext(Unscoped(context.Background())).Model(&models.Membership{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// Models that do not belong to an organisation need no tenant
+	require.NoError(t, db.Create(&models.Role{Name: "user"}).Error)
+	require.NoError(t, db.Model(&models.Role{}).Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestPlugin_CrossTenantReadsReturnNothing(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:tenant_cross_reads?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Use(Plugin{}))
+	require.NoError(t, db.AutoMigrate(&models.Role{}, &models.Membership{}))
+
+	first := NewContext(context.Background(), 1)
+	second := NewContext(context.Background(), 2)
+	membership := &models.Membership{UserID: 10, RoleID: 1}
+	require.NoError(t, db.WithContext(first).Create(membership).Error)
+
+	// Looking the row up by primary key or by its columns from another organisation finds nothing
+	var found models.Membership
+	assert.ErrorIs(t, db.WithContext(second).First(&found, membership.ID).Error, gorm.ErrRecordNotFound)
+	assert.ErrorIs(t, db.WithContext(second).Where("user_id = ?", 10).Take(&found).Error, gorm.ErrRecordNotFound)
+	var userIDs []uint
+	require.NoError(t, db.WithContext(second).Model(&models.Membership{}).Pluck("user_id", &userIDs).Error)
+	assert.Empty(t, userIDs)
+	var count int64
+	require.NoError(t, db.WithContext(second).Model(&models.Membership{}).Where("organization_id = ?", 1).Count(&count).Error)
+	assert.Zero(t, count)
+
+	require.NoError(t, db.WithContext(first).First(&found, membership.ID).Error)
+	assert.Equal(t, uint(10), found.UserID)
+}
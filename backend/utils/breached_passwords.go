@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"go-web/config"
+	"os"
+	"strings"
+	"sync"
+)
+
+// breachedPrefixLength 是按前缀查询时提交的SHA-1十六进制字符数，与 Have I Been Pwned 的 range API 相同
+const breachedPrefixLength = 5
+
+// BreachedPasswordList 是泄露密码的SHA-1哈希集合，采用k-匿名的查询方式：
+// 调用方只提交哈希的前5个字符，得到该前缀下所有泄露哈希的后缀，再在本地比对。
+// 这样本地文件和远程服务可以使用相同的接口。
+type BreachedPasswordList interface {
+	// Range 返回以 prefix（5个大写十六进制字符）开头的所有泄露哈希的后缀（35个大写十六进制字符）
+	Range(prefix string) ([]string, error)
+}
+
+// fileBreachedPasswordList 是从本地文件加载的泄露密码列表，按哈希前缀分组
+type fileBreachedPasswordList struct {
+	ranges map[string][]string
+}
+
+// breachedPasswordLists 缓存根据配置加载的泄露密码列表
+var breachedPasswordLists sync.Map // map[*config.Config]BreachedPasswordList
+
+// LoadBreachedPasswordList 从文件加载泄露密码列表。
+// 文件每行一个SHA-1哈希（40个十六进制字符），可以带有 ":出现次数" 后缀，即 Have I Been Pwned 下载工具导出的格式；
+// 空行和以 # 开头的行会被忽略。
+func LoadBreachedPasswordList(path string) (BreachedPasswordList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list := &fileBreachedPasswordList{ranges: make(map[string][]string)}
+	scanner := bufio.NewScanner(f)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hash, _, _ := strings.Cut(line, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 hash", path, lineNo)
+		}
+		if _, err := hex.DecodeString(hash); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid SHA-1 hash", path, lineNo)
+		}
+		prefix := hash[:breachedPrefixLength]
+		list.ranges[prefix] = append(list.ranges[prefix], hash[breachedPrefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// BreachedPasswordListFor 返回给定配置对应的泄露密码列表，首次调用时加载并缓存。
+// 未配置 password.breached_list_file 时返回 nil。
+func BreachedPasswordListFor(cfg *config.Config) (BreachedPasswordList, error) {
+	if cfg.Password.BreachedListFile == "" {
+		return nil, nil
+	}
+	if list, ok := breachedPasswordLists.Load(cfg); ok {
+		return list.(BreachedPasswordList), nil
+	}
+	list, err := LoadBreachedPasswordList(cfg.Password.BreachedListFile)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := breachedPasswordLists.LoadOrStore(cfg, list)
+	return actual.(BreachedPasswordList), nil
+}
+
+// Range 实现了 BreachedPasswordList 接口的 Range 方法
+func (l *fileBreachedPasswordList) Range(prefix string) ([]string, error) {
+	return l.ranges[strings.ToUpper(prefix)], nil
+}
+
+// IsBreachedPassword 检查密码是否出现在泄露密码列表中，只向列表提交密码SHA-1哈希的前缀
+func IsBreachedPassword(list BreachedPasswordList, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes, err := list.Range(hash[:breachedPrefixLength])
+	if err != nil {
+		return false, err
+	}
+	for _, suffix := range suffixes {
+		if suffix == hash[breachedPrefixLength:] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreachedPasswordList(t *testing.T) {
+	// SHA-1("password") = 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# breached passwords\n\n5baa61e4c9b93f3f0682250b6cf8331b7ee68fd8:9545824\n7C4A8D09CA3762AF61E59520943DC26494F8941B\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	list, err := LoadBreachedPasswordList(path)
+	assert.NoError(t, err)
+
+	// Only the 5-character prefix is sent to the list
+	suffixes, err := list.Range("5BAA6")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1E4C9B93F3F0682250B6CF8331B7EE68FD8"}, suffixes)
+
+	breached, err := IsBreachedPassword(list, "password")
+	assert.NoError(t, err)
+	assert.True(t, breached)
+	breached, err = IsBreachedPassword(list, "123456")
+	assert.NoError(t, err)
+	assert.True(t, breached)
+	breached, err = IsBreachedPassword(list, "correct horse battery staple")
+	assert.NoError(t, err)
+	assert.False(t, breached)
+}
+
+func TestLoadBreachedPasswordList_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("not-a-hash\n"), 0o600))
+
+	_, err := LoadBreachedPasswordList(path)
+	assert.Error(t, err)
+
+	_, err = LoadBreachedPasswordList(filepath.Join(t.TempDir(), "missing.txt"))
+	assert.Error(t, err)
+}
@@ -8,47 +8,56 @@ import (
 )
 
 type Claims struct {
-	UserID uint   `json:"user_id"`
-	Role   string `json:"role"`
+	UserID    uint   `json:"user_id"`
+	Role      string `json:"role"`
+	SessionID uint   `json:"sid,omitempty"` // 签发令牌的登录会话，会话被吊销后令牌随之失效
+	TenantID  uint   `json:"tid,omitempty"` // 当前组织，为0时表示不在任何组织中，Role 为组织内的角色
 	jwt.RegisteredClaims
 }
 
 // GenerateToken 生成JWT token
+// 每个token都带有唯一的jti声明，用于在过期之前将其加入吊销列表
 func GenerateToken(userID uint, role string, cfg *config.Config) (string, error) {
-	expirationTime := time.Now().Add(time.Duration(cfg.JWT.Expiration) * time.Second)
+	return GenerateSessionToken(userID, role, 0, cfg)
+}
+
+// GenerateSessionToken 生成绑定到登录会话的JWT token，sessionID 为0时不绑定会话
+func GenerateSessionToken(userID uint, role string, sessionID uint, cfg *config.Config) (string, error) {
+	return GenerateTenantToken(userID, role, sessionID, 0, cfg)
+}
+
+// GenerateTenantToken 生成绑定到登录会话和组织的JWT token，tenantID 为0时不绑定组织
+func GenerateTenantToken(userID uint, role string, sessionID, tenantID uint, cfg *config.Config) (string, error) {
+	now := time.Now()
+	expirationTime := now.Add(time.Duration(cfg.JWT.Expiration) * time.Second)
+
+	jti, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
 
 	claims := &Claims{
-		UserID: userID,
-		Role:   role,
+		UserID:    userID,
+		Role:      role,
+		SessionID: sessionID,
+		TenantID:  tenantID,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(cfg.JWT.Secret))
-	if err != nil {
-		return "", err
-	}
-
-	return tokenString, nil
+	return SignToken(claims, TokenTypeAccess, cfg)
 }
 
-// ParseToken 解析JWT token
-func ParseToken(tokenStr, secret string) (*Claims, error) {
+// ValidateToken 使用配置中的签名算法和验证密钥解析JWT token
+// 非对称算法下会根据头部的kid选择公钥，从而支持密钥轮换
+func ValidateToken(tokenStr string, cfg *config.Config) (*Claims, error) {
 	claims := &Claims{}
-
-	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
-
-	if err != nil {
+	if err := ParseSignedToken(tokenStr, claims, TokenTypeAccess, cfg); err != nil {
 		return nil, err
 	}
 
-	if !token.Valid {
-		return nil, jwt.ErrSignatureInvalid
-	}
-
 	return claims, nil
 }
@@ -26,16 +26,24 @@ func TestJWTFunctions(t *testing.T) {
 	assert.NotEmpty(t, tokenString, "Generated token string should not be empty")
 
 	// 3. Test Token Parsing (Happy Path)
-	claims, err := ParseToken(tokenString, cfg.JWT.Secret)
+	claims, err := ValidateToken(tokenString, cfg)
 
 	assert.NoError(t, err, "Token parsing should not produce an error for a valid token")
 	assert.NotNil(t, claims, "Claims should not be nil for a valid token")
 	assert.Equal(t, userID, claims.UserID, "UserID in claims should match the original UserID")
 	assert.Equal(t, role, claims.Role, "Role in claims should match the original role")
+	assert.NotEmpty(t, claims.ID, "Token should carry a jti claim")
+
+	// Every token gets its own jti so it can be revoked individually
+	otherToken, err := GenerateToken(userID, role, cfg)
+	assert.NoError(t, err)
+	otherClaims, err := ValidateToken(otherToken, cfg)
+	assert.NoError(t, err)
+	assert.NotEqual(t, claims.ID, otherClaims.ID, "Each token should have a unique jti")
 
 	// 4. Test Token Parsing (Invalid Token)
 	invalidTokenString := "this-is-not-a-valid-jwt"
-	claims, err = ParseToken(invalidTokenString, cfg.JWT.Secret)
+	claims, err = ValidateToken(invalidTokenString, cfg)
 
 	assert.Error(t, err, "Parsing an invalid token should produce an error")
 	assert.Nil(t, claims, "Claims should be nil for an invalid token")
@@ -50,7 +58,7 @@ func TestJWTFunctions(t *testing.T) {
 	expiredToken, _ := GenerateToken(userID, role, cfgExpired)
 	// Wait a moment to ensure the timestamp is in the past
 	time.Sleep(1 * time.Second)
-	_, err = ParseToken(expiredToken, cfgExpired.JWT.Secret)
+	_, err = ValidateToken(expiredToken, cfgExpired)
 
 	assert.Error(t, err, "Parsing an expired token should produce an error")
 	assert.Contains(t, err.Error(), "token is expired", "Error message should indicate token expiration")
@@ -0,0 +1,352 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"go-web/config"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// 支持的JWT签名算法
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// 令牌类型，写入JWT头部的typ字段。
+// 不同用途的令牌使用同一套密钥签名，解析时校验typ可以防止一种令牌被当作另一种使用。
+const (
+	TokenTypeAccess            = "JWT" // 访问令牌，沿用库的默认值以兼容已签发的令牌
+	TokenTypeEmailVerification = "email-verification+jwt"
+	TokenTypeMFAChallenge      = "mfa-challenge+jwt"
+)
+
+// verificationKey 是一个可用于验证签名的密钥及其算法
+type verificationKey struct {
+	method jwt.SigningMethod
+	key    interface{}
+}
+
+// KeySet 持有当前的签名密钥以及所有可接受的验证密钥
+type KeySet struct {
+	method     jwt.SigningMethod
+	signingKey interface{}
+	keyID      string
+	// verificationKeys 以kid为键；HS256模式下为空，直接使用对称密钥验证
+	verificationKeys map[string]verificationKey
+	secret           []byte
+}
+
+// JWK 是 RFC 7517 中定义的JSON Web Key，这里只包含签名公钥需要的字段
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS 是一组JWK，即 /.well-known/jwks.json 的响应体
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// keySets 缓存根据配置加载的密钥，避免每次签名都重新读取PEM文件
+var keySets sync.Map // map[*config.Config]*KeySet
+
+// keySetFor 返回给定配置对应的密钥集合，首次调用时加载并缓存
+func keySetFor(cfg *config.Config) (*KeySet, error) {
+	if ks, ok := keySets.Load(cfg); ok {
+		return ks.(*KeySet), nil
+	}
+	ks, err := LoadKeySet(cfg.JWT)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := keySets.LoadOrStore(cfg, ks)
+	return actual.(*KeySet), nil
+}
+
+// LoadKeySet 根据JWT配置加载签名密钥和验证密钥
+func LoadKeySet(cfg config.JWTConfig) (*KeySet, error) {
+	switch cfg.Algorithm {
+	case "", AlgorithmHS256:
+		if cfg.Secret == "" {
+			return nil, errors.New("jwt.secret is required for HS256")
+		}
+		return &KeySet{
+			method:     jwt.SigningMethodHS256,
+			signingKey: []byte(cfg.Secret),
+			keyID:      cfg.KeyID,
+			secret:     []byte(cfg.Secret),
+		}, nil
+	case AlgorithmRS256, AlgorithmEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported jwt.algorithm %q", cfg.Algorithm)
+	}
+
+	if cfg.PrivateKeyFile == "" {
+		return nil, fmt.Errorf("jwt.private_key_file is required for %s", cfg.Algorithm)
+	}
+	pemBytes, err := os.ReadFile(cfg.PrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{verificationKeys: make(map[string]verificationKey)}
+	var publicKey interface{}
+	if cfg.Algorithm == AlgorithmRS256 {
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RS256 private key: %w", err)
+		}
+		ks.method, ks.signingKey, publicKey = jwt.SigningMethodRS256, privateKey, &privateKey.PublicKey
+	} else {
+		privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EdDSA private key: %w", err)
+		}
+		ks.method, ks.signingKey, publicKey = jwt.SigningMethodEdDSA, privateKey, privateKey.(ed25519.PrivateKey).Public()
+	}
+
+	ks.keyID = cfg.KeyID
+	if ks.keyID == "" {
+		if ks.keyID, err = keyThumbprint(publicKey); err != nil {
+			return nil, err
+		}
+	}
+	ks.verificationKeys[ks.keyID] = verificationKey{method: ks.method, key: publicKey}
+
+	// 加载轮换期间仍然有效的旧公钥
+	for _, keyCfg := range cfg.VerificationKeys {
+		vk, err := loadVerificationKey(keyCfg.PublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid verification key %s: %w", keyCfg.PublicKeyFile, err)
+		}
+		kid := keyCfg.KeyID
+		if kid == "" {
+			if kid, err = keyThumbprint(vk.key); err != nil {
+				return nil, err
+			}
+		}
+		if _, exists := ks.verificationKeys[kid]; exists {
+			return nil, fmt.Errorf("duplicate jwt key id %q", kid)
+		}
+		ks.verificationKeys[kid] = vk
+	}
+
+	return ks, nil
+}
+
+// Sign 使用当前签名密钥对声明进行签名，并在头部写入kid和令牌类型
+func (ks *KeySet) Sign(claims jwt.Claims, tokenType string) (string, error) {
+	token := jwt.NewWithClaims(ks.method, claims)
+	token.Header["typ"] = tokenType
+	if ks.keyID != "" {
+		token.Header["kid"] = ks.keyID
+	}
+	return token.SignedString(ks.signingKey)
+}
+
+// Parse 验证令牌签名并将声明解析到 claims 中。
+// 令牌使用的算法必须与kid对应密钥的算法一致，防止算法混淆攻击；
+// 令牌类型必须与 tokenType 一致，防止不同用途的令牌被混用。
+func (ks *KeySet) Parse(tokenStr string, claims jwt.Claims, tokenType string) error {
+	token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		typ, _ := token.Header["typ"].(string)
+		if typ != tokenType && !(tokenType == TokenTypeAccess && typ == "") {
+			return nil, fmt.Errorf("unexpected token type %q", typ)
+		}
+
+		if ks.secret != nil {
+			if token.Method.Alg() != AlgorithmHS256 {
+				return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+			}
+			return ks.secret, nil
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		vk, ok := ks.verificationKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if token.Method.Alg() != vk.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return vk.key, nil
+	})
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return jwt.ErrSignatureInvalid
+	}
+	return nil
+}
+
+// JWKS 返回所有验证公钥的JWK表示；HS256模式下没有可公开的密钥
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for kid, vk := range ks.verificationKeys {
+		switch key := vk.key.(type) {
+		case *rsa.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "RSA",
+				Use: "sig",
+				Alg: AlgorithmRS256,
+				Kid: kid,
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			jwks.Keys = append(jwks.Keys, JWK{
+				Kty: "OKP",
+				Use: "sig",
+				Alg: AlgorithmEdDSA,
+				Kid: kid,
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(key),
+			})
+		}
+	}
+	return jwks
+}
+
+// PublicKey 将JWK解析为验证签名使用的公钥，支持RSA、EC（P-256/P-384/P-521）和Ed25519，
+// 用于验证第三方身份提供方签发的令牌
+func (k JWK) PublicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if _, err := key.ECDH(); err != nil { // 同时检查点是否在曲线上
+			return nil, errors.New("invalid EC key")
+		}
+		return key, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// SignToken 使用配置中的签名密钥对任意声明进行签名
+func SignToken(claims jwt.Claims, tokenType string, cfg *config.Config) (string, error) {
+	ks, err := keySetFor(cfg)
+	if err != nil {
+		return "", err
+	}
+	return ks.Sign(claims, tokenType)
+}
+
+// ParseSignedToken 验证由 SignToken 签发的令牌，并将声明解析到 claims 中
+func ParseSignedToken(tokenStr string, claims jwt.Claims, tokenType string, cfg *config.Config) error {
+	ks, err := keySetFor(cfg)
+	if err != nil {
+		return err
+	}
+	return ks.Parse(tokenStr, claims, tokenType)
+}
+
+// GetJWKS 返回给定配置下所有验证公钥的JWK集合
+func GetJWKS(cfg *config.Config) (JWKS, error) {
+	ks, err := keySetFor(cfg)
+	if err != nil {
+		return JWKS{}, err
+	}
+	return ks.JWKS(), nil
+}
+
+// loadVerificationKey 从PEM文件中读取RSA或Ed25519公钥
+func loadVerificationKey(path string) (verificationKey, error) {
+	pemBytes, err := os.ReadFile(path)
+	if err != nil {
+		return verificationKey{}, err
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return verificationKey{}, errors.New("no PEM data found")
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return verificationKey{}, err
+	}
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return verificationKey{method: jwt.SigningMethodRS256, key: key}, nil
+	case ed25519.PublicKey:
+		return verificationKey{method: jwt.SigningMethodEdDSA, key: key}, nil
+	default:
+		return verificationKey{}, fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+}
+
+// keyThumbprint 根据公钥的DER编码计算一个稳定的kid
+func keyThumbprint(publicKey interface{}) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
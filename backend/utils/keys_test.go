@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"go-web/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeKeyPair writes a PKCS#8 private key and its PKIX public key to dir and returns both paths.
+func writeKeyPair(t *testing.T, dir, name string, privateKey, publicKey interface{}) (string, string) {
+	t.Helper()
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+
+	privatePath := filepath.Join(dir, name+".pem")
+	publicPath := filepath.Join(dir, name+".pub.pem")
+	require.NoError(t, os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}), 0o600))
+	require.NoError(t, os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}), 0o644))
+	return privatePath, publicPath
+}
+
+func TestAsymmetricSigning(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPrivate, _ := writeKeyPair(t, dir, "rsa", rsaKey, &rsaKey.PublicKey)
+
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edPrivatePath, _ := writeKeyPair(t, dir, "ed", edPrivate, edPublic)
+
+	for _, tc := range []struct {
+		algorithm string
+		keyFile   string
+		kty       string
+	}{
+		{AlgorithmRS256, rsaPrivate, "RSA"},
+		{AlgorithmEdDSA, edPrivatePath, "OKP"},
+	} {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			cfg := &config.Config{JWT: config.JWTConfig{
+				Algorithm:      tc.algorithm,
+				PrivateKeyFile: tc.keyFile,
+				KeyID:          "current",
+				Expiration:     60,
+			}}
+
+			tokenString, err := GenerateToken(42, "user", cfg)
+			require.NoError(t, err)
+
+			// The token header carries the kid and the configured algorithm
+			parsed, _, err := new(jwt.Parser).ParseUnverified(tokenString, &Claims{})
+			require.NoError(t, err)
+			assert.Equal(t, "current", parsed.Header["kid"])
+			assert.Equal(t, tc.algorithm, parsed.Method.Alg())
+
+			claims, err := ValidateToken(tokenString, cfg)
+			require.NoError(t, err)
+			assert.Equal(t, uint(42), claims.UserID)
+
+			jwks, err := GetJWKS(cfg)
+			require.NoError(t, err)
+			require.Len(t, jwks.Keys, 1)
+			assert.Equal(t, "current", jwks.Keys[0].Kid)
+			assert.Equal(t, tc.kty, jwks.Keys[0].Kty)
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	oldPrivate, oldPublic := writeKeyPair(t, dir, "old", oldKey, &oldKey.PublicKey)
+
+	newPublic, newPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	newPrivatePath, _ := writeKeyPair(t, dir, "new", newPrivate, newPublic)
+
+	// A token signed before the rotation
+	oldCfg := &config.Config{JWT: config.JWTConfig{Algorithm: AlgorithmRS256, PrivateKeyFile: oldPrivate, KeyID: "old", Expiration: 60}}
+	oldToken, err := GenerateToken(1, "admin", oldCfg)
+	require.NoError(t, err)
+
+	// After the rotation the old public key is still accepted for verification
+	rotatedCfg := &config.Config{JWT: config.JWTConfig{
+		Algorithm:        AlgorithmEdDSA,
+		PrivateKeyFile:   newPrivatePath,
+		KeyID:            "new",
+		Expiration:       60,
+		VerificationKeys: []config.JWTKeyConfig{{KeyID: "old", PublicKeyFile: oldPublic}},
+	}}
+	claims, err := ValidateToken(oldToken, rotatedCfg)
+	require.NoError(t, err)
+	assert.Equal(t, "admin", claims.Role)
+
+	newToken, err := GenerateToken(2, "user", rotatedCfg)
+	require.NoError(t, err)
+	_, err = ValidateToken(newToken, rotatedCfg)
+	assert.NoError(t, err)
+
+	jwks, err := GetJWKS(rotatedCfg)
+	require.NoError(t, err)
+	assert.Len(t, jwks.Keys, 2)
+
+	// Once the old key is retired its tokens are rejected
+	retiredCfg := &config.Config{JWT: config.JWTConfig{Algorithm: AlgorithmEdDSA, PrivateKeyFile: newPrivatePath, KeyID: "new", Expiration: 60}}
+	_, err = ValidateToken(oldToken, retiredCfg)
+	assert.Error(t, err)
+}
+
+func TestAsymmetricSigning_RejectsHS256Tokens(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPrivate, _ := writeKeyPair(t, dir, "rsa", rsaKey, &rsaKey.PublicKey)
+
+	cfg := &config.Config{JWT: config.JWTConfig{Algorithm: AlgorithmRS256, PrivateKeyFile: rsaPrivate, KeyID: "current", Expiration: 60}}
+
+	// An attacker forging an HS256 token with a guessed secret must not be accepted
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{UserID: 1, Role: "admin"})
+	forged.Header["kid"] = "current"
+	forgedString, err := forged.SignedString([]byte("guessed-secret"))
+	require.NoError(t, err)
+
+	_, err = ValidateToken(forgedString, cfg)
+	assert.Error(t, err)
+}
+
+func TestLoadKeySet_RequiresKeys(t *testing.T) {
+	_, err := LoadKeySet(config.JWTConfig{Algorithm: AlgorithmHS256})
+	assert.Error(t, err, "HS256 without a secret should be rejected")
+
+	_, err = LoadKeySet(config.JWTConfig{Algorithm: AlgorithmRS256})
+	assert.Error(t, err, "RS256 without a private key file should be rejected")
+
+	_, err = LoadKeySet(config.JWTConfig{Algorithm: "none", Secret: "secret"})
+	assert.Error(t, err, "Unknown algorithms should be rejected")
+}
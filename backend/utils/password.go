@@ -1,17 +1,244 @@
 package utils
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go-web/config"
+	"strings"
+	"sync"
 
-// HashPassword 对密码进行哈希处理
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 支持的密码哈希算法
+const (
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
+)
+
+// Argon2id 的默认参数，取自 OWASP Password Storage Cheat Sheet 的推荐配置
+const (
+	defaultArgon2Memory      = 19 * 1024 // KiB
+	defaultArgon2Iterations  = 2
+	defaultArgon2Parallelism = 1
+	argon2SaltLength         = 16
+	argon2KeyLength          = 32
+)
+
+// ErrPasswordMismatch 在密码与哈希不匹配时返回
+var ErrPasswordMismatch = errors.New("password does not match")
+
+// ErrUnknownPasswordHash 在无法识别哈希的格式时返回
+var ErrUnknownPasswordHash = errors.New("unknown password hash format")
+
+// PasswordHasher 对密码进行哈希和校验。
+// 哈希使用PHC风格的编码（如 $argon2id$v=19$m=...,t=...,p=...$salt$hash，或bcrypt的 $2a$cost$...），
+// 算法和参数都保存在哈希中，因此可以校验以任意受支持算法生成的哈希。
+type PasswordHasher interface {
+	// Hash 使用当前配置的算法和参数对密码进行哈希
+	Hash(password string) (string, error)
+	// Verify 校验密码是否与哈希匹配，不匹配时返回 ErrPasswordMismatch
+	Verify(password, encoded string) error
+	// NeedsRehash 返回哈希是否使用了与当前配置不同的算法或参数，需要在下次登录时重新生成
+	NeedsRehash(encoded string) bool
+	// DummyHash 返回一个随机密码按当前配置生成的哈希，只生成一次。
+	// 用户不存在时对它校验密码，使登录的耗时与用户存在时相同
+	DummyHash() (string, error)
+}
+
+// argon2Params 是一次Argon2id哈希使用的参数
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	keyLength   uint32
+}
+
+// configuredHasher 按配置的算法生成哈希，并能校验所有受支持算法的哈希
+type configuredHasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     argon2Params
+
+	dummyOnce sync.Once
+	dummy     string
+	dummyErr  error
+}
+
+// passwordHashers 缓存根据配置创建的哈希器
+var passwordHashers sync.Map // map[*config.Config]PasswordHasher
+
+// defaultPasswordHasher 用于 HashPassword 和 CheckPasswordHash，保持这两个函数原有的bcrypt行为
+var defaultPasswordHasher = &configuredHasher{algorithm: PasswordAlgorithmBcrypt, bcryptCost: bcrypt.DefaultCost}
+
+// NewPasswordHasher 根据密码配置创建哈希器，未设置的参数使用默认值
+func NewPasswordHasher(cfg config.PasswordConfig) (PasswordHasher, error) {
+	h := &configuredHasher{
+		algorithm:  cfg.Algorithm,
+		bcryptCost: cfg.BcryptCost,
+		argon2: argon2Params{
+			memory:      cfg.Argon2Memory,
+			iterations:  cfg.Argon2Iterations,
+			parallelism: cfg.Argon2Parallelism,
+			keyLength:   argon2KeyLength,
+		},
+	}
+	if h.algorithm == "" {
+		h.algorithm = PasswordAlgorithmArgon2id
+	}
+	if h.bcryptCost == 0 {
+		h.bcryptCost = bcrypt.DefaultCost
+	}
+	if h.argon2.memory == 0 {
+		h.argon2.memory = defaultArgon2Memory
+	}
+	if h.argon2.iterations == 0 {
+		h.argon2.iterations = defaultArgon2Iterations
+	}
+	if h.argon2.parallelism == 0 {
+		h.argon2.parallelism = defaultArgon2Parallelism
+	}
+
+	switch h.algorithm {
+	case PasswordAlgorithmArgon2id:
+	case PasswordAlgorithmBcrypt:
+		if h.bcryptCost < bcrypt.MinCost || h.bcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("password.bcrypt_cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported password.algorithm %q", h.algorithm)
+	}
+	return h, nil
+}
+
+// PasswordHasherFor 返回给定配置对应的哈希器，首次调用时创建并缓存
+func PasswordHasherFor(cfg *config.Config) (PasswordHasher, error) {
+	if h, ok := passwordHashers.Load(cfg); ok {
+		return h.(PasswordHasher), nil
+	}
+	h, err := NewPasswordHasher(cfg.Password)
 	if err != nil {
+		return nil, err
+	}
+	actual, _ := passwordHashers.LoadOrStore(cfg, h)
+	return actual.(PasswordHasher), nil
+}
+
+// Hash 实现了 PasswordHasher 接口的 Hash 方法
+func (h *configuredHasher) Hash(password string) (string, error) {
+	if h.algorithm == PasswordAlgorithmBcrypt {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hashed), nil
+	}
+
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
 		return "", err
 	}
-	return string(hashedPassword), nil
+	p := h.argon2
+	key := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, p.keyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.iterations, p.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify 实现了 PasswordHasher 接口的 Verify 方法
+func (h *configuredHasher) Verify(password, encoded string) error {
+	if isBcryptHash(encoded) {
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrPasswordMismatch
+		}
+		return err
+	}
+
+	p, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+	actual := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, p.keyLength)
+	if subtle.ConstantTimeCompare(actual, key) != 1 {
+		return ErrPasswordMismatch
+	}
+	return nil
+}
+
+// NeedsRehash 实现了 PasswordHasher 接口的 NeedsRehash 方法
+func (h *configuredHasher) NeedsRehash(encoded string) bool {
+	if isBcryptHash(encoded) {
+		if h.algorithm != PasswordAlgorithmBcrypt {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err != nil || cost != h.bcryptCost
+	}
+
+	if h.algorithm != PasswordAlgorithmArgon2id {
+		return true
+	}
+	p, _, _, err := decodeArgon2id(encoded)
+	return err != nil || p != h.argon2
+}
+
+// DummyHash 实现了 PasswordHasher 接口的 DummyHash 方法
+func (h *configuredHasher) DummyHash() (string, error) {
+	h.dummyOnce.Do(func() {
+		password := make([]byte, 32)
+		if _, err := rand.Read(password); err != nil {
+			h.dummyErr = err
+			return
+		}
+		h.dummy, h.dummyErr = h.Hash(base64.RawStdEncoding.EncodeToString(password))
+	})
+	return h.dummy, h.dummyErr
+}
+
+// isBcryptHash 根据前缀判断是否为bcrypt哈希（$2a$、$2b$ 或 $2y$）
+func isBcryptHash(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// decodeArgon2id 解析PHC格式的Argon2id哈希
+func decodeArgon2id(encoded string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != PasswordAlgorithmArgon2id {
+		return p, nil, nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownPasswordHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return p, nil, nil, ErrUnknownPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownPasswordHash
+	}
+	p.keyLength = uint32(len(key))
+	return p, salt, key, nil
+}
+
+// HashPassword 对密码进行哈希处理
+func HashPassword(password string) (string, error) {
+	return defaultPasswordHasher.Hash(password)
 }
 
-// CheckPasswordHash 验证密码是否匹配
+// CheckPasswordHash 验证密码是否匹配，支持所有受支持算法生成的哈希
 func CheckPasswordHash(password, hashedPassword string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+	return defaultPasswordHasher.Verify(password, hashedPassword)
 }
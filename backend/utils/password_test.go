@@ -2,9 +2,12 @@
 package utils
 
 import (
+	"go-web/config"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestPasswordHashing(t *testing.T) {
@@ -26,3 +29,87 @@ func TestPasswordHashing(t *testing.T) {
 	err = CheckPasswordHash(wrongPassword, hashedPassword)
 	assert.Error(t, err, "Checking the wrong password should produce an error")
 }
+
+func TestPasswordHasher_Argon2id(t *testing.T) {
+	hasher, err := NewPasswordHasher(config.PasswordConfig{})
+	assert.NoError(t, err)
+
+	hashed, err := hasher.Hash("my-super-secret-password")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hashed, "$argon2id$v=19$m=19456,t=2,p=1$"), hashed)
+
+	assert.NoError(t, hasher.Verify("my-super-secret-password", hashed))
+	assert.ErrorIs(t, hasher.Verify("not-my-password", hashed), ErrPasswordMismatch)
+	assert.False(t, hasher.NeedsRehash(hashed))
+
+	// The legacy helpers still verify hashes produced by any supported algorithm
+	assert.NoError(t, CheckPasswordHash("my-super-secret-password", hashed))
+}
+
+func TestPasswordHasher_Bcrypt(t *testing.T) {
+	hasher, err := NewPasswordHasher(config.PasswordConfig{Algorithm: PasswordAlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	assert.NoError(t, err)
+
+	hashed, err := hasher.Hash("my-super-secret-password")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hashed, "$2a$04$"), hashed)
+	assert.NoError(t, hasher.Verify("my-super-secret-password", hashed))
+	assert.ErrorIs(t, hasher.Verify("not-my-password", hashed), ErrPasswordMismatch)
+	assert.False(t, hasher.NeedsRehash(hashed))
+}
+
+func TestPasswordHasher_NeedsRehash(t *testing.T) {
+	bcryptHash, err := HashPassword("my-super-secret-password")
+	assert.NoError(t, err)
+
+	argon, err := NewPasswordHasher(config.PasswordConfig{Argon2Memory: 8 * 1024})
+	assert.NoError(t, err)
+	argonHash, err := argon.Hash("my-super-secret-password")
+	assert.NoError(t, err)
+
+	// An argon2id hasher still accepts existing bcrypt hashes but asks for a rehash
+	assert.NoError(t, argon.Verify("my-super-secret-password", bcryptHash))
+	assert.True(t, argon.NeedsRehash(bcryptHash))
+
+	// Changing the argon2id parameters or the bcrypt cost also triggers a rehash
+	stronger, err := NewPasswordHasher(config.PasswordConfig{Argon2Memory: 16 * 1024})
+	assert.NoError(t, err)
+	assert.True(t, stronger.NeedsRehash(argonHash))
+	assert.NoError(t, stronger.Verify("my-super-secret-password", argonHash))
+
+	bcryptHasher, err := NewPasswordHasher(config.PasswordConfig{Algorithm: PasswordAlgorithmBcrypt, BcryptCost: bcrypt.DefaultCost + 1})
+	assert.NoError(t, err)
+	assert.True(t, bcryptHasher.NeedsRehash(bcryptHash))
+	assert.True(t, bcryptHasher.NeedsRehash(argonHash))
+}
+
+func TestPasswordHasher_InvalidInput(t *testing.T) {
+	_, err := NewPasswordHasher(config.PasswordConfig{Algorithm: "md5"})
+	assert.Error(t, err)
+	_, err = NewPasswordHasher(config.PasswordConfig{Algorithm: PasswordAlgorithmBcrypt, BcryptCost: 100})
+	assert.Error(t, err)
+
+	hasher, err := NewPasswordHasher(config.PasswordConfig{})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, hasher.Verify("password", "plain-text"), ErrUnknownPasswordHash)
+	assert.ErrorIs(t, hasher.Verify("password", "$argon2id$v=19$m=x$salt$hash"), ErrUnknownPasswordHash)
+}
+
+func TestPasswordHasher_DummyHash(t *testing.T) {
+	for _, cfg := range []config.PasswordConfig{
+		{Argon2Memory: 8 * 1024},
+		{Algorithm: PasswordAlgorithmBcrypt, BcryptCost: bcrypt.MinCost},
+	} {
+		hasher, err := NewPasswordHasher(cfg)
+		assert.NoError(t, err)
+		dummy, err := hasher.DummyHash()
+		assert.NoError(t, err)
+
+		// The hash uses the configured parameters, so verifying against it costs as much as a real one
+		assert.False(t, hasher.NeedsRehash(dummy), cfg.Algorithm)
+		assert.ErrorIs(t, hasher.Verify("password", dummy), ErrPasswordMismatch)
+		again, err := hasher.DummyHash()
+		assert.NoError(t, err)
+		assert.Equal(t, dummy, again)
+	}
+}
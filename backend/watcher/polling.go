@@ -0,0 +1,120 @@
+package watcher
+
+import (
+	"context"
+	"go-web/models"
+	"go-web/utils"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// policyRevisionID 是 policy_revisions 表中唯一一行的ID。
+const policyRevisionID = 1
+
+// PollingWatcher 通过轮询 policy_revisions 表中的修订号同步策略修改。
+// 它不依赖特定的数据库功能，但其他实例最多在一个轮询间隔之后才能看到修改。
+type PollingWatcher struct {
+	db       *gorm.DB
+	interval time.Duration
+
+	mu       sync.Mutex
+	revision int64 // 本实例已经加载的修订号
+	callback func(string)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPollingWatcher 创建一个按 interval 轮询的观察者，修订号记录不存在时创建它。
+func NewPollingWatcher(db *gorm.DB, interval time.Duration) (*PollingWatcher, error) {
+	current := models.PolicyRevision{ID: policyRevisionID}
+	if err := db.FirstOrCreate(&current).Error; err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &PollingWatcher{db: db, interval: interval, revision: current.Revision, cancel: cancel, done: make(chan struct{})}
+	go w.run(ctx)
+	return w, nil
+}
+
+// SetUpdateCallback 实现了 persist.Watcher 接口。
+func (w *PollingWatcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callback = callback
+	return nil
+}
+
+// Update 实现了 persist.Watcher 接口，将修订号加一。
+// 如果期间没有其他实例修改过策略，本实例不需要为自己的修改重新加载。
+func (w *PollingWatcher) Update() error {
+	var current models.PolicyRevision
+	err := w.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PolicyRevision{}).Where("id = ?", policyRevisionID).UpdateColumn("revision", gorm.Expr("revision + 1")).Error; err != nil {
+			return err
+		}
+		return tx.First(&current, policyRevisionID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	if current.Revision == w.revision+1 {
+		w.revision = current.Revision
+	}
+	w.mu.Unlock()
+	return nil
+}
+
+// Close 实现了 persist.Watcher 接口，停止轮询。
+func (w *PollingWatcher) Close() {
+	w.cancel()
+	<-w.done
+}
+
+// run 按固定间隔检查修订号，直到 Close 被调用。
+func (w *PollingWatcher) run(ctx context.Context) {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.poll(); err != nil {
+				utils.Logger.Warn("failed to poll casbin policy revision", zap.Error(err))
+			}
+		}
+	}
+}
+
+// poll 读取修订号，发现其他实例的修改时调用回调。
+func (w *PollingWatcher) poll() error {
+	var current models.PolicyRevision
+	if err := w.db.First(&current, policyRevisionID).Error; err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	if current.Revision <= w.revision {
+		w.mu.Unlock()
+		return nil
+	}
+	w.revision = current.Revision
+	callback := w.callback
+	w.mu.Unlock()
+
+	if callback != nil {
+		callback("revision " + strconv.FormatInt(current.Revision, 10))
+	}
+	return nil
+}
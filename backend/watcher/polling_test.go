@@ -0,0 +1,66 @@
+package watcher
+
+import (
+	"go-web/config"
+	"go-web/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestPollingWatcher_NotifiesOtherInstances(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:watcher_polling?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.PolicyRevision{}))
+
+	// 轮询间隔足够长，由测试直接调用 poll
+	first, err := NewPollingWatcher(db, time.Hour)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := NewPollingWatcher(db, time.Hour)
+	require.NoError(t, err)
+	defer second.Close()
+
+	var firstCalls, secondCalls []string
+	require.NoError(t, first.SetUpdateCallback(func(source string) { firstCalls = append(firstCalls, source) }))
+	require.NoError(t, second.SetUpdateCallback(func(source string) { secondCalls = append(secondCalls, source) }))
+
+	// 自己的修改不会触发重新加载，其他实例在下一次轮询时重新加载一次
+	require.NoError(t, first.Update())
+	require.NoError(t, first.poll())
+	require.NoError(t, second.poll())
+	require.NoError(t, second.poll())
+	assert.Empty(t, firstCalls)
+	assert.Equal(t, []string{"revision 1"}, secondCalls)
+
+	// 两个实例先后修改时，双方都需要加载对方的修改
+	require.NoError(t, second.Update())
+	require.NoError(t, first.Update())
+	require.NoError(t, first.poll())
+	require.NoError(t, second.poll())
+	assert.Equal(t, []string{"revision 3"}, firstCalls)
+	assert.Equal(t, []string{"revision 1", "revision 3"}, secondCalls)
+}
+
+func TestNewWatcher_Disabled(t *testing.T) {
+	for _, kind := range []string{"", "none"} {
+		w, err := NewWatcher(&config.Config{Casbin: config.CasbinConfig{Watcher: kind}}, nil)
+		require.NoError(t, err)
+		assert.Nil(t, w)
+	}
+
+	_, err := NewWatcher(&config.Config{Casbin: config.CasbinConfig{Watcher: "redis"}}, nil)
+	assert.Error(t, err)
+}
+
+func TestNewWatcher_PostgresRequiresPostgresDriver(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file:watcher_driver?mode=memory&cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+
+	_, err = NewWatcher(&config.Config{Casbin: config.CasbinConfig{Watcher: "postgres", WatcherChannel: "casbin_policy"}}, db)
+	assert.ErrorContains(t, err, "polling")
+}
@@ -0,0 +1,129 @@
+package watcher
+
+import (
+	"context"
+	"go-web/utils"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// reconnectDelay 是监听连接断开后重新连接之前的等待时间。
+const reconnectDelay = 5 * time.Second
+
+// PostgresWatcher 使用 PostgreSQL 的 LISTEN/NOTIFY 同步策略修改。
+// 它持有一个专用连接监听通知频道；Update 通过 pg_notify 发送带有本实例ID的通知，收到自己发出的通知时忽略。
+type PostgresWatcher struct {
+	db      *gorm.DB // 用于发送通知
+	dsn     string
+	channel string
+	id      string // 本实例的ID，作为通知内容
+
+	mu       sync.Mutex
+	callback func(string)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewPostgresWatcher 连接数据库并开始监听 channel。连接失败时直接返回错误，以便在启动时发现配置问题。
+func NewPostgresWatcher(dsn, channel string, db *gorm.DB) (*PostgresWatcher, error) {
+	id, err := utils.GenerateRandomToken(8)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &PostgresWatcher{db: db, dsn: dsn, channel: channel, id: id, done: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := w.listen(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	w.cancel = cancel
+
+	go w.run(ctx, conn)
+	return w, nil
+}
+
+// SetUpdateCallback 实现了 persist.Watcher 接口。
+func (w *PostgresWatcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callback = callback
+	return nil
+}
+
+// Update 实现了 persist.Watcher 接口，通知其他实例策略已经修改。
+func (w *PostgresWatcher) Update() error {
+	return w.db.Exec("SELECT pg_notify(?, ?)", w.channel, w.id).Error
+}
+
+// Close 实现了 persist.Watcher 接口，停止监听并关闭连接。
+func (w *PostgresWatcher) Close() {
+	w.cancel()
+	<-w.done
+}
+
+// listen 建立一个新的连接并监听通知频道。
+func (w *PostgresWatcher) listen(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, w.dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{w.channel}.Sanitize()); err != nil {
+		_ = conn.Close(context.Background())
+		return nil, err
+	}
+	return conn, nil
+}
+
+// run 等待通知并调用回调，直到 Close 被调用。
+// 连接断开期间可能错过通知，因此重新连接后总是调用一次回调。
+func (w *PostgresWatcher) run(ctx context.Context, conn *pgx.Conn) {
+	defer close(w.done)
+
+	for {
+		if conn == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(reconnectDelay):
+			}
+			var err error
+			if conn, err = w.listen(ctx); err != nil {
+				utils.Logger.Warn("failed to reconnect casbin policy listener", zap.String("channel", w.channel), zap.Error(err))
+				continue
+			}
+			w.notify("reconnected")
+		}
+
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			_ = conn.Close(context.Background())
+			if ctx.Err() != nil {
+				return
+			}
+			utils.Logger.Warn("casbin policy listener disconnected", zap.String("channel", w.channel), zap.Error(err))
+			conn = nil
+			continue
+		}
+		if notification.Payload == w.id {
+			continue
+		}
+		w.notify(notification.Payload)
+	}
+}
+
+// notify 调用更新回调。
+func (w *PostgresWatcher) notify(source string) {
+	w.mu.Lock()
+	callback := w.callback
+	w.mu.Unlock()
+	if callback != nil {
+		callback(source)
+	}
+}
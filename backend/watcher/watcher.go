@@ -0,0 +1,37 @@
+package watcher
+
+// package watcher 提供了在多个实例之间同步Casbin策略的观察者（persist.Watcher）。
+// 一个实例修改策略后，执行器调用观察者的 Update 通知其他实例，其他实例在回调中重新加载策略。
+// 具体使用 PostgreSQL 的 LISTEN/NOTIFY 还是轮询数据库由配置决定。
+
+import (
+	"fmt"
+	"go-web/config"
+	"time"
+
+	"github.com/casbin/casbin/v2/persist"
+	"gorm.io/gorm"
+)
+
+// NewWatcher 根据配置创建对应的观察者。casbin.watcher 为 "none" 或空时返回 nil，表示单实例部署不需要同步。
+func NewWatcher(cfg *config.Config, db *gorm.DB) (persist.Watcher, error) {
+	switch cfg.Casbin.Watcher {
+	case "", "none":
+		return nil, nil
+	case "postgres":
+		if db.Dialector.Name() != "postgres" {
+			return nil, fmt.Errorf("the postgres watcher requires the postgres database driver, use the polling watcher instead")
+		}
+		if cfg.Casbin.WatcherChannel == "" {
+			return nil, fmt.Errorf("casbin.watcher_channel is required for the postgres watcher")
+		}
+		return NewPostgresWatcher(cfg.GetDSN(), cfg.Casbin.WatcherChannel, db)
+	case "polling":
+		if cfg.Casbin.PollInterval <= 0 {
+			return nil, fmt.Errorf("casbin.poll_interval must be positive for the polling watcher")
+		}
+		return NewPollingWatcher(db, time.Duration(cfg.Casbin.PollInterval)*time.Second)
+	default:
+		return nil, fmt.Errorf("unsupported casbin watcher %q", cfg.Casbin.Watcher)
+	}
+}
@@ -0,0 +1,101 @@
+package watchertest
+
+// package watchertest 提供了一个进程内的观察者，用于在测试中代替 PostgreSQL 或轮询观察者，
+// 把同一进程中的多个执行器当作多个实例来验证策略同步。
+
+import (
+	"strconv"
+	"sync"
+)
+
+// Bus 连接同一进程中的多个 Watcher，相当于多个实例共享的通知频道。
+type Bus struct {
+	mu       sync.Mutex
+	watchers []*Watcher
+}
+
+// NewBus 创建一个新的通知频道。
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Watcher 创建一个连接到频道的观察者，每个执行器（实例）使用一个。
+func (b *Bus) Watcher() *Watcher {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	w := &Watcher{bus: b, id: strconv.Itoa(len(b.watchers) + 1)}
+	b.watchers = append(b.watchers, w)
+	return w
+}
+
+// publish 通知除 from 之外所有未关闭的观察者。
+func (b *Bus) publish(from *Watcher) {
+	b.mu.Lock()
+	targets := make([]*Watcher, 0, len(b.watchers))
+	for _, w := range b.watchers {
+		if w != from {
+			targets = append(targets, w)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, w := range targets {
+		w.deliver(from.id)
+	}
+}
+
+// Watcher 实现了 persist.Watcher 接口。
+// Update 会在返回之前同步调用其他观察者的回调，因此测试可以在修改策略后立即断言其他执行器的结果。
+type Watcher struct {
+	bus *Bus
+	id  string
+
+	mu       sync.Mutex
+	callback func(string)
+	updates  int
+	closed   bool
+}
+
+// SetUpdateCallback 实现了 persist.Watcher 接口。
+func (w *Watcher) SetUpdateCallback(callback func(string)) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.callback = callback
+	return nil
+}
+
+// Update 实现了 persist.Watcher 接口，通知频道中的其他观察者。
+func (w *Watcher) Update() error {
+	w.mu.Lock()
+	w.updates++
+	w.mu.Unlock()
+	w.bus.publish(w)
+	return nil
+}
+
+// Close 实现了 persist.Watcher 接口，关闭后不再调用回调。
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+}
+
+// Updates 返回 Update 被调用的次数，即本实例发出的通知数量。
+func (w *Watcher) Updates() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.updates
+}
+
+// deliver 调用回调，source 是发出通知的观察者ID。
+func (w *Watcher) deliver(source string) {
+	w.mu.Lock()
+	callback := w.callback
+	if w.closed {
+		callback = nil
+	}
+	w.mu.Unlock()
+	if callback != nil {
+		callback(source)
+	}
+}